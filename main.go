@@ -26,60 +26,197 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
 	api "smidgen-backend/src/api"
 	models "smidgen-backend/src/models"
 	service "smidgen-backend/src/services"
 	utils "smidgen-backend/src/utils"
+	"sort"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/urfave/cli/v2"
 	"gopkg.in/yaml.v2"
 )
 
 var log = utils.Log()
 
 func main() {
-	_, err := os.Stat("configs")
-	dirExists := !os.IsNotExist(err)
-	argsLengthMoreThanTwo := (len(os.Args) > 2)
-
-	switch {
-	case !dirExists || argsLengthMoreThanTwo:
-		log.Error("Path to configuration files not found. Ensure you have a \"configs\" directory, or run the server with the appropriate arguments.")
-		log.Error("Usage: go run main.go <path_to_server_configurations> <path_to_database_configurations>")
-		return
-	case argsLengthMoreThanTwo:
-		utils.ServerConfigPath = os.Args[1]
-		utils.DatabaseConfigPath = os.Args[2]
-	default:
-		utils.ServerConfigPath = "configs/server.yaml"
-		utils.DatabaseConfigPath = "configs/db_conn.yaml"
+	app := &cli.App{
+		Name:  "smidgen",
+		Usage: "API for interacting with Smidgen",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "server-config",
+				Usage:   "path to the server configurations",
+				Value:   "configs/server.yaml",
+				EnvVars: []string{utils.EnvironmentPrefix + "_SERVER_CONFIG"},
+			},
+			&cli.StringFlag{
+				Name:    "database-config",
+				Usage:   "path to the database configurations",
+				Value:   "configs/db_conn.yaml",
+				EnvVars: []string{utils.EnvironmentPrefix + "_DATABASE_CONFIG"},
+			},
+			&cli.StringFlag{
+				Name:    "environment",
+				Aliases: []string{"e"},
+				Usage:   "environment of the server configurations to run with",
+				Value:   "Development",
+				EnvVars: []string{utils.EnvironmentPrefix + "_ENVIRONMENT"},
+			},
+		},
+		ArgsUsage: "[<path_to_server_configurations> <path_to_database_configurations>]",
+		Action:    serve,
+		Commands: []*cli.Command{
+			{
+				Name:   "serve",
+				Usage:  "Start the server (default)",
+				Action: serve,
+			},
+			{
+				Name:  "config",
+				Usage: "Inspect the configurations",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "print",
+						Usage:  "Print the effective configurations, with secrets redacted",
+						Action: printConfig,
+					},
+				},
+			},
+			{
+				Name:  "secrets",
+				Usage: "Manage the encrypted secrets file, unlocked by the master key in $" + utils.MasterKeyVariable,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "file",
+						Usage:   "path to the encrypted secrets file",
+						Value:   "configs/secrets.enc",
+						EnvVars: []string{utils.EnvironmentPrefix + "_SECRETS_FILE"},
+					},
+				},
+				Subcommands: []*cli.Command{
+					{
+						Name:   "keygen",
+						Usage:  "Print a new master key",
+						Action: generateMasterKey,
+					},
+					{
+						Name:      "set",
+						Usage:     "Store the value read from standard input as secret <name>",
+						ArgsUsage: "<name>",
+						Action:    setSecret,
+					},
+					{
+						Name:      "remove",
+						Usage:     "Remove secret <name>",
+						ArgsUsage: "<name>",
+						Action:    removeSecret,
+					},
+					{
+						Name:   "list",
+						Usage:  "List the names of the stored secrets",
+						Action: listSecrets,
+					},
+				},
+			},
+			{
+				Name:  "api-keys",
+				Usage: "Manage API keys without going through the API, e.g. to create the first admin key",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "create",
+						Usage: "Create an API key and print it",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "name", Usage: "description of the key", Required: true},
+							&cli.StringFlag{Name: "service-account", Usage: "service account the key authenticates", Required: true},
+							&cli.StringSliceFlag{Name: "scope", Usage: "scope granted to the key: " + strings.Join(utils.Scopes, ", "), Required: true},
+							&cli.DurationFlag{Name: "expires-in", Usage: "lifetime of the key, e.g. 2160h (default: never expires)"},
+							&cli.StringFlag{Name: "tenant", Usage: "slug of the tenant the key belongs to (default: the default tenant)"},
+						},
+						Action: createAPIKey,
+					},
+				},
+			},
+		},
 	}
 
-	serverConfig, err := LoadServerConfig(utils.ServerConfigPath)
-	if err != nil {
-		log.Errorf("Failed to load server config: %v", err)
-	} else {
-		log.Info("Loaded server configurations.")
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
 	}
+}
 
-	envConfig, ok := serverConfig.Environments["Development"]
-	if !ok {
-		log.Fatal("Environment configuration not found")
-	} else {
-		log.Info("Loaded server environment configurations.")
+// configPaths sets the configuration paths from the flags. The paths may also be given as the two
+// positional arguments that older releases required.
+func configPaths(c *cli.Context) error {
+	switch c.NArg() {
+	case 0:
+		utils.ServerConfigPath = c.String("server-config")
+		utils.DatabaseConfigPath = c.String("database-config")
+	case 2:
+		utils.ServerConfigPath = c.Args().Get(0)
+		utils.DatabaseConfigPath = c.Args().Get(1)
+	default:
+		return cli.Exit("expected either no arguments or <path_to_server_configurations> <path_to_database_configurations>", 2)
+	}
+	return nil
+}
+
+func serve(c *cli.Context) error {
+	if err := configPaths(c); err != nil {
+		return err
 	}
 
+	envConfig, err := LoadEnvironmentConfig(utils.ServerConfigPath, c.String("environment"))
+	if err != nil {
+		return fmt.Errorf("failed to load server configurations: %v", err)
+	}
+	if err := utils.SetLogFormat(envConfig.LogFormat); err != nil {
+		return err
+	}
 	log = utils.Log(envConfig.Debug)
+	log.Info("Loaded server environment configurations.")
+
+	utils.SoftDeleteRetention, _ = time.ParseDuration(envConfig.SoftDeleteRetention)
+	utils.IdempotencyWindow, _ = time.ParseDuration(envConfig.IdempotencyWindow)
+
+	shutdownTracing, err := utils.InitTracing(envConfig.Tracing)
+	if err != nil {
+		return fmt.Errorf("failed to start tracing: %v", err)
+	}
+	if envConfig.Tracing.Enabled() {
+		log.Infof("Exporting traces to %s.", envConfig.Tracing.Exporter)
+	}
 
 	hostname := envConfig.Host + ":" + envConfig.Port
-	router := loadRoutes(envConfig)
+	router, err := loadRoutes(envConfig)
+	if err != nil {
+		return fmt.Errorf("failed to set up routes: %v", err)
+	}
+	log.Infof("Sending mail with the %s sender.", envConfig.Mail.Sender)
 
 	checkDatabaseConnection(utils.DatabaseConfigPath)
+	if err := utils.MigrateDatabase(utils.DatabaseConfigPath); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
+	utils.RunInBackground("idempotency key expiry", func(ctx context.Context) {
+		expireIdempotencyKeys(ctx, utils.DatabaseConfigPath)
+	})
+	utils.RunInBackground("session expiry", func(ctx context.Context) {
+		expireSessions(ctx, utils.DatabaseConfigPath)
+	})
+	utils.RunInBackground("work order generation", func(ctx context.Context) {
+		generateWorkOrders(ctx, envConfig.Maintenance)
+	})
 
 	log.Debug("Routes loaded.")
 	log.Infof("Server starting on %s", hostname)
@@ -89,9 +226,168 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 		Handler:      router,
 	}
-	err = server.ListenAndServe()
+	if envConfig.TLS.Enabled() {
+		server.TLSConfig, err = utils.NewServerTLSConfig(envConfig.TLS)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS configurations: %v", err)
+		}
+	}
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+
+	serverErr := make(chan error, 2)
+	go func() {
+		if envConfig.TLS.Enabled() {
+			serverErr <- server.ListenAndServeTLS("", "")
+		} else {
+			serverErr <- server.ListenAndServe()
+		}
+	}()
+	var metricsServer *http.Server
+	if envConfig.Metrics.Enabled() {
+		log.Infof("Serving metrics on %s", envConfig.Metrics.Address)
+		metricsServer = utils.NewMetricsServer(envConfig.Metrics)
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != http.ErrServerClosed {
+				serverErr <- fmt.Errorf("metrics: %v", err)
+			}
+		}()
+	}
+	utils.SetReady(true)
+
+	select {
+	case err := <-serverErr:
+		utils.SetReady(false)
+		return fmt.Errorf("failed to start server: %v", err)
+	case <-signals.Done():
+	}
+	// A second signal stops the server immediately
+	stopSignals()
+	return shutdown(server, metricsServer, envConfig, shutdownTracing)
+}
+
+// shutdown stops accepting connections, drains in-flight requests, stops the background workers and
+// closes the database pools and flushes the remaining spans, giving up on whatever is left once the
+// shutdown timeout has passed.
+func shutdown(server *http.Server, metricsServer *http.Server, envConfig models.EnvironmentConfig, shutdownTracing func(context.Context) error) error {
+	utils.SetReady(false)
+	timeout, _ := time.ParseDuration(envConfig.ShutdownTimeout)
+	log.Infof("Shutting down, waiting up to %s for in-flight requests.", timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	if err := server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to drain requests: %v", err))
+		server.Close()
+	}
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			metricsServer.Close()
+		}
+	}
+	if err := utils.StopBackgroundWorkers(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to stop background workers: %v", err))
+	}
+	if err := utils.ClosePools(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close database pools: %v", err))
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to flush traces: %v", err))
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	log.Info("Server stopped.")
+	return nil
+}
+
+func printConfig(c *cli.Context) error {
+	if err := configPaths(c); err != nil {
+		return err
+	}
+
+	envConfig, err := LoadEnvironmentConfig(utils.ServerConfigPath, c.String("environment"))
 	if err != nil {
-		log.Errorf("Failed to start server: %v", err)
+		return fmt.Errorf("failed to load server configurations: %v", err)
+	}
+	utils.Redact(&envConfig)
+	databaseConfig, err := utils.LoadRedactedDatabaseConfig(utils.DatabaseConfigPath)
+	if err != nil {
+		return fmt.Errorf("failed to load database configurations: %v", err)
+	}
+
+	effective := struct {
+		Environment string                   `yaml:"environment"`
+		Server      models.EnvironmentConfig `yaml:"server"`
+		Database    interface{}              `yaml:"database"`
+	}{c.String("environment"), envConfig, databaseConfig}
+
+	out, err := yaml.Marshal(effective)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(out)
+	return err
+}
+
+// expireIdempotencyKeys periodically removes stored idempotent responses that are past their window, for every tenant.
+func expireIdempotencyKeys(ctx context.Context, configPath string) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := utils.ForEachTenant(ctx, configPath, func(ctx context.Context) error {
+				return utils.ExpireIdempotencyKeys(ctx, configPath)
+			}); err != nil {
+				log.Errorf("Failed to expire idempotency keys: %v", err)
+			}
+		}
+	}
+}
+
+// expireSessions periodically removes expired sessions and abandoned logins, for every tenant.
+func expireSessions(ctx context.Context, configPath string) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := utils.ForEachTenant(ctx, configPath, func(ctx context.Context) error {
+				return utils.ExpireSessions(ctx, configPath)
+			}); err != nil {
+				log.Errorf("Failed to expire sessions: %v", err)
+			}
+		}
+	}
+}
+
+// generateWorkOrders periodically opens the work orders of maintenance coming due, for every tenant.
+func generateWorkOrders(ctx context.Context, config utils.MaintenanceConfig) {
+	interval, _ := time.ParseDuration(config.GenerateInterval)
+	maintenance := service.NewMaintenanceAPIService(config)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := utils.ForEachTenant(ctx, utils.DatabaseConfigPath, func(ctx context.Context) error {
+				ctx = utils.WithPrincipal(ctx, utils.Principal{Subject: "maintenance", Role: utils.RoleService})
+				_, err := maintenance.GenerateWorkOrders(ctx)
+				return err
+			}); err != nil {
+				log.Errorf("Failed to generate work orders: %v", err)
+			}
+		}
 	}
 }
 
@@ -150,20 +446,159 @@ func LoadServerConfig(yamlFilePath string) (models.ServerConfig, error) {
 	return config, nil
 }
 
-func loadRoutes(environmentConfig struct {
-	Host     string "yaml:\"host\""
-	Port     string "yaml:\"port\""
-	Debug    bool   "yaml:\"debug\""
-	RootPath string "yaml:\"root_path\""
-}) *mux.Router {
+func generateMasterKey(c *cli.Context) error {
+	key, err := utils.GenerateMasterKey()
+	if err != nil {
+		return err
+	}
+	fmt.Println(key)
+	return nil
+}
+
+func setSecret(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return cli.Exit("expected the name of the secret", 2)
+	}
+	value, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	return updateSecrets(c, func(secrets map[string]string) {
+		secrets[c.Args().First()] = strings.TrimRight(string(value), "\r\n")
+	})
+}
+
+func removeSecret(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return cli.Exit("expected the name of the secret", 2)
+	}
+	return updateSecrets(c, func(secrets map[string]string) {
+		delete(secrets, c.Args().First())
+	})
+}
+
+func listSecrets(c *cli.Context) error {
+	secrets, err := utils.ReadEncryptedSecrets(c.String("file"), os.Getenv(utils.MasterKeyVariable))
+	if err != nil {
+		return err
+	}
+	for _, name := range utils.SecretNames(secrets) {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+func updateSecrets(c *cli.Context, update func(map[string]string)) error {
+	masterKey := os.Getenv(utils.MasterKeyVariable)
+	secrets, err := utils.ReadEncryptedSecrets(c.String("file"), masterKey)
+	if err != nil {
+		return err
+	}
+	update(secrets)
+	return utils.WriteEncryptedSecrets(c.String("file"), masterKey, secrets)
+}
+
+// createAPIKey creates a key as an administrator named "cli", so it is recorded in the audit log like keys
+// created through the API.
+func createAPIKey(c *cli.Context) error {
+	if err := configPaths(c); err != nil {
+		return err
+	}
+	if err := utils.MigrateDatabase(utils.DatabaseConfigPath); err != nil {
+		return fmt.Errorf("failed to migrate database: %v", err)
+	}
+	defer utils.ClosePools()
+
+	request := models.ApiKeyRequest{
+		Name:           c.String("name"),
+		ServiceAccount: c.String("service-account"),
+		Scopes:         c.StringSlice("scope"),
+	}
+	if expiresIn := c.Duration("expires-in"); expiresIn > 0 {
+		expiresAt := time.Now().Add(expiresIn).UTC()
+		request.ExpiresAt = &expiresAt
+	}
+	if err := models.AssertApiKeyRequestConstraints(request); err != nil {
+		return err
+	}
+
+	ctx := utils.WithPrincipal(context.Background(), utils.Principal{Subject: "cli", Role: utils.RoleAdmin})
+	if slug := c.String("tenant"); slug != "" {
+		tenants, err := utils.ListTenants(ctx, false)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, tenant := range tenants {
+			if tenant.Slug == slug {
+				ctx, found = utils.WithTenant(ctx, tenant), true
+			}
+		}
+		if !found {
+			return fmt.Errorf("tenant %q does not exist", slug)
+		}
+	}
+	result, err := service.NewApiKeyAPIService().AddApiKey(ctx, request)
+	if err != nil {
+		return err
+	}
+	created := result.Body.(models.ApiKeyCreated)
+	fmt.Fprintf(os.Stderr, "Created API key %d for %s. Store it now, it cannot be shown again.\n", created.ApiKey.KeyId, created.ApiKey.ServiceAccount)
+	fmt.Println(created.Key)
+	return nil
+}
+
+// LoadEnvironmentConfig returns the settings of environment from the server configurations at yamlFilePath,
+// overridden by the SMIDGEN_* environment variables and completed with defaults.
+func LoadEnvironmentConfig(yamlFilePath string, environment string) (models.EnvironmentConfig, error) {
+	serverConfig, err := LoadServerConfig(yamlFilePath)
+	if err != nil {
+		return models.EnvironmentConfig{}, err
+	}
+
+	envConfig, ok := serverConfig.Environments[environment]
+	if !ok {
+		var available []string
+		for name := range serverConfig.Environments {
+			available = append(available, name)
+		}
+		sort.Strings(available)
+		return models.EnvironmentConfig{}, fmt.Errorf("environment %q not found, available environments: %s", environment, strings.Join(available, ", "))
+	}
+
+	if err := utils.ApplyEnvironmentOverrides(utils.EnvironmentPrefix, &envConfig); err != nil {
+		return models.EnvironmentConfig{}, err
+	}
+	models.ApplyEnvironmentConfigDefaults(&envConfig)
+	if err := models.AssertEnvironmentConfigConstraints(envConfig); err != nil {
+		return models.EnvironmentConfig{}, err
+	}
+	return envConfig, nil
+}
+
+func loadRoutes(environmentConfig models.EnvironmentConfig) (*mux.Router, error) {
+	mailer, err := utils.NewMailSender(environmentConfig.Mail)
+	if err != nil {
+		return nil, err
+	}
 
 	DefaultAPIService := service.NewDefaultAPIService()
 	BusinessUnitAPIService := service.NewBusinessUnitAPIService()
 	EquipmentAPIService := service.NewEquipmentAPIService()
 	ManufacturerAPIService := service.NewManufacturerAPIService()
 	EquipmentAssignmentAPIService := service.NewEquipmentAssignmentAPIService()
-	UserAPIService := service.NewUserAPIService()
+	UserAPIService := service.NewUserAPIService(environmentConfig.Accounts, mailer)
+	ApiKeyAPIService := service.NewApiKeyAPIService()
 	AuditLogService := service.NewAuditLogAPIService()
+	AuthAPIService := service.NewAuthAPIService(environmentConfig, mailer)
+	TenantAPIService := service.NewTenantAPIService()
+	MaintenanceAPIService := service.NewMaintenanceAPIService(environmentConfig.Maintenance)
+	WorkOrderAPIService := service.NewWorkOrderAPIService(environmentConfig.Maintenance)
+	// Batch operations are dispatched back through the router, which is only created below
+	var router *mux.Router
+	BatchAPIService := service.NewBatchAPIService(environmentConfig.RootPath, environmentConfig.Batch, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		router.ServeHTTP(w, r)
+	}))
 	log.Debug("loaded API services")
 
 	DefaultAPIController := api.NewDefaultAPIController(DefaultAPIService)
@@ -172,10 +607,26 @@ func loadRoutes(environmentConfig struct {
 	ManufacturerAPIController := api.NewManufacturerAPIController(ManufacturerAPIService)
 	EquipmentAssignmentAPIController := api.NewEquipmentAssignmentAPIController(EquipmentAssignmentAPIService)
 	UserAPIController := api.NewUserAPIController(UserAPIService)
+	ApiKeyAPIController := api.NewApiKeyAPIController(ApiKeyAPIService)
 	AuditLogAPIController := api.NewAuditLogAPIController(AuditLogService)
+	AuthAPIController := api.NewAuthAPIController(AuthAPIService)
+	BatchAPIController := api.NewBatchAPIController(BatchAPIService)
+	TenantAPIController := api.NewTenantAPIController(TenantAPIService)
+	MaintenanceAPIController := api.NewMaintenanceAPIController(MaintenanceAPIService)
+	WorkOrderAPIController := api.NewWorkOrderAPIController(WorkOrderAPIService)
 	log.Debug("loaded API controllers")
 
-	router := utils.NewRouter(environmentConfig.RootPath, BusinessUnitAPIController, DefaultAPIController, EquipmentAPIController, EquipmentAssignmentAPIController, UserAPIController, AuditLogAPIController, ManufacturerAPIController)
+	router = utils.NewRouter(environmentConfig.RootPath, BusinessUnitAPIController, DefaultAPIController, EquipmentAPIController, EquipmentAssignmentAPIController, UserAPIController, AuditLogAPIController, ManufacturerAPIController, BatchAPIController, ApiKeyAPIController, AuthAPIController, TenantAPIController, MaintenanceAPIController, WorkOrderAPIController)
+	router.Use(utils.Tenancy(environmentConfig.Tenancy))
+	router.Use(utils.CORS(environmentConfig.CORS))
+	if environmentConfig.TLS.ClientCAFile != "" {
+		router.Use(utils.ClientCertificateAuthentication(environmentConfig.TLS.ServiceIdentities))
+	}
+	limits := utils.NewRequestLimits(environmentConfig.Limits)
+	router.Use(limits.FailedAuthentications)
+	router.Use(utils.APIKeyAuthentication())
+	router.Use(utils.SessionAuthentication())
+	router.Use(limits.Limits)
 	log.Debug("successfully created routers")
-	return router
+	return router, nil
 }
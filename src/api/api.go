@@ -29,55 +29,129 @@ import (
 	"context"
 	models "smidgen-backend/src/models"
 	utils "smidgen-backend/src/utils"
+	"time"
 )
 
 
+type ApiKeyAPIServicer interface {
+	AddApiKey(context.Context, models.ApiKeyRequest) (utils.ImplResponse, error)
+	GetApiKeys(context.Context) (utils.ImplResponse, error)
+	GetApiKeyById(context.Context, int32) (utils.ImplResponse, error)
+	RotateApiKey(context.Context, int32) (utils.ImplResponse, error)
+	RevokeApiKey(context.Context, int32) (utils.ImplResponse, error)
+}
+
+type AuthAPIServicer interface {
+	OidcLogin(context.Context) (utils.ImplResponse, error)
+	OidcCallback(context.Context, string, string, string) (utils.ImplResponse, error)
+	Logout(context.Context, string) (utils.ImplResponse, error)
+	GetCurrentUser(context.Context) (utils.ImplResponse, error)
+	EnrollMfa(context.Context, models.MfaEnrollmentRequest) (utils.ImplResponse, error)
+	ConfirmMfa(context.Context, string, models.MfaCode) (utils.ImplResponse, error)
+	VerifyMfa(context.Context, string, models.MfaCode) (utils.ImplResponse, error)
+	Login(context.Context, models.LoginRequest) (utils.ImplResponse, error)
+	ForgotPassword(context.Context, models.PasswordForgotRequest) (utils.ImplResponse, error)
+	ResetPassword(context.Context, models.PasswordResetRequest) (utils.ImplResponse, error)
+}
+
 type BusinessUnitAPIServicer interface {
 	AddBusinessUnit(context.Context, models.BusinessUnit) (utils.ImplResponse, error)
-	DeleteBusinessUnit(context.Context, int32) (utils.ImplResponse, error)
-	GetBusinessUnits(context.Context) (utils.ImplResponse, error)
+	DeleteBusinessUnit(context.Context, int32, utils.IfMatch) (utils.ImplResponse, error)
+	GetBusinessUnits(context.Context, bool) (utils.ImplResponse, error)
 	GetBusinessUnitById(context.Context, int32) (utils.ImplResponse, error)
-	UpdateBusinessUnit(context.Context, int32, models.BusinessUnit) (utils.ImplResponse, error)
+	UpdateBusinessUnit(context.Context, int32, models.BusinessUnit, utils.IfMatch) (utils.ImplResponse, error)
+	PatchBusinessUnit(context.Context, int32, utils.PatchDocument, utils.IfMatch) (utils.ImplResponse, error)
+	RestoreBusinessUnit(context.Context, int32) (utils.ImplResponse, error)
+	PurgeBusinessUnit(context.Context, int32) (utils.ImplResponse, error)
+	GetBusinessUnitTree(context.Context, int32) (utils.ImplResponse, error)
 }
 
 type DefaultAPIServicer interface {
-	HealthCheck(context.Context) (utils.ImplResponse, error)
+	HealthCheck(context.Context, bool) (utils.ImplResponse, error)
+	Livez(context.Context) (utils.ImplResponse, error)
+	Readyz(context.Context) (utils.ImplResponse, error)
 	RootGet(context.Context) (utils.ImplResponse, error)
 }
 
 type EquipmentAPIServicer interface {
 	AddEquipment(context.Context, models.Equipment) (utils.ImplResponse, error)
-	DeleteEquipment(context.Context, int32) (utils.ImplResponse, error)
-	GetEquipments(context.Context) (utils.ImplResponse, error)
+	DeleteEquipment(context.Context, int32, utils.IfMatch) (utils.ImplResponse, error)
+	GetEquipments(context.Context, bool, int32, bool) (utils.ImplResponse, error)
 	GetEquipmentById(context.Context, int32) (utils.ImplResponse, error)
-	UpdateEquipment(context.Context, int32, models.Equipment) (utils.ImplResponse, error)
+	UpdateEquipment(context.Context, int32, models.Equipment, utils.IfMatch) (utils.ImplResponse, error)
+	PatchEquipment(context.Context, int32, utils.PatchDocument, utils.IfMatch) (utils.ImplResponse, error)
+	RestoreEquipment(context.Context, int32) (utils.ImplResponse, error)
+	PurgeEquipment(context.Context, int32) (utils.ImplResponse, error)
 }
 
 type ManufacturerAPIServicer interface {
 	AddManufacturer(context.Context, models.Manufacturer) (utils.ImplResponse, error)
-	DeleteManufacturer(context.Context, int32) (utils.ImplResponse, error)
-	GetManufacturers(context.Context) (utils.ImplResponse, error)
+	DeleteManufacturer(context.Context, int32, utils.IfMatch) (utils.ImplResponse, error)
+	GetManufacturers(context.Context, bool) (utils.ImplResponse, error)
 	GetManufacturerById(context.Context, int32) (utils.ImplResponse, error)
-	UpdateManufacturer(context.Context, int32, models.Manufacturer) (utils.ImplResponse, error)
+	UpdateManufacturer(context.Context, int32, models.Manufacturer, utils.IfMatch) (utils.ImplResponse, error)
+	PatchManufacturer(context.Context, int32, utils.PatchDocument, utils.IfMatch) (utils.ImplResponse, error)
+	RestoreManufacturer(context.Context, int32) (utils.ImplResponse, error)
+	PurgeManufacturer(context.Context, int32) (utils.ImplResponse, error)
 }
 
 type EquipmentAssignmentAPIServicer interface {
 	AddEquipmentAssignment(context.Context, models.EquipmentAssignment) (utils.ImplResponse, error)
-	DeleteEquipmentAssignment(context.Context, int32) (utils.ImplResponse, error)
-	GetEquipmentAssignments(context.Context) (utils.ImplResponse, error)
+	DeleteEquipmentAssignment(context.Context, int32, utils.IfMatch) (utils.ImplResponse, error)
+	GetEquipmentAssignments(context.Context, bool, int32, bool) (utils.ImplResponse, error)
 	GetEquipmentAssignmentById(context.Context, int32) (utils.ImplResponse, error)
-	UpdateEquipmentAssignment(context.Context, int32, models.EquipmentAssignment) (utils.ImplResponse, error)
+	UpdateEquipmentAssignment(context.Context, int32, models.EquipmentAssignment, utils.IfMatch) (utils.ImplResponse, error)
+	PatchEquipmentAssignment(context.Context, int32, utils.PatchDocument, utils.IfMatch) (utils.ImplResponse, error)
+	RestoreEquipmentAssignment(context.Context, int32) (utils.ImplResponse, error)
+	PurgeEquipmentAssignment(context.Context, int32) (utils.ImplResponse, error)
 }
 
 type UserAPIServicer interface {
 	AddUser(context.Context, models.User) (utils.ImplResponse, error)
-	DeleteUser(context.Context, int32) (utils.ImplResponse, error)
-	GetUsers(context.Context) (utils.ImplResponse, error)
+	DeleteUser(context.Context, int32, utils.IfMatch) (utils.ImplResponse, error)
+	GetUsers(context.Context, bool, int32, bool) (utils.ImplResponse, error)
 	GetUserById(context.Context, int32) (utils.ImplResponse, error)
-	UpdateUser(context.Context, int32, models.User) (utils.ImplResponse, error)
+	UpdateUser(context.Context, int32, models.User, utils.IfMatch) (utils.ImplResponse, error)
+	PatchUser(context.Context, int32, utils.PatchDocument, utils.IfMatch) (utils.ImplResponse, error)
+	RestoreUser(context.Context, int32) (utils.ImplResponse, error)
+	PurgeUser(context.Context, int32) (utils.ImplResponse, error)
+	ResetUserMfa(context.Context, int32) (utils.ImplResponse, error)
+	InviteUser(context.Context, int32) (utils.ImplResponse, error)
+	DeactivateUser(context.Context, int32, bool) (utils.ImplResponse, error)
+	ReactivateUser(context.Context, int32) (utils.ImplResponse, error)
 }
 
 type AuditLogAPIServicer interface {
 	GetAuditLogs(context.Context) (utils.ImplResponse, error)
 	GetAuditLogById(context.Context, int32) (utils.ImplResponse, error)
 }
+
+type BatchAPIServicer interface {
+	ExecuteBatch(context.Context, models.BatchRequest) (utils.ImplResponse, error)
+}
+
+type TenantAPIServicer interface {
+	AddTenant(context.Context, models.TenantRequest) (utils.ImplResponse, error)
+	GetTenants(context.Context, bool) (utils.ImplResponse, error)
+	GetTenantById(context.Context, int32) (utils.ImplResponse, error)
+	DeleteTenant(context.Context, int32) (utils.ImplResponse, error)
+}
+
+type MaintenanceAPIServicer interface {
+	AddMaintenancePlan(context.Context, models.MaintenancePlan) (utils.ImplResponse, error)
+	GetMaintenancePlans(context.Context, bool) (utils.ImplResponse, error)
+	GetMaintenancePlanById(context.Context, int32) (utils.ImplResponse, error)
+	UpdateMaintenancePlan(context.Context, int32, models.MaintenancePlan, utils.IfMatch) (utils.ImplResponse, error)
+	DeleteMaintenancePlan(context.Context, int32, utils.IfMatch) (utils.ImplResponse, error)
+	GetMaintenanceDue(context.Context, time.Duration) (utils.ImplResponse, error)
+	GenerateWorkOrders(context.Context) (utils.ImplResponse, error)
+}
+
+type WorkOrderAPIServicer interface {
+	AddWorkOrder(context.Context, models.WorkOrder) (utils.ImplResponse, error)
+	GetWorkOrders(context.Context, string, int32) (utils.ImplResponse, error)
+	GetWorkOrderById(context.Context, int32) (utils.ImplResponse, error)
+	UpdateWorkOrder(context.Context, int32, models.WorkOrder, utils.IfMatch) (utils.ImplResponse, error)
+	StartWorkOrder(context.Context, int32, utils.IfMatch) (utils.ImplResponse, error)
+	CompleteWorkOrder(context.Context, int32, utils.IfMatch) (utils.ImplResponse, error)
+}
@@ -0,0 +1,186 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"encoding/json"
+	"net/http"
+	models "smidgen-backend/src/models"
+	utils "smidgen-backend/src/utils"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type ApiKeyAPIController struct {
+	service      ApiKeyAPIServicer
+	errorHandler utils.ErrorHandler
+}
+
+type ApiKeyAPIOption func(*ApiKeyAPIController)
+
+func WithApiKeyAPIErrorHandler(h utils.ErrorHandler) ApiKeyAPIOption {
+	return func(c *ApiKeyAPIController) {
+		c.errorHandler = h
+	}
+}
+
+func NewApiKeyAPIController(s ApiKeyAPIServicer, opts ...ApiKeyAPIOption) utils.Router {
+	controller := &ApiKeyAPIController{
+		service:      s,
+		errorHandler: utils.DefaultErrorHandler,
+	}
+
+	for _, opt := range opts {
+		opt(controller)
+	}
+
+	return controller
+}
+
+func (c *ApiKeyAPIController) Routes() utils.Routes {
+	return utils.Routes{
+		"AddApiKey": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "api_key",
+			HandlerFunc: c.AddApiKey,
+			Credentials: true,
+		},
+		"GetApiKeys": utils.Route{
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "api_key/",
+			HandlerFunc: c.GetApiKeys,
+		},
+		"GetApiKeyById": utils.Route{
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "api_key/{key_id}",
+			HandlerFunc: c.GetApiKeyById,
+		},
+		"RotateApiKey": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "api_key/{key_id}/rotate",
+			HandlerFunc: c.RotateApiKey,
+			Credentials: true,
+		},
+		"RevokeApiKey": utils.Route{
+			Method:      strings.ToUpper("Delete"),
+			Pattern:     "api_key/{key_id}",
+			HandlerFunc: c.RevokeApiKey,
+		},
+	}
+}
+
+func (c *ApiKeyAPIController) AddApiKey(w http.ResponseWriter, r *http.Request) {
+	apiKeyRequestParam := models.ApiKeyRequest{}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&apiKeyRequestParam); err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	if err := models.AssertApiKeyRequestRequired(apiKeyRequestParam); err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	if err := models.AssertApiKeyRequestConstraints(apiKeyRequestParam); err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.AddApiKey(r.Context(), apiKeyRequestParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+func (c *ApiKeyAPIController) GetApiKeys(w http.ResponseWriter, r *http.Request) {
+	result, err := c.service.GetApiKeys(r.Context())
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+func (c *ApiKeyAPIController) GetApiKeyById(w http.ResponseWriter, r *http.Request) {
+	keyIdParam, err := parseKeyId(r)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.GetApiKeyById(r.Context(), keyIdParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+func (c *ApiKeyAPIController) RotateApiKey(w http.ResponseWriter, r *http.Request) {
+	keyIdParam, err := parseKeyId(r)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.RotateApiKey(r.Context(), keyIdParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+func (c *ApiKeyAPIController) RevokeApiKey(w http.ResponseWriter, r *http.Request) {
+	keyIdParam, err := parseKeyId(r)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.RevokeApiKey(r.Context(), keyIdParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+func parseKeyId(r *http.Request) (int32, error) {
+	return utils.ParseNumericParameter[int32](
+		mux.Vars(r)["key_id"],
+		utils.WithRequire[int32](utils.ParseInt32),
+	)
+}
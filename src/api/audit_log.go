@@ -88,7 +88,7 @@ func (c *AuditLogAPIController) GetAuditLog(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 // GetAuditLogById - Get Business Unit
@@ -109,5 +109,5 @@ func (c *AuditLogAPIController) GetAuditLogById(w http.ResponseWriter, r *http.R
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
@@ -0,0 +1,315 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	models "smidgen-backend/src/models"
+	utils "smidgen-backend/src/utils"
+	"strings"
+)
+
+type AuthAPIController struct {
+	service      AuthAPIServicer
+	errorHandler utils.ErrorHandler
+}
+
+type AuthAPIOption func(*AuthAPIController)
+
+func WithAuthAPIErrorHandler(h utils.ErrorHandler) AuthAPIOption {
+	return func(c *AuthAPIController) {
+		c.errorHandler = h
+	}
+}
+
+func NewAuthAPIController(s AuthAPIServicer, opts ...AuthAPIOption) utils.Router {
+	controller := &AuthAPIController{
+		service:      s,
+		errorHandler: utils.DefaultErrorHandler,
+	}
+
+	for _, opt := range opts {
+		opt(controller)
+	}
+
+	return controller
+}
+
+func (c *AuthAPIController) Routes() utils.Routes {
+	return utils.Routes{
+		"OidcLogin": utils.Route{
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "auth/oidc/login",
+			HandlerFunc: c.OidcLogin,
+			Public:      true,
+		},
+		"OidcCallback": utils.Route{
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "auth/oidc/callback",
+			HandlerFunc: c.OidcCallback,
+			Public:      true,
+			Credentials: true,
+		},
+		"Logout": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "auth/logout",
+			HandlerFunc: c.Logout,
+		},
+		"GetCurrentUser": utils.Route{
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "auth/me",
+			HandlerFunc: c.GetCurrentUser,
+		},
+		"EnrollMfa": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "auth/mfa/enroll",
+			HandlerFunc: c.EnrollMfa,
+			Credentials: true,
+		},
+		"ConfirmMfa": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "auth/mfa/confirm",
+			HandlerFunc: c.ConfirmMfa,
+			Credentials: true,
+		},
+		"VerifyMfa": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "auth/mfa/verify",
+			HandlerFunc: c.VerifyMfa,
+			Credentials: true,
+		},
+		"Login": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "auth/login",
+			HandlerFunc: c.Login,
+			Public:      true,
+			Credentials: true,
+		},
+		"ForgotPassword": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "auth/password/forgot",
+			HandlerFunc: c.ForgotPassword,
+			Public:      true,
+		},
+		"ResetPassword": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "auth/password/reset",
+			HandlerFunc: c.ResetPassword,
+			Public:      true,
+		},
+	}
+}
+
+// OidcLogin - Redirect to the identity provider to log in
+func (c *AuthAPIController) OidcLogin(w http.ResponseWriter, r *http.Request) {
+	result, err := c.service.OidcLogin(r.Context())
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// OidcCallback - Complete a login once the identity provider redirects back
+func (c *AuthAPIController) OidcCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	result, err := c.service.OidcCallback(r.Context(), query.Get("state"), query.Get("code"), query.Get("error"))
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// Logout - End the session the request was made with
+func (c *AuthAPIController) Logout(w http.ResponseWriter, r *http.Request) {
+	token, _ := utils.SessionTokenFromRequest(r)
+	result, err := c.service.Logout(r.Context(), token)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// GetCurrentUser - Get the user the request was made by
+func (c *AuthAPIController) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	result, err := c.service.GetCurrentUser(r.Context())
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// EnrollMfa - Start enrolling an authenticator app as the second factor of the user
+func (c *AuthAPIController) EnrollMfa(w http.ResponseWriter, r *http.Request) {
+	// The body is optional, since only sessions that have yet to complete their login need a token
+	mfaEnrollmentRequestParam := models.MfaEnrollmentRequest{}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&mfaEnrollmentRequestParam); err != nil && !errors.Is(err, io.EOF) {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.EnrollMfa(r.Context(), mfaEnrollmentRequestParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// ConfirmMfa - Complete the enrollment with a code of the authenticator app
+func (c *AuthAPIController) ConfirmMfa(w http.ResponseWriter, r *http.Request) {
+	mfaCodeParam, ok := c.decodeMfaCode(w, r)
+	if !ok {
+		return
+	}
+	token, _ := utils.SessionTokenFromRequest(r)
+	result, err := c.service.ConfirmMfa(r.Context(), token, mfaCodeParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// VerifyMfa - Present the second factor to complete a login
+func (c *AuthAPIController) VerifyMfa(w http.ResponseWriter, r *http.Request) {
+	mfaCodeParam, ok := c.decodeMfaCode(w, r)
+	if !ok {
+		return
+	}
+	token, _ := utils.SessionTokenFromRequest(r)
+	result, err := c.service.VerifyMfa(r.Context(), token, mfaCodeParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// Login - Log in with a username and password
+func (c *AuthAPIController) Login(w http.ResponseWriter, r *http.Request) {
+	loginRequestParam := models.LoginRequest{}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&loginRequestParam); err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	if err := models.AssertLoginRequestRequired(loginRequestParam); err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.Login(r.Context(), loginRequestParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// ForgotPassword - Email a link to reset the password of a user
+func (c *AuthAPIController) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	passwordForgotRequestParam := models.PasswordForgotRequest{}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&passwordForgotRequestParam); err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	if err := models.AssertPasswordForgotRequestRequired(passwordForgotRequestParam); err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.ForgotPassword(r.Context(), passwordForgotRequestParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// ResetPassword - Choose a password with the token of an invitation or password reset email
+func (c *AuthAPIController) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	passwordResetRequestParam := models.PasswordResetRequest{}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&passwordResetRequestParam); err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	if err := models.AssertPasswordResetRequestRequired(passwordResetRequestParam); err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.ResetPassword(r.Context(), passwordResetRequestParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+func (c *AuthAPIController) decodeMfaCode(w http.ResponseWriter, r *http.Request) (models.MfaCode, bool) {
+	mfaCodeParam := models.MfaCode{}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&mfaCodeParam); err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return models.MfaCode{}, false
+	}
+	if err := models.AssertMfaCodeRequired(mfaCodeParam); err != nil {
+		c.errorHandler(w, r, err, nil)
+		return models.MfaCode{}, false
+	}
+	return mfaCodeParam, true
+}
@@ -0,0 +1,96 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"encoding/json"
+	"net/http"
+	models "smidgen-backend/src/models"
+	utils "smidgen-backend/src/utils"
+	"strings"
+)
+
+type BatchAPIController struct {
+	service      BatchAPIServicer
+	errorHandler utils.ErrorHandler
+}
+
+type BatchAPIOption func(*BatchAPIController)
+
+func WithBatchAPIErrorHandler(h utils.ErrorHandler) BatchAPIOption {
+	return func(c *BatchAPIController) {
+		c.errorHandler = h
+	}
+}
+
+func NewBatchAPIController(s BatchAPIServicer, opts ...BatchAPIOption) utils.Router {
+	controller := &BatchAPIController{
+		service:      s,
+		errorHandler: utils.DefaultErrorHandler,
+	}
+
+	for _, opt := range opts {
+		opt(controller)
+	}
+
+	return controller
+}
+
+func (c *BatchAPIController) Routes() utils.Routes {
+	return utils.Routes{
+		"ExecuteBatch": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "batch",
+			HandlerFunc: c.ExecuteBatch,
+		},
+	}
+}
+
+func (c *BatchAPIController) ExecuteBatch(w http.ResponseWriter, r *http.Request) {
+	batchRequestParam := models.BatchRequest{}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&batchRequestParam); err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	if err := models.AssertBatchRequestRequired(batchRequestParam); err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	if err := models.AssertBatchRequestConstraints(batchRequestParam); err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.ExecuteBatch(r.Context(), batchRequestParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
@@ -93,6 +93,21 @@ func (c *BusinessUnitAPIController) Routes() utils.Routes {
 			Pattern:     "business_unit/{unit_id}",
 			HandlerFunc: c.UpdateBusinessUnit,
 		},
+		"PatchBusinessUnit": utils.Route{
+			Method:      strings.ToUpper("Patch"),
+			Pattern:     "business_unit/{unit_id}",
+			HandlerFunc: c.PatchBusinessUnit,
+		},
+		"RestoreBusinessUnit": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "business_unit/{unit_id}/restore",
+			HandlerFunc: c.RestoreBusinessUnit,
+		},
+		"GetBusinessUnitTree": utils.Route{
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "business_unit/{unit_id}/tree",
+			HandlerFunc: c.GetBusinessUnitTree,
+		},
 	}
 }
 
@@ -121,7 +136,7 @@ func (c *BusinessUnitAPIController) AddBusinessUnit(w http.ResponseWriter, r *ht
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 // DeleteBusinessUnit - Delete Business Unit
@@ -136,27 +151,55 @@ func (c *BusinessUnitAPIController) DeleteBusinessUnit(w http.ResponseWriter, r
 		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
 		return
 	}
-	result, err := c.service.DeleteBusinessUnit(r.Context(), unitIdParam)
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	query := r.URL.Query()
+	purgeParam, err := utils.ParseBoolParameter(
+		query.Get("purge"),
+		utils.WithParse[bool](utils.ParseBool),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	var result utils.ImplResponse
+	if purgeParam {
+		result, err = c.service.PurgeBusinessUnit(r.Context(), unitIdParam)
+	} else {
+		result, err = c.service.DeleteBusinessUnit(r.Context(), unitIdParam, ifMatchParam)
+	}
 	// If an error occurred, encode the error with the status code
 	if err != nil {
 		c.errorHandler(w, r, err, &result)
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 // GetBusinessUnit - Get Business Units
 func (c *BusinessUnitAPIController) GetBusinessUnit(w http.ResponseWriter, r *http.Request) {
 
-	result, err := c.service.GetBusinessUnits(r.Context())
+	query := r.URL.Query()
+	includeDeletedParam, err := utils.ParseBoolParameter(
+		query.Get("include_deleted"),
+		utils.WithParse[bool](utils.ParseBool),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.GetBusinessUnits(r.Context(), includeDeletedParam)
 	// If an error occurred, encode the error with the status code
 	if err != nil {
 		c.errorHandler(w, r, err, &result)
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 // GetBusinessUnitById - Get Business Unit
@@ -177,8 +220,13 @@ func (c *BusinessUnitAPIController) GetBusinessUnitById(w http.ResponseWriter, r
 		c.errorHandler(w, r, err, &result)
 		return
 	}
+	// If the client already holds the current representation, there is nothing to send
+	if utils.IsNotModified(r, result.Headers) {
+		utils.EncodeJSONResponse(nil, func(i int) *int { return &i }(http.StatusNotModified), result.Headers, w)
+		return
+	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 // UpdateBusinessUnit - Update Business Unit
@@ -208,12 +256,93 @@ func (c *BusinessUnitAPIController) UpdateBusinessUnit(w http.ResponseWriter, r
 		c.errorHandler(w, r, err, nil)
 		return
 	}
-	result, err := c.service.UpdateBusinessUnit(r.Context(), unitIdParam, businessUnitParam)
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.UpdateBusinessUnit(r.Context(), unitIdParam, businessUnitParam, ifMatchParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// PatchBusinessUnit - Partially update Business Unit
+func (c *BusinessUnitAPIController) PatchBusinessUnit(w http.ResponseWriter, r *http.Request) {
+
+	params := mux.Vars(r)
+	unitIdParam, err := utils.ParseNumericParameter[int32](
+		params["unit_id"],
+		utils.WithRequire[int32](utils.ParseInt32),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	patchParam, err := utils.ParsePatchDocument(r.Header.Get("Content-Type"), r.Body)
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.PatchBusinessUnit(r.Context(), unitIdParam, patchParam, ifMatchParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// RestoreBusinessUnit - Restore deleted Business Unit
+func (c *BusinessUnitAPIController) RestoreBusinessUnit(w http.ResponseWriter, r *http.Request) {
+
+	params := mux.Vars(r)
+	unitIdParam, err := utils.ParseNumericParameter[int32](
+		params["unit_id"],
+		utils.WithRequire[int32](utils.ParseInt32),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.RestoreBusinessUnit(r.Context(), unitIdParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// GetBusinessUnitTree - Get a Business Unit with the units below it and their counts
+func (c *BusinessUnitAPIController) GetBusinessUnitTree(w http.ResponseWriter, r *http.Request) {
+
+	params := mux.Vars(r)
+	unitIdParam, err := utils.ParseNumericParameter[int32](
+		params["unit_id"],
+		utils.WithRequire[int32](utils.ParseInt32),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.GetBusinessUnitTree(r.Context(), unitIdParam)
 	// If an error occurred, encode the error with the status code
 	if err != nil {
 		c.errorHandler(w, r, err, &result)
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
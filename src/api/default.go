@@ -68,6 +68,19 @@ func (c *DefaultAPIController) Routes() utils.Routes {
 			Method:      strings.ToUpper("Get"),
 			Pattern:     "healthcheck",
 			HandlerFunc: c.HealthCheckGet,
+			Public:      true,
+		},
+		"LivezGet": utils.Route{
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "livez",
+			HandlerFunc: c.LivezGet,
+			Public:      true,
+		},
+		"ReadyzGet": utils.Route{
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "readyz",
+			HandlerFunc: c.ReadyzGet,
+			Public:      true,
 		},
 		"RootGet": utils.Route{
 			Method:      strings.ToUpper("Get"),
@@ -78,15 +91,49 @@ func (c *DefaultAPIController) Routes() utils.Routes {
 }
 
 func (c *DefaultAPIController) HealthCheckGet(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	verboseParam, err := utils.ParseBoolParameter(
+		query.Get("verbose"),
+		utils.WithParse[bool](utils.ParseBool),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.HealthCheck(r.Context(), verboseParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// LivezGet - Report whether the process is up
+func (c *DefaultAPIController) LivezGet(w http.ResponseWriter, r *http.Request) {
+
+	result, err := c.service.Livez(r.Context())
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// ReadyzGet - Report whether the server can take traffic
+func (c *DefaultAPIController) ReadyzGet(w http.ResponseWriter, r *http.Request) {
 
-	result, err := c.service.HealthCheck(r.Context())
+	result, err := c.service.Readyz(r.Context())
 	// If an error occurred, encode the error with the status code
 	if err != nil {
 		c.errorHandler(w, r, err, &result)
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 // RootGet - Root
@@ -99,5 +146,5 @@ func (c *DefaultAPIController) RootGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
@@ -93,6 +93,16 @@ func (c *EquipmentAPIController) Routes() utils.Routes {
 			Pattern:     "equipment/{equipment_id}",
 			HandlerFunc: c.UpdateEquipment,
 		},
+		"PatchEquipment": utils.Route{
+			Method:      strings.ToUpper("Patch"),
+			Pattern:     "equipment/{equipment_id}",
+			HandlerFunc: c.PatchEquipment,
+		},
+		"RestoreEquipment": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "equipment/{equipment_id}/restore",
+			HandlerFunc: c.RestoreEquipment,
+		},
 	}
 }
 
@@ -125,7 +135,7 @@ func (c *EquipmentAPIController) AddEquipment(w http.ResponseWriter, r *http.Req
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 // DeleteEquipment - Delete equipment
@@ -140,14 +150,33 @@ func (c *EquipmentAPIController) DeleteEquipment(w http.ResponseWriter, r *http.
 		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
 		return
 	}
-	result, err := c.service.DeleteEquipment(r.Context(), equipmentIdParam)
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	query := r.URL.Query()
+	purgeParam, err := utils.ParseBoolParameter(
+		query.Get("purge"),
+		utils.WithParse[bool](utils.ParseBool),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	var result utils.ImplResponse
+	if purgeParam {
+		result, err = c.service.PurgeEquipment(r.Context(), equipmentIdParam)
+	} else {
+		result, err = c.service.DeleteEquipment(r.Context(), equipmentIdParam, ifMatchParam)
+	}
 	// If an error occurred, encode the error with the status code
 	if err != nil {
 		c.errorHandler(w, r, err, &result)
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 // GetEquipment - Get equipments
@@ -157,14 +186,39 @@ func (c *EquipmentAPIController) GetEquipment(w http.ResponseWriter, r *http.Req
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
-	result, err := c.service.GetEquipments(r.Context())
+	query := r.URL.Query()
+	includeDeletedParam, err := utils.ParseBoolParameter(
+		query.Get("include_deleted"),
+		utils.WithParse[bool](utils.ParseBool),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	businessUnitIdParam, err := utils.ParseNumericParameter[int32](
+		query.Get("business_unit_id"),
+		utils.WithParse[int32](utils.ParseInt32),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	includeChildrenParam, err := utils.ParseBoolParameter(
+		query.Get("include_children"),
+		utils.WithParse[bool](utils.ParseBool),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.GetEquipments(r.Context(), includeDeletedParam, businessUnitIdParam, includeChildrenParam)
 	// If an error occurred, encode the error with the status code
 	if err != nil {
 		c.errorHandler(w, r, err, &result)
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 // GetEquipmentById - Get equipment
@@ -185,8 +239,13 @@ func (c *EquipmentAPIController) GetEquipmentById(w http.ResponseWriter, r *http
 		c.errorHandler(w, r, err, &result)
 		return
 	}
+	// If the client already holds the current representation, there is nothing to send
+	if utils.IsNotModified(r, result.Headers) {
+		utils.EncodeJSONResponse(nil, func(i int) *int { return &i }(http.StatusNotModified), result.Headers, w)
+		return
+	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 // UpdateEquipment - Update equipment
@@ -218,12 +277,71 @@ func (c *EquipmentAPIController) UpdateEquipment(w http.ResponseWriter, r *http.
 		c.errorHandler(w, r, err, nil)
 		return
 	}
-	result, err := c.service.UpdateEquipment(r.Context(), equipmentIdParam, equipmentParam)
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.UpdateEquipment(r.Context(), equipmentIdParam, equipmentParam, ifMatchParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// PatchEquipment - Partially update equipment
+func (c *EquipmentAPIController) PatchEquipment(w http.ResponseWriter, r *http.Request) {
+
+	params := mux.Vars(r)
+	equipmentIdParam, err := utils.ParseNumericParameter[int32](
+		params["equipment_id"],
+		utils.WithRequire[int32](utils.ParseInt32),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	patchParam, err := utils.ParsePatchDocument(r.Header.Get("Content-Type"), r.Body)
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.PatchEquipment(r.Context(), equipmentIdParam, patchParam, ifMatchParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// RestoreEquipment - Restore deleted equipment
+func (c *EquipmentAPIController) RestoreEquipment(w http.ResponseWriter, r *http.Request) {
+
+	params := mux.Vars(r)
+	equipmentIdParam, err := utils.ParseNumericParameter[int32](
+		params["equipment_id"],
+		utils.WithRequire[int32](utils.ParseInt32),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.RestoreEquipment(r.Context(), equipmentIdParam)
 	// If an error occurred, encode the error with the status code
 	if err != nil {
 		c.errorHandler(w, r, err, &result)
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
@@ -93,6 +93,16 @@ func (c *EquipmentAssignmentAPIController) Routes() utils.Routes {
 			Pattern:     "equipment_assignment/{assignment_id}",
 			HandlerFunc: c.UpdateEquipmentAssignment,
 		},
+		"PatchEquipmentAssignment": utils.Route{
+			Method:      strings.ToUpper("Patch"),
+			Pattern:     "equipment_assignment/{assignment_id}",
+			HandlerFunc: c.PatchEquipmentAssignment,
+		},
+		"RestoreEquipmentAssignment": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "equipment_assignment/{assignment_id}/restore",
+			HandlerFunc: c.RestoreEquipmentAssignment,
+		},
 	}
 }
 
@@ -121,7 +131,7 @@ func (c *EquipmentAssignmentAPIController) AddEquipmentAssignment(w http.Respons
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 // DeleteEquipmentAssignment - Delete assignment
@@ -136,27 +146,71 @@ func (c *EquipmentAssignmentAPIController) DeleteEquipmentAssignment(w http.Resp
 		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
 		return
 	}
-	result, err := c.service.DeleteEquipmentAssignment(r.Context(), assignmentIdParam)
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	query := r.URL.Query()
+	purgeParam, err := utils.ParseBoolParameter(
+		query.Get("purge"),
+		utils.WithParse[bool](utils.ParseBool),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	var result utils.ImplResponse
+	if purgeParam {
+		result, err = c.service.PurgeEquipmentAssignment(r.Context(), assignmentIdParam)
+	} else {
+		result, err = c.service.DeleteEquipmentAssignment(r.Context(), assignmentIdParam, ifMatchParam)
+	}
 	// If an error occurred, encode the error with the status code
 	if err != nil {
 		c.errorHandler(w, r, err, &result)
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 // GetEquipmentAssignment - Get assignments
 func (c *EquipmentAssignmentAPIController) GetEquipmentAssignment(w http.ResponseWriter, r *http.Request) {
 
-	result, err := c.service.GetEquipmentAssignments(r.Context())
+	query := r.URL.Query()
+	includeDeletedParam, err := utils.ParseBoolParameter(
+		query.Get("include_deleted"),
+		utils.WithParse[bool](utils.ParseBool),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	businessUnitIdParam, err := utils.ParseNumericParameter[int32](
+		query.Get("business_unit_id"),
+		utils.WithParse[int32](utils.ParseInt32),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	includeChildrenParam, err := utils.ParseBoolParameter(
+		query.Get("include_children"),
+		utils.WithParse[bool](utils.ParseBool),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.GetEquipmentAssignments(r.Context(), includeDeletedParam, businessUnitIdParam, includeChildrenParam)
 	// If an error occurred, encode the error with the status code
 	if err != nil {
 		c.errorHandler(w, r, err, &result)
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 // GetEquipmentAssignmentById - Get assignment
@@ -177,8 +231,13 @@ func (c *EquipmentAssignmentAPIController) GetEquipmentAssignmentById(w http.Res
 		c.errorHandler(w, r, err, &result)
 		return
 	}
+	// If the client already holds the current representation, there is nothing to send
+	if utils.IsNotModified(r, result.Headers) {
+		utils.EncodeJSONResponse(nil, func(i int) *int { return &i }(http.StatusNotModified), result.Headers, w)
+		return
+	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 // UpdateEquipmentAssignment - Update assignment
@@ -208,12 +267,71 @@ func (c *EquipmentAssignmentAPIController) UpdateEquipmentAssignment(w http.Resp
 		c.errorHandler(w, r, err, nil)
 		return
 	}
-	result, err := c.service.UpdateEquipmentAssignment(r.Context(), assignmentIdParam, equipmentAssignmentParam)
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.UpdateEquipmentAssignment(r.Context(), assignmentIdParam, equipmentAssignmentParam, ifMatchParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// PatchEquipmentAssignment - Partially update assignment
+func (c *EquipmentAssignmentAPIController) PatchEquipmentAssignment(w http.ResponseWriter, r *http.Request) {
+
+	params := mux.Vars(r)
+	assignmentIdParam, err := utils.ParseNumericParameter[int32](
+		params["assignment_id"],
+		utils.WithRequire[int32](utils.ParseInt32),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	patchParam, err := utils.ParsePatchDocument(r.Header.Get("Content-Type"), r.Body)
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.PatchEquipmentAssignment(r.Context(), assignmentIdParam, patchParam, ifMatchParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// RestoreEquipmentAssignment - Restore deleted assignment
+func (c *EquipmentAssignmentAPIController) RestoreEquipmentAssignment(w http.ResponseWriter, r *http.Request) {
+
+	params := mux.Vars(r)
+	assignmentIdParam, err := utils.ParseNumericParameter[int32](
+		params["assignment_id"],
+		utils.WithRequire[int32](utils.ParseInt32),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.RestoreEquipmentAssignment(r.Context(), assignmentIdParam)
 	// If an error occurred, encode the error with the status code
 	if err != nil {
 		c.errorHandler(w, r, err, &result)
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
@@ -0,0 +1,272 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"encoding/json"
+	"net/http"
+	models "smidgen-backend/src/models"
+	utils "smidgen-backend/src/utils"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// MaintenanceAPIController binds http requests to an api service and writes the service results to the http response
+type MaintenanceAPIController struct {
+	service      MaintenanceAPIServicer
+	errorHandler utils.ErrorHandler
+}
+
+// MaintenanceAPIOption for how the controller is set up.
+type MaintenanceAPIOption func(*MaintenanceAPIController)
+
+// WithMaintenanceAPIErrorHandler inject ErrorHandler into controller
+func WithMaintenanceAPIErrorHandler(h utils.ErrorHandler) MaintenanceAPIOption {
+	return func(c *MaintenanceAPIController) {
+		c.errorHandler = h
+	}
+}
+
+// NewMaintenanceAPIController creates a default api controller
+func NewMaintenanceAPIController(s MaintenanceAPIServicer, opts ...MaintenanceAPIOption) utils.Router {
+	controller := &MaintenanceAPIController{
+		service:      s,
+		errorHandler: utils.DefaultErrorHandler,
+	}
+
+	for _, opt := range opts {
+		opt(controller)
+	}
+
+	return controller
+}
+
+// Routes returns all the api routes for the MaintenanceAPIController
+func (c *MaintenanceAPIController) Routes() utils.Routes {
+	return utils.Routes{
+		"AddMaintenancePlan": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "maintenance_plan",
+			HandlerFunc: c.AddMaintenancePlan,
+		},
+		"GetMaintenancePlans": utils.Route{
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "maintenance_plan/",
+			HandlerFunc: c.GetMaintenancePlans,
+		},
+		"GetMaintenancePlanById": utils.Route{
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "maintenance_plan/{plan_id}",
+			HandlerFunc: c.GetMaintenancePlanById,
+		},
+		"UpdateMaintenancePlan": utils.Route{
+			Method:      strings.ToUpper("Put"),
+			Pattern:     "maintenance_plan/{plan_id}",
+			HandlerFunc: c.UpdateMaintenancePlan,
+		},
+		"DeleteMaintenancePlan": utils.Route{
+			Method:      strings.ToUpper("Delete"),
+			Pattern:     "maintenance_plan/{plan_id}",
+			HandlerFunc: c.DeleteMaintenancePlan,
+		},
+		"GetMaintenanceDue": utils.Route{
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "maintenance/due",
+			HandlerFunc: c.GetMaintenanceDue,
+		},
+		"GenerateWorkOrders": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "maintenance/generate",
+			HandlerFunc: c.GenerateWorkOrders,
+		},
+	}
+}
+
+// AddMaintenancePlan - Create maintenance plan
+func (c *MaintenanceAPIController) AddMaintenancePlan(w http.ResponseWriter, r *http.Request) {
+	maintenancePlanParam := models.MaintenancePlan{}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&maintenancePlanParam); err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	if err := models.AssertMaintenancePlanRequired(maintenancePlanParam); err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	if err := models.AssertMaintenancePlanConstraints(maintenancePlanParam); err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.AddMaintenancePlan(r.Context(), maintenancePlanParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// GetMaintenancePlans - Get maintenance plans
+func (c *MaintenanceAPIController) GetMaintenancePlans(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	includeDeletedParam, err := utils.ParseBoolParameter(
+		query.Get("include_deleted"),
+		utils.WithParse[bool](utils.ParseBool),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.GetMaintenancePlans(r.Context(), includeDeletedParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// GetMaintenancePlanById - Get maintenance plan
+func (c *MaintenanceAPIController) GetMaintenancePlanById(w http.ResponseWriter, r *http.Request) {
+	planIdParam, err := parsePlanId(r)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.GetMaintenancePlanById(r.Context(), planIdParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// UpdateMaintenancePlan - Update maintenance plan
+func (c *MaintenanceAPIController) UpdateMaintenancePlan(w http.ResponseWriter, r *http.Request) {
+	planIdParam, err := parsePlanId(r)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	maintenancePlanParam := models.MaintenancePlan{}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&maintenancePlanParam); err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	if err := models.AssertMaintenancePlanRequired(maintenancePlanParam); err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	if err := models.AssertMaintenancePlanConstraints(maintenancePlanParam); err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.UpdateMaintenancePlan(r.Context(), planIdParam, maintenancePlanParam, ifMatchParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// DeleteMaintenancePlan - Delete maintenance plan
+func (c *MaintenanceAPIController) DeleteMaintenancePlan(w http.ResponseWriter, r *http.Request) {
+	planIdParam, err := parsePlanId(r)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.DeleteMaintenancePlan(r.Context(), planIdParam, ifMatchParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// GetMaintenanceDue - Get the maintenance coming due within a period, 30 days by default
+func (c *MaintenanceAPIController) GetMaintenanceDue(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	withinParam, err := utils.ParseNumericParameter[time.Duration](
+		query.Get("within"),
+		utils.WithParse[time.Duration](utils.ParseDuration),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.GetMaintenanceDue(r.Context(), withinParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// GenerateWorkOrders - Open the work orders of the maintenance plans coming due now, instead of waiting for the next run
+func (c *MaintenanceAPIController) GenerateWorkOrders(w http.ResponseWriter, r *http.Request) {
+	result, err := c.service.GenerateWorkOrders(r.Context())
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+func parsePlanId(r *http.Request) (int32, error) {
+	return utils.ParseNumericParameter[int32](
+		mux.Vars(r)["plan_id"],
+		utils.WithRequire[int32](utils.ParseInt32),
+	)
+}
@@ -87,6 +87,16 @@ func (c *ManufacturerAPIController) Routes() utils.Routes {
 			Pattern:     "manufacturer/{manufacturer_id}",
 			HandlerFunc: c.UpdateManufacturer,
 		},
+		"PatchManufacturer": utils.Route{
+			Method:      strings.ToUpper("Patch"),
+			Pattern:     "manufacturer/{manufacturer_id}",
+			HandlerFunc: c.PatchManufacturer,
+		},
+		"RestoreManufacturer": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "manufacturer/{manufacturer_id}/restore",
+			HandlerFunc: c.RestoreManufacturer,
+		},
 	}
 }
 
@@ -115,7 +125,7 @@ func (c *ManufacturerAPIController) AddManufacturer(w http.ResponseWriter, r *ht
 		c.errorHandler(w, r, err, &result)
 		return
 	}
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 func (c *ManufacturerAPIController) DeleteManufacturer(w http.ResponseWriter, r *http.Request) {
@@ -129,12 +139,31 @@ func (c *ManufacturerAPIController) DeleteManufacturer(w http.ResponseWriter, r
 		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
 		return
 	}
-	result, err := c.service.DeleteManufacturer(r.Context(), ManufacturerIdParam)
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	query := r.URL.Query()
+	purgeParam, err := utils.ParseBoolParameter(
+		query.Get("purge"),
+		utils.WithParse[bool](utils.ParseBool),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	var result utils.ImplResponse
+	if purgeParam {
+		result, err = c.service.PurgeManufacturer(r.Context(), ManufacturerIdParam)
+	} else {
+		result, err = c.service.DeleteManufacturer(r.Context(), ManufacturerIdParam, ifMatchParam)
+	}
 	if err != nil {
 		c.errorHandler(w, r, err, &result)
 		return
 	}
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 func (c *ManufacturerAPIController) GetManufacturer(w http.ResponseWriter, r *http.Request) {
@@ -142,12 +171,21 @@ func (c *ManufacturerAPIController) GetManufacturer(w http.ResponseWriter, r *ht
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
-	result, err := c.service.GetManufacturers(r.Context())
+	query := r.URL.Query()
+	includeDeletedParam, err := utils.ParseBoolParameter(
+		query.Get("include_deleted"),
+		utils.WithParse[bool](utils.ParseBool),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.GetManufacturers(r.Context(), includeDeletedParam)
 	if err != nil {
 		c.errorHandler(w, r, err, &result)
 		return
 	}
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 func (c *ManufacturerAPIController) GetManufacturerById(w http.ResponseWriter, r *http.Request) {
@@ -166,7 +204,7 @@ func (c *ManufacturerAPIController) GetManufacturerById(w http.ResponseWriter, r
 		c.errorHandler(w, r, err, &result)
 		return
 	}
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 func (c *ManufacturerAPIController) UpdateManufacturer(w http.ResponseWriter, r *http.Request) {
@@ -195,10 +233,74 @@ func (c *ManufacturerAPIController) UpdateManufacturer(w http.ResponseWriter, r
 		c.errorHandler(w, r, err, nil)
 		return
 	}
-	result, err := c.service.UpdateManufacturer(r.Context(), ManufacturerIdParam, ManufacturerParam)
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.UpdateManufacturer(r.Context(), ManufacturerIdParam, ManufacturerParam, ifMatchParam)
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// PatchManufacturer - Partially update manufacturer
+func (c *ManufacturerAPIController) PatchManufacturer(w http.ResponseWriter, r *http.Request) {
+
+	params := mux.Vars(r)
+	ManufacturerIdParam, err := utils.ParseNumericParameter[int32](
+		params["manufacturer_id"],
+		utils.WithRequire[int32](utils.ParseInt32),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	patchParam, err := utils.ParsePatchDocument(r.Header.Get("Content-Type"), r.Body)
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.PatchManufacturer(r.Context(), ManufacturerIdParam, patchParam, ifMatchParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If the client already holds the current representation, there is nothing to send
+	if utils.IsNotModified(r, result.Headers) {
+		utils.EncodeJSONResponse(nil, func(i int) *int { return &i }(http.StatusNotModified), result.Headers, w)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// RestoreManufacturer - Restore deleted manufacturer
+func (c *ManufacturerAPIController) RestoreManufacturer(w http.ResponseWriter, r *http.Request) {
+
+	params := mux.Vars(r)
+	ManufacturerIdParam, err := utils.ParseNumericParameter[int32](
+		params["manufacturer_id"],
+		utils.WithRequire[int32](utils.ParseInt32),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.RestoreManufacturer(r.Context(), ManufacturerIdParam)
+	// If an error occurred, encode the error with the status code
 	if err != nil {
 		c.errorHandler(w, r, err, &result)
 		return
 	}
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
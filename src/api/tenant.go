@@ -0,0 +1,172 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"encoding/json"
+	"net/http"
+	models "smidgen-backend/src/models"
+	utils "smidgen-backend/src/utils"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type TenantAPIController struct {
+	service      TenantAPIServicer
+	errorHandler utils.ErrorHandler
+}
+
+type TenantAPIOption func(*TenantAPIController)
+
+func WithTenantAPIErrorHandler(h utils.ErrorHandler) TenantAPIOption {
+	return func(c *TenantAPIController) {
+		c.errorHandler = h
+	}
+}
+
+func NewTenantAPIController(s TenantAPIServicer, opts ...TenantAPIOption) utils.Router {
+	controller := &TenantAPIController{
+		service:      s,
+		errorHandler: utils.DefaultErrorHandler,
+	}
+
+	for _, opt := range opts {
+		opt(controller)
+	}
+
+	return controller
+}
+
+func (c *TenantAPIController) Routes() utils.Routes {
+	return utils.Routes{
+		"AddTenant": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "tenant",
+			HandlerFunc: c.AddTenant,
+		},
+		"GetTenants": utils.Route{
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "tenant/",
+			HandlerFunc: c.GetTenants,
+		},
+		"GetTenantById": utils.Route{
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "tenant/{tenant_id}",
+			HandlerFunc: c.GetTenantById,
+		},
+		"DeleteTenant": utils.Route{
+			Method:      strings.ToUpper("Delete"),
+			Pattern:     "tenant/{tenant_id}",
+			HandlerFunc: c.DeleteTenant,
+		},
+	}
+}
+
+func (c *TenantAPIController) AddTenant(w http.ResponseWriter, r *http.Request) {
+	tenantRequestParam := models.TenantRequest{}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&tenantRequestParam); err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	if err := models.AssertTenantRequestRequired(tenantRequestParam); err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	if err := models.AssertTenantRequestConstraints(tenantRequestParam); err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.AddTenant(r.Context(), tenantRequestParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+func (c *TenantAPIController) GetTenants(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	includeDeletedParam, err := utils.ParseBoolParameter(
+		query.Get("include_deleted"),
+		utils.WithParse[bool](utils.ParseBool),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.GetTenants(r.Context(), includeDeletedParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+func (c *TenantAPIController) GetTenantById(w http.ResponseWriter, r *http.Request) {
+	tenantIdParam, err := parseTenantId(r)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.GetTenantById(r.Context(), tenantIdParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+func (c *TenantAPIController) DeleteTenant(w http.ResponseWriter, r *http.Request) {
+	tenantIdParam, err := parseTenantId(r)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.DeleteTenant(r.Context(), tenantIdParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+func parseTenantId(r *http.Request) (int32, error) {
+	return utils.ParseNumericParameter[int32](
+		mux.Vars(r)["tenant_id"],
+		utils.WithRequire[int32](utils.ParseInt32),
+	)
+}
@@ -93,6 +93,36 @@ func (c *UserAPIController) Routes() utils.Routes {
 			Pattern:     "user/{user_id}",
 			HandlerFunc: c.UpdateUser,
 		},
+		"PatchUser": utils.Route{
+			Method:      strings.ToUpper("Patch"),
+			Pattern:     "user/{user_id}",
+			HandlerFunc: c.PatchUser,
+		},
+		"RestoreUser": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "user/{user_id}/restore",
+			HandlerFunc: c.RestoreUser,
+		},
+		"ResetUserMfa": utils.Route{
+			Method:      strings.ToUpper("Delete"),
+			Pattern:     "user/{user_id}/mfa",
+			HandlerFunc: c.ResetUserMfa,
+		},
+		"InviteUser": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "user/{user_id}/invitation",
+			HandlerFunc: c.InviteUser,
+		},
+		"DeactivateUser": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "user/{user_id}/deactivate",
+			HandlerFunc: c.DeactivateUser,
+		},
+		"ReactivateUser": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "user/{user_id}/reactivate",
+			HandlerFunc: c.ReactivateUser,
+		},
 	}
 }
 
@@ -121,7 +151,7 @@ func (c *UserAPIController) AddUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 // DeleteUser - Delete user
@@ -136,27 +166,71 @@ func (c *UserAPIController) DeleteUser(w http.ResponseWriter, r *http.Request) {
 		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
 		return
 	}
-	result, err := c.service.DeleteUser(r.Context(), userIdParam)
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	query := r.URL.Query()
+	purgeParam, err := utils.ParseBoolParameter(
+		query.Get("purge"),
+		utils.WithParse[bool](utils.ParseBool),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	var result utils.ImplResponse
+	if purgeParam {
+		result, err = c.service.PurgeUser(r.Context(), userIdParam)
+	} else {
+		result, err = c.service.DeleteUser(r.Context(), userIdParam, ifMatchParam)
+	}
 	// If an error occurred, encode the error with the status code
 	if err != nil {
 		c.errorHandler(w, r, err, &result)
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 // GetUser - Get Users
 func (c *UserAPIController) GetUser(w http.ResponseWriter, r *http.Request) {
 
-	result, err := c.service.GetUsers(r.Context())
+	query := r.URL.Query()
+	includeDeletedParam, err := utils.ParseBoolParameter(
+		query.Get("include_deleted"),
+		utils.WithParse[bool](utils.ParseBool),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	businessUnitIdParam, err := utils.ParseNumericParameter[int32](
+		query.Get("business_unit_id"),
+		utils.WithParse[int32](utils.ParseInt32),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	includeChildrenParam, err := utils.ParseBoolParameter(
+		query.Get("include_children"),
+		utils.WithParse[bool](utils.ParseBool),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.GetUsers(r.Context(), includeDeletedParam, businessUnitIdParam, includeChildrenParam)
 	// If an error occurred, encode the error with the status code
 	if err != nil {
 		c.errorHandler(w, r, err, &result)
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 // GetUserById - Get user
@@ -177,8 +251,13 @@ func (c *UserAPIController) GetUserById(w http.ResponseWriter, r *http.Request)
 		c.errorHandler(w, r, err, &result)
 		return
 	}
+	// If the client already holds the current representation, there is nothing to send
+	if utils.IsNotModified(r, result.Headers) {
+		utils.EncodeJSONResponse(nil, func(i int) *int { return &i }(http.StatusNotModified), result.Headers, w)
+		return
+	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
 
 // UpdateUser - Update user
@@ -208,12 +287,168 @@ func (c *UserAPIController) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		c.errorHandler(w, r, err, nil)
 		return
 	}
-	result, err := c.service.UpdateUser(r.Context(), userIdParam, userParam)
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.UpdateUser(r.Context(), userIdParam, userParam, ifMatchParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// PatchUser - Partially update user
+func (c *UserAPIController) PatchUser(w http.ResponseWriter, r *http.Request) {
+
+	params := mux.Vars(r)
+	userIdParam, err := utils.ParseNumericParameter[int32](
+		params["user_id"],
+		utils.WithRequire[int32](utils.ParseInt32),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	patchParam, err := utils.ParsePatchDocument(r.Header.Get("Content-Type"), r.Body)
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.PatchUser(r.Context(), userIdParam, patchParam, ifMatchParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// RestoreUser - Restore deleted user
+func (c *UserAPIController) RestoreUser(w http.ResponseWriter, r *http.Request) {
+
+	params := mux.Vars(r)
+	userIdParam, err := utils.ParseNumericParameter[int32](
+		params["user_id"],
+		utils.WithRequire[int32](utils.ParseInt32),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.RestoreUser(r.Context(), userIdParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// ResetUserMfa - Remove the second factor of a user, who must enroll again if required to
+func (c *UserAPIController) ResetUserMfa(w http.ResponseWriter, r *http.Request) {
+
+	params := mux.Vars(r)
+	userIdParam, err := utils.ParseNumericParameter[int32](
+		params["user_id"],
+		utils.WithRequire[int32](utils.ParseInt32),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.ResetUserMfa(r.Context(), userIdParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// InviteUser - Email a user a new link to choose their password
+func (c *UserAPIController) InviteUser(w http.ResponseWriter, r *http.Request) {
+
+	params := mux.Vars(r)
+	userIdParam, err := utils.ParseNumericParameter[int32](
+		params["user_id"],
+		utils.WithRequire[int32](utils.ParseInt32),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.InviteUser(r.Context(), userIdParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// DeactivateUser - Refuse every login of a user, keeping their history
+func (c *UserAPIController) DeactivateUser(w http.ResponseWriter, r *http.Request) {
+
+	params := mux.Vars(r)
+	userIdParam, err := utils.ParseNumericParameter[int32](
+		params["user_id"],
+		utils.WithRequire[int32](utils.ParseInt32),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	query := r.URL.Query()
+	forceParam, err := utils.ParseBoolParameter(
+		query.Get("force"),
+		utils.WithParse[bool](utils.ParseBool),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.DeactivateUser(r.Context(), userIdParam, forceParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// ReactivateUser - Let a deactivated or locked user log in again
+func (c *UserAPIController) ReactivateUser(w http.ResponseWriter, r *http.Request) {
+
+	params := mux.Vars(r)
+	userIdParam, err := utils.ParseNumericParameter[int32](
+		params["user_id"],
+		utils.WithRequire[int32](utils.ParseInt32),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.ReactivateUser(r.Context(), userIdParam)
 	// If an error occurred, encode the error with the status code
 	if err != nil {
 		c.errorHandler(w, r, err, &result)
 		return
 	}
 	// If no error, encode the body and the result code
-	utils.EncodeJSONResponse(result.Body, &result.Code, w)
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
 }
@@ -0,0 +1,255 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"encoding/json"
+	"net/http"
+	models "smidgen-backend/src/models"
+	utils "smidgen-backend/src/utils"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// WorkOrderAPIController binds http requests to an api service and writes the service results to the http response
+type WorkOrderAPIController struct {
+	service      WorkOrderAPIServicer
+	errorHandler utils.ErrorHandler
+}
+
+// WorkOrderAPIOption for how the controller is set up.
+type WorkOrderAPIOption func(*WorkOrderAPIController)
+
+// WithWorkOrderAPIErrorHandler inject ErrorHandler into controller
+func WithWorkOrderAPIErrorHandler(h utils.ErrorHandler) WorkOrderAPIOption {
+	return func(c *WorkOrderAPIController) {
+		c.errorHandler = h
+	}
+}
+
+// NewWorkOrderAPIController creates a default api controller
+func NewWorkOrderAPIController(s WorkOrderAPIServicer, opts ...WorkOrderAPIOption) utils.Router {
+	controller := &WorkOrderAPIController{
+		service:      s,
+		errorHandler: utils.DefaultErrorHandler,
+	}
+
+	for _, opt := range opts {
+		opt(controller)
+	}
+
+	return controller
+}
+
+// Routes returns all the api routes for the WorkOrderAPIController
+func (c *WorkOrderAPIController) Routes() utils.Routes {
+	return utils.Routes{
+		"AddWorkOrder": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "work_order",
+			HandlerFunc: c.AddWorkOrder,
+		},
+		"GetWorkOrders": utils.Route{
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "work_order/",
+			HandlerFunc: c.GetWorkOrders,
+		},
+		"GetWorkOrderById": utils.Route{
+			Method:      strings.ToUpper("Get"),
+			Pattern:     "work_order/{work_order_id}",
+			HandlerFunc: c.GetWorkOrderById,
+		},
+		"UpdateWorkOrder": utils.Route{
+			Method:      strings.ToUpper("Put"),
+			Pattern:     "work_order/{work_order_id}",
+			HandlerFunc: c.UpdateWorkOrder,
+		},
+		"StartWorkOrder": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "work_order/{work_order_id}/start",
+			HandlerFunc: c.StartWorkOrder,
+		},
+		"CompleteWorkOrder": utils.Route{
+			Method:      strings.ToUpper("Post"),
+			Pattern:     "work_order/{work_order_id}/complete",
+			HandlerFunc: c.CompleteWorkOrder,
+		},
+	}
+}
+
+// AddWorkOrder - Open a work order by hand, such as for a repair
+func (c *WorkOrderAPIController) AddWorkOrder(w http.ResponseWriter, r *http.Request) {
+	workOrderParam := models.WorkOrder{}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&workOrderParam); err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	if err := models.AssertWorkOrderRequired(workOrderParam); err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	if err := models.AssertWorkOrderConstraints(workOrderParam); err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.AddWorkOrder(r.Context(), workOrderParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// GetWorkOrders - Get work orders, optionally of one status or piece of equipment
+func (c *WorkOrderAPIController) GetWorkOrders(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	equipmentIdParam, err := utils.ParseNumericParameter[int32](
+		query.Get("equipment_id"),
+		utils.WithParse[int32](utils.ParseInt32),
+	)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.GetWorkOrders(r.Context(), query.Get("status"), equipmentIdParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// GetWorkOrderById - Get work order
+func (c *WorkOrderAPIController) GetWorkOrderById(w http.ResponseWriter, r *http.Request) {
+	workOrderIdParam, err := parseWorkOrderId(r)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	result, err := c.service.GetWorkOrderById(r.Context(), workOrderIdParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// UpdateWorkOrder - Update the technician, parts, labor, notes and due date of a work order
+func (c *WorkOrderAPIController) UpdateWorkOrder(w http.ResponseWriter, r *http.Request) {
+	workOrderIdParam, err := parseWorkOrderId(r)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	workOrderParam := models.WorkOrder{}
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+	if err := d.Decode(&workOrderParam); err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	if err := models.AssertWorkOrderRequired(workOrderParam); err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	if err := models.AssertWorkOrderConstraints(workOrderParam); err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.UpdateWorkOrder(r.Context(), workOrderIdParam, workOrderParam, ifMatchParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// StartWorkOrder - Start work on an open work order, putting its equipment in repair
+func (c *WorkOrderAPIController) StartWorkOrder(w http.ResponseWriter, r *http.Request) {
+	workOrderIdParam, err := parseWorkOrderId(r)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.StartWorkOrder(r.Context(), workOrderIdParam, ifMatchParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+// CompleteWorkOrder - Complete a work order in progress, taking its equipment out of repair
+func (c *WorkOrderAPIController) CompleteWorkOrder(w http.ResponseWriter, r *http.Request) {
+	workOrderIdParam, err := parseWorkOrderId(r)
+	if err != nil {
+		c.errorHandler(w, r, &utils.ParsingError{Err: err}, nil)
+		return
+	}
+	ifMatchParam, err := utils.ParseIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		c.errorHandler(w, r, err, nil)
+		return
+	}
+	result, err := c.service.CompleteWorkOrder(r.Context(), workOrderIdParam, ifMatchParam)
+	// If an error occurred, encode the error with the status code
+	if err != nil {
+		c.errorHandler(w, r, err, &result)
+		return
+	}
+	// If no error, encode the body and the result code
+	utils.EncodeJSONResponse(result.Body, &result.Code, result.Headers, w)
+}
+
+func parseWorkOrderId(r *http.Request) (int32, error) {
+	return utils.ParseNumericParameter[int32](
+		mux.Vars(r)["work_order_id"],
+		utils.WithRequire[int32](utils.ParseInt32),
+	)
+}
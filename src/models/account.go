@@ -0,0 +1,91 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	utils "smidgen-backend/src/utils"
+)
+
+// LoginRequest logs a user in with their password.
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// PasswordForgotRequest asks for a link to reset the password of a user to be emailed to them.
+type PasswordForgotRequest struct {
+	Username string `json:"username"`
+}
+
+// PasswordResetRequest chooses a password with the token of an invitation or password reset email.
+type PasswordResetRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// AssertLoginRequestRequired checks if the required fields are not zero-ed
+func AssertLoginRequestRequired(obj LoginRequest) error {
+	elements := map[string]interface{}{
+		"username": obj.Username,
+		"password": obj.Password,
+	}
+	for name, el := range elements {
+		if isZero := utils.IsZeroValue(el); isZero {
+			return &utils.RequiredError{Field: name}
+		}
+	}
+
+	return nil
+}
+
+// AssertPasswordForgotRequestRequired checks if the required fields are not zero-ed
+func AssertPasswordForgotRequestRequired(obj PasswordForgotRequest) error {
+	elements := map[string]interface{}{
+		"username": obj.Username,
+	}
+	for name, el := range elements {
+		if isZero := utils.IsZeroValue(el); isZero {
+			return &utils.RequiredError{Field: name}
+		}
+	}
+
+	return nil
+}
+
+// AssertPasswordResetRequestRequired checks if the required fields are not zero-ed
+func AssertPasswordResetRequestRequired(obj PasswordResetRequest) error {
+	elements := map[string]interface{}{
+		"token":    obj.Token,
+		"password": obj.Password,
+	}
+	for name, el := range elements {
+		if isZero := utils.IsZeroValue(el); isZero {
+			return &utils.RequiredError{Field: name}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"fmt"
+	utils "smidgen-backend/src/utils"
+	"time"
+)
+
+// ApiKey authenticates a service account. Only the hash of its secret is stored, and it is never returned.
+type ApiKey struct {
+	KeyId          int32  `json:"key_id"`
+	Name           string `json:"name"`
+	ServiceAccount string `json:"service_account"`
+	// Scopes is the space-separated list of scopes granted to the key.
+	Scopes     string     `json:"scopes"`
+	KeyHash    string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	CreatedBy  string     `json:"created_by"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Version    int32      `json:"version"`
+}
+
+type ApiKeyRequest struct {
+	Name           string     `json:"name"`
+	ServiceAccount string     `json:"service_account"`
+	Scopes         []string   `json:"scopes"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+}
+
+// ApiKeyCreated is returned when a key is created or rotated. Key is shown only this once.
+type ApiKeyCreated struct {
+	ApiKey ApiKey `json:"api_key"`
+	Key    string `json:"key"`
+}
+
+// AssertApiKeyRequestRequired checks if the required fields are not zero-ed
+func AssertApiKeyRequestRequired(obj ApiKeyRequest) error {
+	elements := map[string]interface{}{
+		"name":            obj.Name,
+		"service_account": obj.ServiceAccount,
+		"scopes":          obj.Scopes,
+	}
+	for name, el := range elements {
+		if isZero := utils.IsZeroValue(el); isZero {
+			return &utils.RequiredError{Field: name}
+		}
+	}
+
+	return nil
+}
+
+// AssertApiKeyRequestConstraints checks if the values respects the defined constraints
+func AssertApiKeyRequestConstraints(obj ApiKeyRequest) error {
+	if len(obj.Scopes) == 0 {
+		return &utils.RequiredError{Field: "scopes"}
+	}
+	granted := make(map[string]bool)
+	for _, scope := range obj.Scopes {
+		known := false
+		for _, valid := range utils.Scopes {
+			known = known || scope == valid
+		}
+		if !known {
+			return &utils.ParsingError{Err: fmt.Errorf("unknown scope %q, expected one of %v", scope, utils.Scopes)}
+		}
+		if granted[scope] {
+			return &utils.ParsingError{Err: fmt.Errorf("duplicate scope %q", scope)}
+		}
+		granted[scope] = true
+	}
+	if obj.ExpiresAt != nil && !obj.ExpiresAt.After(time.Now()) {
+		return &utils.ParsingError{Err: fmt.Errorf("expires_at must be in the future")}
+	}
+	return nil
+}
@@ -32,4 +32,8 @@ type AuditLog struct {
 	ActionTimestamp time.Time `json:"action_timestamp"`
 	ActionStatus    string    `json:"action_status"`
 	Action          string    `json:"action"`
+	// Actor is the subject of the principal that performed the action, or "" for anonymous requests.
+	Actor string `json:"actor"`
+	// Detail describes the circumstances of the action that its code does not, or is "" when there are none.
+	Detail string `json:"detail"`
 }
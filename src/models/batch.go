@@ -0,0 +1,104 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	utils "smidgen-backend/src/utils"
+	"strings"
+)
+
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchOperation is a request against one of the resource routes, relative to the API root path.
+// Strings of the form ${id.field} in Path or Body are replaced with field of the response body of
+// the earlier operation named id.
+type BatchOperation struct {
+	Id      string            `json:"id,omitempty"`
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+type BatchResult struct {
+	Id     string          `json:"id,omitempty"`
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+type BatchResponse struct {
+	Committed bool          `json:"committed"`
+	Results   []BatchResult `json:"results"`
+}
+
+func AssertBatchRequestRequired(obj BatchRequest) error {
+	if len(obj.Operations) == 0 {
+		return &utils.RequiredError{Field: "operations"}
+	}
+	for _, operation := range obj.Operations {
+		elements := map[string]interface{}{
+			"method": operation.Method,
+			"path":   operation.Path,
+		}
+		for name, el := range elements {
+			if isZero := utils.IsZeroValue(el); isZero {
+				return &utils.RequiredError{Field: name}
+			}
+		}
+	}
+
+	return nil
+}
+
+// AssertBatchRequestConstraints checks if the values respects the defined constraints
+func AssertBatchRequestConstraints(obj BatchRequest) error {
+	ids := make(map[string]bool)
+	for i, operation := range obj.Operations {
+		switch strings.ToUpper(operation.Method) {
+		case "GET", "POST", "PUT", "PATCH", "DELETE":
+		default:
+			return &utils.ParsingError{Err: fmt.Errorf("operation %d: unsupported method %s", i, operation.Method)}
+		}
+		if !strings.HasPrefix(operation.Path, "/") {
+			return &utils.ParsingError{Err: fmt.Errorf("operation %d: path must start with /", i)}
+		}
+		if strings.HasPrefix(strings.TrimSuffix(operation.Path, "/"), "/batch") {
+			return &utils.ParsingError{Err: errors.New("batches cannot be nested")}
+		}
+		if operation.Id != "" {
+			if ids[operation.Id] {
+				return &utils.ParsingError{Err: fmt.Errorf("operation %d: duplicate id %s", i, operation.Id)}
+			}
+			ids[operation.Id] = true
+		}
+	}
+	return nil
+}
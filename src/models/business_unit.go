@@ -28,17 +28,41 @@ package smidgen
 
 import (
 	utils "smidgen-backend/src/utils"
+	"time"
 )
 
 type BusinessUnit struct {
-	BusinessUnitId int32  `json:"business_unit_id"`
-	Name           string `json:"name"`
-	PointOfContact string `json:"point_of_contact"`
-	AddressLineOne string `json:"address_line_one"`
-	AddressLineTwo string `json:"address_line_two"`
-	State          string `json:"state"`
-	City           string `json:"city"`
-	Country        string `json:"country"`
+	BusinessUnitId int32      `json:"business_unit_id"`
+	Name           string     `json:"name"`
+	PointOfContact string     `json:"point_of_contact"`
+	AddressLineOne string     `json:"address_line_one"`
+	AddressLineTwo string     `json:"address_line_two"`
+	State          string     `json:"state"`
+	City           string     `json:"city"`
+	Country        string     `json:"country"`
+	Version        int32      `json:"version"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy      *string    `json:"deleted_by,omitempty"`
+	// RequireMfa makes users of the business unit log in with a second factor. Only admins may change it.
+	RequireMfa bool `json:"require_mfa"`
+	// ParentBusinessUnitId places the business unit under another one, e.g. a company under its battalion.
+	ParentBusinessUnitId *int32 `json:"parent_business_unit_id,omitempty"`
+}
+
+// BusinessUnitCounts counts what business units hold. Assignments belong to the unit of their equipment.
+type BusinessUnitCounts struct {
+	Users       int `json:"users"`
+	Equipment   int `json:"equipment"`
+	Assignments int `json:"assignments"`
+}
+
+// BusinessUnitTree is a business unit with the units below it. Counts are those of the unit itself, and
+// TotalCounts roll up those of every unit below it as well.
+type BusinessUnitTree struct {
+	BusinessUnit
+	Counts      BusinessUnitCounts `json:"counts"`
+	TotalCounts BusinessUnitCounts `json:"total_counts"`
+	Children    []BusinessUnitTree `json:"children"`
 }
 
 func AssertBusinessUnitRequired(obj BusinessUnit) error {
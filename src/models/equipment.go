@@ -31,14 +31,17 @@ import (
 )
 
 type Equipment struct {
-	EquipmentId     int32     `json:"equipment_id"`
-	BusinessUnitId  int32     `json:"business_unit_id"`
-	ManufacturerId  int32     `json:"manufacturer_id"`
-	Model           string    `json:"model"`
-	Description     string    `json:"description"`
-	StatusId        int32     `json:"status_id"`
-	DateReceived    time.Time `json:"date_received"`
-	LastInventoried time.Time `json:"last_inventoried"`
+	EquipmentId     int32      `json:"equipment_id"`
+	BusinessUnitId  int32      `json:"business_unit_id"`
+	ManufacturerId  int32      `json:"manufacturer_id"`
+	Model           string     `json:"model"`
+	Description     string     `json:"description"`
+	StatusId        int32      `json:"status_id"`
+	DateReceived    time.Time  `json:"date_received"`
+	LastInventoried time.Time  `json:"last_inventoried"`
+	Version         int32      `json:"version"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy       *string    `json:"deleted_by,omitempty"`
 }
 
 func AssertEquipmentRequired(obj Equipment) error {
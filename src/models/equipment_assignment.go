@@ -31,10 +31,13 @@ import (
 )
 
 type EquipmentAssignment struct {
-	AssignmentId     int32     `json:"assignment_id"`
-	UserId           int32     `json:"user_id"`
-	EquipmentId      int32     `json:"equipment_id"`
-	DateOfAssignment time.Time `json:"date_of_assignment"`
+	AssignmentId     int32      `json:"assignment_id"`
+	UserId           int32      `json:"user_id"`
+	EquipmentId      int32      `json:"equipment_id"`
+	DateOfAssignment time.Time  `json:"date_of_assignment"`
+	Version          int32      `json:"version"`
+	DeletedAt        *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy        *string    `json:"deleted_by,omitempty"`
 }
 
 func AssertEquipmentAssignmentRequired(obj EquipmentAssignment) error {
@@ -0,0 +1,136 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"fmt"
+	utils "smidgen-backend/src/utils"
+	"time"
+)
+
+// MaintenancePlan repeats maintenance every IntervalDays, either for the equipment EquipmentId or for every piece
+// of equipment of ManufacturerId, narrowed to Model when it is set.
+type MaintenancePlan struct {
+	PlanId         int32  `json:"plan_id"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	EquipmentId    *int32 `json:"equipment_id,omitempty"`
+	ManufacturerId *int32 `json:"manufacturer_id,omitempty"`
+	Model          string `json:"model"`
+	IntervalDays   int32  `json:"interval_days"`
+	// LeadDays is how many days before maintenance comes due its work order is generated.
+	LeadDays  int32      `json:"lead_days"`
+	CreatedAt time.Time  `json:"created_at"`
+	Version   int32      `json:"version"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy *string    `json:"deleted_by,omitempty"`
+}
+
+// WorkOrder is maintenance to be done on a piece of equipment, generated for a plan or opened by hand.
+// Its status and timestamps are managed by the server, see utils.StartWorkOrder and utils.CompleteWorkOrder.
+type WorkOrder struct {
+	WorkOrderId int32      `json:"work_order_id"`
+	EquipmentId int32      `json:"equipment_id"`
+	PlanId      *int32     `json:"plan_id,omitempty"`
+	Status      string     `json:"status"`
+	DueDate     time.Time  `json:"due_date"`
+	Technician  string     `json:"technician"`
+	Parts       string     `json:"parts"`
+	LaborHours  float64    `json:"labor_hours"`
+	Notes       string     `json:"notes"`
+	OpenedAt    time.Time  `json:"opened_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Version     int32      `json:"version"`
+}
+
+// MaintenanceDue is the next maintenance of a plan for a piece of equipment.
+type MaintenanceDue struct {
+	PlanId          int32      `json:"plan_id"`
+	PlanName        string     `json:"plan_name"`
+	EquipmentId     int32      `json:"equipment_id"`
+	BusinessUnitId  int32      `json:"business_unit_id"`
+	DueDate         time.Time  `json:"due_date"`
+	Overdue         bool       `json:"overdue"`
+	LastCompletedAt *time.Time `json:"last_completed_at,omitempty"`
+	// WorkOrderId is the work order already open or in progress for the maintenance, if any.
+	WorkOrderId *int32 `json:"work_order_id,omitempty"`
+}
+
+// AssertMaintenancePlanRequired checks if the required fields are not zero-ed
+func AssertMaintenancePlanRequired(obj MaintenancePlan) error {
+	elements := map[string]interface{}{
+		"name":          obj.Name,
+		"interval_days": obj.IntervalDays,
+	}
+	for name, el := range elements {
+		if isZero := utils.IsZeroValue(el); isZero {
+			return &utils.RequiredError{Field: name}
+		}
+	}
+
+	return nil
+}
+
+// AssertMaintenancePlanConstraints checks if the values respects the defined constraints
+func AssertMaintenancePlanConstraints(obj MaintenancePlan) error {
+	if (obj.EquipmentId == nil) == (obj.ManufacturerId == nil) {
+		return &utils.ParsingError{Err: fmt.Errorf("exactly one of equipment_id and manufacturer_id must be set")}
+	}
+	if obj.EquipmentId != nil && obj.Model != "" {
+		return &utils.ParsingError{Err: fmt.Errorf("model can only be set with manufacturer_id")}
+	}
+	if obj.IntervalDays < 1 {
+		return &utils.ParsingError{Err: fmt.Errorf("interval_days must be positive, got %d", obj.IntervalDays)}
+	}
+	if obj.LeadDays < 0 || obj.LeadDays >= obj.IntervalDays {
+		return &utils.ParsingError{Err: fmt.Errorf("lead_days must be at least 0 and less than interval_days, got %d", obj.LeadDays)}
+	}
+	return nil
+}
+
+// AssertWorkOrderRequired checks if the required fields are not zero-ed
+func AssertWorkOrderRequired(obj WorkOrder) error {
+	elements := map[string]interface{}{
+		"equipment_id": obj.EquipmentId,
+		"due_date":     obj.DueDate,
+	}
+	for name, el := range elements {
+		if isZero := utils.IsZeroValue(el); isZero {
+			return &utils.RequiredError{Field: name}
+		}
+	}
+
+	return nil
+}
+
+// AssertWorkOrderConstraints checks if the values respects the defined constraints
+func AssertWorkOrderConstraints(obj WorkOrder) error {
+	if obj.LaborHours < 0 {
+		return &utils.ParsingError{Err: fmt.Errorf("labor_hours cannot be negative, got %v", obj.LaborHours)}
+	}
+	return nil
+}
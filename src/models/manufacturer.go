@@ -31,12 +31,15 @@ import (
 )
 
 type Manufacturer struct {
-	ManufacturerId int32     `json:"manufacturer_id"`
-	Name           string    `json:"name"`
-	PrimaryService string    `json:"primary_service"`
-	PointOfContact string    `json:"point_of_contact"`
-	Location       string    `json:"location"`
-	DateAdded      time.Time `json:"date_added"`
+	ManufacturerId int32      `json:"manufacturer_id"`
+	Name           string     `json:"name"`
+	PrimaryService string     `json:"primary_service"`
+	PointOfContact string     `json:"point_of_contact"`
+	Location       string     `json:"location"`
+	DateAdded      time.Time  `json:"date_added"`
+	Version        int32      `json:"version"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy      *string    `json:"deleted_by,omitempty"`
 }
 
 func AssertManufacturerRequired(obj Manufacturer) error {
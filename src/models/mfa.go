@@ -0,0 +1,71 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	utils "smidgen-backend/src/utils"
+)
+
+// MfaEnrollment is the secret a user adds to their authenticator app, by typing it in or scanning the QR code
+// of the provisioning URI.
+type MfaEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningUri string `json:"provisioning_uri"`
+	// QrCode is a PNG image of the provisioning URI, as a data URI.
+	QrCode string `json:"qr_code"`
+}
+
+// MfaEnrollmentRequest starts an enrollment. Users who must present a second factor but have none yet are
+// emailed a Token when they log in with their password, which their session needs to enroll; sessions that
+// completed their login enroll without one.
+type MfaEnrollmentRequest struct {
+	Token string `json:"token,omitempty"`
+}
+
+// MfaCode is a code of the authenticator app of a user, or one of their recovery codes.
+type MfaCode struct {
+	Code string `json:"code"`
+}
+
+// MfaRecoveryCodes are returned once a user is enrolled. Each code can be used once instead of the
+// authenticator app, and they are shown only this once.
+type MfaRecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// AssertMfaCodeRequired checks if the required fields are not zero-ed
+func AssertMfaCodeRequired(obj MfaCode) error {
+	elements := map[string]interface{}{
+		"code": obj.Code,
+	}
+	for name, el := range elements {
+		if isZero := utils.IsZeroValue(el); isZero {
+			return &utils.RequiredError{Field: name}
+		}
+	}
+
+	return nil
+}
@@ -25,11 +25,126 @@
 
 package smidgen
 
+import (
+	"fmt"
+	utils "smidgen-backend/src/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type ServerConfig struct {
-	Environments map[string]struct {
-		Host     string `yaml:"host"`
-		Port     string `yaml:"port"`
-		Debug    bool   `yaml:"debug"`
-		RootPath string `yaml:"root_path"`
-	} `yaml:",inline"`
+	Environments map[string]EnvironmentConfig `yaml:",inline"`
+}
+
+type EnvironmentConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	Debug    bool   `yaml:"debug"`
+	RootPath string `yaml:"root_path"`
+	// LogFormat is "console" for colored, human-readable logs or "json" for one JSON object per line.
+	LogFormat string `yaml:"log_format"`
+	// SoftDeleteRetention is how long deleted rows are kept before they may be purged, e.g. "720h".
+	SoftDeleteRetention string `yaml:"soft_delete_retention"`
+	// IdempotencyWindow is how long responses to requests with an Idempotency-Key are replayed, e.g. "24h".
+	IdempotencyWindow string `yaml:"idempotency_window"`
+	// ShutdownTimeout is how long in-flight requests may take to finish once shutdown begins, e.g. "30s".
+	ShutdownTimeout string `yaml:"shutdown_timeout"`
+	// TLS serves the API over HTTPS, and optionally authenticates services by their client certificates.
+	TLS utils.TLSConfig `yaml:"tls"`
+	// CORS is the policy browsers apply to cross-origin calls to the API.
+	CORS utils.CORSConfig `yaml:"cors"`
+	// Limits bound the request rate of each client and the size of request bodies.
+	Limits utils.LimitsConfig `yaml:"limits"`
+	// Batch bounds the batches of POST /batch.
+	Batch utils.BatchConfig `yaml:"batch"`
+	// Metrics serves the Prometheus metrics on an address of their own.
+	Metrics utils.MetricsConfig `yaml:"metrics"`
+	// Tracing exports OpenTelemetry spans of requests, service calls and SQL statements.
+	Tracing utils.TracingConfig `yaml:"tracing"`
+	// OIDC lets users log in with an OpenID Connect identity provider.
+	OIDC utils.OIDCConfig `yaml:"oidc"`
+	// Accounts sets the password policy, lockout and the emails inviting users to choose their password.
+	Accounts utils.AccountsConfig `yaml:"accounts"`
+	// Mail sends emails such as invitations, through SMTP or to the log.
+	Mail utils.MailConfig `yaml:"mail"`
+	// Tenancy isolates the data of several organisations served by the same server, by host or by token.
+	Tenancy utils.TenancyConfig `yaml:"tenancy"`
+	// Maintenance sets the equipment statuses work orders move between and how often they are generated.
+	Maintenance utils.MaintenanceConfig `yaml:"maintenance"`
+}
+
+// ApplyEnvironmentConfigDefaults fills in every setting an environment leaves out.
+func ApplyEnvironmentConfigDefaults(obj *EnvironmentConfig) {
+	defaults := map[*string]string{
+		&obj.Host:                "127.0.0.1",
+		&obj.Port:                "8050",
+		&obj.RootPath:            "/api/v1",
+		&obj.LogFormat:           utils.LogFormatConsole,
+		&obj.SoftDeleteRetention: "720h",
+		&obj.IdempotencyWindow:   "24h",
+		&obj.ShutdownTimeout:     "30s",
+	}
+	for setting, value := range defaults {
+		if *setting == "" {
+			*setting = value
+		}
+	}
+	utils.ApplyCORSDefaults(&obj.CORS)
+	utils.ApplyOIDCDefaults(&obj.OIDC)
+	utils.ApplyAccountsDefaults(&obj.Accounts)
+	utils.ApplyMailDefaults(&obj.Mail)
+	utils.ApplyBatchDefaults(&obj.Batch)
+	utils.ApplyMaintenanceDefaults(&obj.Maintenance)
+}
+
+// AssertEnvironmentConfigConstraints checks if the values respects the defined constraints
+func AssertEnvironmentConfigConstraints(obj EnvironmentConfig) error {
+	if port, err := strconv.Atoi(obj.Port); err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("port must be a number between 1 and 65535, got %q", obj.Port)
+	}
+	if !strings.HasPrefix(obj.RootPath, "/") {
+		return fmt.Errorf("root_path must start with /, got %q", obj.RootPath)
+	}
+	if obj.LogFormat != utils.LogFormatConsole && obj.LogFormat != utils.LogFormatJSON {
+		return fmt.Errorf("log_format must be %q or %q, got %q", utils.LogFormatConsole, utils.LogFormatJSON, obj.LogFormat)
+	}
+	durations := map[string]string{
+		"soft_delete_retention": obj.SoftDeleteRetention,
+		"idempotency_window":    obj.IdempotencyWindow,
+		"shutdown_timeout":      obj.ShutdownTimeout,
+	}
+	for name, value := range durations {
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("%s must be a duration such as \"24h\": %v", name, err)
+		}
+	}
+	if err := obj.TLS.Validate(); err != nil {
+		return err
+	}
+	if err := obj.CORS.Validate(); err != nil {
+		return err
+	}
+	if err := obj.Limits.Validate(); err != nil {
+		return err
+	}
+	if err := obj.Tracing.Validate(); err != nil {
+		return err
+	}
+	if err := obj.OIDC.Validate(); err != nil {
+		return err
+	}
+	if err := obj.Accounts.Validate(); err != nil {
+		return err
+	}
+	if err := obj.Mail.Validate(); err != nil {
+		return err
+	}
+	if err := obj.Batch.Validate(); err != nil {
+		return err
+	}
+	if err := obj.Tenancy.Validate(); err != nil {
+		return err
+	}
+	return obj.Maintenance.Validate()
 }
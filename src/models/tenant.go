@@ -0,0 +1,72 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"fmt"
+	utils "smidgen-backend/src/utils"
+	"strings"
+)
+
+type TenantRequest struct {
+	Slug  string   `json:"slug"`
+	Name  string   `json:"name"`
+	Hosts []string `json:"hosts,omitempty"`
+}
+
+// AssertTenantRequestRequired checks if the required fields are not zero-ed
+func AssertTenantRequestRequired(obj TenantRequest) error {
+	elements := map[string]interface{}{
+		"slug": obj.Slug,
+		"name": obj.Name,
+	}
+	for name, el := range elements {
+		if isZero := utils.IsZeroValue(el); isZero {
+			return &utils.RequiredError{Field: name}
+		}
+	}
+
+	return nil
+}
+
+// AssertTenantRequestConstraints checks if the values respects the defined constraints
+func AssertTenantRequestConstraints(obj TenantRequest) error {
+	if !utils.IsValidTenantSlug(obj.Slug) {
+		return &utils.ParsingError{Err: fmt.Errorf("slug must be 2 to 30 lowercase letters and digits, starting with a letter, got %q", obj.Slug)}
+	}
+	requested := make(map[string]bool)
+	for _, host := range obj.Hosts {
+		host = strings.ToLower(host)
+		if host == "" || strings.ContainsAny(host, "/:* ,") {
+			return &utils.ParsingError{Err: fmt.Errorf("invalid host %q, expected a bare host name such as acme.example.com", host)}
+		}
+		if requested[host] {
+			return &utils.ParsingError{Err: fmt.Errorf("duplicate host %q", host)}
+		}
+		requested[host] = true
+	}
+	return nil
+}
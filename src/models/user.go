@@ -27,17 +27,33 @@ package smidgen
 
 import (
 	utils "smidgen-backend/src/utils"
+	"time"
 )
 
+// User is a person who may log in. PasswordHash and PasswordSalt are only set by the server, when users choose
+// their password, and are never part of requests or responses.
 type User struct {
-	UserId         int32  `json:"user_id"`
-	BusinessUnitId int32  `json:"business_unit_id"`
-	Username       string `json:"username"`
-	PasswordHash   string `json:"password_hash"`
-	PasswordSalt   string `json:"password_salt"`
-	FirstName      string `json:"first_name"`
-	LastName       string `json:"last_name"`
-	PrimaryEmail   string `json:"primary_email"`
+	UserId         int32      `json:"user_id"`
+	BusinessUnitId int32      `json:"business_unit_id"`
+	Username       string     `json:"username"`
+	PasswordHash   string     `json:"-"`
+	PasswordSalt   string     `json:"-"`
+	FirstName      string     `json:"first_name"`
+	LastName       string     `json:"last_name"`
+	PrimaryEmail   string     `json:"primary_email"`
+	Version        int32      `json:"version"`
+	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy      *string    `json:"deleted_by,omitempty"`
+	// Role grants the user permissions beyond those of every user, e.g. "admin". Only admins may grant roles.
+	Role string `json:"role"`
+	// FailedLogins counts consecutive wrong passwords, which lock the account until LockedUntil.
+	FailedLogins int32      `json:"-"`
+	LockedUntil  *time.Time `json:"-"`
+	// Status is "active", "locked" or "deactivated". Like the times of the last login and activity, it is
+	// maintained by the server and ignored in requests.
+	Status         string     `json:"status"`
+	LastLoginAt    *time.Time `json:"last_login_at,omitempty"`
+	LastActivityAt *time.Time `json:"last_activity_at,omitempty"`
 }
 
 // AssertUserRequired checks if the required fields are not zero-ed
@@ -45,8 +61,6 @@ func AssertUserRequired(obj User) error {
 	elements := map[string]interface{}{
 		"business_unit_id": obj.BusinessUnitId,
 		"username":         obj.Username,
-		"password_hash":    obj.PasswordHash,
-		"password_salt":    obj.PasswordSalt,
 		"first_name":       obj.FirstName,
 		"last_name":        obj.LastName,
 		"primary_email":    obj.PrimaryEmail,
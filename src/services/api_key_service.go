@@ -0,0 +1,304 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	api "smidgen-backend/src/api"
+	models "smidgen-backend/src/models"
+	utils "smidgen-backend/src/utils"
+	"strings"
+	"time"
+)
+
+// ApiKeyAPIService is a service that implements the logic for the ApiKeyAPIServicer
+// Every endpoint is restricted to administrators.
+type ApiKeyAPIService struct {
+}
+
+// NewApiKeyAPIService creates a default api service
+func NewApiKeyAPIService() api.ApiKeyAPIServicer {
+	return &ApiKeyAPIService{}
+}
+
+// AddApiKey - Create an API key for a service account
+func (s *ApiKeyAPIService) AddApiKey(ctx context.Context, request models.ApiKeyRequest) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "ApiKeyAPIService.AddApiKey")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "admin"
+	logEntry, err := newApiKeyLogEntry(ctx, "ADD_API_KEY")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+		return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
+	}
+
+	secret, hash, err := utils.NewAPIKeySecret()
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
+	}
+	apiKey := models.ApiKey{
+		Name:           request.Name,
+		ServiceAccount: request.ServiceAccount,
+		Scopes:         strings.Join(request.Scopes, " "),
+		KeyHash:        hash,
+		CreatedAt:      time.Now().UTC(),
+		CreatedBy:      utils.ActorFromContext(ctx),
+		ExpiresAt:      request.ExpiresAt,
+	}
+	id, err := dbConnection.InsertRowReturningID("api_keys", apiKey)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
+	}
+
+	apiKey.KeyId = int32(id)
+	apiKey.Version = 1
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(202, models.ApiKeyCreated{ApiKey: apiKey, Key: utils.TenantToken(ctx, utils.FormatAPIKey(apiKey.KeyId, secret))}), nil
+}
+
+// GetApiKeys - Get every API key, including expired and revoked ones
+func (s *ApiKeyAPIService) GetApiKeys(ctx context.Context) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "ApiKeyAPIService.GetApiKeys")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "read"
+	logEntry, err := newApiKeyLogEntry(ctx, "GET_API_KEYS")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+
+	var dest models.ApiKey
+	rows, err := dbConnection.GetRows("api_keys", &dest)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	apiKeys := []models.ApiKey{}
+	for _, row := range rows {
+		apiKey, ok := row.(models.ApiKey)
+		if !ok {
+			logEntry.ActionStatus = "WARN"
+			logConnection.InsertRow("audit_log", logEntry)
+			log.Warn("Warn: Unexpected type in row")
+			continue
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, apiKeys), nil
+}
+
+// GetApiKeyById - Get API key
+func (s *ApiKeyAPIService) GetApiKeyById(ctx context.Context, keyId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "ApiKeyAPIService.GetApiKeyById")
+	defer span.End()
+
+	logEntry, err := newApiKeyLogEntry(ctx, "GET_API_KEY_BY_ID")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+	apiKey, result, err := getApiKey(ctx, keyId)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.ResponseWithHeaders(200, utils.ETagHeaders(apiKey.Version), apiKey), nil
+}
+
+// RotateApiKey - Replace the secret of an API key. The previous secret stops working immediately.
+func (s *ApiKeyAPIService) RotateApiKey(ctx context.Context, keyId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "ApiKeyAPIService.RotateApiKey")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "admin"
+	logEntry, err := newApiKeyLogEntry(ctx, "ROTATE_API_KEY")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+	apiKey, result, err := getApiKey(ctx, keyId)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
+	}
+	if apiKey.RevokedAt != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(409, nil), fmt.Errorf("API key %d has been revoked", keyId)
+	}
+
+	secret, hash, err := utils.NewAPIKeySecret()
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+	apiKey.KeyHash = hash
+	if result, err := updateApiKey(ctx, privilege, apiKey); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
+	}
+
+	apiKey.Version++
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, models.ApiKeyCreated{ApiKey: apiKey, Key: utils.TenantToken(ctx, utils.FormatAPIKey(apiKey.KeyId, secret))}), nil
+}
+
+// RevokeApiKey - Revoke an API key. Revoked keys are kept so their use remains traceable in the audit log.
+func (s *ApiKeyAPIService) RevokeApiKey(ctx context.Context, keyId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "ApiKeyAPIService.RevokeApiKey")
+	defer span.End()
+
+	privilege := "admin"
+	logEntry, err := newApiKeyLogEntry(ctx, "REVOKE_API_KEY")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+	apiKey, result, err := getApiKey(ctx, keyId)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
+	}
+	if apiKey.RevokedAt == nil {
+		now := time.Now().UTC()
+		apiKey.RevokedAt = &now
+		if result, err := updateApiKey(ctx, privilege, apiKey); err != nil {
+			logConnection.InsertRow("audit_log", logEntry)
+			return result, err
+		}
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, nil), nil
+}
+
+func newApiKeyLogEntry(ctx context.Context, action string) (models.AuditLog, error) {
+	var uuid16 [2]byte
+	if _, err := rand.Read(uuid16[:]); err != nil {
+		return models.AuditLog{}, err
+	}
+	return models.AuditLog{
+		LogId:           int(binary.BigEndian.Uint16(uuid16[:])),
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          action,
+		Actor:           utils.ActorFromContext(ctx),
+	}, nil
+}
+
+// getApiKey reads the key keyId, or returns the response to send when that fails.
+func getApiKey(ctx context.Context, keyId int32) (models.ApiKey, utils.ImplResponse, error) {
+	log := utils.LoggerFromContext(ctx)
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		log.Errorf("Failed to establish database connection as read: %v", err)
+		return models.ApiKey{}, utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	var dest models.ApiKey
+	row, err := dbConnection.GetByID("api_keys", "key_id", keyId, &dest)
+	if err != nil {
+		log.Errorf("Data Not Found: %v", err)
+		return models.ApiKey{}, utils.Response(404, nil), fmt.Errorf("the requested ID was not found")
+	}
+	apiKey, ok := row.(models.ApiKey)
+	if !ok {
+		log.Warn("Warn: Unexpected type in row")
+		return models.ApiKey{}, utils.Response(500, nil), errors.New("unexpected type in row")
+	}
+	return apiKey, utils.ImplResponse{}, nil
+}
+
+func updateApiKey(ctx context.Context, privilege string, apiKey models.ApiKey) (utils.ImplResponse, error) {
+	log := utils.LoggerFromContext(ctx)
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+	if err := dbConnection.UpdateRow("api_keys", "key_id", apiKey.KeyId, apiKey); err != nil {
+		log.Errorf("Error: %v", err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(409, nil), errors.New("the API key was modified concurrently, retry the request")
+		}
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+	return utils.ImplResponse{}, nil
+}
@@ -47,25 +47,25 @@ func NewAuditLogAPIService() api.AuditLogAPIServicer {
 
 // GetAuditLogs - Get Audit Log
 func (s *AuditLogAPIService) GetAuditLogs(ctx context.Context) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "AuditLogAPIService.GetAuditLogs")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	privilege := "read"
 
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
 
 	var dest models.AuditLog
 	rows, err := dbConnection.GetRows("audit_log", &dest)
-
 	if err != nil {
 		log.Errorf("Error: %v", err)
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
 	}
 
-	if len(rows) == 0 {
-		return utils.Response(404, nil), fmt.Errorf("no logs were found in the database")
-	}
-
-	var AuditLogs []models.AuditLog
+	AuditLogs := []models.AuditLog{}
 	for _, row := range rows {
 		AuditLog, ok := row.(models.AuditLog)
 		if !ok {
@@ -80,9 +80,13 @@ func (s *AuditLogAPIService) GetAuditLogs(ctx context.Context) (utils.ImplRespon
 
 // GetAuditLogById - Get Business Unit
 func (s *AuditLogAPIService) GetAuditLogById(ctx context.Context, unitId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "AuditLogAPIService.GetAuditLogById")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	privilege := "read"
 
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
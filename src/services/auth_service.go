@@ -0,0 +1,742 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	api "smidgen-backend/src/api"
+	models "smidgen-backend/src/models"
+	utils "smidgen-backend/src/utils"
+	"strings"
+	"time"
+)
+
+var errSingleSignOnDisabled = errors.New("single sign-on is not configured")
+
+// AuthAPIService is a service that implements the logic for the AuthAPIServicer
+// Users log in with their password, or with the OpenID Connect identity provider of the environment, which
+// creates them on their first login.
+type AuthAPIService struct {
+	provider      *utils.OIDCProvider
+	accounts      utils.AccountsConfig
+	mailer        utils.MailSender
+	secureCookies bool
+}
+
+// NewAuthAPIService creates a default api service
+func NewAuthAPIService(config models.EnvironmentConfig, mailer utils.MailSender) api.AuthAPIServicer {
+	return &AuthAPIService{
+		provider: utils.NewOIDCProvider(config.OIDC),
+		accounts: config.Accounts,
+		mailer:   mailer,
+		// Behind a proxy terminating TLS, the public URL of the callback tells that browsers use HTTPS
+		secureCookies: config.TLS.Enabled() || strings.HasPrefix(config.OIDC.RedirectURL, "https://"),
+	}
+}
+
+// Login - Log in with a username and password. Accounts are locked for a while after too many wrong passwords.
+func (s *AuthAPIService) Login(ctx context.Context, request models.LoginRequest) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "AuthAPIService.Login")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	logEntry, err := newAuthLogEntry(ctx, "LOGIN")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while logging in")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+
+	userId, found, err := utils.FindUserByUsername(ctx, request.Username)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while logging in")
+	}
+	var user models.User
+	if found {
+		var result utils.ImplResponse
+		if user, result, err = getCurrentUser(ctx, userId); err != nil && result.Code != 404 {
+			logConnection.InsertRow("audit_log", logEntry)
+			return result, err
+		}
+	}
+	// Unknown and deleted users get the same answer, after the same work, as wrong passwords
+	if user.UserId == 0 {
+		utils.VerifyPassword(request.Password, unknownUserHash, unknownUserSalt)
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(401, nil), utils.ErrWrongPassword
+	}
+	logEntry.Actor = utils.UserSubject(user.UserId)
+	locked := user.LockedUntil != nil && time.Now().Before(*user.LockedUntil)
+	if !utils.VerifyPassword(request.Password, user.PasswordHash, user.PasswordSalt) {
+		logConnection.InsertRow("audit_log", logEntry)
+		// Wrong passwords during a lockout do not extend it
+		if !locked {
+			lockout, _ := time.ParseDuration(s.accounts.LockoutDuration)
+			if err := utils.RecordFailedLogin(ctx, user.UserId, s.accounts.MaxFailedLogins, lockout); err != nil {
+				log.Error(err)
+			}
+		}
+		return utils.Response(401, nil), utils.ErrWrongPassword
+	}
+	// Locked accounts answer the right password like a wrong one, so guesses made during the lockout learn nothing
+	if locked {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(401, nil), utils.ErrWrongPassword
+	}
+	// Only users who know the password learn that the account is deactivated
+	if user.Status == utils.UserStatusDeactivated {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrUserDeactivated
+	}
+	if user.FailedLogins != 0 || user.LockedUntil != nil {
+		if err := utils.ResetFailedLogins(ctx, user.UserId); err != nil {
+			log.Error(err)
+		}
+	}
+
+	mfaPending, err := mfaRequired(ctx, user)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while logging in")
+	}
+	if mfaPending {
+		// Users who have yet to enroll get the token enrolling them by email, not in the response to their password
+		if enrolled, err := utils.MFAEnrolled(ctx, user.UserId); err != nil {
+			log.Error(err)
+		} else if !enrolled {
+			if err := sendUserTokenMail(ctx, s.accounts, s.mailer, user, utils.TokenPurposeMFAEnrollment); err != nil {
+				log.Errorf("Failed to email the enrollment token of user %d: %v", user.UserId, err)
+			}
+		}
+	}
+	ttl, _ := time.ParseDuration(s.accounts.SessionTTL)
+	token, expiresAt, err := utils.CreateSession(ctx, user.UserId, ttl, mfaPending)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while logging in")
+	}
+	if err := utils.RecordLogin(ctx, user.UserId); err != nil {
+		log.Errorf("Failed to record the login of user %d: %v", user.UserId, err)
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	headers := map[string][]string{"Set-Cookie": {s.sessionCookie(token, expiresAt).String()}}
+	return utils.ResponseWithHeaders(200, headers, models.Session{Token: token, ExpiresAt: expiresAt, User: user, MfaPending: mfaPending}), nil
+}
+
+// ForgotPassword - Email a link to reset the password of a user. The response is the same whether the user
+// exists or not, so it cannot be used to find out usernames.
+func (s *AuthAPIService) ForgotPassword(ctx context.Context, request models.PasswordForgotRequest) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "AuthAPIService.ForgotPassword")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	logEntry, err := newAuthLogEntry(ctx, "FORGOT_PASSWORD")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while resetting the password")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+
+	userId, found, err := utils.FindUserByUsername(ctx, request.Username)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while resetting the password")
+	}
+	if found {
+		user, _, err := getCurrentUser(ctx, userId)
+		if err == nil && user.PrimaryEmail != "" && user.Status != utils.UserStatusDeactivated {
+			logEntry.Actor = utils.UserSubject(user.UserId)
+			err = sendUserTokenMail(ctx, s.accounts, s.mailer, user, utils.TokenPurposePasswordReset)
+		}
+		if err != nil {
+			logConnection.InsertRow("audit_log", logEntry)
+			log.Errorf("Failed to send a password reset email to user %d: %v", userId, err)
+			return utils.Response(202, nil), nil
+		}
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(202, nil), nil
+}
+
+// ResetPassword - Choose a password with the token of an invitation or password reset email. Every session of
+// the user is ended, and a locked account is unlocked.
+func (s *AuthAPIService) ResetPassword(ctx context.Context, request models.PasswordResetRequest) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "AuthAPIService.ResetPassword")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	logEntry, err := newAuthLogEntry(ctx, "RESET_PASSWORD")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while resetting the password")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+
+	userId, purpose, err := utils.LookUpUserToken(ctx, request.Token)
+	if err == nil && purpose != utils.TokenPurposePasswordReset && purpose != utils.TokenPurposeInvitation {
+		err = utils.ErrInvalidUserToken
+	}
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrInvalidUserToken) {
+			return utils.Response(400, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while resetting the password")
+	}
+	logEntry.Actor = utils.UserSubject(userId)
+	if purpose == utils.TokenPurposeInvitation {
+		logEntry.Action = "ACCEPT_INVITATION"
+	}
+	user, result, err := getCurrentUser(ctx, userId)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
+	}
+	if user.Status == utils.UserStatusDeactivated {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrUserDeactivated
+	}
+	// The token is only used up along with the new password, so a password the policy refuses can be fixed
+	if err := s.accounts.PasswordPolicy.Check(request.Password, user.Username); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(422, nil), err
+	}
+
+	passwordHash, passwordSalt, err := utils.HashPassword(request.Password)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while resetting the password")
+	}
+	if err := utils.SetPasswordWithToken(ctx, request.Token, user.UserId, passwordHash, passwordSalt); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrInvalidUserToken) {
+			return utils.Response(400, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while resetting the password")
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, nil), nil
+}
+
+// OidcLogin - Redirect to the identity provider to log in
+func (s *AuthAPIService) OidcLogin(ctx context.Context) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "AuthAPIService.OidcLogin")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	if s.provider == nil {
+		return utils.Response(404, nil), errSingleSignOnDisabled
+	}
+	location, err := s.provider.AuthCodeURL(ctx)
+	if err != nil {
+		log.Errorf("Failed to start login: %v", err)
+		return utils.Response(503, nil), errors.New("the identity provider is unavailable")
+	}
+	return utils.ResponseWithHeaders(302, map[string][]string{"Location": {location}}, nil), nil
+}
+
+// OidcCallback - Complete a login once the identity provider redirects back, creating the user on their first login
+func (s *AuthAPIService) OidcCallback(ctx context.Context, state string, code string, providerError string) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "AuthAPIService.OidcCallback")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	logEntry, err := newAuthLogEntry(ctx, "LOGIN")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while logging in")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if s.provider == nil {
+		return utils.Response(404, nil), errSingleSignOnDisabled
+	}
+	if providerError != "" {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(401, nil), fmt.Errorf("the identity provider refused the login: %s", providerError)
+	}
+	if state == "" || code == "" {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(400, nil), errors.New("the state and code parameters are required")
+	}
+
+	claims, err := s.provider.Exchange(ctx, state, code)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Warnf("Rejected login: %v", err)
+		if errors.Is(err, utils.ErrInvalidLoginState) {
+			return utils.Response(400, nil), err
+		}
+		return utils.Response(401, nil), errors.New("the login could not be verified")
+	}
+
+	user, result, err := s.provisionUser(ctx, claims)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
+	}
+	logEntry.Actor = utils.UserSubject(user.UserId)
+
+	mfaPending, err := mfaRequired(ctx, user)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while logging in")
+	}
+	config := s.provider.Config()
+	ttl, _ := time.ParseDuration(config.SessionTTL)
+	token, expiresAt, err := utils.CreateSession(ctx, user.UserId, ttl, mfaPending)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while logging in")
+	}
+	if err := utils.RecordLogin(ctx, user.UserId); err != nil {
+		log.Errorf("Failed to record the login of user %d: %v", user.UserId, err)
+	}
+
+	headers := map[string][]string{"Set-Cookie": {s.sessionCookie(token, expiresAt).String()}}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	if config.PostLoginRedirect != "" {
+		headers["Location"] = []string{config.PostLoginRedirect}
+		return utils.ResponseWithHeaders(302, headers, nil), nil
+	}
+	return utils.ResponseWithHeaders(200, headers, models.Session{Token: token, ExpiresAt: expiresAt, User: user, MfaPending: mfaPending}), nil
+}
+
+// Logout - End the session the request was made with
+func (s *AuthAPIService) Logout(ctx context.Context, token string) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "AuthAPIService.Logout")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	logEntry, err := newAuthLogEntry(ctx, "LOGOUT")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while logging out")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	principal, ok := utils.PrincipalFromContext(ctx)
+	if !ok || principal.UserId == 0 || token == "" {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(401, nil), utils.ErrInvalidSession
+	}
+	if err := utils.RevokeSession(ctx, token); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while logging out")
+	}
+
+	// Expire the cookie, in case the session was sent in it
+	cookie := s.sessionCookie("", time.Time{})
+	cookie.MaxAge = -1
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.ResponseWithHeaders(200, map[string][]string{"Set-Cookie": {cookie.String()}}, nil), nil
+}
+
+// GetCurrentUser - Get the user the request was made by
+func (s *AuthAPIService) GetCurrentUser(ctx context.Context) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "AuthAPIService.GetCurrentUser")
+	defer span.End()
+
+	principal, ok := utils.PrincipalFromContext(ctx)
+	if !ok || principal.UserId == 0 {
+		return utils.Response(401, nil), errors.New("the request was not made by a logged in user")
+	}
+	user, result, err := getCurrentUser(ctx, principal.UserId)
+	if err != nil {
+		return result, err
+	}
+	return utils.ResponseWithHeaders(200, utils.ETagHeaders(user.Version), user), nil
+}
+
+// EnrollMfa - Start enrolling an authenticator app as the second factor of the user
+func (s *AuthAPIService) EnrollMfa(ctx context.Context, request models.MfaEnrollmentRequest) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "AuthAPIService.EnrollMfa")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	principal, ok := utils.PrincipalFromContext(ctx)
+	if !ok || principal.UserId == 0 {
+		return utils.Response(401, nil), errors.New("the request was not made by a logged in user")
+	}
+	user, result, err := getCurrentUser(ctx, principal.UserId)
+	if err != nil {
+		return result, err
+	}
+	// Sessions that only presented the password also prove they can read the email of the user
+	if principal.MFAPending {
+		if err := utils.UseUserToken(ctx, request.Token, user.UserId, utils.TokenPurposeMFAEnrollment); err != nil {
+			if errors.Is(err, utils.ErrInvalidUserToken) {
+				return utils.Response(403, nil), err
+			}
+			log.Error(err)
+			return utils.Response(500, nil), errors.New("an error has occurred while enrolling")
+		}
+	}
+	secret, err := utils.BeginMFAEnrollment(ctx, user.UserId)
+	if err != nil {
+		if errors.Is(err, utils.ErrMFAAlreadyEnrolled) {
+			return utils.Response(409, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while enrolling")
+	}
+	uri := utils.TOTPProvisioningURI(user.Username, secret)
+	qrCode, err := utils.TOTPQRCode(uri)
+	if err != nil {
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while enrolling")
+	}
+	return utils.Response(200, models.MfaEnrollment{Secret: secret, ProvisioningUri: uri, QrCode: qrCode}), nil
+}
+
+// ConfirmMfa - Complete the enrollment with a code of the authenticator app, completing the login of the session too
+func (s *AuthAPIService) ConfirmMfa(ctx context.Context, token string, mfaCode models.MfaCode) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "AuthAPIService.ConfirmMfa")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	logEntry, err := newAuthLogEntry(ctx, "ENROLL_MFA")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while enrolling")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	principal, ok := utils.PrincipalFromContext(ctx)
+	if !ok || principal.UserId == 0 {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(401, nil), errors.New("the request was not made by a logged in user")
+	}
+	recoveryCodes, err := utils.ConfirmMFAEnrollment(ctx, principal.UserId, mfaCode.Code)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		switch {
+		case errors.Is(err, utils.ErrInvalidMFACode):
+			return utils.Response(422, nil), err
+		case errors.Is(err, utils.ErrMFANotEnrolled), errors.Is(err, utils.ErrMFAAlreadyEnrolled):
+			return utils.Response(409, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while enrolling")
+	}
+	if principal.MFAPending {
+		if err := utils.CompleteSessionMFA(ctx, token); err != nil {
+			logConnection.InsertRow("audit_log", logEntry)
+			log.Error(err)
+			return utils.Response(500, nil), errors.New("an error has occurred while enrolling")
+		}
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, models.MfaRecoveryCodes{RecoveryCodes: recoveryCodes}), nil
+}
+
+// VerifyMfa - Present the second factor to complete a login. Sessions sending too many wrong codes are revoked.
+func (s *AuthAPIService) VerifyMfa(ctx context.Context, token string, mfaCode models.MfaCode) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "AuthAPIService.VerifyMfa")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	logEntry, err := newAuthLogEntry(ctx, "VERIFY_MFA")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while verifying the code")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	principal, ok := utils.PrincipalFromContext(ctx)
+	if !ok || principal.UserId == 0 || token == "" {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(401, nil), errors.New("the request was not made by a logged in user")
+	}
+	err = utils.VerifyMFACode(ctx, principal.UserId, mfaCode.Code)
+	if errors.Is(err, utils.ErrInvalidMFACode) {
+		logConnection.InsertRow("audit_log", logEntry)
+		revoked, recordErr := utils.RecordSessionMFAFailure(ctx, token)
+		if recordErr != nil {
+			log.Error(recordErr)
+		}
+		if revoked {
+			log.Warnf("Revoked the session of user %d after too many wrong codes", principal.UserId)
+			return utils.Response(401, nil), utils.ErrInvalidSession
+		}
+		return utils.Response(422, nil), err
+	}
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrMFANotEnrolled) {
+			return utils.Response(409, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while verifying the code")
+	}
+	if err := utils.CompleteSessionMFA(ctx, token); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while verifying the code")
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, nil), nil
+}
+
+// sessionCookie returns the cookie holding the session token of browsers.
+func (s *AuthAPIService) sessionCookie(token string, expiresAt time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     utils.SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   s.secureCookies,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// unknownUserHash is checked against when the user logging in does not exist, so that the response takes
+// as long as for a wrong password.
+var unknownUserHash, unknownUserSalt, _ = utils.HashPassword("unknown user")
+
+// sendUserTokenMail emails user a new one-time token for purpose, to choose their password or enroll a second
+// factor.
+func sendUserTokenMail(ctx context.Context, accounts utils.AccountsConfig, mailer utils.MailSender, user models.User, purpose string) error {
+	ttlSetting := accounts.PasswordResetTTL
+	subject := "Reset your Smidgen password"
+	intro := "Someone asked to reset the password of your Smidgen account %s. If it was not you, ignore this email."
+	switch purpose {
+	case utils.TokenPurposeInvitation:
+		ttlSetting = accounts.InvitationTTL
+		subject = "Your Smidgen account"
+		intro = "An account named %s was created for you in Smidgen. Choose your password to start using it."
+	case utils.TokenPurposeMFAEnrollment:
+		subject = "Set up the second factor of your Smidgen account"
+		intro = "Your Smidgen account %s logged in and must now set up an authenticator app. If it was not you, change your password."
+	}
+	ttl, _ := time.ParseDuration(ttlSetting)
+	token, expiresAt, err := utils.CreateUserToken(ctx, user.UserId, purpose, ttl)
+	if err != nil {
+		return err
+	}
+
+	link := "Use this token to choose your password: " + token
+	if purpose == utils.TokenPurposeMFAEnrollment {
+		link = "Use this token to set up your authenticator app: " + token
+	} else if accounts.PasswordLinkURL != "" {
+		link = "Choose your password at " + accounts.PasswordLinkURL + "?" + url.Values{"token": {token}}.Encode()
+	}
+	body := fmt.Sprintf(intro, user.Username) + "\n\n" + link + "\n\nThe link can be used once, until " + expiresAt.Format(time.RFC1123) + ".\n"
+	return mailer.Send(ctx, utils.Mail{To: user.PrimaryEmail, Subject: subject, Body: body})
+}
+
+// mfaRequired reports whether user must present a second factor to log in: once enrolled, always, and
+// otherwise when the user is an administrator or their business unit requires it.
+func mfaRequired(ctx context.Context, user models.User) (bool, error) {
+	if user.Role == utils.RoleAdmin {
+		return true, nil
+	}
+	enrolled, err := utils.MFAEnrolled(ctx, user.UserId)
+	if err != nil || enrolled {
+		return enrolled, err
+	}
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		return false, err
+	}
+	var dest models.BusinessUnit
+	row, err := dbConnection.GetByID("business_units", "businessUnitId", user.BusinessUnitId, &dest, utils.IncludeDeleted(true))
+	if err != nil {
+		return false, err
+	}
+	businessUnit, ok := row.(models.BusinessUnit)
+	if !ok {
+		return false, errors.New("unexpected type in row")
+	}
+	return businessUnit.RequireMfa, nil
+}
+
+// getCurrentUser reads the user userId, or returns the response to send when that fails.
+func getCurrentUser(ctx context.Context, userId int32) (models.User, utils.ImplResponse, error) {
+	log := utils.LoggerFromContext(ctx)
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		log.Errorf("Failed to establish database connection as read: %v", err)
+		return models.User{}, utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	var dest models.User
+	row, err := dbConnection.GetByID("users", "user_id", userId, &dest)
+	if err != nil {
+		log.Errorf("Data Not Found: %v", err)
+		return models.User{}, utils.Response(404, nil), fmt.Errorf("the requested ID was not found")
+	}
+	user, ok := row.(models.User)
+	if !ok {
+		log.Warn("Warn: Unexpected type in row")
+		return models.User{}, utils.Response(500, nil), errors.New("unexpected type in row")
+	}
+	return user, utils.ImplResponse{}, nil
+}
+
+// provisionUser returns the user linked to the account of claims, creating the user on their first login.
+// The name, email, business unit and role of existing users are brought up to date with the identity provider.
+func (s *AuthAPIService) provisionUser(ctx context.Context, claims utils.OIDCClaims) (models.User, utils.ImplResponse, error) {
+	log := utils.LoggerFromContext(ctx)
+	config := s.provider.Config()
+	businessUnitId, role := config.MapGroups(claims.Groups)
+
+	userId, linked, err := utils.FindUserIdentity(ctx, claims.Issuer, claims.Subject)
+	if err != nil {
+		log.Error(err)
+		return models.User{}, utils.Response(500, nil), errors.New("an error has occurred while logging in")
+	}
+
+	if !linked {
+		if businessUnitId == 0 {
+			businessUnitId = config.DefaultBusinessUnitId
+		}
+		if businessUnitId == 0 {
+			log.Warnf("Refused to create a user for %s of %s, who is in no mapped group", claims.Subject, claims.Issuer)
+			return models.User{}, utils.Response(403, nil), errors.New("your groups at the identity provider do not grant access to any business unit")
+		}
+		user := models.User{
+			BusinessUnitId: businessUnitId,
+			Username:       oidcUsername(claims),
+			FirstName:      claims.GivenName,
+			LastName:       claims.FamilyName,
+			PrimaryEmail:   claims.Email,
+			Role:           role,
+			Status:         utils.UserStatusActive,
+		}
+		dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+		if err != nil {
+			log.Errorf("Failed to establish database connection as write: %v", err)
+			return models.User{}, utils.Response(500, nil), errors.New("an error has occurred while logging in")
+		}
+		// Users of the identity provider have no password, so they cannot log in any other way
+		id, err := dbConnection.InsertRowReturningID("users", user)
+		if err != nil {
+			log.Errorf("Failed to create a user for %s of %s: %v", claims.Subject, claims.Issuer, err)
+			if errors.Is(err, utils.ErrUniqueViolation) {
+				return models.User{}, utils.Response(409, nil), fmt.Errorf("a user named %q already exists", user.Username)
+			}
+			return models.User{}, utils.Response(500, nil), errors.New("an error has occurred while logging in")
+		}
+		user.UserId = int32(id)
+		user.Version = 1
+		if err := utils.AddUserIdentity(ctx, claims.Issuer, claims.Subject, user.UserId); err != nil {
+			log.Error(err)
+			return models.User{}, utils.Response(500, nil), errors.New("an error has occurred while logging in")
+		}
+		log.Infof("Created user %d for %s of %s", user.UserId, claims.Subject, claims.Issuer)
+		return user, utils.ImplResponse{}, nil
+	}
+
+	readConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		log.Errorf("Failed to establish database connection as read: %v", err)
+		return models.User{}, utils.Response(500, nil), errors.New("an error has occurred while logging in")
+	}
+	var dest models.User
+	row, err := readConnection.GetByID("users", "user_id", userId, &dest, utils.IncludeDeleted(true))
+	if err != nil {
+		log.Errorf("Data Not Found: %v", err)
+		return models.User{}, utils.Response(500, nil), errors.New("an error has occurred while logging in")
+	}
+	user, ok := row.(models.User)
+	if !ok {
+		log.Warn("Warn: Unexpected type in row")
+		return models.User{}, utils.Response(500, nil), errors.New("unexpected type in row")
+	}
+	if user.DeletedAt != nil {
+		return models.User{}, utils.Response(403, nil), fmt.Errorf("user %d has been deleted", user.UserId)
+	}
+	if user.Status == utils.UserStatusDeactivated {
+		return models.User{}, utils.Response(403, nil), utils.ErrUserDeactivated
+	}
+
+	updated := user
+	updated.FirstName = claims.GivenName
+	updated.LastName = claims.FamilyName
+	updated.PrimaryEmail = claims.Email
+	updated.Role = role
+	if businessUnitId != 0 {
+		updated.BusinessUnitId = businessUnitId
+	}
+	if updated == user {
+		return user, utils.ImplResponse{}, nil
+	}
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if err != nil {
+		log.Errorf("Failed to establish database connection as write: %v", err)
+		return models.User{}, utils.Response(500, nil), errors.New("an error has occurred while logging in")
+	}
+	if err := dbConnection.UpdateRow("users", "user_id", user.UserId, updated); err != nil {
+		log.Error(err)
+		return models.User{}, utils.Response(500, nil), errors.New("an error has occurred while logging in")
+	}
+	updated.Version++
+	return updated, utils.ImplResponse{}, nil
+}
+
+// oidcUsername picks the username of a new user from the claims that identity providers commonly set.
+func oidcUsername(claims utils.OIDCClaims) string {
+	for _, name := range []string{claims.PreferredUsername, claims.Email} {
+		if name != "" {
+			return name
+		}
+	}
+	return claims.Subject
+}
+
+func newAuthLogEntry(ctx context.Context, action string) (models.AuditLog, error) {
+	var uuid16 [2]byte
+	if _, err := rand.Read(uuid16[:]); err != nil {
+		return models.AuditLog{}, err
+	}
+	return models.AuditLog{
+		LogId:           int(binary.BigEndian.Uint16(uuid16[:])),
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          action,
+		Actor:           utils.ActorFromContext(ctx),
+	}, nil
+}
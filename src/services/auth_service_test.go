@@ -0,0 +1,475 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	models "smidgen-backend/src/models"
+	utils "smidgen-backend/src/utils"
+)
+
+// oidcTestIssuer is an OpenID Connect identity provider whose logins always succeed, as the account given by
+// the claims set with login. It serves discovery, its JWKS and a token endpoint that enforces PKCE.
+type oidcTestIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu     sync.Mutex
+	codes  map[string]url.Values
+	claims map[string]interface{}
+}
+
+func newOIDCTestIssuer(t *testing.T) *oidcTestIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &oidcTestIssuer{key: key, codes: make(map[string]url.Values)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                issuer.server.URL,
+			"authorization_endpoint":                issuer.server.URL + "/authorize",
+			"token_endpoint":                        issuer.server.URL + "/token",
+			"jwks_uri":                              issuer.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "alg": "RS256", "use": "sig", "kid": "test",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", issuer.token)
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+// login logs in at the issuer as the account of claims, following the authorization URL location, and returns
+// the state and code the issuer redirects back with.
+func (i *oidcTestIssuer) login(t *testing.T, location string, claims map[string]interface{}) (string, string) {
+	t.Helper()
+	parsed, err := url.Parse(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := base64.RawURLEncoding.EncodeToString(big.NewInt(time.Now().UnixNano()).Bytes())
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.codes[code] = parsed.Query()
+	i.claims = claims
+	return parsed.Query().Get("state"), code
+}
+
+func (i *oidcTestIssuer) token(w http.ResponseWriter, r *http.Request) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	authorization, ok := i.codes[r.PostFormValue("code")]
+	delete(i.codes, r.PostFormValue("code"))
+	verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != authorization.Get("code_challenge") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := map[string]interface{}{
+		"iss":   i.server.URL,
+		"aud":   authorization.Get("client_id"),
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": authorization.Get("nonce"),
+	}
+	for name, value := range i.claims {
+		claims[name] = value
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, i.key, crypto.SHA256, digest[:])
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed + "." + base64.RawURLEncoding.EncodeToString(signature),
+	})
+}
+
+// useTestDatabase makes a new SQLite database the database of every connection until t ends.
+func useTestDatabase(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "db_conn.yaml")
+	config := "driver: \"sqlite\"\nsqlite:\n  path: \"" + filepath.ToSlash(filepath.Join(dir, "smidgen.db")) + "\"\n"
+	if err := os.WriteFile(configPath, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	previous := utils.DatabaseConfigPath
+	utils.DatabaseConfigPath = configPath
+	t.Cleanup(func() {
+		if err := utils.ClosePools(); err != nil {
+			t.Errorf("ClosePools() error = %v", err)
+		}
+		utils.DatabaseConfigPath = previous
+	})
+	if err := utils.MigrateDatabase(configPath); err != nil {
+		t.Fatalf("MigrateDatabase() error = %v", err)
+	}
+}
+
+func TestOidcCallbackProvisioning(t *testing.T) {
+	useTestDatabase(t)
+	for _, name := range []string{"Battalion", "Logistics"} {
+		dao, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := dao.InsertRowReturningID("business_units", models.BusinessUnit{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	issuer := newOIDCTestIssuer(t)
+	config := models.EnvironmentConfig{OIDC: utils.OIDCConfig{
+		Issuer:      issuer.server.URL,
+		ClientID:    "smidgen",
+		RedirectURL: "https://smidgen.example.com/api/v1/auth/oidc/callback",
+		GroupMappings: []utils.OIDCGroupMapping{
+			{Group: "admins", Role: utils.RoleAdmin},
+			{Group: "battalion", BusinessUnitId: 1},
+			{Group: "logistics", BusinessUnitId: 2},
+			{Group: "disbanded", BusinessUnitId: 99},
+		},
+	}}
+	withDefault := config
+	withDefault.OIDC.DefaultBusinessUnitId = 1
+
+	account := func(subject string, username string, groups ...string) map[string]interface{} {
+		return map[string]interface{}{"sub": subject, "preferred_username": username, "email": username + "@example.com",
+			"given_name": "Jane", "family_name": "Doe", "groups": groups}
+	}
+	// The logins run in order, as later ones find the users created by earlier ones
+	steps := []struct {
+		name             string
+		config           models.EnvironmentConfig
+		claims           map[string]interface{}
+		deactivate       bool
+		wantCode         int
+		wantUserId       int32
+		wantBusinessUnit int32
+		wantRole         string
+	}{
+		{name: "first login creates the user", config: config, claims: account("subject-1", "jdoe", "logistics"),
+			wantCode: 200, wantUserId: 1, wantBusinessUnit: 2, wantRole: ""},
+		{name: "later logins map the current groups", config: config, claims: account("subject-1", "jdoe", "battalion", "admins"),
+			wantCode: 200, wantUserId: 1, wantBusinessUnit: 1, wantRole: utils.RoleAdmin},
+		{name: "unmapped groups keep the business unit", config: config, claims: account("subject-1", "jdoe", "visitors"),
+			wantCode: 200, wantUserId: 1, wantBusinessUnit: 1, wantRole: ""},
+		{name: "new user in no mapped group", config: config, claims: account("subject-2", "rroe", "visitors"),
+			wantCode: 403},
+		{name: "new user in the default business unit", config: withDefault, claims: account("subject-2", "rroe", "visitors"),
+			wantCode: 200, wantUserId: 2, wantBusinessUnit: 1, wantRole: ""},
+		{name: "username of another user", config: config, claims: account("subject-3", "jdoe", "logistics"),
+			wantCode: 409},
+		{name: "business unit that does not exist", config: config, claims: account("subject-4", "mmoe", "disbanded"),
+			wantCode: 500},
+		{name: "deactivated user", config: config, claims: account("subject-2", "rroe", "battalion"), deactivate: true,
+			wantCode: 403},
+	}
+	ctx := context.Background()
+	for _, step := range steps {
+		if step.deactivate {
+			deactivateTestUser(t, 2)
+		}
+		service := NewAuthAPIService(step.config, nil)
+		result, err := service.OidcLogin(ctx)
+		if err != nil || result.Code != 302 {
+			t.Fatalf("%s: OidcLogin() = %d, %v", step.name, result.Code, err)
+		}
+		state, code := issuer.login(t, result.Headers["Location"][0], step.claims)
+		result, err = service.OidcCallback(ctx, state, code, "")
+		if result.Code != step.wantCode {
+			t.Errorf("%s: OidcCallback() = %d, %v, want %d", step.name, result.Code, err, step.wantCode)
+			continue
+		}
+		if step.wantCode != 200 {
+			continue
+		}
+		session, ok := result.Body.(models.Session)
+		if !ok || session.Token == "" {
+			t.Errorf("%s: OidcCallback() body = %#v, want a session", step.name, result.Body)
+			continue
+		}
+		user := session.User
+		if user.UserId != step.wantUserId || user.BusinessUnitId != step.wantBusinessUnit || user.Role != step.wantRole {
+			t.Errorf("%s: user %d in business unit %d with role %q, want user %d in business unit %d with role %q", step.name,
+				user.UserId, user.BusinessUnitId, user.Role, step.wantUserId, step.wantBusinessUnit, step.wantRole)
+		}
+		if userId, linked, err := utils.FindUserIdentity(ctx, issuer.server.URL, step.claims["sub"].(string)); err != nil || !linked || userId != user.UserId {
+			t.Errorf("%s: FindUserIdentity() = %d, %v, %v, want user %d", step.name, userId, linked, err, user.UserId)
+		}
+	}
+}
+
+// deactivateTestUser deactivates the user userId.
+func deactivateTestUser(t *testing.T, userId int32) {
+	t.Helper()
+	dao, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dest models.User
+	row, err := dao.GetByID("users", "user_id", userId, &dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := row.(models.User)
+	user.Status = utils.UserStatusDeactivated
+	dao, err = utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dao.UpdateRow("users", "user_id", userId, user); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	useTestDatabase(t)
+	hash, salt, err := utils.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dao, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dao.InsertRowReturningID("business_units", models.BusinessUnit{Name: "Battalion"}); err != nil {
+		t.Fatal(err)
+	}
+	dao, err = utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{BusinessUnitId: 1, Username: "jdoe", PasswordHash: hash, PasswordSalt: salt, Status: utils.UserStatusActive}
+	if _, err := dao.InsertRowReturningID("users", user); err != nil {
+		t.Fatal(err)
+	}
+
+	accounts := utils.AccountsConfig{}
+	utils.ApplyAccountsDefaults(&accounts)
+	accounts.MaxFailedLogins = 3
+	accounts.LockoutDuration = "1h"
+	service := NewAuthAPIService(models.EnvironmentConfig{Accounts: accounts}, nil)
+
+	// The logins run in order, as wrong passwords lock the account for the later ones
+	steps := []struct {
+		name     string
+		password string
+		unlock   bool
+		want     int
+	}{
+		{name: "right password", password: "correct horse", want: 200},
+		{name: "first wrong password", password: "wrong", want: 401},
+		{name: "second wrong password", password: "wrong", want: 401},
+		{name: "wrong password locking the account", password: "wrong", want: 401},
+		{name: "right password while locked", password: "correct horse", want: 401},
+		{name: "wrong password while locked", password: "wrong", want: 401},
+		{name: "right password once unlocked", password: "correct horse", unlock: true, want: 200},
+	}
+	ctx := context.Background()
+	for _, step := range steps {
+		if step.unlock {
+			if err := utils.ResetFailedLogins(ctx, 1); err != nil {
+				t.Fatal(err)
+			}
+		}
+		result, err := service.Login(ctx, models.LoginRequest{Username: "jdoe", Password: step.password})
+		if result.Code != step.want {
+			t.Errorf("%s: Login() = %d, %v, want %d", step.name, result.Code, err, step.want)
+			continue
+		}
+		// A locked account must not tell that the password was right
+		if step.want == 401 && err != utils.ErrWrongPassword {
+			t.Errorf("%s: Login() error = %v, want %v", step.name, err, utils.ErrWrongPassword)
+		}
+	}
+}
+
+// testMailer keeps the emails it is asked to send.
+type testMailer struct {
+	mu    sync.Mutex
+	mails []utils.Mail
+}
+
+func (m *testMailer) Send(ctx context.Context, mail utils.Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mails = append(m.mails, mail)
+	return nil
+}
+
+func TestEnrollMfaPendingSession(t *testing.T) {
+	useTestDatabase(t)
+	hash, salt, err := utils.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dao, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dao.InsertRowReturningID("business_units", models.BusinessUnit{Name: "Battalion"}); err != nil {
+		t.Fatal(err)
+	}
+	dao, err = utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{BusinessUnitId: 1, Username: "admin", PrimaryEmail: "admin@example.com", PasswordHash: hash, PasswordSalt: salt, Status: utils.UserStatusActive, Role: utils.RoleAdmin}
+	if _, err := dao.InsertRowReturningID("users", user); err != nil {
+		t.Fatal(err)
+	}
+
+	accounts := utils.AccountsConfig{}
+	utils.ApplyAccountsDefaults(&accounts)
+	mailer := &testMailer{}
+	service := NewAuthAPIService(models.EnvironmentConfig{Accounts: accounts}, mailer)
+
+	// Administrators must present a second factor, so logging in without one emails the token enrolling it
+	ctx := context.Background()
+	if result, err := service.Login(ctx, models.LoginRequest{Username: "admin", Password: "correct horse"}); result.Code != 200 {
+		t.Fatalf("Login() = %d, %v, want 200", result.Code, err)
+	}
+	if len(mailer.mails) != 1 || mailer.mails[0].To != "admin@example.com" {
+		t.Fatalf("Login() sent %+v, want one email to admin@example.com", mailer.mails)
+	}
+	_, token, found := strings.Cut(mailer.mails[0].Body, "authenticator app: ")
+	if !found {
+		t.Fatalf("Login() sent %q, want an enrollment token", mailer.mails[0].Body)
+	}
+	token, _, _ = strings.Cut(token, "\n")
+
+	// The steps run in order, as the token can be used once
+	pending := utils.WithPrincipal(ctx, utils.Principal{Subject: utils.UserSubject(1), UserId: 1, Role: utils.RoleAdmin, MFAPending: true})
+	steps := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{name: "password only", want: 403},
+		{name: "wrong token", token: "wrong", want: 403},
+		{name: "emailed token", token: token, want: 200},
+		{name: "emailed token again", token: token, want: 403},
+	}
+	for _, step := range steps {
+		result, err := service.EnrollMfa(pending, models.MfaEnrollmentRequest{Token: step.token})
+		if result.Code != step.want {
+			t.Errorf("%s: EnrollMfa() = %d, %v, want %d", step.name, result.Code, err, step.want)
+		}
+	}
+}
+
+func TestResetPasswordToken(t *testing.T) {
+	useTestDatabase(t)
+	hash, salt, err := utils.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dao, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dao.InsertRowReturningID("business_units", models.BusinessUnit{Name: "Battalion"}); err != nil {
+		t.Fatal(err)
+	}
+	dao, err = utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{BusinessUnitId: 1, Username: "jdoe", PasswordHash: hash, PasswordSalt: salt, Status: utils.UserStatusActive}
+	if _, err := dao.InsertRowReturningID("users", user); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	token, _, err := utils.CreateUserToken(ctx, 1, utils.TokenPurposePasswordReset, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enrollmentToken, _, err := utils.CreateUserToken(ctx, 1, utils.TokenPurposeMFAEnrollment, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accounts := utils.AccountsConfig{}
+	utils.ApplyAccountsDefaults(&accounts)
+	service := NewAuthAPIService(models.EnvironmentConfig{Accounts: accounts}, nil)
+
+	// The steps run in order: a password the policy refuses leaves the token usable, a new password uses it up
+	steps := []struct {
+		name     string
+		token    string
+		password string
+		want     int
+	}{
+		{name: "password too short", token: token, password: "short", want: 422},
+		{name: "token of another purpose", token: enrollmentToken, password: "a much longer password", want: 400},
+		{name: "password following the policy", token: token, password: "a much longer password", want: 200},
+		{name: "token used up", token: token, password: "another long password", want: 400},
+	}
+	for _, step := range steps {
+		result, err := service.ResetPassword(ctx, models.PasswordResetRequest{Token: step.token, Password: step.password})
+		if result.Code != step.want {
+			t.Errorf("%s: ResetPassword() = %d, %v, want %d", step.name, result.Code, err, step.want)
+		}
+	}
+	if result, err := service.Login(ctx, models.LoginRequest{Username: "jdoe", Password: "a much longer password"}); result.Code != 200 {
+		t.Errorf("Login() with the new password = %d, %v, want 200", result.Code, err)
+	}
+}
@@ -0,0 +1,278 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	api "smidgen-backend/src/api"
+	models "smidgen-backend/src/models"
+	utils "smidgen-backend/src/utils"
+	"strings"
+	"time"
+)
+
+// batchReference matches ${id.field}, which refers to field of the response body of operation id.
+var batchReference = regexp.MustCompile(`\$\{([A-Za-z0-9_-]+)\.([A-Za-z0-9_]+)\}`)
+
+// BatchAPIService is a service that implements the logic for the BatchAPIServicer
+// Operations are dispatched to handler, which serves the resource routes below basePath.
+type BatchAPIService struct {
+	basePath string
+	config   utils.BatchConfig
+	handler  http.Handler
+}
+
+// NewBatchAPIService creates a default api service
+func NewBatchAPIService(basePath string, config utils.BatchConfig, handler http.Handler) api.BatchAPIServicer {
+	return &BatchAPIService{basePath: basePath, config: config, handler: handler}
+}
+
+// ExecuteBatch - Run a list of operations in a single transaction
+func (s *BatchAPIService) ExecuteBatch(ctx context.Context, batchRequest models.BatchRequest) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "BatchAPIService.ExecuteBatch")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	var uuid16 [2]byte
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while executing the batch")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          "EXECUTE_BATCH",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	// The entry of the batch is written outside of it, and those of its operations once it ends, so failures
+	// are recorded even though the batch is rolled back
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if len(batchRequest.Operations) > s.config.MaxOperations {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(413, nil), fmt.Errorf("a batch may contain at most %d operations", s.config.MaxOperations)
+	}
+
+	methods := make([]string, 0, len(batchRequest.Operations))
+	for _, operation := range batchRequest.Operations {
+		methods = append(methods, strings.ToUpper(operation.Method))
+	}
+	batchCtx, batch, err := utils.BeginBatch(ctx, utils.DatabaseConfigPath, utils.BatchPrivilege(methods...))
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to begin batch: %v", err)
+		return utils.Response(500, nil), errors.New("an error has occurred while executing the batch")
+	}
+
+	response := models.BatchResponse{Results: make([]models.BatchResult, 0, len(batchRequest.Operations))}
+	bodies := make(map[string]map[string]json.RawMessage)
+	for _, operation := range batchRequest.Operations {
+		result := s.execute(batchCtx, operation, bodies)
+		response.Results = append(response.Results, result)
+		if result.Status >= http.StatusBadRequest {
+			if err := batch.Rollback(); err != nil {
+				log.Errorf("Failed to roll back batch: %v", err)
+			}
+			logConnection.InsertRow("audit_log", logEntry)
+			return utils.Response(result.Status, response), nil
+		}
+	}
+
+	if err := batch.Commit(); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to commit batch: %v", err)
+		return utils.Response(500, nil), errors.New("an error has occurred while executing the batch")
+	}
+	response.Committed = true
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, response), nil
+}
+
+// execute runs a single operation through the router and records its response. The response bodies of
+// named operations are kept in bodies so later operations can refer to them.
+func (s *BatchAPIService) execute(ctx context.Context, operation models.BatchOperation, bodies map[string]map[string]json.RawMessage) models.BatchResult {
+	result := models.BatchResult{Id: operation.Id}
+
+	path, err := resolveBatchReferences(operation.Path, bodies)
+	if err != nil {
+		return batchError(result, err)
+	}
+	var body []byte
+	if len(operation.Body) > 0 {
+		if body, err = resolveBatchBody(operation.Body, bodies); err != nil {
+			return batchError(result, err)
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, strings.ToUpper(operation.Method), s.basePath+path, bytes.NewReader(body))
+	if err != nil {
+		return batchError(result, err)
+	}
+	for name, value := range operation.Headers {
+		request.Header.Set(name, value)
+	}
+	if len(body) > 0 && request.Header.Get("Content-Type") == "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	// Idempotency applies to the batch as a whole, not to its operations
+	request.Header.Del(utils.IdempotencyKeyHeader)
+
+	writer := &batchResponseWriter{header: make(http.Header)}
+	s.handler.ServeHTTP(writer, request)
+
+	result.Status = writer.status
+	if result.Status == 0 {
+		result.Status = http.StatusOK
+	}
+	if trimmed := bytes.TrimSpace(writer.body.Bytes()); len(trimmed) > 0 && json.Valid(trimmed) {
+		result.Body = trimmed
+	}
+	if operation.Id != "" {
+		var fields map[string]json.RawMessage
+		json.Unmarshal(result.Body, &fields)
+		bodies[operation.Id] = fields
+	}
+	return result
+}
+
+func batchError(result models.BatchResult, err error) models.BatchResult {
+	result.Status = http.StatusBadRequest
+	result.Body, _ = json.Marshal(err.Error())
+	return result
+}
+
+// resolveBatchReferences replaces every reference in s with the text of the value it refers to.
+func resolveBatchReferences(s string, bodies map[string]map[string]json.RawMessage) (string, error) {
+	var err error
+	resolved := batchReference.ReplaceAllStringFunc(s, func(reference string) string {
+		value, lookupErr := lookupBatchReference(reference, bodies)
+		if lookupErr != nil {
+			err = lookupErr
+			return reference
+		}
+		var text string
+		if json.Unmarshal(value, &text) == nil {
+			return text
+		}
+		return string(value)
+	})
+	return resolved, err
+}
+
+// resolveBatchBody replaces the references in the strings of body. A string that is nothing but a
+// reference is replaced with the referenced value itself, so numeric IDs stay numbers.
+func resolveBatchBody(body json.RawMessage, bodies map[string]map[string]json.RawMessage) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	var document interface{}
+	if err := d.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	var resolve func(node interface{}) (interface{}, error)
+	resolve = func(node interface{}) (interface{}, error) {
+		switch value := node.(type) {
+		case string:
+			if batchReference.FindString(value) == value {
+				return lookupBatchReference(value, bodies)
+			}
+			return resolveBatchReferences(value, bodies)
+		case map[string]interface{}:
+			for name, member := range value {
+				resolved, err := resolve(member)
+				if err != nil {
+					return nil, err
+				}
+				value[name] = resolved
+			}
+		case []interface{}:
+			for i, element := range value {
+				resolved, err := resolve(element)
+				if err != nil {
+					return nil, err
+				}
+				value[i] = resolved
+			}
+		}
+		return node, nil
+	}
+
+	resolved, err := resolve(document)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(resolved)
+}
+
+func lookupBatchReference(reference string, bodies map[string]map[string]json.RawMessage) (json.RawMessage, error) {
+	match := batchReference.FindStringSubmatch(reference)
+	fields, ok := bodies[match[1]]
+	if !ok {
+		return nil, fmt.Errorf("%s refers to an unknown operation", reference)
+	}
+	value, ok := fields[match[2]]
+	if !ok {
+		return nil, fmt.Errorf("%s refers to a field missing from the response of %s", reference, match[1])
+	}
+	return value, nil
+}
+
+// batchResponseWriter keeps the response of an operation in memory.
+type batchResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *batchResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *batchResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *batchResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(data)
+}
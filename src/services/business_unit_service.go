@@ -50,6 +50,10 @@ func NewBusinessUnitAPIService() api.BusinessUnitAPIServicer {
 
 // AddBusinessUnit - Create Business Unit
 func (s *BusinessUnitAPIService) AddBusinessUnit(ctx context.Context, businessUnit models.BusinessUnit) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "BusinessUnitAPIService.AddBusinessUnit")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	privilege := "write"
 
 	var uuid16 [2]byte
@@ -66,10 +70,21 @@ func (s *BusinessUnitAPIService) AddBusinessUnit(ctx context.Context, businessUn
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "POST",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if businessUnit.RequireMfa && !utils.IsAdmin(ctx) {
+		logEntry.Action = "ADD_BUSINESS_UNIT"
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), errRequireMfaForbidden
+	}
+	if result, err := assertParentAllowed(ctx, 0, businessUnit.ParentBusinessUnitId); err != nil {
+		logEntry.Action = "ADD_BUSINESS_UNIT"
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
 
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logEntry.Action = "ADD_BUSINESS_UNIT"
 		logEntry.ActionStatus = "FAILED"
@@ -77,7 +92,7 @@ func (s *BusinessUnitAPIService) AddBusinessUnit(ctx context.Context, businessUn
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
 
-	err = dbConnection.InsertRow("business_units", businessUnit)
+	id, err := dbConnection.InsertRowReturningID("business_units", businessUnit)
 	if err != nil {
 		logEntry.Action = "ADD_BUSINESS_UNIT"
 		logEntry.ActionStatus = "FAILED"
@@ -86,16 +101,22 @@ func (s *BusinessUnitAPIService) AddBusinessUnit(ctx context.Context, businessUn
 		return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
 	}
 
+	businessUnit.BusinessUnitId = int32(id)
+	businessUnit.Version = 1
 	logEntry.Action = "ADD_BUSINESS_UNIT"
 	logEntry.ActionStatus = "SUCCESS"
 	logConnection.InsertRow("audit_log", logEntry)
-	return utils.Response(202, nil), nil
+	return utils.Response(202, businessUnit), nil
 }
 
 // DeleteBusinessUnit - Delete Business Unit
-func (s *BusinessUnitAPIService) DeleteBusinessUnit(ctx context.Context, unitId int32) (utils.ImplResponse, error) {
-	privilege := "delete"
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+func (s *BusinessUnitAPIService) DeleteBusinessUnit(ctx context.Context, unitId int32, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "BusinessUnitAPIService.DeleteBusinessUnit")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 
 	var uuid16 [2]byte
 
@@ -111,8 +132,18 @@ func (s *BusinessUnitAPIService) DeleteBusinessUnit(ctx context.Context, unitId
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "POST",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	version, err := utils.ResolveIfMatch(ctx, "business_units", "unitId", unitId, ifMatch)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while checking the version")
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
 
 	if err != nil {
 		logEntry.Action = "DELETE_BUSINESS_UNIT"
@@ -121,12 +152,15 @@ func (s *BusinessUnitAPIService) DeleteBusinessUnit(ctx context.Context, unitId
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
 
-	err = dbConnection.DeleteRow("business_units", "UnitID", unitId)
+	err = dbConnection.SoftDeleteRow("business_units", "businessUnitId", unitId, version, utils.ActorFromContext(ctx))
 	if err != nil {
 		logEntry.Action = "DELETE_BUSINESS_UNIT"
 		logEntry.ActionStatus = "FAILED"
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Error: %v", err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
 		return utils.Response(404, nil), err
 	}
 
@@ -137,7 +171,11 @@ func (s *BusinessUnitAPIService) DeleteBusinessUnit(ctx context.Context, unitId
 }
 
 // GetBusinessUnits - Get Business Units
-func (s *BusinessUnitAPIService) GetBusinessUnits(ctx context.Context) (utils.ImplResponse, error) {
+func (s *BusinessUnitAPIService) GetBusinessUnits(ctx context.Context, includeDeleted bool) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "BusinessUnitAPIService.GetBusinessUnits")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	// Add api_user_service.go to the .openapi-generator-ignore to avoid overwriting this service implementation when updating open api generation.
 	privilege := "read"
 	var uuid16 [2]byte
@@ -155,32 +193,31 @@ func (s *BusinessUnitAPIService) GetBusinessUnits(ctx context.Context) (utils.Im
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "",
+		Actor:           utils.ActorFromContext(ctx),
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
 
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
 
 	var dest models.BusinessUnit
-	rows, err := dbConnection.GetRows("business_units", &dest)
-
-	if err != nil {
+	if includeDeleted && !utils.IsAdmin(ctx) {
 		logEntry.Action = "GET_BUSINESS_UNIT"
-		logEntry.ActionStatus = "WARN"
 		logConnection.InsertRow("audit_log", logEntry)
-		log.Errorf("Error: %v", err)
+		return utils.Response(403, nil), utils.ErrForbidden
 	}
-
-	if len(rows) == 0 {
+	rows, err := dbConnection.GetRows("business_units", &dest, utils.IncludeDeleted(includeDeleted))
+	if err != nil {
 		logEntry.Action = "GET_BUSINESS_UNIT"
-		logEntry.ActionStatus = "FAILED"
+		logEntry.ActionStatus = "WARN"
 		logConnection.InsertRow("audit_log", logEntry)
-		return utils.Response(404, nil), fmt.Errorf("no business units were found in the database")
+		log.Errorf("Error: %v", err)
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
 	}
 
-	var businessUnits []models.BusinessUnit
+	businessUnits := []models.BusinessUnit{}
 	for _, row := range rows {
 		businessUnit, ok := row.(models.BusinessUnit)
 		if !ok {
@@ -201,6 +238,10 @@ func (s *BusinessUnitAPIService) GetBusinessUnits(ctx context.Context) (utils.Im
 
 // GetBusinessUnitById - Get Business Unit
 func (s *BusinessUnitAPIService) GetBusinessUnitById(ctx context.Context, unitId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "BusinessUnitAPIService.GetBusinessUnitById")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	privilege := "read"
 
 	var uuid16 [2]byte
@@ -218,10 +259,11 @@ func (s *BusinessUnitAPIService) GetBusinessUnitById(ctx context.Context, unitId
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "",
+		Actor:           utils.ActorFromContext(ctx),
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
 
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logEntry.Action = "GET_BUSINESS_UNIT_BY_ID"
 		logEntry.ActionStatus = "FAILED"
@@ -230,7 +272,7 @@ func (s *BusinessUnitAPIService) GetBusinessUnitById(ctx context.Context, unitId
 	}
 
 	var dest models.BusinessUnit
-	row, err := dbConnection.GetByID("business_units", "unitId", unitId, &dest)
+	row, err := dbConnection.GetByID("business_units", "businessUnitId", unitId, &dest)
 	if err != nil {
 		logEntry.Action = "GET_BUSINESS_UNIT_BY_ID"
 		logEntry.ActionStatus = "FAILED"
@@ -251,11 +293,15 @@ func (s *BusinessUnitAPIService) GetBusinessUnitById(ctx context.Context, unitId
 	logEntry.Action = "GET_BUSINESS_UNIT_BY_ID"
 	logEntry.ActionStatus = "SUCCESS"
 	logConnection.InsertRow("audit_log", logEntry)
-	return utils.Response(200, unit), nil
+	return utils.ResponseWithHeaders(200, utils.ETagHeaders(unit.Version), unit), nil
 }
 
 // UpdateBusinessUnit - Update Business Unit
-func (s *BusinessUnitAPIService) UpdateBusinessUnit(ctx context.Context, unitId int32, businessUnit models.BusinessUnit) (utils.ImplResponse, error) {
+func (s *BusinessUnitAPIService) UpdateBusinessUnit(ctx context.Context, unitId int32, businessUnit models.BusinessUnit, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "BusinessUnitAPIService.UpdateBusinessUnit")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	privilege := "write"
 
 	var uuid16 [2]byte
@@ -273,10 +319,20 @@ func (s *BusinessUnitAPIService) UpdateBusinessUnit(ctx context.Context, unitId
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	version, err := utils.ResolveIfMatch(ctx, "business_units", "unitId", unitId, ifMatch)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while checking the version")
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
 
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logEntry.Action = "UPDATE_BUSINESS_UNIT"
 		logEntry.ActionStatus = "FAILED"
@@ -284,12 +340,30 @@ func (s *BusinessUnitAPIService) UpdateBusinessUnit(ctx context.Context, unitId
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
 
-	err = dbConnection.UpdateRow("business_units", "unitId", unitId, businessUnit)
+	if !utils.IsAdmin(ctx) {
+		if result, err := assertRequireMfaUnchanged(ctx, unitId, businessUnit.RequireMfa); err != nil {
+			logEntry.Action = "UPDATE_BUSINESS_UNIT"
+			logConnection.InsertRow("audit_log", logEntry)
+			return result, err
+		}
+	}
+	if result, err := assertParentAllowed(ctx, unitId, businessUnit.ParentBusinessUnitId); err != nil {
+		logEntry.Action = "UPDATE_BUSINESS_UNIT"
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
+	}
+	if version != 0 {
+		businessUnit.Version = version
+	}
+	err = dbConnection.UpdateRow("business_units", "businessUnitId", unitId, businessUnit)
 	if err != nil {
 		logEntry.Action = "UPDATE_BUSINESS_UNIT"
 		logEntry.ActionStatus = "FAILED"
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Error(err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
 		return utils.Response(400, nil), err
 	}
 
@@ -298,3 +372,396 @@ func (s *BusinessUnitAPIService) UpdateBusinessUnit(ctx context.Context, unitId
 	logConnection.InsertRow("audit_log", logEntry)
 	return utils.Response(202, nil), nil
 }
+
+// PatchBusinessUnit - Partially update Business Unit
+func (s *BusinessUnitAPIService) PatchBusinessUnit(ctx context.Context, unitId int32, patch utils.PatchDocument, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "BusinessUnitAPIService.PatchBusinessUnit")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          "PATCH_BUSINESS_UNIT",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	version, err := utils.ResolveIfMatch(ctx, "business_units", "unitId", unitId, ifMatch)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while checking the version")
+	}
+	readConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as read: %v", err)
+	}
+
+	var dest models.BusinessUnit
+	row, err := readConnection.GetByID("business_units", "businessUnitId", unitId, &dest)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Data Not Found: %v", err)
+		return utils.Response(404, nil), fmt.Errorf("the requested ID was not found")
+	}
+	current, ok := row.(models.BusinessUnit)
+	if !ok {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Warn("Warn: Unexpected type in row")
+		return utils.Response(500, nil), errors.New("unexpected type in row")
+	}
+	if version != 0 && version != current.Version {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(412, nil), utils.ErrVersionMismatch
+	}
+
+	var businessUnit models.BusinessUnit
+	columns, err := patch.Apply(current, &businessUnit)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(400, nil), err
+	}
+	if err := models.AssertBusinessUnitRequired(businessUnit); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(422, nil), err
+	}
+	if err := models.AssertBusinessUnitConstraints(businessUnit); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(422, nil), err
+	}
+	if businessUnit.RequireMfa != current.RequireMfa && !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), errRequireMfaForbidden
+	}
+	if result, err := assertParentAllowed(ctx, unitId, businessUnit.ParentBusinessUnitId); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
+	}
+
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+	}
+
+	err = dbConnection.UpdateColumns("business_units", "businessUnitId", unitId, businessUnit, columns)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		return utils.Response(400, nil), err
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(202, nil), nil
+}
+
+// RestoreBusinessUnit - Restore deleted Business Unit
+func (s *BusinessUnitAPIService) RestoreBusinessUnit(ctx context.Context, unitId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "BusinessUnitAPIService.RestoreBusinessUnit")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while restoring data")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          "RESTORE_BUSINESS_UNIT",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+	}
+
+	err = dbConnection.RestoreRow("business_units", "businessUnitId", unitId)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		return utils.Response(404, nil), fmt.Errorf("no deleted business unit with ID %d was found", unitId)
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, nil), nil
+}
+
+// PurgeBusinessUnit - Permanently delete Business Unit
+func (s *BusinessUnitAPIService) PurgeBusinessUnit(ctx context.Context, unitId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "BusinessUnitAPIService.PurgeBusinessUnit")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "delete"
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while deleting data")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          "PURGE_BUSINESS_UNIT",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+
+	readConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as read: %v", err)
+	}
+	var dest models.BusinessUnit
+	row, err := readConnection.GetByID("business_units", "businessUnitId", unitId, &dest, utils.IncludeDeleted(true))
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Data Not Found: %v", err)
+		return utils.Response(404, nil), fmt.Errorf("the requested ID was not found")
+	}
+	current, ok := row.(models.BusinessUnit)
+	if !ok {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Warn("Warn: Unexpected type in row")
+		return utils.Response(500, nil), errors.New("unexpected type in row")
+	}
+	if current.DeletedAt == nil || time.Since(*current.DeletedAt) < utils.SoftDeleteRetention {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(409, nil), fmt.Errorf("business unit can only be purged once it has been deleted for %s", utils.SoftDeleteRetention)
+	}
+	subtree, err := utils.BusinessUnitSubtree(ctx, unitId)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		return utils.Response(500, nil), errors.New("an error has occurred while deleting data")
+	}
+	if len(subtree) > 1 {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(409, nil), errors.New("business unit can only be purged once no other business unit is under it")
+	}
+
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+	}
+
+	err = dbConnection.DeleteVersionedRow("business_units", "businessUnitId", unitId, current.Version)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		return utils.Response(400, nil), err
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, nil), nil
+}
+
+// GetBusinessUnitTree - Get a Business Unit with the units below it, and what each of them holds
+func (s *BusinessUnitAPIService) GetBusinessUnitTree(ctx context.Context, unitId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "BusinessUnitAPIService.GetBusinessUnitTree")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "read"
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          "GET_BUSINESS_UNIT_TREE",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+
+	var dest models.BusinessUnit
+	rows, err := dbConnection.GetRows("business_units", &dest)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	units := make(map[int32]models.BusinessUnit)
+	children := make(map[int32][]int32)
+	for _, row := range rows {
+		unit, ok := row.(models.BusinessUnit)
+		if !ok {
+			log.Warn("Warn: Unexpected type in row")
+			continue
+		}
+		units[unit.BusinessUnitId] = unit
+		if unit.ParentBusinessUnitId != nil {
+			children[*unit.ParentBusinessUnitId] = append(children[*unit.ParentBusinessUnitId], unit.BusinessUnitId)
+		}
+	}
+	if _, ok := units[unitId]; !ok {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(404, nil), fmt.Errorf("the requested ID was not found")
+	}
+	users, equipment, assignments, err := utils.CountByBusinessUnit(ctx)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+
+	visited := make(map[int32]bool)
+	var build func(id int32) models.BusinessUnitTree
+	build = func(id int32) models.BusinessUnitTree {
+		visited[id] = true
+		counts := models.BusinessUnitCounts{Users: users[id], Equipment: equipment[id], Assignments: assignments[id]}
+		node := models.BusinessUnitTree{BusinessUnit: units[id], Counts: counts, TotalCounts: counts, Children: []models.BusinessUnitTree{}}
+		for _, childId := range children[id] {
+			if visited[childId] {
+				continue
+			}
+			child := build(childId)
+			node.TotalCounts.Users += child.TotalCounts.Users
+			node.TotalCounts.Equipment += child.TotalCounts.Equipment
+			node.TotalCounts.Assignments += child.TotalCounts.Assignments
+			node.Children = append(node.Children, child)
+		}
+		return node
+	}
+	tree := build(unitId)
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, tree), nil
+}
+
+// assertParentAllowed checks that parentId may be the parent of the business unit unitId, which is 0 for new
+// units: the parent must exist, and must not be the unit itself or below it, which would make a cycle.
+func assertParentAllowed(ctx context.Context, unitId int32, parentId *int32) (utils.ImplResponse, error) {
+	if parentId == nil {
+		return utils.ImplResponse{}, nil
+	}
+	log := utils.LoggerFromContext(ctx)
+	readConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		log.Errorf("Failed to establish database connection as read: %v", err)
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+	var dest models.BusinessUnit
+	if _, err := readConnection.GetByID("business_units", "businessUnitId", *parentId, &dest); err != nil {
+		return utils.Response(422, nil), fmt.Errorf("parent business unit %d does not exist", *parentId)
+	}
+	if unitId == 0 {
+		return utils.ImplResponse{}, nil
+	}
+	subtree, err := utils.BusinessUnitSubtree(ctx, unitId)
+	if err != nil {
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+	for _, id := range subtree {
+		if id == *parentId {
+			return utils.Response(422, nil), fmt.Errorf("business unit %d cannot be placed under %d, which is itself or below it", unitId, *parentId)
+		}
+	}
+	return utils.ImplResponse{}, nil
+}
+
+// businessUnitScope returns the business units that listings filtered on businessUnitId cover: the unit alone,
+// or with includeChildren every unit below it as well. Listings are not filtered when it returns nil.
+func businessUnitScope(ctx context.Context, businessUnitId int32, includeChildren bool) ([]int32, utils.ImplResponse, error) {
+	if businessUnitId == 0 {
+		if includeChildren {
+			return nil, utils.Response(400, nil), errors.New("include_children requires business_unit_id")
+		}
+		return nil, utils.ImplResponse{}, nil
+	}
+	if !includeChildren {
+		return []int32{businessUnitId}, utils.ImplResponse{}, nil
+	}
+	subtree, err := utils.BusinessUnitSubtree(ctx, businessUnitId)
+	if err != nil {
+		utils.LoggerFromContext(ctx).Error(err)
+		return nil, utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	return subtree, utils.ImplResponse{}, nil
+}
+
+var errRequireMfaForbidden = fmt.Errorf("%w: only administrators may change whether a business unit requires multi-factor authentication", utils.ErrForbidden)
+
+// assertRequireMfaUnchanged checks that requireMfa is the current setting of the business unit unitId, since
+// only administrators may change it.
+func assertRequireMfaUnchanged(ctx context.Context, unitId int32, requireMfa bool) (utils.ImplResponse, error) {
+	log := utils.LoggerFromContext(ctx)
+	readConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		log.Errorf("Failed to establish database connection as read: %v", err)
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+	var dest models.BusinessUnit
+	row, err := readConnection.GetByID("business_units", "businessUnitId", unitId, &dest)
+	if err != nil {
+		log.Errorf("Data Not Found: %v", err)
+		return utils.Response(404, nil), fmt.Errorf("the requested ID was not found")
+	}
+	current, ok := row.(models.BusinessUnit)
+	if !ok {
+		log.Warn("Warn: Unexpected type in row")
+		return utils.Response(500, nil), errors.New("unexpected type in row")
+	}
+	if current.RequireMfa != requireMfa {
+		return utils.Response(403, nil), errRequireMfaForbidden
+	}
+	return utils.ImplResponse{}, nil
+}
@@ -30,6 +30,7 @@ import (
 	"fmt"
 	api "smidgen-backend/src/api"
 	utils "smidgen-backend/src/utils"
+	"strings"
 	"time"
 )
 
@@ -42,62 +43,147 @@ type healthCheck struct {
 	Latency string `json:"latency"`
 }
 
+type verboseHealthCheck struct {
+	Status       string             `json:"status"`
+	Ready        bool               `json:"ready"`
+	Version      string             `json:"version"`
+	Uptime       string             `json:"uptime"`
+	Dependencies []dependencyHealth `json:"dependencies"`
+}
+
+type dependencyHealth struct {
+	Name          string      `json:"name"`
+	Status        string      `json:"status"`
+	Latency       string      `json:"latency"`
+	Error         string      `json:"error,omitempty"`
+	MissingTables []string    `json:"missing_tables,omitempty"`
+	Pool          *poolHealth `json:"pool,omitempty"`
+	duration      time.Duration
+}
+
+type poolHealth struct {
+	MaxOpen      int     `json:"max_open"`
+	Open         int     `json:"open"`
+	InUse        int     `json:"in_use"`
+	Idle         int     `json:"idle"`
+	Utilization  float64 `json:"utilization"`
+	WaitCount    int64   `json:"wait_count"`
+	WaitDuration string  `json:"wait_duration"`
+}
+
+type probe struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// readinessTimeout bounds how long a health check waits for the database.
+const readinessTimeout = 2 * time.Second
+
 
 func NewDefaultAPIService() api.DefaultAPIServicer {
 	return &DefaultAPIService{}
 }
 
-func (s *DefaultAPIService) HealthCheck(ctx context.Context) (utils.ImplResponse, error) {
+func (s *DefaultAPIService) HealthCheck(ctx context.Context, verbose bool) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "DefaultAPIService.HealthCheck")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	log.Debug("checking status of core Smidgen services")
 	healthcheckStart := time.Now()
-	var services []healthCheck
-	db, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "read")
-	if err != nil {
-		log.Errorf("failed to create database connection: %v", err)
-		healthcheckEnd := time.Since(healthcheckStart).Milliseconds()
-		services = append(services, healthCheck{"Overall", "DEGRADED", "DEGRADED"})
-		services = append(services, healthCheck{"API Server", "OK", fmt.Sprintf("%dms", healthcheckEnd)})
-		services = append(services, healthCheck{"Database", "DOWN", "DOWN"})
-		return utils.Response(500, services), nil
+	database := checkDatabase(ctx)
+	healthcheckEnd := time.Since(healthcheckStart)
+
+	code := 200
+	overall := "OK"
+	if database.Status != "OK" {
+		code = 503
+		overall = "DEGRADED"
 	}
-	defer func() {
-		if db != nil {
-			db.Close()
-		}
-	}()
-
-	if db == nil {
-		log.Errorf("database connection is nil")
-		healthcheckEnd := time.Since(healthcheckStart).Milliseconds()
-		services = append(services, healthCheck{"Overall", "DEGRADED", "DEGRADED"})
-		services = append(services, healthCheck{"API Server", "OK", fmt.Sprintf("%dms", healthcheckEnd)})
-		services = append(services, healthCheck{"Database", "DEGRADED", "DEGRADED"})
-		return utils.Response(500, services), nil
+
+	if verbose {
+		return utils.Response(code, verboseHealthCheck{
+			Status:       overall,
+			Ready:        utils.IsReady(),
+			Version:      utils.BuildVersion(),
+			Uptime:       utils.Uptime().Round(time.Second).String(),
+			Dependencies: []dependencyHealth{database},
+		}), nil
 	}
 
-	start := time.Now()
-	err = db.Ping()
-	databaseLatency := time.Since(start).Milliseconds()
-
-	if err != nil {
-		log.Errorf("failed to ping database: %v", err)
-		healthcheckEnd := time.Since(healthcheckStart).Milliseconds()
-		services = append(services, healthCheck{"Overall", "DEGRADED", "DEGRADED"})
-		services = append(services, healthCheck{"API Server", "OK", fmt.Sprintf("%dms", healthcheckEnd)})
-		services = append(services, healthCheck{"Database", "DEGRADED", "DEGRADED"})
-		return utils.Response(500, services), nil
+	var services []healthCheck
+	services = append(services, healthCheck{"Overall", overall, fmt.Sprintf("%dms", healthcheckEnd.Milliseconds())})
+	services = append(services, healthCheck{"API Server", "OK", fmt.Sprintf("%dms", (healthcheckEnd - database.duration).Milliseconds())})
+	services = append(services, healthCheck{"Database", database.Status, database.Latency})
+	return utils.Response(code, services), nil
+}
+
+// Livez - The process is up and serving requests
+func (s *DefaultAPIService) Livez(ctx context.Context) (utils.ImplResponse, error) {
+	_, span := utils.StartSpan(ctx, "DefaultAPIService.Livez")
+	defer span.End()
+
+	return utils.Response(200, probe{Status: "OK"}), nil
+}
+
+// Readyz - The server is not shutting down, and the database is reachable with its schema in place
+func (s *DefaultAPIService) Readyz(ctx context.Context) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "DefaultAPIService.Readyz")
+	defer span.End()
+
+	if !utils.IsReady() {
+		return utils.Response(503, probe{Status: "UNAVAILABLE", Reason: "the server is not accepting traffic"}), nil
 	}
+	database := checkDatabase(ctx)
+	if database.Status != "OK" {
+		reason := database.Error
+		if len(database.MissingTables) > 0 {
+			reason = "missing tables: " + strings.Join(database.MissingTables, ", ")
+		}
+		return utils.Response(503, probe{Status: "UNAVAILABLE", Reason: reason}), nil
+	}
+	return utils.Response(200, probe{Status: "OK"}), nil
+}
 
-	log.Infof("connection to database successfully established in %d", databaseLatency)
+// checkDatabase reports on the database through the shared pool, waiting at most readinessTimeout.
+func checkDatabase(ctx context.Context) dependencyHealth {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
 
-	healthcheckEnd := time.Since(healthcheckStart).Milliseconds()
-	totalLatency := healthcheckEnd + databaseLatency
-	services = append(services, healthCheck{"Overall", "OK", fmt.Sprintf("%dms", totalLatency)})
-	services = append(services, healthCheck{"API Server", "OK", fmt.Sprintf("%dms", healthcheckEnd)})
-	services = append(services, healthCheck{"Database", "OK", fmt.Sprintf("%dms", databaseLatency)})
-	return utils.Response(200, services), nil
+	health, err := utils.CheckDatabase(ctx, utils.DatabaseConfigPath)
+	dependency := dependencyHealth{
+		Name:          "Database",
+		Status:        "OK",
+		Latency:       health.Latency.String(),
+		duration:      health.Latency,
+		MissingTables: health.MissingTables,
+		Pool: &poolHealth{
+			MaxOpen:      health.Stats.MaxOpenConnections,
+			Open:         health.Stats.OpenConnections,
+			InUse:        health.Stats.InUse,
+			Idle:         health.Stats.Idle,
+			WaitCount:    health.Stats.WaitCount,
+			WaitDuration: health.Stats.WaitDuration.String(),
+		},
+	}
+	if health.Stats.MaxOpenConnections > 0 {
+		dependency.Pool.Utilization = float64(health.Stats.InUse) / float64(health.Stats.MaxOpenConnections)
+	}
+	switch {
+	case err != nil:
+		log.Errorf("database health check failed: %v", err)
+		dependency.Status = "DOWN"
+		dependency.Latency = "DOWN"
+		dependency.Error = err.Error()
+	case len(health.MissingTables) > 0:
+		dependency.Status = "DEGRADED"
+	}
+	return dependency
 }
 
 func (s *DefaultAPIService) RootGet(ctx context.Context) (utils.ImplResponse, error) {
+	_, span := utils.StartSpan(ctx, "DefaultAPIService.RootGet")
+	defer span.End()
+
 	return utils.Response(403, nil), nil
 }
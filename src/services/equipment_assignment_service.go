@@ -50,6 +50,10 @@ func NewEquipmentAssignmentAPIService() api.EquipmentAssignmentAPIServicer {
 
 // AddEquipmentAssignment - Create assignment
 func (s *EquipmentAssignmentAPIService) AddEquipmentAssignment(ctx context.Context, equipmentAssignment models.EquipmentAssignment) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "EquipmentAssignmentAPIService.AddEquipmentAssignment")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	privilege := "write"
 	var uuid16 [2]byte
 
@@ -65,29 +69,36 @@ func (s *EquipmentAssignmentAPIService) AddEquipmentAssignment(ctx context.Conte
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "ADD_EQUIPMENT_ASSIGNMENT",
+		Actor:           utils.ActorFromContext(ctx),
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
 
-	err = dbConnection.InsertRow("equipment_assignment", equipmentAssignment)
+	id, err := dbConnection.InsertRowReturningID("equipment_assignment", equipmentAssignment)
 	if err != nil {
 		log.Error(err)
 		logConnection.InsertRow("audit_log", logEntry)
 		return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
 	}
 
+	equipmentAssignment.AssignmentId = int32(id)
+	equipmentAssignment.Version = 1
 	logEntry.ActionStatus = "SUCCESS"
 	logConnection.InsertRow("audit_log", logEntry)
-	return utils.Response(202, nil), nil
+	return utils.Response(202, equipmentAssignment), nil
 }
 
 // DeleteEquipmentAssignment - Delete assignment
-func (s *EquipmentAssignmentAPIService) DeleteEquipmentAssignment(ctx context.Context, assignmentId int32) (utils.ImplResponse, error) {
-	privilege := "delete"
+func (s *EquipmentAssignmentAPIService) DeleteEquipmentAssignment(ctx context.Context, assignmentId int32, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "EquipmentAssignmentAPIService.DeleteEquipmentAssignment")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
 	var uuid16 [2]byte
 
 	_, err := rand.Read(uuid16[:])
@@ -102,18 +113,31 @@ func (s *EquipmentAssignmentAPIService) DeleteEquipmentAssignment(ctx context.Co
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "DELETE_EQUIPMENT_ASSIGNMENT",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	version, err := utils.ResolveIfMatch(ctx, "equipment_assignment", "assignmentId", assignmentId, ifMatch)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while checking the version")
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
 
-	err = dbConnection.DeleteRow("equipment", "equipmentid", assignmentId)
+	err = dbConnection.SoftDeleteRow("equipment_assignment", "assignmentId", assignmentId, version, utils.ActorFromContext(ctx))
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Error: %v", err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
 		return utils.Response(404, nil), err
 	}
 
@@ -123,7 +147,11 @@ func (s *EquipmentAssignmentAPIService) DeleteEquipmentAssignment(ctx context.Co
 }
 
 // GetEquipmentAssignments - Get assignments
-func (s *EquipmentAssignmentAPIService) GetEquipmentAssignments(ctx context.Context) (utils.ImplResponse, error) {
+func (s *EquipmentAssignmentAPIService) GetEquipmentAssignments(ctx context.Context, includeDeleted bool, businessUnitId int32, includeChildren bool) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "EquipmentAssignmentAPIService.GetEquipmentAssignments")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	// Add api_user_service.go to the .openapi-generator-ignore to avoid overwriting this service implementation when updating open api generation.
 	privilege := "read"
 	var uuid16 [2]byte
@@ -140,29 +168,43 @@ func (s *EquipmentAssignmentAPIService) GetEquipmentAssignments(ctx context.Cont
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "GET_EQUIPMENT_ASSIGNMENT",
+		Actor:           utils.ActorFromContext(ctx),
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
 
 	var dest models.EquipmentAssignment
-	rows, err := dbConnection.GetRows("equipment_assignment", &dest)
-
+	if includeDeleted && !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+	options := []utils.QueryOption{utils.IncludeDeleted(includeDeleted)}
+	scope, result, err := businessUnitScope(ctx, businessUnitId, includeChildren)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
-		log.Error("Error: %v", err)
+		return result, err
 	}
-
-	if len(rows) == 0 {
-		logEntry.ActionStatus = "SUCCESS"
+	if scope != nil {
+		// Assignments belong to the business unit of their equipment
+		equipmentIds, result, err := equipmentInBusinessUnits(ctx, scope)
+		if err != nil {
+			logConnection.InsertRow("audit_log", logEntry)
+			return result, err
+		}
+		options = append(options, utils.WhereIn("equipment_id", equipmentIds...))
+	}
+	rows, err := dbConnection.GetRows("equipment_assignment", &dest, options...)
+	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
-		return utils.Response(404, nil), fmt.Errorf("no equipment assignments were found in the database")
+		log.Errorf("Error: %v", err)
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
 	}
 
-	var Assignments []models.EquipmentAssignment
+	Assignments := []models.EquipmentAssignment{}
 	for _, row := range rows {
 		Assignment, ok := row.(models.EquipmentAssignment)
 		if !ok {
@@ -181,6 +223,10 @@ func (s *EquipmentAssignmentAPIService) GetEquipmentAssignments(ctx context.Cont
 
 // GetEquipmentAssignmentById - Get assignment
 func (s *EquipmentAssignmentAPIService) GetEquipmentAssignmentById(ctx context.Context, assignmentId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "EquipmentAssignmentAPIService.GetEquipmentAssignmentById")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	privilege := "read"
 	var uuid16 [2]byte
 
@@ -196,9 +242,10 @@ func (s *EquipmentAssignmentAPIService) GetEquipmentAssignmentById(ctx context.C
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "GET_EQUIPMENT_ASSIGNMENT_BY_ID",
+		Actor:           utils.ActorFromContext(ctx),
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
@@ -219,11 +266,15 @@ func (s *EquipmentAssignmentAPIService) GetEquipmentAssignmentById(ctx context.C
 	}
 	logEntry.ActionStatus = "SUCCESS"
 	logConnection.InsertRow("audit_log", logEntry)
-	return utils.Response(200, assignment), nil
+	return utils.ResponseWithHeaders(200, utils.ETagHeaders(assignment.Version), assignment), nil
 }
 
 // UpdateEquipmentAssignment - Update assignment
-func (s *EquipmentAssignmentAPIService) UpdateEquipmentAssignment(ctx context.Context, assignmentId int32, equipmentAssignment models.EquipmentAssignment) (utils.ImplResponse, error) {
+func (s *EquipmentAssignmentAPIService) UpdateEquipmentAssignment(ctx context.Context, assignmentId int32, equipmentAssignment models.EquipmentAssignment, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "EquipmentAssignmentAPIService.UpdateEquipmentAssignment")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	privilege := "write"
 	var uuid16 [2]byte
 
@@ -239,18 +290,127 @@ func (s *EquipmentAssignmentAPIService) UpdateEquipmentAssignment(ctx context.Co
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "UPDATE_EQUIPMENT_ASSIGNMENT",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	version, err := utils.ResolveIfMatch(ctx, "equipment_assignment", "assignmentId", assignmentId, ifMatch)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while checking the version")
+	}
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+	}
+
+	if version != 0 {
+		equipmentAssignment.Version = version
+	}
+	err = dbConnection.UpdateRow("equipment_assignment", "assignmentId", assignmentId, equipmentAssignment)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		return utils.Response(400, nil), err
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(202, nil), nil
+}
+
+// PatchEquipmentAssignment - Partially update assignment
+func (s *EquipmentAssignmentAPIService) PatchEquipmentAssignment(ctx context.Context, assignmentId int32, patch utils.PatchDocument, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "EquipmentAssignmentAPIService.PatchEquipmentAssignment")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          "PATCH_EQUIPMENT_ASSIGNMENT",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	version, err := utils.ResolveIfMatch(ctx, "equipment_assignment", "assignmentId", assignmentId, ifMatch)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while checking the version")
+	}
+	readConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as read: %v", err)
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+
+	var dest models.EquipmentAssignment
+	row, err := readConnection.GetByID("equipment_assignment", "assignmentId", assignmentId, &dest)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Data Not Found: %v", err)
+		return utils.Response(404, nil), fmt.Errorf("the requested ID was not found")
+	}
+	current, ok := row.(models.EquipmentAssignment)
+	if !ok {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Warn("Warn: Unexpected type in row")
+		return utils.Response(500, nil), errors.New("unexpected type in row")
+	}
+	if version != 0 && version != current.Version {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(412, nil), utils.ErrVersionMismatch
+	}
+
+	var equipmentAssignment models.EquipmentAssignment
+	columns, err := patch.Apply(current, &equipmentAssignment)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(400, nil), err
+	}
+	if err := models.AssertEquipmentAssignmentRequired(equipmentAssignment); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(422, nil), err
+	}
+	if err := models.AssertEquipmentAssignmentConstraints(equipmentAssignment); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(422, nil), err
+	}
+
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
 
-	err = dbConnection.UpdateRow("equipment_assignment", "assignmentid", assignmentId, equipmentAssignment)
+	err = dbConnection.UpdateColumns("equipment_assignment", "assignmentId", assignmentId, equipmentAssignment, columns)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Error(err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
 		return utils.Response(400, nil), err
 	}
 
@@ -258,3 +418,142 @@ func (s *EquipmentAssignmentAPIService) UpdateEquipmentAssignment(ctx context.Co
 	logConnection.InsertRow("audit_log", logEntry)
 	return utils.Response(202, nil), nil
 }
+
+// RestoreEquipmentAssignment - Restore deleted assignment
+func (s *EquipmentAssignmentAPIService) RestoreEquipmentAssignment(ctx context.Context, assignmentId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "EquipmentAssignmentAPIService.RestoreEquipmentAssignment")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while restoring data")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          "RESTORE_EQUIPMENT_ASSIGNMENT",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+	}
+
+	err = dbConnection.RestoreRow("equipment_assignment", "assignmentId", assignmentId)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		return utils.Response(404, nil), fmt.Errorf("no deleted assignment with ID %d was found", assignmentId)
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, nil), nil
+}
+
+// PurgeEquipmentAssignment - Permanently delete assignment
+func (s *EquipmentAssignmentAPIService) PurgeEquipmentAssignment(ctx context.Context, assignmentId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "EquipmentAssignmentAPIService.PurgeEquipmentAssignment")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "delete"
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while deleting data")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          "PURGE_EQUIPMENT_ASSIGNMENT",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+
+	readConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as read: %v", err)
+	}
+	var dest models.EquipmentAssignment
+	row, err := readConnection.GetByID("equipment_assignment", "assignmentId", assignmentId, &dest, utils.IncludeDeleted(true))
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Data Not Found: %v", err)
+		return utils.Response(404, nil), fmt.Errorf("the requested ID was not found")
+	}
+	current, ok := row.(models.EquipmentAssignment)
+	if !ok {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Warn("Warn: Unexpected type in row")
+		return utils.Response(500, nil), errors.New("unexpected type in row")
+	}
+	if current.DeletedAt == nil || time.Since(*current.DeletedAt) < utils.SoftDeleteRetention {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(409, nil), fmt.Errorf("assignment can only be purged once it has been deleted for %s", utils.SoftDeleteRetention)
+	}
+
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+	}
+
+	err = dbConnection.DeleteVersionedRow("equipment_assignment", "assignmentId", assignmentId, current.Version)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		return utils.Response(400, nil), err
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, nil), nil
+}
+
+// equipmentInBusinessUnits returns the ids of the equipment of the business units unitIds, including deleted
+// equipment, whose assignments may still be listed.
+func equipmentInBusinessUnits(ctx context.Context, unitIds []int32) ([]int32, utils.ImplResponse, error) {
+	log := utils.LoggerFromContext(ctx)
+	readConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		log.Errorf("Failed to establish database connection as read: %v", err)
+		return nil, utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	var dest models.Equipment
+	rows, err := readConnection.GetRows("equipment", &dest, utils.IncludeDeleted(true), utils.WhereIn("business_unit_id", unitIds...))
+	if err != nil {
+		log.Errorf("Error: %v", err)
+		return nil, utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	ids := make([]int32, 0, len(rows))
+	for _, row := range rows {
+		if equipment, ok := row.(models.Equipment); ok {
+			ids = append(ids, equipment.EquipmentId)
+		}
+	}
+	return ids, utils.ImplResponse{}, nil
+}
@@ -34,7 +34,6 @@ import (
 	api "smidgen-backend/src/api"
 	models "smidgen-backend/src/models"
 	utils "smidgen-backend/src/utils"
-	"strconv"
 	"time"
 )
 
@@ -51,6 +50,10 @@ func NewEquipmentAPIService() api.EquipmentAPIServicer {
 
 // AddEquipment - Create equipment
 func (s *EquipmentAPIService) AddEquipment(ctx context.Context, equipment models.Equipment) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "EquipmentAPIService.AddEquipment")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	privilege := "write"
 	var uuid16 [2]byte
 
@@ -66,33 +69,41 @@ func (s *EquipmentAPIService) AddEquipment(ctx context.Context, equipment models
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "ADD_EQUIPMENT",
+		Actor:           utils.ActorFromContext(ctx),
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+		return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
 	}
 
-	err = dbConnection.InsertRow("equipment", equipment)
+	id, err := dbConnection.InsertRowReturningID("equipment", equipment)
 	if err != nil {
-		if err.Error() == "23503" {
+		if errors.Is(err, utils.ErrForeignKeyViolation) {
 			logConnection.InsertRow("audit_log", logEntry)
 			log.Error("unable to insert new data. The data requires reference to another table (foreign key constraint)")
-			return utils.Response(400, nil), errors.New("the BusinessUnit with ID of " + strconv.Itoa(int(equipment.BusinessUnitId)) + " does not exist")
+			return utils.Response(400, nil), fmt.Errorf("the business unit %d or the manufacturer %d of the equipment does not exist", equipment.BusinessUnitId, equipment.ManufacturerId)
 		}
 		logConnection.InsertRow("audit_log", logEntry)
 		return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
 	}
 
+	equipment.EquipmentId = int32(id)
+	equipment.Version = 1
 	logEntry.ActionStatus = "SUCCESS"
 	logConnection.InsertRow("audit_log", logEntry)
-	return utils.Response(202, nil), nil
+	return utils.Response(202, equipment), nil
 }
 
 // DeleteEquipment - Delete equipment
-func (s *EquipmentAPIService) DeleteEquipment(ctx context.Context, equipmentId int32) (utils.ImplResponse, error) {
-	privilege := "delete"
+func (s *EquipmentAPIService) DeleteEquipment(ctx context.Context, equipmentId int32, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "EquipmentAPIService.DeleteEquipment")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
 	var uuid16 [2]byte
 
 	_, err := rand.Read(uuid16[:])
@@ -107,18 +118,31 @@ func (s *EquipmentAPIService) DeleteEquipment(ctx context.Context, equipmentId i
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "DELETE_EQUIPMENT",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	version, err := utils.ResolveIfMatch(ctx, "equipment", "equipmentId", equipmentId, ifMatch)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while checking the version")
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
 
-	err = dbConnection.DeleteRow("equipment", "EquipmentId", equipmentId)
+	err = dbConnection.SoftDeleteRow("equipment", "EquipmentId", equipmentId, version, utils.ActorFromContext(ctx))
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Error: %v", err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
 		return utils.Response(404, nil), err
 	}
 
@@ -128,7 +152,11 @@ func (s *EquipmentAPIService) DeleteEquipment(ctx context.Context, equipmentId i
 }
 
 // GetEquipments - Get equipments
-func (s *EquipmentAPIService) GetEquipments(ctx context.Context) (utils.ImplResponse, error) {
+func (s *EquipmentAPIService) GetEquipments(ctx context.Context, includeDeleted bool, businessUnitId int32, includeChildren bool) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "EquipmentAPIService.GetEquipments")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	// Add api_user_service.go to the .openapi-generator-ignore to avoid overwriting this service implementation when updating open api generation.
 	privilege := "read"
 	var uuid16 [2]byte
@@ -145,25 +173,36 @@ func (s *EquipmentAPIService) GetEquipments(ctx context.Context) (utils.ImplResp
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "GET_EQUIPMENT",
+		Actor:           utils.ActorFromContext(ctx),
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
 
 	var dest models.Equipment
-	rows, err := dbConnection.GetRows("equipment", &dest)
+	if includeDeleted && !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+	options := []utils.QueryOption{utils.IncludeDeleted(includeDeleted)}
+	scope, result, err := businessUnitScope(ctx, businessUnitId, includeChildren)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
-		log.Errorf("Error: %v", err)
+		return result, err
+	}
+	if scope != nil {
+		options = append(options, utils.WhereIn("business_unit_id", scope...))
 	}
-	if len(rows) == 0 {
+	rows, err := dbConnection.GetRows("equipment", &dest, options...)
+	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
-		return utils.Response(200, nil), fmt.Errorf("no equipment was found in the database")
+		log.Errorf("Error: %v", err)
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
 	}
-	var Assets []models.Equipment
+	Assets := []models.Equipment{}
 	for _, row := range rows {
 		equipment, ok := row.(models.Equipment)
 		if !ok {
@@ -182,6 +221,10 @@ func (s *EquipmentAPIService) GetEquipments(ctx context.Context) (utils.ImplResp
 
 // GetEquipmentById - Get equipment
 func (s *EquipmentAPIService) GetEquipmentById(ctx context.Context, equipmentId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "EquipmentAPIService.GetEquipmentById")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	privilege := "read"
 	var uuid16 [2]byte
 
@@ -197,9 +240,10 @@ func (s *EquipmentAPIService) GetEquipmentById(ctx context.Context, equipmentId
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "GET_EQUIPMENT_BY_ID",
+		Actor:           utils.ActorFromContext(ctx),
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
@@ -222,11 +266,15 @@ func (s *EquipmentAPIService) GetEquipmentById(ctx context.Context, equipmentId
 
 	logEntry.ActionStatus = "SUCCESS"
 	logConnection.InsertRow("audit_log", logEntry)
-	return utils.Response(200, equipment), nil
+	return utils.ResponseWithHeaders(200, utils.ETagHeaders(equipment.Version), equipment), nil
 }
 
 // UpdateEquipment - Update equipment
-func (s *EquipmentAPIService) UpdateEquipment(ctx context.Context, equipmentId int32, equipment models.Equipment) (utils.ImplResponse, error) {
+func (s *EquipmentAPIService) UpdateEquipment(ctx context.Context, equipmentId int32, equipment models.Equipment, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "EquipmentAPIService.UpdateEquipment")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	privilege := "write"
 	var uuid16 [2]byte
 
@@ -242,18 +290,127 @@ func (s *EquipmentAPIService) UpdateEquipment(ctx context.Context, equipmentId i
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "UPDATE_EQUIPMENT",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	version, err := utils.ResolveIfMatch(ctx, "equipment", "equipmentId", equipmentId, ifMatch)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while checking the version")
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
 
+	if version != 0 {
+		equipment.Version = version
+	}
 	err = dbConnection.UpdateRow("equipment", "equipmentId", equipmentId, equipment)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Error(err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		return utils.Response(400, nil), err
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(202, nil), nil
+}
+
+// PatchEquipment - Partially update equipment
+func (s *EquipmentAPIService) PatchEquipment(ctx context.Context, equipmentId int32, patch utils.PatchDocument, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "EquipmentAPIService.PatchEquipment")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          "PATCH_EQUIPMENT",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	version, err := utils.ResolveIfMatch(ctx, "equipment", "equipmentId", equipmentId, ifMatch)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while checking the version")
+	}
+	readConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as read: %v", err)
+	}
+
+	var dest models.Equipment
+	row, err := readConnection.GetByID("equipment", "equipmentId", equipmentId, &dest)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Data Not Found: %v", err)
+		return utils.Response(404, nil), fmt.Errorf("the requested ID was not found")
+	}
+	current, ok := row.(models.Equipment)
+	if !ok {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Warn("Warn: Unexpected type in row")
+		return utils.Response(500, nil), errors.New("unexpected type in row")
+	}
+	if version != 0 && version != current.Version {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(412, nil), utils.ErrVersionMismatch
+	}
+
+	var equipment models.Equipment
+	columns, err := patch.Apply(current, &equipment)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(400, nil), err
+	}
+	if err := models.AssertEquipmentRequired(equipment); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(422, nil), err
+	}
+	if err := models.AssertEquipmentConstraints(equipment); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(422, nil), err
+	}
+
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+	}
+
+	err = dbConnection.UpdateColumns("equipment", "equipmentId", equipmentId, equipment, columns)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
 		return utils.Response(400, nil), err
 	}
 
@@ -261,3 +418,118 @@ func (s *EquipmentAPIService) UpdateEquipment(ctx context.Context, equipmentId i
 	logConnection.InsertRow("audit_log", logEntry)
 	return utils.Response(202, nil), nil
 }
+
+// RestoreEquipment - Restore deleted equipment
+func (s *EquipmentAPIService) RestoreEquipment(ctx context.Context, equipmentId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "EquipmentAPIService.RestoreEquipment")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while restoring data")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          "RESTORE_EQUIPMENT",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+	}
+
+	err = dbConnection.RestoreRow("equipment", "equipmentId", equipmentId)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		return utils.Response(404, nil), fmt.Errorf("no deleted equipment with ID %d was found", equipmentId)
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, nil), nil
+}
+
+// PurgeEquipment - Permanently delete equipment
+func (s *EquipmentAPIService) PurgeEquipment(ctx context.Context, equipmentId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "EquipmentAPIService.PurgeEquipment")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "delete"
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while deleting data")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          "PURGE_EQUIPMENT",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+
+	readConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as read: %v", err)
+	}
+	var dest models.Equipment
+	row, err := readConnection.GetByID("equipment", "equipmentId", equipmentId, &dest, utils.IncludeDeleted(true))
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Data Not Found: %v", err)
+		return utils.Response(404, nil), fmt.Errorf("the requested ID was not found")
+	}
+	current, ok := row.(models.Equipment)
+	if !ok {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Warn("Warn: Unexpected type in row")
+		return utils.Response(500, nil), errors.New("unexpected type in row")
+	}
+	if current.DeletedAt == nil || time.Since(*current.DeletedAt) < utils.SoftDeleteRetention {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(409, nil), fmt.Errorf("equipment can only be purged once it has been deleted for %s", utils.SoftDeleteRetention)
+	}
+
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+	}
+
+	err = dbConnection.DeleteVersionedRow("equipment", "equipmentId", equipmentId, current.Version)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		return utils.Response(400, nil), err
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, nil), nil
+}
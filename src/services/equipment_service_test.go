@@ -0,0 +1,108 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	models "smidgen-backend/src/models"
+	utils "smidgen-backend/src/utils"
+)
+
+func TestAddEquipmentReferences(t *testing.T) {
+	useTestDatabase(t)
+	dao, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dao.InsertRowReturningID("business_units", models.BusinessUnit{Name: "Battalion"}); err != nil {
+		t.Fatal(err)
+	}
+	dao, err = utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dao.InsertRowReturningID("manufacturers", models.Manufacturer{Name: "Acme", DateAdded: time.Now().UTC()}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		businessUnitId int32
+		manufacturerId int32
+		want           int
+	}{
+		{name: "existing references", businessUnitId: 1, manufacturerId: 1, want: 202},
+		{name: "unknown business unit", businessUnitId: 99, manufacturerId: 1, want: 400},
+		{name: "unknown manufacturer", businessUnitId: 1, manufacturerId: 99, want: 400},
+	}
+	service := NewEquipmentAPIService()
+	for _, tt := range tests {
+		equipment := models.Equipment{BusinessUnitId: tt.businessUnitId, ManufacturerId: tt.manufacturerId, Model: "M1",
+			StatusId: 1, DateReceived: time.Now().UTC(), LastInventoried: time.Now().UTC()}
+		result, err := service.AddEquipment(context.Background(), equipment)
+		if result.Code != tt.want {
+			t.Errorf("%s: AddEquipment() = %d, %v, want %d", tt.name, result.Code, err, tt.want)
+		}
+	}
+}
+
+func TestListEmptyCollections(t *testing.T) {
+	useTestDatabase(t)
+	ctx := utils.WithPrincipal(context.Background(), utils.Principal{Subject: "test", Role: utils.RoleAdmin})
+
+	// The audit log is listed first, before the other listings write to it
+	lists := []struct {
+		name string
+		list func() (utils.ImplResponse, error)
+	}{
+		{"audit logs", func() (utils.ImplResponse, error) { return NewAuditLogAPIService().GetAuditLogs(ctx) }},
+		{"equipment", func() (utils.ImplResponse, error) {
+			return NewEquipmentAPIService().GetEquipments(ctx, false, 0, false)
+		}},
+		{"manufacturers", func() (utils.ImplResponse, error) { return NewManufacturerAPIService().GetManufacturers(ctx, false) }},
+		{"business units", func() (utils.ImplResponse, error) { return NewBusinessUnitAPIService().GetBusinessUnits(ctx, false) }},
+		{"equipment assignments", func() (utils.ImplResponse, error) {
+			return NewEquipmentAssignmentAPIService().GetEquipmentAssignments(ctx, false, 0, false)
+		}},
+		{"users", func() (utils.ImplResponse, error) {
+			return NewUserAPIService(utils.AccountsConfig{}, nil).GetUsers(ctx, false, 0, false)
+		}},
+	}
+	for _, tt := range lists {
+		result, err := tt.list()
+		if err != nil || result.Code != 200 {
+			t.Errorf("listing %s = %d, %v, want 200", tt.name, result.Code, err)
+			continue
+		}
+		if body, _ := json.Marshal(result.Body); string(body) != "[]" {
+			t.Errorf("listing %s = %s, want []", tt.name, body)
+		}
+	}
+}
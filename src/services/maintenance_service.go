@@ -0,0 +1,476 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	api "smidgen-backend/src/api"
+	models "smidgen-backend/src/models"
+	utils "smidgen-backend/src/utils"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultDueWithin is how far ahead the maintenance due report looks when no period is given.
+const defaultDueWithin = 30 * 24 * time.Hour
+
+// MaintenanceAPIService is a service that implements the logic for the MaintenanceAPIServicer
+// Maintenance plans generate the work orders of the WorkOrderAPIService as they come due.
+type MaintenanceAPIService struct {
+	config utils.MaintenanceConfig
+}
+
+// NewMaintenanceAPIService creates a default api service
+func NewMaintenanceAPIService(config utils.MaintenanceConfig) api.MaintenanceAPIServicer {
+	return &MaintenanceAPIService{config: config}
+}
+
+// AddMaintenancePlan - Create maintenance plan
+func (s *MaintenanceAPIService) AddMaintenancePlan(ctx context.Context, plan models.MaintenancePlan) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "MaintenanceAPIService.AddMaintenancePlan")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
+	logEntry, err := newMaintenanceLogEntry(ctx, "ADD_MAINTENANCE_PLAN")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+		return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
+	}
+
+	plan.CreatedAt = time.Now().UTC()
+	id, err := dbConnection.InsertRowReturningID("maintenance_plans", plan)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrForeignKeyViolation) {
+			return utils.Response(422, nil), errors.New("the equipment or manufacturer of the plan does not exist")
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
+	}
+
+	plan.PlanId = int32(id)
+	plan.Version = 1
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(202, plan), nil
+}
+
+// GetMaintenancePlans - Get maintenance plans
+func (s *MaintenanceAPIService) GetMaintenancePlans(ctx context.Context, includeDeleted bool) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "MaintenanceAPIService.GetMaintenancePlans")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	logEntry, err := newMaintenanceLogEntry(ctx, "GET_MAINTENANCE_PLANS")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if includeDeleted && !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+
+	plans, err := readRows[models.MaintenancePlan](ctx, "maintenance_plans", utils.IncludeDeleted(includeDeleted))
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, plans), nil
+}
+
+// GetMaintenancePlanById - Get maintenance plan
+func (s *MaintenanceAPIService) GetMaintenancePlanById(ctx context.Context, planId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "MaintenanceAPIService.GetMaintenancePlanById")
+	defer span.End()
+
+	logEntry, err := newMaintenanceLogEntry(ctx, "GET_MAINTENANCE_PLAN_BY_ID")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	plan, result, err := getMaintenancePlan(ctx, planId)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.ResponseWithHeaders(200, utils.ETagHeaders(plan.Version), plan), nil
+}
+
+// UpdateMaintenancePlan - Update maintenance plan
+func (s *MaintenanceAPIService) UpdateMaintenancePlan(ctx context.Context, planId int32, plan models.MaintenancePlan, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "MaintenanceAPIService.UpdateMaintenancePlan")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
+	logEntry, err := newMaintenanceLogEntry(ctx, "UPDATE_MAINTENANCE_PLAN")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	version, err := utils.ResolveIfMatch(ctx, "maintenance_plans", "planId", planId, ifMatch)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while checking the version")
+	}
+	current, result, err := getMaintenancePlan(ctx, planId)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
+	}
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+
+	// The schedule of the plan keeps counting from when it was created
+	plan.CreatedAt = current.CreatedAt
+	if version != 0 {
+		plan.Version = version
+	}
+	err = dbConnection.UpdateRow("maintenance_plans", "planId", planId, plan)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		if errors.Is(err, utils.ErrForeignKeyViolation) {
+			return utils.Response(422, nil), errors.New("the equipment or manufacturer of the plan does not exist")
+		}
+		return utils.Response(400, nil), err
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(202, nil), nil
+}
+
+// DeleteMaintenancePlan - Delete maintenance plan. Its work orders are kept.
+func (s *MaintenanceAPIService) DeleteMaintenancePlan(ctx context.Context, planId int32, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "MaintenanceAPIService.DeleteMaintenancePlan")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
+	logEntry, err := newMaintenanceLogEntry(ctx, "DELETE_MAINTENANCE_PLAN")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while deleting data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	version, err := utils.ResolveIfMatch(ctx, "maintenance_plans", "planId", planId, ifMatch)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while checking the version")
+	}
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+		return utils.Response(500, nil), errors.New("an error has occurred while deleting data")
+	}
+
+	err = dbConnection.SoftDeleteRow("maintenance_plans", "planId", planId, version, utils.ActorFromContext(ctx))
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		return utils.Response(404, nil), err
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, nil), nil
+}
+
+// GetMaintenanceDue - Get the maintenance coming due within a period, 30 days by default, overdue maintenance first
+func (s *MaintenanceAPIService) GetMaintenanceDue(ctx context.Context, within time.Duration) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "MaintenanceAPIService.GetMaintenanceDue")
+	defer span.End()
+
+	logEntry, err := newMaintenanceLogEntry(ctx, "GET_MAINTENANCE_DUE")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if within < 0 {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(400, nil), errors.New("within cannot be negative")
+	}
+	if within == 0 {
+		within = defaultDueWithin
+	}
+	schedule, result, err := maintenanceSchedule(ctx)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
+	}
+
+	now := time.Now().UTC()
+	horizon := now.Add(within)
+	due := []models.MaintenanceDue{}
+	for _, scheduled := range schedule {
+		if scheduled.due.DueDate.After(horizon) {
+			continue
+		}
+		scheduled.due.Overdue = scheduled.due.DueDate.Before(now)
+		due = append(due, scheduled.due)
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].DueDate.Before(due[j].DueDate) })
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, due), nil
+}
+
+// GenerateWorkOrders - Open a work order for every plan and piece of equipment whose maintenance comes due within
+// the lead days of the plan, unless one is already open or in progress. It also runs every
+// maintenance.generate_interval.
+func (s *MaintenanceAPIService) GenerateWorkOrders(ctx context.Context) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "MaintenanceAPIService.GenerateWorkOrders")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
+	logEntry, err := newMaintenanceLogEntry(ctx, "GENERATE_WORK_ORDERS")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	schedule, result, err := maintenanceSchedule(ctx)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
+	}
+
+	now := time.Now().UTC()
+	generated := []models.WorkOrder{}
+	for _, scheduled := range schedule {
+		if scheduled.due.WorkOrderId != nil || scheduled.due.DueDate.After(now.AddDate(0, 0, int(scheduled.plan.LeadDays))) {
+			continue
+		}
+		dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+		if err != nil {
+			logConnection.InsertRow("audit_log", logEntry)
+			log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+			return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
+		}
+		planId := scheduled.plan.PlanId
+		order := models.WorkOrder{
+			EquipmentId: scheduled.due.EquipmentId,
+			PlanId:      &planId,
+			Status:      utils.WorkOrderStatusOpen,
+			DueDate:     scheduled.due.DueDate,
+			Notes:       scheduled.plan.Name,
+			OpenedAt:    now,
+		}
+		id, err := dbConnection.InsertRowReturningID("work_orders", order)
+		if err != nil {
+			logConnection.InsertRow("audit_log", logEntry)
+			log.Error(err)
+			return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
+		}
+		order.WorkOrderId = int32(id)
+		order.Version = 1
+		generated = append(generated, order)
+	}
+
+	logEntry.Action = fmt.Sprintf("GENERATE_WORK_ORDERS %d", len(generated))
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(202, generated), nil
+}
+
+// scheduledMaintenance is the next maintenance of a plan for a piece of equipment it covers.
+type scheduledMaintenance struct {
+	plan models.MaintenancePlan
+	due  models.MaintenanceDue
+}
+
+// maintenanceSchedule returns the next maintenance of every plan for every piece of equipment it covers. It comes
+// due an interval after the last work order of the plan was completed, or else after the plan was created or the
+// equipment received, whichever is later.
+func maintenanceSchedule(ctx context.Context) ([]scheduledMaintenance, utils.ImplResponse, error) {
+	log := utils.LoggerFromContext(ctx)
+	plans, err := readRows[models.MaintenancePlan](ctx, "maintenance_plans")
+	if err != nil {
+		log.Error(err)
+		return nil, utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	equipment, err := readRows[models.Equipment](ctx, "equipment")
+	if err != nil {
+		log.Error(err)
+		return nil, utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	workOrders, err := readRows[models.WorkOrder](ctx, "work_orders")
+	if err != nil {
+		log.Error(err)
+		return nil, utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+
+	type planEquipment struct{ planId, equipmentId int32 }
+	lastCompleted := make(map[planEquipment]time.Time)
+	pending := make(map[planEquipment]int32)
+	for _, order := range workOrders {
+		if order.PlanId == nil {
+			continue
+		}
+		key := planEquipment{*order.PlanId, order.EquipmentId}
+		if order.Status != utils.WorkOrderStatusCompleted {
+			pending[key] = order.WorkOrderId
+		} else if order.CompletedAt != nil && order.CompletedAt.After(lastCompleted[key]) {
+			lastCompleted[key] = *order.CompletedAt
+		}
+	}
+
+	var schedule []scheduledMaintenance
+	for _, plan := range plans {
+		for _, item := range equipment {
+			if !planCovers(plan, item) {
+				continue
+			}
+			key := planEquipment{plan.PlanId, item.EquipmentId}
+			due := models.MaintenanceDue{
+				PlanId:         plan.PlanId,
+				PlanName:       plan.Name,
+				EquipmentId:    item.EquipmentId,
+				BusinessUnitId: item.BusinessUnitId,
+			}
+			since := plan.CreatedAt
+			if item.DateReceived.After(since) {
+				since = item.DateReceived
+			}
+			if completed, ok := lastCompleted[key]; ok {
+				since = completed
+				due.LastCompletedAt = &completed
+			}
+			due.DueDate = since.AddDate(0, 0, int(plan.IntervalDays)).UTC()
+			if workOrderId, ok := pending[key]; ok {
+				due.WorkOrderId = &workOrderId
+			}
+			schedule = append(schedule, scheduledMaintenance{plan: plan, due: due})
+		}
+	}
+	return schedule, utils.ImplResponse{}, nil
+}
+
+// planCovers reports whether plan applies to the equipment item.
+func planCovers(plan models.MaintenancePlan, item models.Equipment) bool {
+	if plan.EquipmentId != nil {
+		return *plan.EquipmentId == item.EquipmentId
+	}
+	return plan.ManufacturerId != nil && *plan.ManufacturerId == item.ManufacturerId && (plan.Model == "" || strings.EqualFold(plan.Model, item.Model))
+}
+
+// getMaintenancePlan reads the plan planId, or returns the response to send when that fails.
+func getMaintenancePlan(ctx context.Context, planId int32) (models.MaintenancePlan, utils.ImplResponse, error) {
+	log := utils.LoggerFromContext(ctx)
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		log.Errorf("Failed to establish database connection as read: %v", err)
+		return models.MaintenancePlan{}, utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	var dest models.MaintenancePlan
+	row, err := dbConnection.GetByID("maintenance_plans", "planId", planId, &dest)
+	if err != nil {
+		log.Errorf("Data Not Found: %v", err)
+		return models.MaintenancePlan{}, utils.Response(404, nil), fmt.Errorf("the requested ID was not found")
+	}
+	plan, ok := row.(models.MaintenancePlan)
+	if !ok {
+		log.Warn("Warn: Unexpected type in row")
+		return models.MaintenancePlan{}, utils.Response(500, nil), errors.New("unexpected type in row")
+	}
+	return plan, utils.ImplResponse{}, nil
+}
+
+// readRows returns every row of tableName as a T.
+func readRows[T any](ctx context.Context, tableName string, options ...utils.QueryOption) ([]T, error) {
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		return nil, err
+	}
+	var dest T
+	rows, err := dbConnection.GetRows(tableName, &dest, options...)
+	if err != nil {
+		return nil, err
+	}
+	values := []T{}
+	for _, row := range rows {
+		value, ok := row.(T)
+		if !ok {
+			return nil, utils.ErrTypeAssertionError
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func newMaintenanceLogEntry(ctx context.Context, action string) (models.AuditLog, error) {
+	var uuid16 [2]byte
+	if _, err := rand.Read(uuid16[:]); err != nil {
+		return models.AuditLog{}, err
+	}
+	return models.AuditLog{
+		LogId:           int(binary.BigEndian.Uint16(uuid16[:])),
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          action,
+		Actor:           utils.ActorFromContext(ctx),
+	}, nil
+}
@@ -50,6 +50,10 @@ func NewManufacturerAPIService() api.ManufacturerAPIServicer {
 
 // AddManufacturer - Create manufacturer
 func (s *ManufacturerAPIService) AddManufacturer(ctx context.Context, manufacturer models.Manufacturer) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "ManufacturerAPIService.AddManufacturer")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	privilege := "write"
 	var uuid16 [2]byte
 	_, err := rand.Read(uuid16[:])
@@ -64,29 +68,36 @@ func (s *ManufacturerAPIService) AddManufacturer(ctx context.Context, manufactur
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "ADD_MANUFACTURER",
+		Actor:           utils.ActorFromContext(ctx),
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
 
-	err = dbConnection.InsertRow("manufacturers", manufacturer)
+	id, err := dbConnection.InsertRowReturningID("manufacturers", manufacturer)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Error(err)
 		return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
 	}
 
+	manufacturer.ManufacturerId = int32(id)
+	manufacturer.Version = 1
 	logEntry.ActionStatus = "SUCCESS"
 	logConnection.InsertRow("audit_log", logEntry)
-	return utils.Response(202, nil), nil
+	return utils.Response(202, manufacturer), nil
 }
 
 // DeleteManufacturer - Delete manufacturer
-func (s *ManufacturerAPIService) DeleteManufacturer(ctx context.Context, manufacturerId int32) (utils.ImplResponse, error) {
-	privilege := "delete"
+func (s *ManufacturerAPIService) DeleteManufacturer(ctx context.Context, manufacturerId int32, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "ManufacturerAPIService.DeleteManufacturer")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
 	var uuid16 [2]byte
 
 	_, err := rand.Read(uuid16[:])
@@ -101,18 +112,31 @@ func (s *ManufacturerAPIService) DeleteManufacturer(ctx context.Context, manufac
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "DELETE_MANUFACTURER",
+		Actor:           utils.ActorFromContext(ctx),
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	version, err := utils.ResolveIfMatch(ctx, "manufacturers", "manufacturerId", manufacturerId, ifMatch)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while checking the version")
+	}
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
 
-	err = dbConnection.DeleteRow("manufacturers", "ManufacturerID", manufacturerId)
+	err = dbConnection.SoftDeleteRow("manufacturers", "ManufacturerID", manufacturerId, version, utils.ActorFromContext(ctx))
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Error: %v", err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
 		return utils.Response(404, nil), err
 	}
 
@@ -122,7 +146,11 @@ func (s *ManufacturerAPIService) DeleteManufacturer(ctx context.Context, manufac
 }
 
 // GetManufacturers - Get manufacturers
-func (s *ManufacturerAPIService) GetManufacturers(ctx context.Context) (utils.ImplResponse, error) {
+func (s *ManufacturerAPIService) GetManufacturers(ctx context.Context, includeDeleted bool) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "ManufacturerAPIService.GetManufacturers")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	// Add api_user_service.go to the .openapi-generator-ignore to avoid overwriting this service implementation when updating open api generation.
 	privilege := "read"
 	var uuid16 [2]byte
@@ -139,25 +167,27 @@ func (s *ManufacturerAPIService) GetManufacturers(ctx context.Context) (utils.Im
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "GET_MANUFACTURER",
+		Actor:           utils.ActorFromContext(ctx),
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
 
 	var dest models.Manufacturer
-	rows, err := dbConnection.GetRows("manufacturers", &dest)
-	if err != nil {
+	if includeDeleted && !utils.IsAdmin(ctx) {
 		logConnection.InsertRow("audit_log", logEntry)
-		log.Errorf("Error: %v", err)
+		return utils.Response(403, nil), utils.ErrForbidden
 	}
-	if len(rows) == 0 {
+	rows, err := dbConnection.GetRows("manufacturers", &dest, utils.IncludeDeleted(includeDeleted))
+	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
-		return utils.Response(200, nil), fmt.Errorf("no manufacturer was found in the database")
+		log.Errorf("Error: %v", err)
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
 	}
-	var Assets []models.Manufacturer
+	Assets := []models.Manufacturer{}
 	for _, row := range rows {
 		manufacturer, ok := row.(models.Manufacturer)
 		if !ok {
@@ -176,6 +206,10 @@ func (s *ManufacturerAPIService) GetManufacturers(ctx context.Context) (utils.Im
 
 // GetManufacturerById - Get manufacturer
 func (s *ManufacturerAPIService) GetManufacturerById(ctx context.Context, manufacturerId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "ManufacturerAPIService.GetManufacturerById")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	privilege := "read"
 	var uuid16 [2]byte
 
@@ -191,9 +225,10 @@ func (s *ManufacturerAPIService) GetManufacturerById(ctx context.Context, manufa
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "GET_MANUFACTURER_BY_ID",
+		Actor:           utils.ActorFromContext(ctx),
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
@@ -216,11 +251,15 @@ func (s *ManufacturerAPIService) GetManufacturerById(ctx context.Context, manufa
 
 	logEntry.ActionStatus = "SUCCESS"
 	logConnection.InsertRow("audit_log", logEntry)
-	return utils.Response(200, manufacturer), nil
+	return utils.ResponseWithHeaders(200, utils.ETagHeaders(manufacturer.Version), manufacturer), nil
 }
 
 // UpdateManufacturer - Update manufacturer
-func (s *ManufacturerAPIService) UpdateManufacturer(ctx context.Context, manufacturerId int32, manufacturer models.Manufacturer) (utils.ImplResponse, error) {
+func (s *ManufacturerAPIService) UpdateManufacturer(ctx context.Context, manufacturerId int32, manufacturer models.Manufacturer, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "ManufacturerAPIService.UpdateManufacturer")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	privilege := "write"
 	var uuid16 [2]byte
 
@@ -236,18 +275,34 @@ func (s *ManufacturerAPIService) UpdateManufacturer(ctx context.Context, manufac
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "UPDATE_MANUFACTURER",
+		Actor:           utils.ActorFromContext(ctx),
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	version, err := utils.ResolveIfMatch(ctx, "manufacturers", "manufacturerId", manufacturerId, ifMatch)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while checking the version")
+	}
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
 
+	if version != 0 {
+		manufacturer.Version = version
+	}
 	err = dbConnection.UpdateRow("manufacturers", "manufacturerId", manufacturerId, manufacturer)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Error(err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
 		return utils.Response(400, nil), err
 	}
 
@@ -255,3 +310,211 @@ func (s *ManufacturerAPIService) UpdateManufacturer(ctx context.Context, manufac
 	logConnection.InsertRow("audit_log", logEntry)
 	return utils.Response(202, nil), nil
 }
+
+// PatchManufacturer - Partially update manufacturer
+func (s *ManufacturerAPIService) PatchManufacturer(ctx context.Context, manufacturerId int32, patch utils.PatchDocument, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "ManufacturerAPIService.PatchManufacturer")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          "PATCH_MANUFACTURER",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	version, err := utils.ResolveIfMatch(ctx, "manufacturers", "manufacturerId", manufacturerId, ifMatch)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while checking the version")
+	}
+	readConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as read: %v", err)
+	}
+
+	var dest models.Manufacturer
+	row, err := readConnection.GetByID("manufacturers", "manufacturerId", manufacturerId, &dest)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Data Not Found: %v", err)
+		return utils.Response(404, nil), fmt.Errorf("the requested ID was not found")
+	}
+	current, ok := row.(models.Manufacturer)
+	if !ok {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Warn("Warn: Unexpected type in row")
+		return utils.Response(500, nil), errors.New("unexpected type in row")
+	}
+	if version != 0 && version != current.Version {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(412, nil), utils.ErrVersionMismatch
+	}
+
+	var manufacturer models.Manufacturer
+	columns, err := patch.Apply(current, &manufacturer)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(400, nil), err
+	}
+	if err := models.AssertManufacturerRequired(manufacturer); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(422, nil), err
+	}
+	if err := models.AssertManufacturerConstraints(manufacturer); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(422, nil), err
+	}
+
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+	}
+
+	err = dbConnection.UpdateColumns("manufacturers", "manufacturerId", manufacturerId, manufacturer, columns)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		return utils.Response(400, nil), err
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(202, nil), nil
+}
+
+// RestoreManufacturer - Restore deleted manufacturer
+func (s *ManufacturerAPIService) RestoreManufacturer(ctx context.Context, manufacturerId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "ManufacturerAPIService.RestoreManufacturer")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while restoring data")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          "RESTORE_MANUFACTURER",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+	}
+
+	err = dbConnection.RestoreRow("manufacturers", "manufacturerId", manufacturerId)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		return utils.Response(404, nil), fmt.Errorf("no deleted manufacturer with ID %d was found", manufacturerId)
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, nil), nil
+}
+
+// PurgeManufacturer - Permanently delete manufacturer
+func (s *ManufacturerAPIService) PurgeManufacturer(ctx context.Context, manufacturerId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "ManufacturerAPIService.PurgeManufacturer")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "delete"
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while deleting data")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          "PURGE_MANUFACTURER",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+
+	readConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as read: %v", err)
+	}
+	var dest models.Manufacturer
+	row, err := readConnection.GetByID("manufacturers", "manufacturerId", manufacturerId, &dest, utils.IncludeDeleted(true))
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Data Not Found: %v", err)
+		return utils.Response(404, nil), fmt.Errorf("the requested ID was not found")
+	}
+	current, ok := row.(models.Manufacturer)
+	if !ok {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Warn("Warn: Unexpected type in row")
+		return utils.Response(500, nil), errors.New("unexpected type in row")
+	}
+	if current.DeletedAt == nil || time.Since(*current.DeletedAt) < utils.SoftDeleteRetention {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(409, nil), fmt.Errorf("manufacturer can only be purged once it has been deleted for %s", utils.SoftDeleteRetention)
+	}
+
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+	}
+
+	err = dbConnection.DeleteVersionedRow("manufacturers", "manufacturerId", manufacturerId, current.Version)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		return utils.Response(400, nil), err
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, nil), nil
+}
@@ -0,0 +1,197 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	api "smidgen-backend/src/api"
+	models "smidgen-backend/src/models"
+	utils "smidgen-backend/src/utils"
+	"time"
+)
+
+// TenantAPIService is a service that implements the logic for the TenantAPIServicer
+// Every endpoint is restricted to administrators of the default tenant, since tenants must not see each other.
+type TenantAPIService struct {
+}
+
+// NewTenantAPIService creates a default api service
+func NewTenantAPIService() api.TenantAPIServicer {
+	return &TenantAPIService{}
+}
+
+// AddTenant - Register a tenant and create its tables
+func (s *TenantAPIService) AddTenant(ctx context.Context, request models.TenantRequest) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "TenantAPIService.AddTenant")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	logEntry, err := newTenantLogEntry(ctx, "ADD_TENANT")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if !isOperator(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+
+	tenant, err := utils.CreateTenant(ctx, utils.Tenant{Slug: request.Slug, Name: request.Name, Hosts: request.Hosts})
+	if errors.Is(err, utils.ErrTenantConflict) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(409, nil), err
+	}
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
+	}
+
+	logEntry.Action = fmt.Sprintf("ADD_TENANT %s", tenant.Slug)
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(202, tenant), nil
+}
+
+// GetTenants - Get every tenant, including deleted ones when includeDeleted is set
+func (s *TenantAPIService) GetTenants(ctx context.Context, includeDeleted bool) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "TenantAPIService.GetTenants")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	logEntry, err := newTenantLogEntry(ctx, "GET_TENANTS")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if !isOperator(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+
+	tenants, err := utils.ListTenants(ctx, includeDeleted)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	if tenants == nil {
+		tenants = []utils.Tenant{}
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, tenants), nil
+}
+
+// GetTenantById - Get tenant
+func (s *TenantAPIService) GetTenantById(ctx context.Context, tenantId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "TenantAPIService.GetTenantById")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	logEntry, err := newTenantLogEntry(ctx, "GET_TENANT_BY_ID")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if !isOperator(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+
+	tenant, err := utils.GetTenant(ctx, tenantId)
+	if errors.Is(err, utils.ErrTenantNotFound) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(404, nil), err
+	}
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, tenant), nil
+}
+
+// DeleteTenant - Stop serving a tenant. Its tables are kept, but its hosts and tokens no longer resolve.
+func (s *TenantAPIService) DeleteTenant(ctx context.Context, tenantId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "TenantAPIService.DeleteTenant")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	logEntry, err := newTenantLogEntry(ctx, "DELETE_TENANT")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while deleting data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if !isOperator(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+
+	tenant, err := utils.DeleteTenant(ctx, tenantId)
+	if errors.Is(err, utils.ErrTenantNotFound) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(404, nil), err
+	}
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while deleting data")
+	}
+
+	logEntry.Action = fmt.Sprintf("DELETE_TENANT %s", tenant.Slug)
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, tenant), nil
+}
+
+// isOperator reports whether the request of ctx was made by an administrator of the default tenant, who
+// administers the server itself. Administrators of other tenants only administer their own tenant.
+func isOperator(ctx context.Context) bool {
+	return utils.IsAdmin(ctx) && utils.TenantFromContext(ctx).Slug == ""
+}
+
+func newTenantLogEntry(ctx context.Context, action string) (models.AuditLog, error) {
+	var uuid16 [2]byte
+	if _, err := rand.Read(uuid16[:]); err != nil {
+		return models.AuditLog{}, err
+	}
+	return models.AuditLog{
+		LogId:           int(binary.BigEndian.Uint16(uuid16[:])),
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          action,
+		Actor:           utils.ActorFromContext(ctx),
+	}, nil
+}
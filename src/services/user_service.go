@@ -43,15 +43,21 @@ var log = utils.Log()
 // This service should implement the business logic for every endpoint for the UserAPI API.
 // Include any external packages or services that will be required by this service.
 type UserAPIService struct {
+	accounts utils.AccountsConfig
+	mailer   utils.MailSender
 }
 
 // NewUserAPIService creates a default api service
-func NewUserAPIService() api.UserAPIServicer {
-	return &UserAPIService{}
+func NewUserAPIService(accounts utils.AccountsConfig, mailer utils.MailSender) api.UserAPIServicer {
+	return &UserAPIService{accounts: accounts, mailer: mailer}
 }
 
 // AddUser - Create user
 func (s *UserAPIService) AddUser(ctx context.Context, user models.User) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "UserAPIService.AddUser")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	privilege := "write"
 	var uuid16 [2]byte
 
@@ -67,29 +73,48 @@ func (s *UserAPIService) AddUser(ctx context.Context, user models.User) (utils.I
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "ADD_USER",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if user.Role != "" && !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), errRoleForbidden
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
 
-	err = dbConnection.InsertRow("users", user)
+	user.Status = utils.UserStatusActive
+	user.LastLoginAt, user.LastActivityAt = nil, nil
+	id, err := dbConnection.InsertRowReturningID("users", user)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Error(err)
 		return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
 	}
 
+	user.UserId = int32(id)
+	user.Version = 1
 	logEntry.ActionStatus = "SUCCESS"
 	logConnection.InsertRow("audit_log", logEntry)
-	return utils.Response(202, nil), nil
+	// Users log in with SSO when they have no email to receive an invitation at, and can be invited again later
+	if user.PrimaryEmail != "" {
+		if err := sendUserTokenMail(ctx, s.accounts, s.mailer, user, utils.TokenPurposeInvitation); err != nil {
+			log.Errorf("Failed to send an invitation to user %d: %v", user.UserId, err)
+		}
+	}
+	return utils.Response(202, user), nil
 }
 
 // DeleteUser - Delete user
-func (s *UserAPIService) DeleteUser(ctx context.Context, userId int32) (utils.ImplResponse, error) {
-	privilege := "delete"
+func (s *UserAPIService) DeleteUser(ctx context.Context, userId int32, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "UserAPIService.DeleteUser")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
 	var uuid16 [2]byte
 
 	_, err := rand.Read(uuid16[:])
@@ -104,18 +129,31 @@ func (s *UserAPIService) DeleteUser(ctx context.Context, userId int32) (utils.Im
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "DELETE_USER",
+		Actor:           utils.ActorFromContext(ctx),
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	version, err := utils.ResolveIfMatch(ctx, "users", "userId", userId, ifMatch)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while checking the version")
+	}
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
 
-	err = dbConnection.DeleteRow("users", "userId", userId)
+	err = dbConnection.SoftDeleteRow("users", "userId", userId, version, utils.ActorFromContext(ctx))
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Error: %v", err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
 		return utils.Response(404, nil), err
 	}
 	logEntry.ActionStatus = "SUCCESS"
@@ -124,7 +162,11 @@ func (s *UserAPIService) DeleteUser(ctx context.Context, userId int32) (utils.Im
 }
 
 // GetUsers - Get Users
-func (s *UserAPIService) GetUsers(ctx context.Context) (utils.ImplResponse, error) {
+func (s *UserAPIService) GetUsers(ctx context.Context, includeDeleted bool, businessUnitId int32, includeChildren bool) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "UserAPIService.GetUsers")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	// Add api_user_service.go to the .openapi-generator-ignore to avoid overwriting this service implementation when updating open api generation.
 	privilege := "read"
 	var uuid16 [2]byte
@@ -141,28 +183,37 @@ func (s *UserAPIService) GetUsers(ctx context.Context) (utils.ImplResponse, erro
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "GET_USER",
+		Actor:           utils.ActorFromContext(ctx),
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
 
 	var dest models.User
-	rows, err := dbConnection.GetRows("users", &dest)
-
+	if includeDeleted && !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+	options := []utils.QueryOption{utils.IncludeDeleted(includeDeleted)}
+	scope, result, err := businessUnitScope(ctx, businessUnitId, includeChildren)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
-		log.Errorf("Error: %v", err)
+		return result, err
 	}
-
-	if len(rows) == 0 {
+	if scope != nil {
+		options = append(options, utils.WhereIn("business_unit_id", scope...))
+	}
+	rows, err := dbConnection.GetRows("users", &dest, options...)
+	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
-		return utils.Response(404, nil), fmt.Errorf("no users were found in the database")
+		log.Errorf("Error: %v", err)
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
 	}
 
-	var users []models.User
+	users := []models.User{}
 	for _, row := range rows {
 		user, ok := row.(models.User)
 		if !ok {
@@ -180,6 +231,10 @@ func (s *UserAPIService) GetUsers(ctx context.Context) (utils.ImplResponse, erro
 
 // GetUserById - Get user
 func (s *UserAPIService) GetUserById(ctx context.Context, userId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "UserAPIService.GetUserById")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	privilege := "read"
 	var uuid16 [2]byte
 
@@ -195,9 +250,10 @@ func (s *UserAPIService) GetUserById(ctx context.Context, userId int32) (utils.I
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "GET_USER_BY_ID",
+		Actor:           utils.ActorFromContext(ctx),
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
@@ -221,11 +277,15 @@ func (s *UserAPIService) GetUserById(ctx context.Context, userId int32) (utils.I
 
 	logEntry.ActionStatus = "SUCCESS"
 	logConnection.InsertRow("audit_log", logEntry)
-	return utils.Response(200, user), nil
+	return utils.ResponseWithHeaders(200, utils.ETagHeaders(user.Version), user), nil
 }
 
 // UpdateUser - Update user
-func (s *UserAPIService) UpdateUser(ctx context.Context, userId int32, user models.User) (utils.ImplResponse, error) {
+func (s *UserAPIService) UpdateUser(ctx context.Context, userId int32, user models.User, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "UserAPIService.UpdateUser")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
 	privilege := "write"
 	var uuid16 [2]byte
 
@@ -241,17 +301,141 @@ func (s *UserAPIService) UpdateUser(ctx context.Context, userId int32, user mode
 		ActionTimestamp: time.Now(),
 		ActionStatus:    "Failed",
 		Action:          "UPDATE_USER",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	version, err := utils.ResolveIfMatch(ctx, "users", "userId", userId, ifMatch)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while checking the version")
 	}
-	logConnection, _ := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
-	dbConnection, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, privilege)
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
 	}
+	current, result, err := getCurrentUser(ctx, userId)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
+	}
+	if user.Role != current.Role && !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), errRoleForbidden
+	}
+	keepServerManagedFields(&user, current)
+	if version != 0 {
+		user.Version = version
+	}
 	err = dbConnection.UpdateRow("users", "userId", userId, user)
 	if err != nil {
 		logConnection.InsertRow("audit_log", logEntry)
 		log.Error(err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		return utils.Response(400, nil), err
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(202, nil), nil
+}
+
+// PatchUser - Partially update user
+func (s *UserAPIService) PatchUser(ctx context.Context, userId int32, patch utils.PatchDocument, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "UserAPIService.PatchUser")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          "PATCH_USER",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	version, err := utils.ResolveIfMatch(ctx, "users", "userId", userId, ifMatch)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while checking the version")
+	}
+	readConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as read: %v", err)
+	}
+
+	var dest models.User
+	row, err := readConnection.GetByID("users", "userId", userId, &dest)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Data Not Found: %v", err)
+		return utils.Response(404, nil), fmt.Errorf("the requested ID was not found")
+	}
+	current, ok := row.(models.User)
+	if !ok {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Warn("Warn: Unexpected type in row")
+		return utils.Response(500, nil), errors.New("unexpected type in row")
+	}
+	if version != 0 && version != current.Version {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(412, nil), utils.ErrVersionMismatch
+	}
+
+	var user models.User
+	columns, err := patch.Apply(current, &user)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(400, nil), err
+	}
+	keepServerManagedFields(&user, current)
+	if err := models.AssertUserRequired(user); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(422, nil), err
+	}
+	if err := models.AssertUserConstraints(user); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(422, nil), err
+	}
+	if user.Role != current.Role && !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), errRoleForbidden
+	}
+
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+	}
+
+	err = dbConnection.UpdateColumns("users", "userId", userId, user, columns)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
 		return utils.Response(400, nil), err
 	}
 
@@ -259,3 +443,340 @@ func (s *UserAPIService) UpdateUser(ctx context.Context, userId int32, user mode
 	logConnection.InsertRow("audit_log", logEntry)
 	return utils.Response(202, nil), nil
 }
+
+// RestoreUser - Restore deleted user
+func (s *UserAPIService) RestoreUser(ctx context.Context, userId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "UserAPIService.RestoreUser")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while restoring data")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          "RESTORE_USER",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+	}
+
+	err = dbConnection.RestoreRow("users", "userId", userId)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		return utils.Response(404, nil), fmt.Errorf("no deleted user with ID %d was found", userId)
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, nil), nil
+}
+
+// PurgeUser - Permanently delete user
+func (s *UserAPIService) PurgeUser(ctx context.Context, userId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "UserAPIService.PurgeUser")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "delete"
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while deleting data")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          "PURGE_USER",
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+
+	readConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as read: %v", err)
+	}
+	var dest models.User
+	row, err := readConnection.GetByID("users", "userId", userId, &dest, utils.IncludeDeleted(true))
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Data Not Found: %v", err)
+		return utils.Response(404, nil), fmt.Errorf("the requested ID was not found")
+	}
+	current, ok := row.(models.User)
+	if !ok {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Warn("Warn: Unexpected type in row")
+		return utils.Response(500, nil), errors.New("unexpected type in row")
+	}
+	if current.DeletedAt == nil || time.Since(*current.DeletedAt) < utils.SoftDeleteRetention {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(409, nil), fmt.Errorf("user can only be purged once it has been deleted for %s", utils.SoftDeleteRetention)
+	}
+
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+	}
+
+	// Sessions and linked identity provider accounts would otherwise keep the user from being deleted
+	if err := utils.DeleteUserLogins(ctx, userId); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		return utils.Response(500, nil), errors.New("an error has occurred while deleting data")
+	}
+	err = dbConnection.DeleteVersionedRow("users", "userId", userId, current.Version)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		return utils.Response(400, nil), err
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, nil), nil
+}
+
+// ResetUserMfa - Remove the second factor of a user, who must enroll again if required to
+func (s *UserAPIService) ResetUserMfa(ctx context.Context, userId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "UserAPIService.ResetUserMfa")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while deleting data")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          fmt.Sprintf("RESET_MFA user:%d", userId),
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+
+	readConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as read: %v", err)
+	}
+	var dest models.User
+	if _, err := readConnection.GetByID("users", "userId", userId, &dest, utils.IncludeDeleted(true)); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Data Not Found: %v", err)
+		return utils.Response(404, nil), fmt.Errorf("the requested ID was not found")
+	}
+
+	if err := utils.ResetMFA(ctx, userId); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		return utils.Response(500, nil), errors.New("an error has occurred while deleting data")
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, nil), nil
+}
+
+// keepServerManagedFields copies the fields of current that requests cannot set into user: the password,
+// lockout and status, and the times of the last login and activity.
+func keepServerManagedFields(user *models.User, current models.User) {
+	user.PasswordHash, user.PasswordSalt = current.PasswordHash, current.PasswordSalt
+	user.FailedLogins, user.LockedUntil = current.FailedLogins, current.LockedUntil
+	user.Status = current.Status
+	user.LastLoginAt, user.LastActivityAt = current.LastLoginAt, current.LastActivityAt
+}
+
+var errRoleForbidden = fmt.Errorf("%w: only administrators may grant or change roles", utils.ErrForbidden)
+
+// InviteUser - Email a user a new link to choose their password, ending the use of earlier links
+func (s *UserAPIService) InviteUser(ctx context.Context, userId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "UserAPIService.InviteUser")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while sending the invitation")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          fmt.Sprintf("INVITE_USER user:%d", userId),
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+
+	user, result, err := getCurrentUser(ctx, userId)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
+	}
+	if user.PrimaryEmail == "" {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(422, nil), errors.New("the user has no primary_email to send the invitation to")
+	}
+	if user.Status == utils.UserStatusDeactivated {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(409, nil), utils.ErrUserDeactivated
+	}
+	if err := sendUserTokenMail(ctx, s.accounts, s.mailer, user, utils.TokenPurposeInvitation); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to send an invitation to user %d: %v", userId, err)
+		return utils.Response(502, nil), errors.New("an error has occurred while sending the invitation")
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(202, nil), nil
+}
+
+// DeactivateUser - Refuse every login of a user, keeping their history. Users who still hold assigned equipment
+// are only deactivated with force.
+func (s *UserAPIService) DeactivateUser(ctx context.Context, userId int32, force bool) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "UserAPIService.DeactivateUser")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          fmt.Sprintf("DEACTIVATE_USER user:%d", userId),
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+
+	if _, result, err := getCurrentUser(ctx, userId); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
+	}
+	assignments, err := utils.CountUserAssignments(ctx, userId)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+	if assignments > 0 {
+		// Forced deactivations are flagged in the audit log, so the equipment can be recovered later
+		logEntry.Detail = fmt.Sprintf("holding %d assignments", assignments)
+		if !force {
+			logConnection.InsertRow("audit_log", logEntry)
+			return utils.Response(409, nil), fmt.Errorf("the user still holds assigned equipment (%d assignments), return it or deactivate with force=true", assignments)
+		}
+	}
+
+	if err := utils.DeactivateUser(ctx, userId); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, nil), nil
+}
+
+// ReactivateUser - Let a deactivated or locked user log in again
+func (s *UserAPIService) ReactivateUser(ctx context.Context, userId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "UserAPIService.ReactivateUser")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	var uuid16 [2]byte
+
+	_, err := rand.Read(uuid16[:])
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+
+	uuid := int(binary.BigEndian.Uint16(uuid16[:]))
+
+	logEntry := models.AuditLog{
+		LogId:           uuid,
+		ActionTimestamp: time.Now(),
+		ActionStatus:    "Failed",
+		Action:          fmt.Sprintf("REACTIVATE_USER user:%d", userId),
+		Actor:           utils.ActorFromContext(ctx),
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if !utils.IsAdmin(ctx) {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(403, nil), utils.ErrForbidden
+	}
+
+	if _, result, err := getCurrentUser(ctx, userId); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
+	}
+	if err := utils.ReactivateUser(ctx, userId); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, nil), nil
+}
@@ -0,0 +1,96 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	models "smidgen-backend/src/models"
+	utils "smidgen-backend/src/utils"
+)
+
+func TestDeactivateUserAuditLog(t *testing.T) {
+	useTestDatabase(t)
+	connection := func() *utils.DatabaseConnection {
+		dao, err := utils.NewDatabaseConnection(utils.DatabaseConfigPath, "write")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dao
+	}
+	if _, err := connection().InsertRowReturningID("business_units", models.BusinessUnit{Name: "Battalion"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := connection().InsertRowReturningID("manufacturers", models.Manufacturer{Name: "Acme", DateAdded: time.Now().UTC()}); err != nil {
+		t.Fatal(err)
+	}
+	equipment := models.Equipment{BusinessUnitId: 1, ManufacturerId: 1, Model: "M1", StatusId: 1, DateReceived: time.Now().UTC(), LastInventoried: time.Now().UTC()}
+	if _, err := connection().InsertRowReturningID("equipment", equipment); err != nil {
+		t.Fatal(err)
+	}
+	hash, salt, err := utils.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{BusinessUnitId: 1, Username: "jdoe", PasswordHash: hash, PasswordSalt: salt, Status: utils.UserStatusActive}
+	if _, err := connection().InsertRowReturningID("users", user); err != nil {
+		t.Fatal(err)
+	}
+	assignment := models.EquipmentAssignment{UserId: 1, EquipmentId: 1, DateOfAssignment: time.Now().UTC()}
+	if _, err := connection().InsertRowReturningID("equipment_assignment", assignment); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := utils.WithPrincipal(context.Background(), utils.Principal{Subject: "service:ops", Role: utils.RoleAdmin})
+	service := NewUserAPIService(utils.AccountsConfig{}, nil)
+	if result, err := service.DeactivateUser(ctx, 1, true); result.Code != 200 {
+		t.Fatalf("DeactivateUser() = %d, %v, want 200", result.Code, err)
+	}
+
+	// The action stays a plain code, the assignments the user held are its detail
+	var dest models.AuditLog
+	rows, err := connection().GetRows("audit_log", &dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, row := range rows {
+		entry := row.(models.AuditLog)
+		if !strings.HasPrefix(entry.Action, "DEACTIVATE_USER") {
+			continue
+		}
+		found = true
+		if entry.Action != "DEACTIVATE_USER user:1" || entry.Detail != "holding 1 assignments" {
+			t.Errorf("audit log entry = %q with detail %q, want %q with detail %q", entry.Action, entry.Detail, "DEACTIVATE_USER user:1", "holding 1 assignments")
+		}
+	}
+	if !found {
+		t.Error("DeactivateUser() wrote no audit log entry")
+	}
+}
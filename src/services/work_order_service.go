@@ -0,0 +1,289 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	api "smidgen-backend/src/api"
+	models "smidgen-backend/src/models"
+	utils "smidgen-backend/src/utils"
+	"time"
+)
+
+// WorkOrderAPIService is a service that implements the logic for the WorkOrderAPIServicer
+// Work orders move from open to in_progress to completed, taking their equipment in and out of repair.
+type WorkOrderAPIService struct {
+	config utils.MaintenanceConfig
+}
+
+// NewWorkOrderAPIService creates a default api service
+func NewWorkOrderAPIService(config utils.MaintenanceConfig) api.WorkOrderAPIServicer {
+	return &WorkOrderAPIService{config: config}
+}
+
+// AddWorkOrder - Open a work order
+func (s *WorkOrderAPIService) AddWorkOrder(ctx context.Context, workOrder models.WorkOrder) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "WorkOrderAPIService.AddWorkOrder")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
+	logEntry, err := newMaintenanceLogEntry(ctx, "ADD_WORK_ORDER")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+		return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
+	}
+
+	// Work orders always start open, the transitions set the rest
+	workOrder.Status = utils.WorkOrderStatusOpen
+	workOrder.OpenedAt = time.Now().UTC()
+	workOrder.StartedAt = nil
+	workOrder.CompletedAt = nil
+	id, err := dbConnection.InsertRowReturningID("work_orders", workOrder)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrForeignKeyViolation) {
+			return utils.Response(422, nil), errors.New("the equipment or plan of the work order does not exist")
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while adding new data")
+	}
+
+	workOrder.WorkOrderId = int32(id)
+	workOrder.Version = 1
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(202, workOrder), nil
+}
+
+// GetWorkOrders - Get work orders, optionally only those with a status or for a piece of equipment
+func (s *WorkOrderAPIService) GetWorkOrders(ctx context.Context, status string, equipmentId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "WorkOrderAPIService.GetWorkOrders")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	logEntry, err := newMaintenanceLogEntry(ctx, "GET_WORK_ORDERS")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	switch status {
+	case "", utils.WorkOrderStatusOpen, utils.WorkOrderStatusInProgress, utils.WorkOrderStatusCompleted:
+	default:
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(400, nil), fmt.Errorf("status must be %q, %q or %q, got %q",
+			utils.WorkOrderStatusOpen, utils.WorkOrderStatusInProgress, utils.WorkOrderStatusCompleted, status)
+	}
+
+	var options []utils.QueryOption
+	if equipmentId != 0 {
+		options = append(options, utils.WhereIn("equipment_id", equipmentId))
+	}
+	workOrders, err := readRows[models.WorkOrder](ctx, "work_orders", options...)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Error: %v", err)
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	if status != "" {
+		filtered := []models.WorkOrder{}
+		for _, workOrder := range workOrders {
+			if workOrder.Status == status {
+				filtered = append(filtered, workOrder)
+			}
+		}
+		workOrders = filtered
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(200, workOrders), nil
+}
+
+// GetWorkOrderById - Get work order
+func (s *WorkOrderAPIService) GetWorkOrderById(ctx context.Context, workOrderId int32) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "WorkOrderAPIService.GetWorkOrderById")
+	defer span.End()
+
+	logEntry, err := newMaintenanceLogEntry(ctx, "GET_WORK_ORDER_BY_ID")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	workOrder, result, err := getWorkOrder(ctx, workOrderId)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.ResponseWithHeaders(200, utils.ETagHeaders(workOrder.Version), workOrder), nil
+}
+
+// UpdateWorkOrder - Update the due date, technician, parts, labor hours and notes of a work order that is not completed
+func (s *WorkOrderAPIService) UpdateWorkOrder(ctx context.Context, workOrderId int32, workOrder models.WorkOrder, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "WorkOrderAPIService.UpdateWorkOrder")
+	defer span.End()
+	log := utils.LoggerFromContext(ctx)
+
+	privilege := "write"
+	logEntry, err := newMaintenanceLogEntry(ctx, "UPDATE_WORK_ORDER")
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	version, err := utils.ResolveIfMatch(ctx, "work_orders", "workOrderId", workOrderId, ifMatch)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while checking the version")
+	}
+	current, result, err := getWorkOrder(ctx, workOrderId)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
+	}
+	if current.Status == utils.WorkOrderStatusCompleted {
+		logConnection.InsertRow("audit_log", logEntry)
+		return utils.Response(409, nil), errors.New("a completed work order cannot be updated")
+	}
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, privilege)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Errorf("Failed to establish database connection as %s: %v", privilege, err)
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+
+	// The equipment, plan, status and timestamps only change through the transitions
+	workOrder.Version = version
+	columns := []string{"due_date", "technician", "parts", "labor_hours", "notes"}
+	err = dbConnection.UpdateColumns("work_orders", "workOrderId", workOrderId, workOrder, columns)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		log.Error(err)
+		if errors.Is(err, utils.ErrVersionMismatch) {
+			return utils.Response(412, nil), err
+		}
+		return utils.Response(400, nil), err
+	}
+
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.Response(202, nil), nil
+}
+
+// StartWorkOrder - Start an open work order, putting its equipment in repair
+func (s *WorkOrderAPIService) StartWorkOrder(ctx context.Context, workOrderId int32, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "WorkOrderAPIService.StartWorkOrder")
+	defer span.End()
+	return s.transition(ctx, "START_WORK_ORDER", workOrderId, func() error {
+		version, err := utils.ResolveIfMatch(ctx, "work_orders", "workOrderId", workOrderId, ifMatch)
+		if err != nil {
+			return err
+		}
+		return utils.StartWorkOrder(ctx, workOrderId, version, s.config)
+	})
+}
+
+// CompleteWorkOrder - Complete a work order in progress, making its equipment available again once no other work
+// order on it is in progress
+func (s *WorkOrderAPIService) CompleteWorkOrder(ctx context.Context, workOrderId int32, ifMatch utils.IfMatch) (utils.ImplResponse, error) {
+	ctx, span := utils.StartSpan(ctx, "WorkOrderAPIService.CompleteWorkOrder")
+	defer span.End()
+	return s.transition(ctx, "COMPLETE_WORK_ORDER", workOrderId, func() error {
+		version, err := utils.ResolveIfMatch(ctx, "work_orders", "workOrderId", workOrderId, ifMatch)
+		if err != nil {
+			return err
+		}
+		return utils.CompleteWorkOrder(ctx, workOrderId, version, s.config)
+	})
+}
+
+// transition runs a status change of a work order and responds with the work order it leaves.
+func (s *WorkOrderAPIService) transition(ctx context.Context, action string, workOrderId int32, change func() error) (utils.ImplResponse, error) {
+	log := utils.LoggerFromContext(ctx)
+	logEntry, err := newMaintenanceLogEntry(ctx, action)
+	if err != nil {
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+	logConnection, _ := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "write")
+	if err := change(); err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		switch {
+		case errors.Is(err, utils.ErrWorkOrderNotFound):
+			return utils.Response(404, nil), err
+		case errors.Is(err, utils.ErrWorkOrderTransition):
+			return utils.Response(409, nil), err
+		case errors.Is(err, utils.ErrVersionMismatch):
+			return utils.Response(412, nil), err
+		}
+		log.Error(err)
+		return utils.Response(500, nil), errors.New("an error has occurred while updating data")
+	}
+
+	workOrder, result, err := getWorkOrder(ctx, workOrderId)
+	if err != nil {
+		logConnection.InsertRow("audit_log", logEntry)
+		return result, err
+	}
+	logEntry.ActionStatus = "SUCCESS"
+	logConnection.InsertRow("audit_log", logEntry)
+	return utils.ResponseWithHeaders(200, utils.ETagHeaders(workOrder.Version), workOrder), nil
+}
+
+// getWorkOrder reads the work order workOrderId, or returns the response to send when that fails.
+func getWorkOrder(ctx context.Context, workOrderId int32) (models.WorkOrder, utils.ImplResponse, error) {
+	log := utils.LoggerFromContext(ctx)
+	dbConnection, err := utils.NewDatabaseConnectionContext(ctx, utils.DatabaseConfigPath, "read")
+	if err != nil {
+		log.Errorf("Failed to establish database connection as read: %v", err)
+		return models.WorkOrder{}, utils.Response(500, nil), errors.New("an error has occurred while retrieving data")
+	}
+	var dest models.WorkOrder
+	row, err := dbConnection.GetByID("work_orders", "workOrderId", workOrderId, &dest)
+	if err != nil {
+		log.Errorf("Data Not Found: %v", err)
+		return models.WorkOrder{}, utils.Response(404, nil), fmt.Errorf("the requested ID was not found")
+	}
+	workOrder, ok := row.(models.WorkOrder)
+	if !ok {
+		log.Warn("Warn: Unexpected type in row")
+		return models.WorkOrder{}, utils.Response(500, nil), errors.New("unexpected type in row")
+	}
+	return workOrder, utils.ImplResponse{}, nil
+}
@@ -0,0 +1,328 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Purposes of the one-time tokens emailed to users.
+const (
+	TokenPurposeInvitation    = "invitation"
+	TokenPurposePasswordReset = "password_reset"
+	TokenPurposeMFAEnrollment = "mfa_enrollment"
+)
+
+// Statuses of users. Locked users are unlocked once their lockout is over, and deactivated users only by an
+// administrator.
+const (
+	UserStatusActive      = "active"
+	UserStatusLocked      = "locked"
+	UserStatusDeactivated = "deactivated"
+)
+
+// activityResolution is how stale the last activity of a user may get before a request updates it, so that
+// not every request writes to the database.
+const activityResolution = time.Minute
+
+var (
+	ErrInvalidUserToken = errors.New("the link is invalid, expired or has already been used")
+	ErrUserDeactivated  = errors.New("the account has been deactivated")
+)
+
+// CreateUserToken returns a one-time token for purpose that is valid for ttl. Creating a token invalidates the
+// unused tokens userId was sent for the same purpose, so only the latest email works.
+func CreateUserToken(ctx context.Context, userId int32, purpose string, ttl time.Duration) (string, time.Time, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer dao.Close()
+
+	table := dao.dialect.table("user_tokens")
+	p := dao.dialect.placeholder
+	now := time.Now().UTC()
+	expiresAt := now.Add(ttl)
+	tx, err := dao.begin()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE user_id=%s AND purpose=%s AND used_at IS NULL", table, p(1), p(2)), userId, purpose); err != nil {
+		tx.Rollback()
+		return "", time.Time{}, err
+	}
+	_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s (token_hash, user_id, purpose, created_at, expires_at) VALUES (%s, %s, %s, %s, %s)", table, p(1), p(2), p(3), p(4), p(5)),
+		HashAPIKeySecret(token), userId, purpose, now, expiresAt)
+	if err != nil {
+		tx.Rollback()
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, tx.Commit()
+}
+
+// LookUpUserToken returns the user token was created for and its purpose, without using it up.
+func LookUpUserToken(ctx context.Context, token string) (int32, string, error) {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "read")
+	if err != nil {
+		return 0, "", err
+	}
+	defer dao.Close()
+
+	var userId int32
+	var purpose string
+	var expiresAt time.Time
+	var usedAt sql.NullTime
+	err = dao.executor().QueryRow(fmt.Sprintf("SELECT user_id, purpose, expires_at, used_at FROM %s WHERE token_hash=%s", dao.dialect.table("user_tokens"), dao.dialect.placeholder(1)),
+		HashAPIKeySecret(token)).Scan(&userId, &purpose, &expiresAt, &usedAt)
+	if err == sql.ErrNoRows || (err == nil && (usedAt.Valid || time.Now().After(expiresAt))) {
+		return 0, "", ErrInvalidUserToken
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	return userId, purpose, nil
+}
+
+// SetPasswordWithToken uses up token, which LookUpUserToken found for userId, and makes passwordHash and
+// passwordSalt the password of userId in the same transaction: the account is unlocked and activated, and its
+// sessions are revoked. The token stays usable when the password cannot be set.
+func SetPasswordWithToken(ctx context.Context, token string, userId int32, passwordHash string, passwordSalt string) error {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return err
+	}
+	defer dao.Close()
+
+	p := dao.dialect.placeholder
+	now := time.Now().UTC()
+	tx, err := dao.begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	// Two requests racing with the same token must not both succeed
+	result, err := tx.Exec(fmt.Sprintf("UPDATE %s SET used_at=%s WHERE token_hash=%s AND user_id=%s AND used_at IS NULL AND expires_at>%s",
+		dao.dialect.table("user_tokens"), p(1), p(2), p(3), p(4)), now, HashAPIKeySecret(token), userId, now)
+	if err != nil {
+		return err
+	}
+	if used, _ := result.RowsAffected(); used != 1 {
+		err = ErrInvalidUserToken
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf("UPDATE %s SET password_hash=%s, password_salt=%s, failed_logins=0, locked_until=NULL, status=%s, version=version+1 WHERE user_id=%s",
+		dao.dialect.table("users"), p(1), p(2), p(3), p(4)), passwordHash, passwordSalt, UserStatusActive, userId)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(fmt.Sprintf("UPDATE %s SET revoked_at=%s WHERE user_id=%s AND revoked_at IS NULL", dao.dialect.table("sessions"), p(1), p(2)), now, userId); err != nil {
+		return err
+	}
+	err = tx.Commit()
+	return err
+}
+
+// UseUserToken uses up token, provided it was created for userId and purpose and is still valid.
+func UseUserToken(ctx context.Context, token string, userId int32, purpose string) error {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return err
+	}
+	defer dao.Close()
+
+	p := dao.dialect.placeholder
+	result, err := dao.executor().Exec(fmt.Sprintf("UPDATE %s SET used_at=%s WHERE token_hash=%s AND user_id=%s AND purpose=%s AND used_at IS NULL AND expires_at>%s",
+		dao.dialect.table("user_tokens"), p(1), p(2), p(3), p(4), p(5)), time.Now().UTC(), HashAPIKeySecret(token), userId, purpose, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if used, _ := result.RowsAffected(); used != 1 {
+		return ErrInvalidUserToken
+	}
+	return nil
+}
+
+// FindUserByUsername returns the ID of the user named username, deleted or not.
+func FindUserByUsername(ctx context.Context, username string) (int32, bool, error) {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "read")
+	if err != nil {
+		return 0, false, err
+	}
+	defer dao.Close()
+
+	var userId int32
+	err = dao.executor().QueryRow(fmt.Sprintf("SELECT user_id FROM %s WHERE username=%s", dao.dialect.table("users"), dao.dialect.placeholder(1)), username).
+		Scan(&userId)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return userId, true, nil
+}
+
+// RecordFailedLogin counts a wrong password for userId, locking the account for lockout once maxFailures
+// passwords in a row were wrong.
+func RecordFailedLogin(ctx context.Context, userId int32, maxFailures int, lockout time.Duration) error {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return err
+	}
+	defer dao.Close()
+
+	table := dao.dialect.table("users")
+	p := dao.dialect.placeholder
+	if _, err := dao.executor().Exec(fmt.Sprintf("UPDATE %s SET failed_logins=failed_logins+1 WHERE user_id=%s", table, p(1)), userId); err != nil {
+		return err
+	}
+	_, err = dao.executor().Exec(fmt.Sprintf("UPDATE %s SET failed_logins=0, locked_until=%s, status=CASE WHEN status=%s THEN %s ELSE status END WHERE user_id=%s AND failed_logins>=%s",
+		table, p(1), p(2), p(3), p(4), p(5)), time.Now().UTC().Add(lockout), UserStatusActive, UserStatusLocked, userId, maxFailures)
+	return err
+}
+
+// ResetFailedLogins clears the wrong passwords counted for userId and unlocks the account.
+func ResetFailedLogins(ctx context.Context, userId int32) error {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return err
+	}
+	defer dao.Close()
+
+	p := dao.dialect.placeholder
+	_, err = dao.executor().Exec(fmt.Sprintf("UPDATE %s SET failed_logins=0, locked_until=NULL, status=CASE WHEN status=%s THEN %s ELSE status END WHERE user_id=%s",
+		dao.dialect.table("users"), p(1), p(2), p(3)), UserStatusLocked, UserStatusActive, userId)
+	return err
+}
+
+// unlockExpiredLockouts makes the users whose lockout is over active again.
+func unlockExpiredLockouts(dao *DatabaseConnection) error {
+	p := dao.dialect.placeholder
+	_, err := dao.executor().Exec(fmt.Sprintf("UPDATE %s SET locked_until=NULL, status=%s WHERE status=%s AND locked_until<%s",
+		dao.dialect.table("users"), p(1), p(2), p(3)), UserStatusActive, UserStatusLocked, time.Now().UTC())
+	return err
+}
+
+// RecordLogin sets when userId last logged in.
+func RecordLogin(ctx context.Context, userId int32) error {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return err
+	}
+	defer dao.Close()
+
+	now := time.Now().UTC()
+	p := dao.dialect.placeholder
+	_, err = dao.executor().Exec(fmt.Sprintf("UPDATE %s SET last_login_at=%s, last_activity_at=%s WHERE user_id=%s", dao.dialect.table("users"), p(1), p(2), p(3)), now, now, userId)
+	return err
+}
+
+// recordActivity sets when userId last made a request, unless lastActivity is more recent than activityResolution.
+func recordActivity(ctx context.Context, userId int32, lastActivity sql.NullTime) error {
+	now := time.Now().UTC()
+	if lastActivity.Valid && now.Sub(lastActivity.Time) < activityResolution {
+		return nil
+	}
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return err
+	}
+	defer dao.Close()
+
+	p := dao.dialect.placeholder
+	_, err = dao.executor().Exec(fmt.Sprintf("UPDATE %s SET last_activity_at=%s WHERE user_id=%s", dao.dialect.table("users"), p(1), p(2)), now, userId)
+	return err
+}
+
+// DeactivateUser refuses every login of userId until ReactivateUser is called: their sessions are revoked and
+// the links of their emails stop working.
+func DeactivateUser(ctx context.Context, userId int32) error {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return err
+	}
+	defer dao.Close()
+
+	p := dao.dialect.placeholder
+	tx, err := dao.begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET status=%s, version=version+1 WHERE user_id=%s", dao.dialect.table("users"), p(1), p(2)), UserStatusDeactivated, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET revoked_at=%s WHERE user_id=%s AND revoked_at IS NULL", dao.dialect.table("sessions"), p(1), p(2)), time.Now().UTC(), userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE user_id=%s AND used_at IS NULL", dao.dialect.table("user_tokens"), p(1)), userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReactivateUser makes userId active again, whether they were deactivated or locked.
+func ReactivateUser(ctx context.Context, userId int32) error {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return err
+	}
+	defer dao.Close()
+
+	p := dao.dialect.placeholder
+	_, err = dao.executor().Exec(fmt.Sprintf("UPDATE %s SET status=%s, failed_logins=0, locked_until=NULL, version=version+1 WHERE user_id=%s",
+		dao.dialect.table("users"), p(1), p(2)), UserStatusActive, userId)
+	return err
+}
+
+// CountUserAssignments returns how many pieces of equipment are assigned to userId.
+func CountUserAssignments(ctx context.Context, userId int32) (int, error) {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "read")
+	if err != nil {
+		return 0, err
+	}
+	defer dao.Close()
+
+	var count int
+	err = dao.executor().QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE user_id=%s AND deleted_at IS NULL", dao.dialect.table("equipment_assignment"), dao.dialect.placeholder(1)), userId).
+		Scan(&count)
+	return count, err
+}
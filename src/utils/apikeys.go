@@ -0,0 +1,182 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// APIKeyScheme is the scheme of Authorization headers carrying an API key, as in "Authorization: ApiKey smk_1_...".
+	APIKeyScheme = "ApiKey"
+	apiKeyPrefix = "smk"
+	// apiKeyUsageInterval limits how often the last use of a key is written to the database.
+	apiKeyUsageInterval = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey = errors.New("the API key is invalid, expired or revoked")
+)
+
+// NewAPIKeySecret generates the secret part of a new API key, and the hash to store in its place.
+func NewAPIKeySecret() (secret string, hash string, err error) {
+	var random [32]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		return "", "", err
+	}
+	secret = base64.RawURLEncoding.EncodeToString(random[:])
+	return secret, HashAPIKeySecret(secret), nil
+}
+
+// HashAPIKeySecret returns the hash stored for secret. Secrets are random, so a plain SHA-256 is enough.
+func HashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// FormatAPIKey returns the key handed to clients, which carries the public ID of the key and its secret.
+func FormatAPIKey(keyId int32, secret string) string {
+	return fmt.Sprintf("%s_%d_%s", apiKeyPrefix, keyId, secret)
+}
+
+// APIKeySubject returns the subject of principals authenticated with the key keyId, which is the actor
+// recorded in the audit log for their actions.
+func APIKeySubject(keyId int32) string {
+	return fmt.Sprintf("apikey:%d", keyId)
+}
+
+// parseAPIKey returns the ID and secret of key, leaving out its tenant prefix, which Tenancy already checked.
+func parseAPIKey(key string) (int32, string, error) {
+	_, key = splitTenantToken(key)
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyPrefix || parts[2] == "" {
+		return 0, "", ErrInvalidAPIKey
+	}
+	keyId, err := strconv.ParseInt(parts[1], 10, 32)
+	if err != nil {
+		return 0, "", ErrInvalidAPIKey
+	}
+	return int32(keyId), parts[2], nil
+}
+
+// APIKeyAuthentication authenticates requests carrying an "Authorization: ApiKey" header as the service
+// account of the key, restricted to the scopes of the key. Unknown, expired and revoked keys are rejected
+// with 401. Requests without an API key are passed on unchanged, but principals with scopes, such as those
+// of batch operations, are still held to them.
+func APIKeyAuthentication() func(http.Handler) http.Handler {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if scheme, key, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, APIKeyScheme) {
+				principal, err := authenticateAPIKey(r, strings.TrimSpace(key))
+				if err != nil {
+					LoggerFromContext(ctx).Warnf("Rejected API key: %v", err)
+					w.Header().Set("WWW-Authenticate", APIKeyScheme)
+					DefaultErrorHandler(w, r, ErrInvalidAPIKey, &ImplResponse{Code: http.StatusUnauthorized})
+					return
+				}
+				ctx = WithPrincipal(ctx, principal)
+			}
+			if principal, ok := PrincipalFromContext(ctx); ok && !principal.Allows(r.Method) {
+				DefaultErrorHandler(w, r, fmt.Errorf("%w: the %s scope is required", ErrForbidden, ScopeForMethod(r.Method)), &ImplResponse{Code: http.StatusForbidden})
+				return
+			}
+			inner.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func authenticateAPIKey(r *http.Request, key string) (Principal, error) {
+	keyId, secret, err := parseAPIKey(key)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	dao, err := NewDatabaseConnectionContext(r.Context(), DatabaseConfigPath, "read")
+	if err != nil {
+		return Principal{}, err
+	}
+	defer dao.Close()
+
+	var serviceAccount, scopes, hash string
+	var expiresAt, revokedAt sql.NullTime
+	err = dao.executor().QueryRow(fmt.Sprintf("SELECT service_account, scopes, key_hash, expires_at, revoked_at FROM %s WHERE key_id=%s",
+		dao.dialect.table("api_keys"), dao.dialect.placeholder(1)), keyId).
+		Scan(&serviceAccount, &scopes, &hash, &expiresAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return Principal{}, fmt.Errorf("key %d does not exist", keyId)
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(HashAPIKeySecret(secret))) != 1 {
+		return Principal{}, fmt.Errorf("wrong secret for key %d", keyId)
+	}
+	if revokedAt.Valid {
+		return Principal{}, fmt.Errorf("key %d has been revoked", keyId)
+	}
+	if expiresAt.Valid && time.Now().After(expiresAt.Time) {
+		return Principal{}, fmt.Errorf("key %d has expired", keyId)
+	}
+
+	if err := recordAPIKeyUsage(r, keyId); err != nil {
+		LoggerFromContext(r.Context()).Warnf("failed to record usage of API key %d: %v", keyId, err)
+	}
+
+	principal := Principal{Subject: APIKeySubject(keyId), Role: RoleService, Scopes: strings.Fields(scopes)}
+	for _, scope := range principal.Scopes {
+		if scope == ScopeAdmin {
+			principal.Role = RoleAdmin
+		}
+	}
+	LoggerFromContext(r.Context()).Debugf("Authenticated API key %d of service account %s", keyId, serviceAccount)
+	return principal, nil
+}
+
+func recordAPIKeyUsage(r *http.Request, keyId int32) error {
+	dao, err := NewDatabaseConnectionContext(r.Context(), DatabaseConfigPath, "write")
+	if err != nil {
+		return err
+	}
+	defer dao.Close()
+
+	now := time.Now().UTC()
+	p := dao.dialect.placeholder
+	_, err = dao.executor().Exec(fmt.Sprintf("UPDATE %s SET last_used_at=%s WHERE key_id=%s AND (last_used_at IS NULL OR last_used_at<%s)",
+		dao.dialect.table("api_keys"), p(1), p(2), p(3)), now, keyId, now.Add(-apiKeyUsageInterval))
+	return err
+}
@@ -0,0 +1,106 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"fmt"
+)
+
+// BusinessUnitSubtree returns unitId and the ids of every business unit below it, however deeply nested.
+// Soft-deleted units are included, since they may be restored along with their place in the hierarchy.
+func BusinessUnitSubtree(ctx context.Context, unitId int32) ([]int32, error) {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "read")
+	if err != nil {
+		return nil, err
+	}
+	defer dao.Close()
+
+	// UNION rather than UNION ALL stops at units already visited, should the data ever hold a cycle
+	table := dao.dialect.table("business_units")
+	rows, err := dao.executor().Query(fmt.Sprintf(`WITH RECURSIVE subtree(business_unit_id) AS (
+		SELECT business_unit_id FROM %s WHERE business_unit_id=%s
+		UNION
+		SELECT b.business_unit_id FROM %s b JOIN subtree s ON b.parent_business_unit_id=s.business_unit_id
+	) SELECT business_unit_id FROM subtree`, table, dao.dialect.placeholder(1), table), unitId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// CountByBusinessUnit returns how many users, pieces of equipment and assignments of that equipment each
+// business unit holds, leaving out soft-deleted rows.
+func CountByBusinessUnit(ctx context.Context) (users map[int32]int, equipment map[int32]int, assignments map[int32]int, err error) {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "read")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer dao.Close()
+
+	queries := []string{
+		fmt.Sprintf("SELECT business_unit_id, COUNT(*) FROM %s WHERE deleted_at IS NULL GROUP BY business_unit_id", dao.dialect.table("users")),
+		fmt.Sprintf("SELECT business_unit_id, COUNT(*) FROM %s WHERE deleted_at IS NULL GROUP BY business_unit_id", dao.dialect.table("equipment")),
+		fmt.Sprintf("SELECT e.business_unit_id, COUNT(*) FROM %s a JOIN %s e ON e.equipment_id=a.equipment_id WHERE a.deleted_at IS NULL AND e.deleted_at IS NULL GROUP BY e.business_unit_id",
+			dao.dialect.table("equipment_assignment"), dao.dialect.table("equipment")),
+	}
+	counts := make([]map[int32]int, len(queries))
+	for i, query := range queries {
+		if counts[i], err = countByUnit(dao, query); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return counts[0], counts[1], counts[2], nil
+}
+
+func countByUnit(dao *DatabaseConnection, query string) (map[int32]int, error) {
+	rows, err := dao.executor().Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int32]int)
+	for rows.Next() {
+		var unitId int32
+		var count int
+		if err := rows.Scan(&unitId, &count); err != nil {
+			return nil, err
+		}
+		counts[unitId] = count
+	}
+	return counts, rows.Err()
+}
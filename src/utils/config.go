@@ -0,0 +1,155 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// EnvironmentPrefix prefixes every environment variable that overrides a configuration setting.
+	EnvironmentPrefix = "SMIDGEN"
+	redacted          = "REDACTED"
+)
+
+// ApplyEnvironmentOverrides replaces the settings of config, a pointer to a struct, with environment variables.
+// Variables are named after the yaml tags of the fields, upper-cased and joined to prefix with underscores,
+// so the password of the write credentials of the database config is SMIDGEN_DATABASE_WRITE_PASSWORD.
+func ApplyEnvironmentOverrides(prefix string, config interface{}) error {
+	return applyEnvironmentOverrides(prefix, reflect.ValueOf(config).Elem())
+}
+
+func applyEnvironmentOverrides(prefix string, v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := yamlName(field)
+		if name == "" || name == "-" {
+			continue
+		}
+		variable := prefix + "_" + strings.ToUpper(name)
+		if isInline(field) {
+			// The settings of inlined structs belong to the enclosing struct
+			variable = prefix
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnvironmentOverrides(variable, v.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := os.LookupEnv(variable)
+		if !ok {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.String:
+			v.Field(i).SetString(value)
+		case reflect.Bool:
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid value for %s: %v", variable, err)
+			}
+			v.Field(i).SetBool(parsed)
+		case reflect.Int, reflect.Int32, reflect.Int64:
+			parsed, err := strconv.ParseInt(value, 10, field.Type.Bits())
+			if err != nil {
+				return fmt.Errorf("invalid value for %s: %v", variable, err)
+			}
+			v.Field(i).SetInt(parsed)
+		}
+	}
+	return nil
+}
+
+// Redact blanks out the secrets of config, a pointer to a struct, so it can be shown to an operator.
+// Fields are considered secret when their yaml name mentions a password, secret, token or key. References to
+// secrets, such as env:NAME, are kept since they reveal where a secret is, not what it is.
+func Redact(config interface{}) {
+	redact(reflect.ValueOf(config).Elem())
+}
+
+func redact(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			redact(v.Field(i))
+		case reflect.String:
+			value := v.Field(i).String()
+			if isSecretSetting(yamlName(field)) && value != "" && !isSecretReference(value) {
+				v.Field(i).SetString(redacted)
+			}
+		case reflect.Map:
+			settings, ok := v.Field(i).Interface().(map[string]string)
+			if !ok || settings == nil {
+				continue
+			}
+			redactedSettings := make(map[string]string, len(settings))
+			for name, value := range settings {
+				if isSecretSetting(name) && !isSecretReference(value) {
+					value = redacted
+				}
+				redactedSettings[name] = value
+			}
+			v.Field(i).Set(reflect.ValueOf(redactedSettings))
+		}
+	}
+}
+
+func isSecretSetting(name string) bool {
+	for _, word := range []string{"password", "secret", "token", "key"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func isInline(field reflect.StructField) bool {
+	for _, option := range strings.Split(field.Tag.Get("yaml"), ",")[1:] {
+		if option == "inline" {
+			return true
+		}
+	}
+	return false
+}
+
+func yamlName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if name == "" {
+		return strings.ToLower(field.Name)
+	}
+	return name
+}
@@ -0,0 +1,167 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig is the cross-origin resource sharing policy of an environment.
+type CORSConfig struct {
+	// AllowedOrigins lists the origins browsers may call the API from, such as "https://app.example.com".
+	// "*" allows any origin, and "https://*.example.com" any subdomain of example.com. Defaults to "*".
+	AllowedOrigins []string `yaml:"allowed_origins"`
+	AllowedMethods []string `yaml:"allowed_methods"`
+	// AllowedHeaders are the request headers browsers may send.
+	AllowedHeaders []string `yaml:"allowed_headers"`
+	// ExposedHeaders are the response headers scripts may read.
+	ExposedHeaders []string `yaml:"exposed_headers"`
+	// AllowCredentials lets browsers send cookies and client certificates. It cannot be used with the "*" origin.
+	AllowCredentials bool `yaml:"allow_credentials"`
+	// MaxAge is how long browsers may cache the result of a preflight request, e.g. "10m".
+	MaxAge string `yaml:"max_age"`
+}
+
+var (
+	defaultCORSOrigins = []string{"*"}
+	defaultCORSMethods = []string{"GET", "POST", "OPTIONS", "PUT", "PATCH", "DELETE"}
+	defaultCORSHeaders = []string{"Authorization", "Content-Type", "If-Match", "If-None-Match", IdempotencyKeyHeader, RequestIDHeader}
+	defaultCORSExposed = []string{"ETag", RequestIDHeader, "Idempotent-Replayed", "Retry-After", "RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"}
+)
+
+// ApplyCORSDefaults fills in every setting the policy leaves out with the policy the server always had.
+func ApplyCORSDefaults(c *CORSConfig) {
+	defaults := map[*[]string][]string{
+		&c.AllowedOrigins: defaultCORSOrigins,
+		&c.AllowedMethods: defaultCORSMethods,
+		&c.AllowedHeaders: defaultCORSHeaders,
+		&c.ExposedHeaders: defaultCORSExposed,
+	}
+	for setting, value := range defaults {
+		if *setting == nil {
+			*setting = value
+		}
+	}
+}
+
+// Validate checks the policy for settings browsers would reject.
+func (c CORSConfig) Validate() error {
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				return errors.New("cors.allow_credentials cannot be used with the \"*\" origin")
+			}
+			continue
+		}
+		if strings.Count(origin, "*") > 1 || !strings.Contains(origin, "://") {
+			return fmt.Errorf("invalid cors origin %q, expected a scheme and host such as https://*.example.com", origin)
+		}
+	}
+	if c.MaxAge != "" {
+		if _, err := time.ParseDuration(c.MaxAge); err != nil {
+			return fmt.Errorf("cors.max_age must be a duration such as \"10m\": %v", err)
+		}
+	}
+	return nil
+}
+
+// allowsOrigin reports whether origin matches one of the allowed origins.
+func (c CORSConfig) allowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range c.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		prefix, suffix, wildcard := strings.Cut(allowed, "*")
+		if wildcard && len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			if subdomain := origin[len(prefix) : len(origin)-len(suffix)]; !strings.ContainsAny(subdomain, "/:") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CORS applies the policy of config to every request, answering preflight requests itself.
+func CORS(config CORSConfig) func(http.Handler) http.Handler {
+	ApplyCORSDefaults(&config)
+	anyOrigin := len(config.AllowedOrigins) == 1 && config.AllowedOrigins[0] == "*"
+	methods := strings.Join(config.AllowedMethods, ", ")
+	headers := strings.Join(config.AllowedHeaders, ", ")
+	exposed := strings.Join(config.ExposedHeaders, ", ")
+	var maxAge string
+	if duration, err := time.ParseDuration(config.MaxAge); err == nil {
+		maxAge = strconv.Itoa(int(duration.Seconds()))
+	}
+
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			wHeader := w.Header()
+			if !anyOrigin {
+				wHeader.Add("Vary", "Origin")
+			}
+			if origin == "" || !config.allowsOrigin(origin) {
+				if preflight {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			if anyOrigin {
+				wHeader.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				wHeader.Set("Access-Control-Allow-Origin", origin)
+			}
+			if config.AllowCredentials {
+				wHeader.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if !preflight {
+				if exposed != "" {
+					wHeader.Set("Access-Control-Expose-Headers", exposed)
+				}
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			wHeader.Set("Access-Control-Allow-Methods", methods)
+			wHeader.Set("Access-Control-Allow-Headers", headers)
+			if maxAge != "" {
+				wHeader.Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
@@ -0,0 +1,192 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// auditStatusRolledBack marks the audit entries of operations that succeeded in a batch that was then rolled back.
+const auditStatusRolledBack = "ROLLED_BACK"
+
+// Database privileges, ranked by what their credentials cover. A batch runs with a single set of credentials,
+// so those of a higher rank must be allowed to do whatever the lower ones do.
+var privilegeRanks = map[string]int{"read": 1, "write": 2, "delete": 3, "admin": 4}
+
+var ErrBatchPrivilege = errors.New("the operation requires database privileges the batch does not hold")
+
+// BatchConfig bounds the batches clients may send.
+type BatchConfig struct {
+	// MaxOperations is the largest number of operations accepted in a single batch.
+	MaxOperations int `yaml:"max_operations"`
+}
+
+// ApplyBatchDefaults fills in every setting the config leaves out.
+func ApplyBatchDefaults(c *BatchConfig) {
+	if c.MaxOperations == 0 {
+		c.MaxOperations = 100
+	}
+}
+
+// Validate checks the config for settings that cannot be applied.
+func (c BatchConfig) Validate() error {
+	if c.MaxOperations < 1 {
+		return fmt.Errorf("batch.max_operations must be positive, got %d", c.MaxOperations)
+	}
+	return nil
+}
+
+type batchContextKey struct{}
+
+// Batch is a database transaction shared by every service call made with its context.
+type Batch struct {
+	dao        *DatabaseConnection
+	configPath string
+	privilege  string
+	tenant     string
+
+	mu sync.Mutex
+	// auditEntries are written once the batch ends, so that they are kept when it is rolled back.
+	auditEntries []interface{}
+}
+
+// BatchPrivilege returns the least privileged database credentials covering requests made with every one of
+// methods: read for GET, write for other changes, and delete as soon as one of them is a DELETE, as deletes may purge.
+func BatchPrivilege(methods ...string) string {
+	privilege := "read"
+	for _, method := range methods {
+		if required := ScopeForMethod(method); privilegeRanks[required] > privilegeRanks[privilege] {
+			privilege = required
+		}
+	}
+	return privilege
+}
+
+// BeginBatch starts a transaction that connections opened with NewDatabaseConnectionContext from the
+// returned context join, so the statements of several service calls commit or roll back together.
+// A single transaction cannot switch roles, so the batch runs with the credentials of privilege, see
+// BatchPrivilege, and the statements of connections asking for more fail with ErrBatchPrivilege.
+func BeginBatch(ctx context.Context, configPath string, privilege string) (context.Context, *Batch, error) {
+	if _, ok := ctx.Value(batchContextKey{}).(*Batch); ok {
+		return nil, nil, errors.New("batches cannot be nested")
+	}
+	tenant := TenantFromContext(ctx).Slug
+	dao, err := newTenantDatabaseConnection(configPath, privilege, tenant)
+	if err != nil {
+		return nil, nil, err
+	}
+	tx, err := dao.db.Begin()
+	if err != nil {
+		dao.Close()
+		return nil, nil, err
+	}
+	dao.tx = tx
+	dao.ctx = context.WithoutCancel(ctx)
+	batch := &Batch{dao: dao, configPath: configPath, privilege: privilege, tenant: tenant}
+	return context.WithValue(ctx, batchContextKey{}, batch), batch, nil
+}
+
+// Commit makes the changes of the batch permanent and releases its connection.
+func (b *Batch) Commit() error {
+	defer b.dao.Close()
+	err := b.dao.tx.Commit()
+	b.writeAuditEntries(err != nil)
+	return err
+}
+
+// Rollback discards the changes of the batch and releases its connection. The audit entries of its
+// operations are still written, those of the operations that succeeded marked as rolled back.
+func (b *Batch) Rollback() error {
+	defer b.dao.Close()
+	err := b.dao.tx.Rollback()
+	b.writeAuditEntries(true)
+	return err
+}
+
+// allows reports whether the credentials of the batch cover privilege.
+func (b *Batch) allows(privilege string) bool {
+	return privilegeRanks[privilege] <= privilegeRanks[b.privilege]
+}
+
+func (b *Batch) deferAuditEntry(entry interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.auditEntries = append(b.auditEntries, entry)
+}
+
+// writeAuditEntries writes the audit entries of the operations of the batch outside of its transaction.
+func (b *Batch) writeAuditEntries(rolledBack bool) {
+	b.mu.Lock()
+	entries := b.auditEntries
+	b.auditEntries = nil
+	b.mu.Unlock()
+
+	for _, entry := range entries {
+		if rolledBack {
+			entry = markRolledBack(entry)
+		}
+		dao, err := newTenantDatabaseConnection(b.configPath, "write", b.tenant)
+		if err != nil {
+			auditInsertFailures.Inc()
+			log.Errorf("failed to write audit log entry: %v", err)
+			continue
+		}
+		dao.ctx = b.dao.ctx
+		dao.InsertRow("audit_log", entry)
+	}
+}
+
+// markRolledBack returns a copy of the audit entry with the status of a successful action replaced by
+// auditStatusRolledBack.
+func markRolledBack(entry interface{}) interface{} {
+	v := reflect.New(reflect.TypeOf(entry)).Elem()
+	v.Set(reflect.ValueOf(entry))
+	if status := v.FieldByName("ActionStatus"); status.IsValid() && status.Kind() == reflect.String && status.String() == "SUCCESS" {
+		status.SetString(auditStatusRolledBack)
+	}
+	return v.Interface()
+}
+
+// NewDatabaseConnectionContext is like NewDatabaseConnection, but when ctx belongs to a batch the
+// returned connection joins the batch transaction instead of opening a new one. Either way, its
+// statements are traced as part of ctx.
+func NewDatabaseConnectionContext(ctx context.Context, configPath string, privilege string) (*DatabaseConnection, error) {
+	if batch, ok := ctx.Value(batchContextKey{}).(*Batch); ok {
+		return &DatabaseConnection{ctx: context.WithoutCancel(ctx), tx: batch.dao.tx, batch: batch, privilege: privilege, dialect: batch.dao.dialect}, nil
+	}
+	// Every query of a request is scoped to its tenant by connecting to the tables of the tenant
+	dao, err := newTenantDatabaseConnection(configPath, privilege, TenantFromContext(ctx).Slug)
+	if err != nil {
+		return nil, err
+	}
+	// Statements are traced as part of ctx, but are not cut short when the request is cancelled
+	dao.ctx = context.WithoutCancel(ctx)
+	return dao, nil
+}
@@ -26,31 +26,64 @@
 package smidgen
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	_ "github.com/lib/pq"
 	"gopkg.in/yaml.v2"
+	_ "modernc.org/sqlite"
 )
 
 type DatabaseConnection struct {
-	db        *sql.DB
+	// ctx is the context of the request the connection serves. Statements are traced as part of it.
+	ctx context.Context
+	db  *sql.DB
+	tx  *sql.Tx
+	// batch is the batch whose transaction tx is, if any.
+	batch     *Batch
 	privilege string
+	dialect   databaseDialect
 	mu        sync.Mutex
+	// closed is set once the connection is released. Its statements fail from then on, see ErrConnectionClosed.
+	closed bool
+}
+
+// ErrConnectionClosed is returned by the statements of a connection after it has been closed.
+var ErrConnectionClosed = errors.New("the database connection has been closed")
+
+// sqlExecutor is the part of *sql.DB and *sql.Tx the facade runs its statements on.
+type sqlExecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
 type databaseConfig struct {
-	Admin  databaseCredentials `yaml:"admin"`
-	Read   databaseCredentials `yaml:"read"`
-	Write  databaseCredentials `yaml:"write"`
-	Delete databaseCredentials `yaml:"delete"`
+	Driver  string              `yaml:"driver"`
+	SQLite  sqliteConfig        `yaml:"sqlite"`
+	SSLMode string              `yaml:"sslmode"`
+	Options map[string]string   `yaml:"options"`
+	Secrets secretsConfig       `yaml:"secrets"`
+	Pool    poolConfig          `yaml:"pool"`
+	Admin   databaseCredentials `yaml:"admin"`
+	Read    databaseCredentials `yaml:"read"`
+	Write   databaseCredentials `yaml:"write"`
+	Delete  databaseCredentials `yaml:"delete"`
 }
 
+// databaseCredentials may hold references to secrets instead of the values themselves, see secretResolver.
 type databaseCredentials struct {
 	Url      string `yaml:"url"`
 	Port     string `yaml:"port"`
@@ -59,11 +92,28 @@ type databaseCredentials struct {
 	Database string `yaml:"database"`
 }
 
+type sqliteConfig struct {
+	Path string `yaml:"path"`
+}
+
+// poolConfig sizes the connection pool of each set of credentials. It does not apply to SQLite,
+// which always uses a single connection.
+type poolConfig struct {
+	MaxOpenConns    int    `yaml:"max_open_conns"`
+	MaxIdleConns    int    `yaml:"max_idle_conns"`
+	ConnMaxLifetime string `yaml:"conn_max_lifetime"`
+}
+
 var log = Log()
 
 func NewDatabaseConnection(configPath string, privilege string) (*DatabaseConnection, error) {
-	instance := &DatabaseConnection{privilege: privilege}
-	initErr := instance.initialize(configPath, privilege)
+	return newTenantDatabaseConnection(configPath, privilege, "")
+}
+
+// newTenantDatabaseConnection opens a connection to the tables of tenant, which is "" for the default tenant.
+func newTenantDatabaseConnection(configPath string, privilege string, tenant string) (*DatabaseConnection, error) {
+	instance := &DatabaseConnection{ctx: context.Background(), privilege: privilege}
+	initErr := instance.initialize(configPath, privilege, tenant)
 	if initErr != nil {
 		log.Errorf("failed to initialize database connection: %v", initErr)
 		return nil, initErr
@@ -71,65 +121,276 @@ func NewDatabaseConnection(configPath string, privilege string) (*DatabaseConnec
 	return instance, nil
 }
 
-func (dao *DatabaseConnection) initialize(configPath string, privilege string) error {
-	yamlFilePath := configPath
-	yamlFile, err := os.Open(yamlFilePath)
+func (dao *DatabaseConnection) initialize(configPath string, privilege string, tenant string) error {
+	pool, err := databasePoolFor(configPath, privilege, tenant)
 	if err != nil {
-		log.Errorf("failed to initialize database connection: %v", err)
+		log.Errorf("\nfailed to open database connection: %v", err)
 		return err
 	}
+	dao.db = pool.db
+	dao.dialect = pool.dialect
+	return nil
+}
+
+// loadDatabaseConfig reads the database config at configPath, applies the SMIDGEN_DATABASE_* environment
+// overrides and validates the result.
+func loadDatabaseConfig(configPath string) (databaseConfig, error) {
+	var config databaseConfig
+
+	yamlFile, err := os.Open(configPath)
+	if err != nil {
+		return config, err
+	}
 	defer yamlFile.Close()
 
 	yamlData, err := io.ReadAll(yamlFile)
 	if err != nil {
-		log.Errorf("\nfailed to read YAML file: %v", err)
-		return err
+		return config, fmt.Errorf("failed to read YAML file: %v", err)
 	}
-	var config databaseConfig
-
 	if err := yaml.Unmarshal(yamlData, &config); err != nil {
-		log.Errorf("\nfailed to unmarshal YAML: %v", err)
+		return config, fmt.Errorf("failed to unmarshal YAML: %v", err)
+	}
+	if err := ApplyEnvironmentOverrides(EnvironmentPrefix+"_DATABASE", &config); err != nil {
+		return config, err
+	}
+	if config.Driver == "" {
+		config.Driver = DriverPostgres
+	}
+	if config.SSLMode == "" {
+		config.SSLMode = "disable"
+	}
+	if config.Pool.MaxOpenConns == 0 {
+		config.Pool.MaxOpenConns = 10
+	}
+	if config.Pool.MaxIdleConns == 0 {
+		config.Pool.MaxIdleConns = 5
+	}
+	if config.Pool.ConnMaxLifetime == "" {
+		config.Pool.ConnMaxLifetime = "30m"
+	}
+	return config, config.validate()
+}
+
+func (config databaseConfig) validate() error {
+	dialect, err := newDatabaseDialect(config.Driver)
+	if err != nil {
 		return err
 	}
-	log.Info("Successfully loaded database configurations.")
+	if config.Pool.MaxOpenConns < 0 || config.Pool.MaxIdleConns < 0 {
+		return fmt.Errorf("pool sizes cannot be negative")
+	}
+	if _, err := time.ParseDuration(config.Pool.ConnMaxLifetime); err != nil {
+		return fmt.Errorf("invalid pool.conn_max_lifetime: %v", err)
+	}
+	if dialect.driver == DriverSQLite {
+		if config.SQLite.Path == "" {
+			return fmt.Errorf("sqlite driver selected but no database path was configured")
+		}
+		return nil
+	}
+	switch config.SSLMode {
+	case "disable", "require", "verify-ca", "verify-full":
+	default:
+		return fmt.Errorf("invalid sslmode %q, expected disable, require, verify-ca or verify-full", config.SSLMode)
+	}
+	credentials := map[string]databaseCredentials{"admin": config.Admin, "read": config.Read, "write": config.Write, "delete": config.Delete}
+	for privilege, c := range credentials {
+		if c.Url == "" || c.User == "" || c.Database == "" {
+			return fmt.Errorf("%s credentials require url, user and database", privilege)
+		}
+		if isSecretReference(c.Port) {
+			continue
+		}
+		if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("%s credentials have an invalid port: %q", privilege, c.Port)
+		}
+	}
+	return nil
+}
+
+// LoadRedactedDatabaseConfig returns the effective database config at configPath with its secrets redacted.
+func LoadRedactedDatabaseConfig(configPath string) (interface{}, error) {
+	config, err := loadDatabaseConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	Redact(&config)
+	return config, nil
+}
 
+// postgresDataSource returns the connection string for the credentials configured for privilege.
+func postgresDataSource(config databaseConfig, privilege string) (string, error) {
 	field := reflect.ValueOf(&config).Elem().FieldByName(strings.ToUpper(privilege[:1]) + privilege[1:])
-	if !field.IsValid() {
-		log.Errorf("\ninvalid privilege level")
-		return err
+	if !field.IsValid() || field.Type() != reflect.TypeOf(databaseCredentials{}) {
+		return "", fmt.Errorf("invalid privilege level: %s", privilege)
 	}
 	log.Info(fmt.Sprintf("Successfully loaded %v connection configurations.", privilege))
 	connectionConfig := field.Interface().(databaseCredentials)
 
-	dataSourceName := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		connectionConfig.Url, connectionConfig.Port, connectionConfig.User, connectionConfig.Password, connectionConfig.Database)
+	resolver := &secretResolver{config: config.Secrets}
+	parameters := map[string]*string{
+		"host":     &connectionConfig.Url,
+		"port":     &connectionConfig.Port,
+		"user":     &connectionConfig.User,
+		"password": &connectionConfig.Password,
+		"dbname":   &connectionConfig.Database,
+	}
+	for name, setting := range parameters {
+		value, err := resolver.resolve(*setting)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve %s of the %s credentials: %v", name, privilege, err)
+		}
+		*setting = value
+	}
+
+	return postgresDataSourceName(connectionConfig, config.SSLMode, config.Options), nil
+}
+
+// postgresDataSourceName builds a key/value connection string, quoting values so passwords may contain
+// spaces and quotes. Options are added after the connection settings, which they cannot override.
+func postgresDataSourceName(c databaseCredentials, sslMode string, options map[string]string) string {
+	parameters := []string{
+		"host=" + quoteDataSourceValue(c.Url),
+		"port=" + quoteDataSourceValue(c.Port),
+		"user=" + quoteDataSourceValue(c.User),
+		"password=" + quoteDataSourceValue(c.Password),
+		"dbname=" + quoteDataSourceValue(c.Database),
+		"sslmode=" + quoteDataSourceValue(sslMode),
+	}
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch name {
+		case "host", "port", "user", "password", "dbname", "sslmode":
+			continue
+		}
+		parameters = append(parameters, name+"="+quoteDataSourceValue(options[name]))
+	}
+	return strings.Join(parameters, " ")
+}
+
+func quoteDataSourceValue(value string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(value, `\`, `\\`), `'`, `\'`) + "'"
+}
+
+// tenantSQLiteConfig returns the config of the database file of tenant, which sits next to the file of the
+// default tenant: tenant "acme" of smidgen.db is stored in smidgen-acme.db.
+func tenantSQLiteConfig(config sqliteConfig, tenant string) sqliteConfig {
+	extension := filepath.Ext(config.Path)
+	config.Path = strings.TrimSuffix(config.Path, extension) + "-" + tenant + extension
+	return config
+}
+
+// sqliteDataSource returns the connection string of the embedded database file. SQLite has no roles,
+// so every privilege level shares the same connection settings.
+func sqliteDataSource(config sqliteConfig) string {
+	return fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", config.Path)
+}
+
+// executor returns the batch transaction the connection has joined, or the database itself.
+func (dao *DatabaseConnection) executor() sqlExecutor {
+	if dao.batch != nil && !dao.batch.allows(dao.privilege) {
+		return dao.traced(batchPrivilegeDenied)
+	}
+	if dao.tx != nil {
+		return dao.traced(dao.tx)
+	}
+	if dao.isClosed() {
+		return dao.traced(closedDatabase)
+	}
+	return dao.traced(dao.db)
+}
 
-	db, err := sql.Open("postgres", dataSourceName)
+// closedDatabase runs the statements of closed connections.
+var closedDatabase = sql.OpenDB(failingConnector{err: ErrConnectionClosed})
+
+// batchPrivilegeDenied runs the statements of connections in a batch whose credentials do not cover them.
+var batchPrivilegeDenied = sql.OpenDB(failingConnector{err: ErrBatchPrivilege})
+
+// failingConnector fails to connect with err, so every statement run on a database opened with it fails with err.
+type failingConnector struct {
+	err error
+}
+
+func (c failingConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, c.err
+}
+
+func (c failingConnector) Driver() driver.Driver {
+	return c
+}
+
+func (c failingConnector) Open(string) (driver.Conn, error) {
+	return nil, c.err
+}
+
+func (dao *DatabaseConnection) traced(target tracedTarget) sqlExecutor {
+	return tracedExecutor{ctx: dao.ctx, exec: target, dialect: dao.dialect}
+}
+
+// begin starts a transaction for a single facade call. Inside a batch the batch transaction is
+// used instead, and committing or rolling it back is left to the batch.
+func (dao *DatabaseConnection) begin() (databaseTransaction, error) {
+	if dao.batch != nil && !dao.batch.allows(dao.privilege) {
+		return databaseTransaction{}, fmt.Errorf("%w: %s", ErrBatchPrivilege, dao.privilege)
+	}
+	if dao.tx != nil {
+		return databaseTransaction{sqlExecutor: dao.traced(dao.tx), tx: dao.tx, shared: true}, nil
+	}
+	if dao.isClosed() {
+		return databaseTransaction{}, ErrConnectionClosed
+	}
+	tx, err := dao.db.BeginTx(dao.ctx, nil)
 	if err != nil {
-		log.Errorf("\nfailed to open database connection: %v", err)
-		return err
+		return databaseTransaction{}, err
 	}
-	if err := db.Ping(); err != nil {
-		log.Errorf("\nfailed to ping database: %v", err)
-		return err
+	return databaseTransaction{sqlExecutor: dao.traced(tx), tx: tx}, nil
+}
+
+type databaseTransaction struct {
+	sqlExecutor
+	tx     *sql.Tx
+	shared bool
+}
+
+func (t databaseTransaction) Commit() error {
+	if t.shared {
+		return nil
 	}
-	dao.db = db
-	return nil
+	return t.tx.Commit()
+}
+
+func (t databaseTransaction) Rollback() error {
+	if t.shared {
+		return nil
+	}
+	return t.tx.Rollback()
 }
 
+// Close releases the connection. The pool it was taken from stays open for other connections until ClosePools.
+// Outside of a batch, later statements of the connection fail with ErrConnectionClosed.
 func (dao *DatabaseConnection) Close() error {
 	dao.mu.Lock()
 	defer dao.mu.Unlock()
-	if dao.db != nil {
-		if err := dao.db.Close(); err != nil {
-			log.Errorf("\nfailed to close database connection: %v", err)
-			return err
-		}
-		dao.db = nil
-	}
+	dao.closed = true
 	return nil
 }
 
+func (dao *DatabaseConnection) isClosed() bool {
+	dao.mu.Lock()
+	defer dao.mu.Unlock()
+	return dao.closed
+}
+
 func (dao *DatabaseConnection) Ping() error {
+	if dao.tx != nil {
+		return nil
+	}
+	if dao.isClosed() {
+		return ErrConnectionClosed
+	}
 	return dao.db.Ping()
 }
@@ -0,0 +1,57 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClosedConnection(t *testing.T) {
+	forEachTestDatabase(t, func(t *testing.T) {
+		dao := testConnection(t, "read")
+		var dest etagTestManufacturer
+		if _, err := dao.GetRows("manufacturers", &dest); err != nil {
+			t.Fatalf("GetRows() error = %v", err)
+		}
+
+		// The facade call closed the connection, so reusing it fails instead of panicking
+		if _, err := dao.GetRows("manufacturers", &dest); !errors.Is(err, ErrConnectionClosed) {
+			t.Errorf("GetRows() after Close error = %v, want %v", err, ErrConnectionClosed)
+		}
+		if err := dao.InsertRow("manufacturers", etagTestManufacturer{Name: "Acme"}); !errors.Is(err, ErrConnectionClosed) {
+			t.Errorf("InsertRow() after Close error = %v, want %v", err, ErrConnectionClosed)
+		}
+		if err := dao.Ping(); !errors.Is(err, ErrConnectionClosed) {
+			t.Errorf("Ping() after Close error = %v, want %v", err, ErrConnectionClosed)
+		}
+
+		// The pool itself stays open for other connections
+		if err := testConnection(t, "read").Ping(); err != nil {
+			t.Errorf("Ping() of a new connection error = %v", err)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// databaseDialect captures the differences between the database engines Smidgen can run on,
+// so the facade can build its queries without caring which one is behind the connection.
+type databaseDialect struct {
+	driver string
+	schema string
+}
+
+func newDatabaseDialect(driver string) (databaseDialect, error) {
+	switch strings.ToLower(driver) {
+	case "", DriverPostgres:
+		return databaseDialect{driver: DriverPostgres, schema: "smidgen"}, nil
+	case DriverSQLite:
+		return databaseDialect{driver: DriverSQLite}, nil
+	default:
+		return databaseDialect{}, fmt.Errorf("unsupported database driver: %s", driver)
+	}
+}
+
+// forTenant returns the dialect of the tables of tenant, which live in a schema of their own on PostgreSQL.
+// On SQLite, tenants have a database file of their own instead, see tenantSQLiteConfig.
+func (d databaseDialect) forTenant(tenant string) databaseDialect {
+	if tenant != "" && d.driver == DriverPostgres {
+		d.schema = d.schema + "_" + tenant
+	}
+	return d
+}
+
+// table returns the fully qualified name of tableName.
+func (d databaseDialect) table(tableName string) string {
+	return d.schemaPrefix() + tableName
+}
+
+// schemaPrefix returns the prefix that qualifies a table with the Smidgen schema, if the dialect has one.
+func (d databaseDialect) schemaPrefix() string {
+	if d.schema == "" {
+		return ""
+	}
+	return d.schema + "."
+}
+
+// placeholder returns the bind parameter for the n-th (1-based) argument of a query.
+func (d databaseDialect) placeholder(n int) string {
+	if d.driver == DriverSQLite {
+		return fmt.Sprintf("?%d", n)
+	}
+	return fmt.Sprintf("$%d", n)
+}
+
+// tablesQuery returns a query listing every base table of the Smidgen schema.
+func (d databaseDialect) tablesQuery() string {
+	if d.driver == DriverSQLite {
+		return `
+    SELECT name
+    FROM sqlite_master
+    WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+`
+	}
+	return fmt.Sprintf(`
+    SELECT table_name
+    FROM information_schema.tables
+    WHERE table_schema = '%s' AND table_type = 'BASE TABLE'
+`, d.schema)
+}
+
+// isForeignKeyViolation reports whether err was raised by a foreign key constraint.
+func (d databaseDialect) isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23503"
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+	}
+	return false
+}
+
+// isUniqueViolation reports whether err was raised by a primary key or unique constraint.
+func (d databaseDialect) isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+	}
+	return false
+}
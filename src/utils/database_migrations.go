@@ -0,0 +1,156 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+//go:embed migrations/control/*.sql
+var controlMigrationFiles embed.FS
+
+// MigrateDatabase applies every embedded migration that has not yet been recorded in the
+// schema_migrations table. Migrations are shared between dialects; the {{schema}} token in
+// each file is replaced with the schema prefix of the configured driver. The tables of every
+// tenant are migrated after those of the default tenant, see MigrateTenant.
+func MigrateDatabase(configPath string) error {
+	dao, err := NewDatabaseConnection(configPath, "admin")
+	if err != nil {
+		return fmt.Errorf("failed to open database connection for migrations: %v", err)
+	}
+	defer dao.Close()
+
+	if err := dao.migrate(migrationFiles, "migrations", "schema_migrations"); err != nil {
+		return err
+	}
+	// The registry of tenants only lives next to the tables of the default tenant
+	if err := dao.migrate(controlMigrationFiles, "migrations/control", "control_migrations"); err != nil {
+		return err
+	}
+
+	tenants, err := dao.listTenants(false)
+	if err != nil {
+		return err
+	}
+	for _, tenant := range tenants {
+		if err := MigrateTenant(configPath, tenant.Slug); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateTenant applies every embedded migration to the tables of tenant, creating them when the tenant is new.
+func MigrateTenant(configPath string, tenant string) error {
+	dao, err := newTenantDatabaseConnection(configPath, "admin", tenant)
+	if err != nil {
+		return fmt.Errorf("failed to open database connection for migrations of tenant %s: %v", tenant, err)
+	}
+	defer dao.Close()
+
+	return dao.migrate(migrationFiles, "migrations", "schema_migrations")
+}
+
+// migrate applies the migrations of dir in files that are not yet recorded in versionsTable.
+func (dao *DatabaseConnection) migrate(files embed.FS, dir string, versionsTable string) error {
+	if dao.dialect.schema != "" {
+		if _, err := dao.db.Exec("CREATE SCHEMA IF NOT EXISTS " + dao.dialect.schema); err != nil {
+			return fmt.Errorf("failed to create schema %s: %v", dao.dialect.schema, err)
+		}
+	}
+
+	migrationsTable := dao.dialect.table(versionsTable)
+	if _, err := dao.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    version    TEXT      PRIMARY KEY,
+    applied_at TIMESTAMP NOT NULL
+)`, migrationsTable)); err != nil {
+		return fmt.Errorf("failed to create %s: %v", migrationsTable, err)
+	}
+
+	applied := make(map[string]bool)
+	rows, err := dao.db.Query("SELECT version FROM " + migrationsTable)
+	if err != nil {
+		return fmt.Errorf("failed to read applied migrations: %v", err)
+	}
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+
+	scripts, err := fs.Glob(files, dir+"/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(scripts)
+
+	for _, file := range scripts {
+		version := strings.TrimSuffix(strings.TrimPrefix(file, dir+"/"), ".sql")
+		if applied[version] {
+			continue
+		}
+
+		script, err := files.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := dao.applyMigration(migrationsTable, version, string(script)); err != nil {
+			return fmt.Errorf("failed to apply migration %s: %v", version, err)
+		}
+		log.Infof("Applied database migration %s to %s", version, migrationsTable)
+	}
+	return nil
+}
+
+func (dao *DatabaseConnection) applyMigration(migrationsTable string, version string, script string) error {
+	tx, err := dao.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(strings.ReplaceAll(script, "{{schema}}", dao.dialect.schemaPrefix())); err != nil {
+		return err
+	}
+	query := fmt.Sprintf("INSERT INTO %s (version, applied_at) VALUES (%s, %s)",
+		migrationsTable, dao.dialect.placeholder(1), dao.dialect.placeholder(2))
+	if _, err := tx.Exec(query, version, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
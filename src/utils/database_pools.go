@@ -0,0 +1,135 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// databasePool is a *sql.DB shared by every connection opened with the same configuration and privilege.
+type databasePool struct {
+	db        *sql.DB
+	dialect   databaseDialect
+	collector prometheus.Collector
+}
+
+var (
+	poolsMu sync.Mutex
+	// pools finds the pool of a configuration file and privilege without reading the file again.
+	pools = make(map[string]*databasePool)
+	// poolsByDataSource shares a pool between privileges that connect with the same settings, as they
+	// all do with SQLite.
+	poolsByDataSource = make(map[string]*databasePool)
+)
+
+// databasePoolFor returns the pool of privilege for the tables of tenant, which is "" for the default tenant.
+// On PostgreSQL, tenants share the pools of the database and only differ by schema.
+func databasePoolFor(configPath string, privilege string, tenant string) (*databasePool, error) {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+
+	key := configPath + "\x00" + privilege + "\x00" + tenant
+	if pool, ok := pools[key]; ok {
+		return pool, nil
+	}
+
+	config, err := loadDatabaseConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Successfully loaded database configurations.")
+	dialect, err := newDatabaseDialect(config.Driver)
+	if err != nil {
+		return nil, err
+	}
+	dialect = dialect.forTenant(tenant)
+	if tenant != "" && dialect.driver == DriverSQLite {
+		config.SQLite = tenantSQLiteConfig(config.SQLite, tenant)
+	}
+
+	var dataSourceName string
+	if dialect.driver == DriverSQLite {
+		dataSourceName = sqliteDataSource(config.SQLite)
+	} else if dataSourceName, err = postgresDataSource(config, privilege); err != nil {
+		return nil, err
+	}
+
+	dataSourceKey := dialect.driver + "\x00" + dataSourceName
+	pool, ok := poolsByDataSource[dataSourceKey]
+	if !ok {
+		db, err := sql.Open(dialect.driver, dataSourceName)
+		if err != nil {
+			return nil, err
+		}
+		if dialect.driver == DriverSQLite {
+			db.SetMaxOpenConns(1)
+		} else {
+			lifetime, _ := time.ParseDuration(config.Pool.ConnMaxLifetime)
+			db.SetMaxOpenConns(config.Pool.MaxOpenConns)
+			db.SetMaxIdleConns(config.Pool.MaxIdleConns)
+			db.SetConnMaxLifetime(lifetime)
+		}
+		if err := db.Ping(); err != nil {
+			db.Close()
+			return nil, err
+		}
+		pool = &databasePool{db: db, dialect: dialect}
+		poolsByDataSource[dataSourceKey] = pool
+		poolName := privilege
+		if dialect.driver == DriverSQLite {
+			poolName = DriverSQLite
+		}
+		registerPoolMetrics(pool, poolName)
+	}
+	if pool.dialect != dialect {
+		pool = &databasePool{db: pool.db, dialect: dialect}
+	}
+	pools[key] = pool
+	return pool, nil
+}
+
+// ClosePools closes every database pool, waiting for the queries in progress to finish.
+// Connections opened afterwards open new pools.
+func ClosePools() error {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+
+	var errs []error
+	for _, pool := range poolsByDataSource {
+		unregisterPoolMetrics(pool)
+		if err := pool.db.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	pools = make(map[string]*databasePool)
+	poolsByDataSource = make(map[string]*databasePool)
+	return errors.Join(errs...)
+}
@@ -0,0 +1,94 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testPostgresConfigEnv names the database configuration of a PostgreSQL server that tests run against as well as
+// SQLite, such as configs/db_conn.yaml. Tests against PostgreSQL are skipped when it is not set.
+const testPostgresConfigEnv = "SMIDGEN_TEST_POSTGRES_CONFIG"
+
+// forEachTestDatabase runs test once against a new SQLite database, and once more against PostgreSQL when
+// SMIDGEN_TEST_POSTGRES_CONFIG is set. DatabaseConfigPath points at the database for the duration of test.
+func forEachTestDatabase(t *testing.T, test func(t *testing.T)) {
+	t.Run(DriverSQLite, func(t *testing.T) {
+		useTestDatabase(t, sqliteTestConfig(t))
+		test(t)
+	})
+	t.Run(DriverPostgres, func(t *testing.T) {
+		configPath := os.Getenv(testPostgresConfigEnv)
+		if configPath == "" {
+			t.Skipf("%s is not set", testPostgresConfigEnv)
+		}
+		useTestDatabase(t, configPath)
+		test(t)
+	})
+}
+
+// useTestDatabase migrates the database of configPath and makes it the database of every connection until t ends.
+func useTestDatabase(t *testing.T, configPath string) {
+	t.Helper()
+	previous := DatabaseConfigPath
+	DatabaseConfigPath = configPath
+	invalidateTenantCache()
+	t.Cleanup(func() {
+		if err := ClosePools(); err != nil {
+			t.Errorf("ClosePools() error = %v", err)
+		}
+		invalidateTenantCache()
+		DatabaseConfigPath = previous
+	})
+	if err := MigrateDatabase(configPath); err != nil {
+		t.Fatalf("MigrateDatabase() error = %v", err)
+	}
+}
+
+// sqliteTestConfig writes the configuration of a new SQLite database in a temporary directory and returns its path.
+func sqliteTestConfig(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "db_conn.yaml")
+	config := "driver: \"sqlite\"\nsqlite:\n  path: \"" + filepath.ToSlash(filepath.Join(dir, "smidgen.db")) + "\"\n"
+	if err := os.WriteFile(configPath, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return configPath
+}
+
+// testConnection opens a connection to the test database as privilege. Facade calls close their connection, so
+// each needs a new one.
+func testConnection(t *testing.T, privilege string) *DatabaseConnection {
+	t.Helper()
+	dao, err := NewDatabaseConnection(DatabaseConfigPath, privilege)
+	if err != nil {
+		t.Fatalf("NewDatabaseConnection() error = %v", err)
+	}
+	return dao
+}
@@ -28,23 +28,83 @@ import (
 	"database/sql"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
+)
 
-	"github.com/lib/pq"
+const (
+	versionColumn   = "version"
+	deletedAtColumn = "deleted_at"
+	deletedByColumn = "deleted_by"
 )
 
-// GetRows returns all of the rows for the provided tableName as type of destInterface
-func (dao *DatabaseConnection) GetRows(tableName string, destInterface interface{}) ([]interface{}, error) {
+// QueryOption changes which rows the read methods of the facade return.
+type QueryOption func(*queryOptions)
+
+type queryOptions struct {
+	includeDeleted bool
+	conditions     []string
+}
+
+// IncludeDeleted makes reads of soft-deletable tables return soft-deleted rows as well.
+func IncludeDeleted(include bool) QueryOption {
+	return func(o *queryOptions) {
+		o.includeDeleted = include
+	}
+}
+
+// WhereIn makes reads return only the rows whose column holds one of ids. The ids are integers, so they are
+// written into the query rather than bound, which keeps the numbering of placeholders to the read methods.
+func WhereIn(column string, ids ...int32) QueryOption {
+	return func(o *queryOptions) {
+		if len(ids) == 0 {
+			o.conditions = append(o.conditions, "1=0")
+			return
+		}
+		values := make([]string, len(ids))
+		for i, id := range ids {
+			values[i] = strconv.FormatInt(int64(id), 10)
+		}
+		o.conditions = append(o.conditions, fmt.Sprintf("%s IN (%s)", column, strings.Join(values, ", ")))
+	}
+}
+
+// whereClause builds the filters implied by options for rows of objectType, joined to any extra conditions.
+func (o queryOptions) whereClause(objectType reflect.Type, conditions ...string) string {
+	conditions = append(conditions, o.conditions...)
+	if isSoftDeletable(objectType) && !o.includeDeleted {
+		conditions = append(conditions, deletedAtColumn+" IS NULL")
+	}
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+func newQueryOptions(options []QueryOption) queryOptions {
+	var o queryOptions
+	for _, option := range options {
+		option(&o)
+	}
+	return o
+}
+
+// GetRows returns all of the rows for the provided tableName as type of destInterface.
+// Soft-deleted rows are left out unless IncludeDeleted is given.
+func (dao *DatabaseConnection) GetRows(tableName string, destInterface interface{}, options ...QueryOption) ([]interface{}, error) {
+	defer observeQuery("GetRows", tableName, time.Now())
 	_, err := validateTableName(dao, tableName)
 	if err != nil {
 		return nil, err
 	}
 
-	query := fmt.Sprintf("SELECT * FROM smidgen.%s;", tableName)
+	whereClause := newQueryOptions(options).whereClause(reflect.TypeOf(destInterface).Elem())
+	query := fmt.Sprintf("SELECT * FROM %s%s;", dao.dialect.table(tableName), whereClause)
 
-	rows, err := dao.db.Query(query)
+	rows, err := dao.executor().Query(query)
 	if err != nil {
-		return nil, fmt.Errorf("\nfailed to query rows from table smidgen.%s: %v", tableName, err)
+		return nil, fmt.Errorf("\nfailed to query rows from table %s: %v", dao.dialect.table(tableName), err)
 	}
 	defer rows.Close()
 
@@ -59,7 +119,7 @@ func (dao *DatabaseConnection) GetRows(tableName string, destInterface interface
 
 	for rows.Next() {
 		if err := rows.Scan(destValues...); err != nil {
-			return nil, fmt.Errorf("\nfailed to scan rows from table smidgen.%s: %v", tableName, err)
+			return nil, fmt.Errorf("\nfailed to scan rows from table %s: %v", dao.dialect.table(tableName), err)
 		}
 
 		result := reflect.New(elementType).Elem()
@@ -70,26 +130,29 @@ func (dao *DatabaseConnection) GetRows(tableName string, destInterface interface
 		results = append(results, result.Interface())
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("\nerror while iterating over rows from table smidgen.%s: %v", tableName, err)
+		return nil, fmt.Errorf("\nerror while iterating over rows from table %s: %v", dao.dialect.table(tableName), err)
 	}
 
-	dao.db.Close()
+	dao.Close()
 	return results, nil
 }
 
 // GetById will return a single row from tableName by using the idName column, and the id filter.
-// The return type is of type destInterface.
-func (dao *DatabaseConnection) GetByID(tableName string, idName string, id int32, destInterface interface{}) (interface{}, error) {
+// The return type is of type destInterface. Soft-deleted rows are not found unless IncludeDeleted is given.
+func (dao *DatabaseConnection) GetByID(tableName string, idName string, id int32, destInterface interface{}, options ...QueryOption) (interface{}, error) {
+	defer observeQuery("GetByID", tableName, time.Now())
 	_, err := validateTableName(dao, tableName)
 	if err != nil {
 		return nil, err
 	}
 
-	query := fmt.Sprintf("SELECT * FROM smidgen.%s WHERE %s = $1;", tableName, CamelToSnake(idName))
-	rows, err := dao.db.Query(query, id)
+	idCondition := fmt.Sprintf("%s = %s", CamelToSnake(idName), dao.dialect.placeholder(1))
+	whereClause := newQueryOptions(options).whereClause(reflect.TypeOf(destInterface).Elem(), idCondition)
+	query := fmt.Sprintf("SELECT * FROM %s%s;", dao.dialect.table(tableName), whereClause)
+	rows, err := dao.executor().Query(query, id)
 
 	if err != nil {
-		return nil, fmt.Errorf("\nfailed to query rows from table smidgen.%s: %v", tableName, err)
+		return nil, fmt.Errorf("\nfailed to query rows from table %s: %v", dao.dialect.table(tableName), err)
 	}
 	defer rows.Close()
 
@@ -105,7 +168,7 @@ func (dao *DatabaseConnection) GetByID(tableName string, idName string, id int32
 	}
 
 	if err := rows.Scan(destValues...); err != nil {
-		return nil, fmt.Errorf("\nfailed to scan rows from table smidgen.%s: %v", tableName, err)
+		return nil, fmt.Errorf("\nfailed to scan rows from table %s: %v", dao.dialect.table(tableName), err)
 	}
 
 	result := reflect.New(objectType).Elem()
@@ -114,25 +177,62 @@ func (dao *DatabaseConnection) GetByID(tableName string, idName string, id int32
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("\nerror while iterating over rows from table smidgen.%s: %v", tableName, err)
+		return nil, fmt.Errorf("\nerror while iterating over rows from table %s: %v", dao.dialect.table(tableName), err)
 	}
 
-	dao.db.Close()
+	dao.Close()
 	return result.Interface(), nil
 }
 
+// GetVersion returns the version column of the row of tableName matching id, deleted or not.
+// sql.ErrNoRows is returned when there is no such row.
+func (dao *DatabaseConnection) GetVersion(tableName string, idLabel string, id int32) (int32, error) {
+	defer observeQuery("GetVersion", tableName, time.Now())
+	_, err := validateTableName(dao, tableName)
+	if err != nil {
+		return 0, err
+	}
+
+	var version int32
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s=%s", versionColumn, dao.dialect.table(tableName), CamelToSnake(idLabel), dao.dialect.placeholder(1))
+	err = dao.executor().QueryRow(query, id).Scan(&version)
+	dao.Close()
+	return version, err
+}
+
 // InsertRow will execute an INSERT query onto tableName with values.
 func (dao *DatabaseConnection) InsertRow(tableName string, values interface{}) error {
+	_, err := dao.InsertRowReturningID(tableName, values)
+	return err
+}
+
+// InsertRowReturningID is like InsertRow, but also returns the ID assigned to the new row.
+func (dao *DatabaseConnection) InsertRowReturningID(tableName string, values interface{}) (int, error) {
+	defer observeQuery("InsertRow", tableName, time.Now())
+	if tableName == "audit_log" && dao.batch != nil {
+		// Audit entries outlive the batch, which writes them once it ends
+		dao.batch.deferAuditEntry(values)
+		return 0, nil
+	}
+	newID, err := dao.insertRow(tableName, values)
+	if err != nil && tableName == "audit_log" {
+		auditInsertFailures.Inc()
+		log.Errorf("failed to write audit log entry: %v", err)
+	}
+	return newID, err
+}
+
+func (dao *DatabaseConnection) insertRow(tableName string, values interface{}) (int, error) {
 	_, err := validateTableName(dao, tableName)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	valuesToInsert := reflect.ValueOf(values)
 	idColumnName := CamelToSnake(valuesToInsert.Type().Field(0).Name)
-	tx, err := dao.db.Begin()
+	tx, err := dao.begin()
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer func() {
 		if err != nil {
@@ -144,53 +244,68 @@ func (dao *DatabaseConnection) InsertRow(tableName string, values interface{}) e
 	var columns []string
 	var placeholders []string
 
-	if err := tx.QueryRow("SELECT COALESCE(MAX(" + idColumnName + "), 0) FROM smidgen." + tableName).Scan(&lastInsertedID); err != nil && err != sql.ErrNoRows {
-		return err
+	if err := tx.QueryRow("SELECT COALESCE(MAX(" + idColumnName + "), 0) FROM " + dao.dialect.table(tableName)).Scan(&lastInsertedID); err != nil && err != sql.ErrNoRows {
+		return 0, err
 	}
 
 	newID := lastInsertedID + 1
 	columns = append(columns, idColumnName)
-	placeholders = append(placeholders, "$1")
+	placeholders = append(placeholders, dao.dialect.placeholder(1))
 	for i := 1; i < valuesToInsert.NumField(); i++ {
 		columns = append(columns, CamelToSnake(valuesToInsert.Type().Field(i).Name))
-		placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
+		placeholders = append(placeholders, dao.dialect.placeholder(i+1))
 	}
 
-	query := fmt.Sprintf("INSERT INTO smidgen.%s (%s) VALUES (%s)", tableName, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
-	stmt, err := tx.Prepare(query)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", dao.dialect.table(tableName), strings.Join(columns, ", "), strings.Join(placeholders, ", "))
 
 	fieldValues := make([]interface{}, valuesToInsert.NumField())
 	fieldValues[0] = newID
 	for i := 1; i < valuesToInsert.NumField(); i++ {
 		fieldValues[i] = valuesToInsert.Field(i).Interface()
 	}
+	if i, ok := versionField(valuesToInsert); ok {
+		fieldValues[i] = int32(1)
+	}
+	for i := 1; i < valuesToInsert.NumField(); i++ {
+		if isSoftDeleteColumn(valuesToInsert.Type().Field(i)) {
+			fieldValues[i] = nil
+		}
+	}
 
-	_, err = stmt.Exec(fieldValues...)
+	_, err = tx.Exec(query, fieldValues...)
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			if pqErr.Code == "23503" {
-				return fmt.Errorf("23503: FOREIGN KEY VIOLATION on %s", tableName)
-			}
+		if dao.dialect.isForeignKeyViolation(err) {
+			return 0, fmt.Errorf("%w on %s", ErrForeignKeyViolation, tableName)
+		}
+		if dao.dialect.isUniqueViolation(err) {
+			return 0, fmt.Errorf("%w on %s", ErrUniqueViolation, tableName)
 		}
+		return 0, err
 	}
 
-	dao.db.Close()
-	return tx.Commit()
+	dao.Close()
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return newID, nil
 }
 
 // DeleteRow will execute a DELETE query onto tableName using the idLabel column with the matching id.
 func (dao *DatabaseConnection) DeleteRow(tableName string, idLabel string, id int32, args ...interface{}) error {
+	return dao.DeleteVersionedRow(tableName, idLabel, id, 0)
+}
+
+// DeleteVersionedRow is like DeleteRow, but only deletes the row while its version column still equals version.
+// A version of 0 skips the check. ErrVersionMismatch is returned when the row exists with a different version.
+func (dao *DatabaseConnection) DeleteVersionedRow(tableName string, idLabel string, id int32, version int32) error {
+	defer observeQuery("DeleteRow", tableName, time.Now())
 
 	_, err := validateTableName(dao, tableName)
 	if err != nil {
 		return err
 	}
 
-	tx, err := dao.db.Begin()
+	tx, err := dao.begin()
 	if err != nil {
 		return err
 	}
@@ -200,15 +315,15 @@ func (dao *DatabaseConnection) DeleteRow(tableName string, idLabel string, id in
 		}
 	}()
 
-	query := fmt.Sprintf("DELETE FROM smidgen.%s WHERE %s=$1;", tableName, CamelToSnake(idLabel))
-
-	stmt, err := tx.Prepare(query)
-	if err != nil {
-		return err
+	whereClause := fmt.Sprintf("%s=%s", CamelToSnake(idLabel), dao.dialect.placeholder(1))
+	whereValues := []interface{}{id}
+	if version != 0 {
+		whereClause += fmt.Sprintf(" AND %s=%s", versionColumn, dao.dialect.placeholder(2))
+		whereValues = append(whereValues, version)
 	}
-	defer stmt.Close()
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s;", dao.dialect.table(tableName), whereClause)
 
-	result, err := stmt.Exec(id)
+	result, err := tx.Exec(query, whereValues...)
 	if err != nil {
 		return err
 	}
@@ -219,13 +334,18 @@ func (dao *DatabaseConnection) DeleteRow(tableName string, idLabel string, id in
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("item with id %d does not exist in table %s", id, tableName)
+		err = dao.missingRowError(tx, tableName, idLabel, id, false)
+		return err
 	}
-	dao.db.Close()
+	dao.Close()
 	return tx.Commit()
 }
 
+// UpdateRow will execute an UPDATE query onto tableName using the idLabel column with the matching id,
+// overwriting every column but the ID. If values carries a non-zero Version, the row is only updated
+// while its version still matches, and ErrVersionMismatch is returned otherwise.
 func (dao *DatabaseConnection) UpdateRow(tableName string, idLabel string, id int32, values interface{}) error {
+	defer observeQuery("UpdateRow", tableName, time.Now())
 	_, err := validateTableName(dao, tableName)
 	if err != nil {
 		return err
@@ -233,17 +353,83 @@ func (dao *DatabaseConnection) UpdateRow(tableName string, idLabel string, id in
 
 	v := reflect.ValueOf(values)
 	objectType := v.Type()
+	versionIndex, _ := versionField(v)
 
 	var setValues []string
-	for i := 1; i < v.NumField(); i++ {
+	var fieldValues []interface{}
+	for i := 1; i < v.NumField(); i++ { // Start from index 1 to exclude the first column
+		if i == versionIndex || isSoftDeleteColumn(objectType.Field(i)) {
+			continue
+		}
+		fieldValues = append(fieldValues, v.Field(i).Interface())
 		fieldName := CamelToSnake(objectType.Field(i).Name)
-		setValues = append(setValues, fmt.Sprintf("%s=$%d", fieldName, i))
+		setValues = append(setValues, fmt.Sprintf("%s=%s", fieldName, dao.dialect.placeholder(len(fieldValues))))
+	}
+
+	return dao.updateRow(tableName, idLabel, id, v, setValues, fieldValues)
+}
+
+// UpdateColumns will execute an UPDATE query onto tableName using the idLabel column with the matching id,
+// setting only the given columns from values. Columns are named by the JSON names of the fields of values.
+// Versions are checked the same way as UpdateRow.
+func (dao *DatabaseConnection) UpdateColumns(tableName string, idLabel string, id int32, values interface{}, columns []string) error {
+	defer observeQuery("UpdateColumns", tableName, time.Now())
+	_, err := validateTableName(dao, tableName)
+	if err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(values)
+	objectType := v.Type()
+	versionIndex, _ := versionField(v)
+
+	fields := make(map[string]int)
+	for i := 1; i < v.NumField(); i++ { // The first column is the ID and can never be updated
+		if isSoftDeleteColumn(objectType.Field(i)) {
+			continue
+		}
+		jsonName := strings.Split(objectType.Field(i).Tag.Get("json"), ",")[0]
+		fields[jsonName] = i
+	}
+
+	var setValues []string
+	var fieldValues []interface{}
+	for _, column := range columns {
+		i, ok := fields[column]
+		if !ok {
+			return fmt.Errorf("column %s cannot be updated in table %s", column, tableName)
+		}
+		if i == versionIndex {
+			continue
+		}
+		fieldValues = append(fieldValues, v.Field(i).Interface())
+		setValues = append(setValues, fmt.Sprintf("%s=%s", CamelToSnake(objectType.Field(i).Name), dao.dialect.placeholder(len(fieldValues))))
+	}
+	if len(setValues) == 0 {
+		return nil
+	}
+
+	return dao.updateRow(tableName, idLabel, id, v, setValues, fieldValues)
+}
+
+func (dao *DatabaseConnection) updateRow(tableName string, idLabel string, id int32, values reflect.Value, setValues []string, fieldValues []interface{}) error {
+	whereClause := fmt.Sprintf("%s=%v", CamelToSnake(idLabel), id)
+	softDeletable := isSoftDeletable(values.Type())
+	if softDeletable {
+		whereClause += fmt.Sprintf(" AND %s IS NULL", deletedAtColumn)
+	}
+	if i, ok := versionField(values); ok {
+		setValues = append(setValues, fmt.Sprintf("%s=%s+1", versionColumn, versionColumn))
+		if version := values.Field(i).Interface().(int32); version != 0 {
+			fieldValues = append(fieldValues, version)
+			whereClause += fmt.Sprintf(" AND %s=%s", versionColumn, dao.dialect.placeholder(len(fieldValues)))
+		}
 	}
 
 	setClause := strings.Join(setValues, ", ")
-	query := fmt.Sprintf("UPDATE smidgen.%s SET %s WHERE %s=%v", tableName, setClause, CamelToSnake(idLabel), id)
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", dao.dialect.table(tableName), setClause, whereClause)
 
-	tx, err := dao.db.Begin()
+	tx, err := dao.begin()
 	if err != nil {
 		return err
 	}
@@ -253,18 +439,81 @@ func (dao *DatabaseConnection) UpdateRow(tableName string, idLabel string, id in
 		}
 	}()
 
-	stmt, err := tx.Prepare(query)
+	result, err := tx.Exec(query, fieldValues...)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
 
-	var fieldValues []interface{}
-	for i := 1; i < v.NumField(); i++ { // Start from index 1 to exclude the first column
-		fieldValues = append(fieldValues, v.Field(i).Interface())
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		err = dao.missingRowError(tx, tableName, idLabel, id, softDeletable)
+		return err
 	}
+	dao.Close()
+	return tx.Commit()
+}
 
-	result, err := stmt.Exec(fieldValues...)
+// missingRowError explains why a versioned statement affected no rows: either the row is gone,
+// or it exists but was modified since the caller last read it.
+func (dao *DatabaseConnection) missingRowError(tx sqlExecutor, tableName string, idLabel string, id int32, excludeDeleted bool) error {
+	var count int
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s=%s", dao.dialect.table(tableName), CamelToSnake(idLabel), dao.dialect.placeholder(1))
+	if excludeDeleted {
+		query += fmt.Sprintf(" AND %s IS NULL", deletedAtColumn)
+	}
+	if err := tx.QueryRow(query, id).Scan(&count); err == nil && count > 0 {
+		return ErrVersionMismatch
+	}
+	return fmt.Errorf("item with id %d does not exist in table %s", id, tableName)
+}
+
+// SoftDeleteRow marks the row of tableName matching id as deleted by deletedBy, without removing it.
+// Versions are checked the same way as DeleteVersionedRow. Rows that are already deleted are not found.
+func (dao *DatabaseConnection) SoftDeleteRow(tableName string, idLabel string, id int32, version int32, deletedBy string) error {
+	defer observeQuery("SoftDeleteRow", tableName, time.Now())
+	var deletedByValue interface{}
+	if deletedBy != "" {
+		deletedByValue = deletedBy
+	}
+	setClause := fmt.Sprintf("%s=%s, %s=%s", deletedAtColumn, dao.dialect.placeholder(1), deletedByColumn, dao.dialect.placeholder(2))
+	return dao.setDeleted(tableName, idLabel, id, version, setClause, deletedAtColumn+" IS NULL", time.Now().UTC(), deletedByValue)
+}
+
+// RestoreRow clears the deletion marks of a soft-deleted row of tableName matching id.
+func (dao *DatabaseConnection) RestoreRow(tableName string, idLabel string, id int32) error {
+	defer observeQuery("RestoreRow", tableName, time.Now())
+	setClause := fmt.Sprintf("%s=NULL, %s=NULL", deletedAtColumn, deletedByColumn)
+	return dao.setDeleted(tableName, idLabel, id, 0, setClause, deletedAtColumn+" IS NOT NULL")
+}
+
+func (dao *DatabaseConnection) setDeleted(tableName string, idLabel string, id int32, version int32, setClause string, condition string, values ...interface{}) error {
+	_, err := validateTableName(dao, tableName)
+	if err != nil {
+		return err
+	}
+
+	whereClause := fmt.Sprintf("%s=%v AND %s", CamelToSnake(idLabel), id, condition)
+	if version != 0 {
+		values = append(values, version)
+		whereClause += fmt.Sprintf(" AND %s=%s", versionColumn, dao.dialect.placeholder(len(values)))
+	}
+	query := fmt.Sprintf("UPDATE %s SET %s, %s=%s+1 WHERE %s", dao.dialect.table(tableName), setClause, versionColumn, versionColumn, whereClause)
+
+	tx, err := dao.begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result, err := tx.Exec(query, values...)
 	if err != nil {
 		return err
 	}
@@ -275,19 +524,42 @@ func (dao *DatabaseConnection) UpdateRow(tableName string, idLabel string, id in
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("item with id %d does not exist in table %s", id, tableName)
+		if version != 0 {
+			err = dao.missingRowError(tx, tableName, idLabel, id, true)
+		} else {
+			err = fmt.Errorf("item with id %d does not exist in table %s", id, tableName)
+		}
+		return err
 	}
-	dao.db.Close()
+	dao.Close()
 	return tx.Commit()
 }
 
+// isSoftDeletable reports whether rows of objectType are soft-deleted rather than removed.
+func isSoftDeletable(objectType reflect.Type) bool {
+	_, ok := objectType.FieldByName("DeletedAt")
+	return ok
+}
+
+// isSoftDeleteColumn reports whether field holds deletion marks, which are managed by the facade
+// and never written from client supplied values.
+func isSoftDeleteColumn(field reflect.StructField) bool {
+	column := CamelToSnake(field.Name)
+	return column == deletedAtColumn || column == deletedByColumn
+}
+
+// versionField returns the index of the Version field used for optimistic concurrency, if values has one.
+func versionField(values reflect.Value) (int, bool) {
+	field, ok := values.Type().FieldByName("Version")
+	if !ok || field.Type.Kind() != reflect.Int32 {
+		return -1, false
+	}
+	return field.Index[0], true
+}
+
 func validateTableName(dao *DatabaseConnection, tableName string) (bool, error) {
 
-	rows, err := dao.db.Query(`
-    SELECT table_name
-    FROM information_schema.tables
-    WHERE table_schema = 'smidgen' AND table_type = 'BASE TABLE'
-`)
+	rows, err := dao.executor().Query(dao.dialect.tablesQuery())
 
 	if err != nil {
 		return true, err
@@ -26,6 +26,7 @@
 package smidgen
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -33,6 +34,11 @@ import (
 
 var (
 	ErrTypeAssertionError = errors.New("unable to assert type")
+	ErrVersionMismatch    = errors.New("the resource has been modified since it was last retrieved")
+	// ErrForeignKeyViolation and ErrUniqueViolation are wrapped by the facade when a row refers to a missing row
+	// of another table, or repeats the key of an existing row.
+	ErrForeignKeyViolation = errors.New("23503: FOREIGN KEY VIOLATION")
+	ErrUniqueViolation     = errors.New("23505: UNIQUE VIOLATION")
 )
 
 type ParsingError struct {
@@ -55,14 +61,66 @@ func (e *RequiredError) Error() string {
 	return fmt.Sprintf("required field '%s' is zero value.", e.Field)
 }
 
+// ProblemContentType is the media type of error responses, see RFC 7807.
+const ProblemContentType = "application/problem+json"
+
+// Problem is the body of every error response, as described by RFC 7807.
+type Problem struct {
+	// Type identifies the kind of problem. It is "about:blank" when the status code says it all.
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
 type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error, result *ImplResponse)
 
+// DefaultErrorHandler responds with a problem describing err. The status code is derived from the type of
+// err, or else taken from result, falling back to 500 when there is no result.
 func DefaultErrorHandler(w http.ResponseWriter, r *http.Request, err error, result *ImplResponse) {
-	if _, ok := err.(*ParsingError); ok {
-		EncodeJSONResponse(err.Error(), func(i int) *int { return &i }(http.StatusBadRequest), w)
+	status := http.StatusInternalServerError
+	var headers map[string][]string
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		status = http.StatusRequestEntityTooLarge
+	} else if _, ok := err.(*ParsingError); ok {
+		status = http.StatusBadRequest
 	} else if _, ok := err.(*RequiredError); ok {
-		EncodeJSONResponse(err.Error(), func(i int) *int { return &i }(http.StatusUnprocessableEntity), w)
-	} else {
-		EncodeJSONResponse(err.Error(), &result.Code, w)
+		status = http.StatusUnprocessableEntity
+	} else if errors.Is(err, ErrUnsupportedMediaType) {
+		status = http.StatusUnsupportedMediaType
+	} else if result != nil && result.Code >= http.StatusBadRequest {
+		// A problem always describes a failure, so successful codes paired with an error are server errors
+		status = result.Code
+		headers = result.Headers
+	}
+	EncodeProblemResponse(w, r, status, err.Error(), headers)
+}
+
+// EncodeProblemResponse writes a problem with status and detail about the request r.
+func EncodeProblemResponse(w http.ResponseWriter, r *http.Request, status int, detail string, headers map[string][]string) error {
+	requestID := RequestIDFromContext(r.Context())
+	if requestID == "" {
+		requestID = w.Header().Get(RequestIDHeader)
+	}
+	problem := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.RequestURI(),
+		RequestID: requestID,
+	}
+
+	wHeader := w.Header()
+	for key, values := range headers {
+		for _, value := range values {
+			wHeader.Add(key, value)
+		}
 	}
+	wHeader.Set("Content-Type", ProblemContentType)
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(problem)
 }
@@ -0,0 +1,135 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// FormatETag returns the entity tag for a row at the given version.
+func FormatETag(version int32) string {
+	return fmt.Sprintf("\"%d\"", version)
+}
+
+// ETagHeaders returns the response headers advertising the entity tag for version.
+func ETagHeaders(version int32) map[string][]string {
+	return map[string][]string{"ETag": {FormatETag(version)}}
+}
+
+// IfMatch lists the versions accepted by an If-Match header. An empty list accepts any version.
+type IfMatch []int32
+
+// Matches reports whether version is accepted by m.
+func (m IfMatch) Matches(version int32) bool {
+	if len(m) == 0 {
+		return true
+	}
+	for _, candidate := range m {
+		if candidate == version {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseIfMatch returns the versions listed by an If-Match header. An empty header or "*" imposes no
+// specific version and yields an empty list. If-Match uses the strong comparison function, so weak entity
+// tags are rejected.
+func ParseIfMatch(header string) (IfMatch, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+	var ifMatch IfMatch
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if strings.HasPrefix(candidate, "W/") {
+			return nil, &ParsingError{Err: fmt.Errorf("weak entity tags cannot be used in If-Match: %s", candidate)}
+		}
+		version, err := strconv.ParseInt(strings.Trim(candidate, "\""), 10, 32)
+		if err != nil || version <= 0 {
+			return nil, &ParsingError{Err: fmt.Errorf("invalid entity tag in If-Match: %s", header)}
+		}
+		ifMatch = append(ifMatch, int32(version))
+	}
+	return ifMatch, nil
+}
+
+// ResolveIfMatch returns the version the row of tableName matching id must still have for ifMatch to hold:
+// 0 when ifMatch accepts any version, and the listed version when it lists only one. When several versions are
+// listed, the current version of the row is returned if it is among them, and ErrVersionMismatch otherwise.
+// A row that does not exist resolves to the first listed version, leaving the caller to report it missing.
+func ResolveIfMatch(ctx context.Context, tableName string, idLabel string, id int32, ifMatch IfMatch) (int32, error) {
+	switch len(ifMatch) {
+	case 0:
+		return 0, nil
+	case 1:
+		return ifMatch[0], nil
+	}
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "read")
+	if err != nil {
+		return 0, err
+	}
+	version, err := dao.GetVersion(tableName, idLabel, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ifMatch[0], nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !ifMatch.Matches(version) {
+		return 0, ErrVersionMismatch
+	}
+	return version, nil
+}
+
+// ETagMatches reports whether any entity tag listed in an If-None-Match header matches etag, using
+// the weak comparison function. If-Match is compared strongly through ParseIfMatch instead.
+func ETagMatches(header string, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// IsNotModified reports whether the If-None-Match header of r already matches the ETag of a response.
+func IsNotModified(r *http.Request, headers map[string][]string) bool {
+	etags := headers["ETag"]
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	return ifNoneMatch != "" && len(etags) > 0 && ETagMatches(ifNoneMatch, etags[0])
+}
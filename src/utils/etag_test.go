@@ -0,0 +1,213 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    IfMatch
+		wantErr bool
+	}{
+		{header: "", want: nil},
+		{header: "*", want: nil},
+		{header: ` "3" `, want: IfMatch{3}},
+		{header: `3`, want: IfMatch{3}},
+		{header: `"1", "2"`, want: IfMatch{1, 2}},
+		{header: `W/"7"`, wantErr: true},
+		{header: `"1", W/"2"`, wantErr: true},
+		{header: `"1",`, wantErr: true},
+		{header: `"0"`, wantErr: true},
+		{header: `"-1"`, wantErr: true},
+		{header: `"abc"`, wantErr: true},
+		{header: `"99999999999"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseIfMatch(tt.header)
+		if tt.wantErr {
+			var parsingError *ParsingError
+			if !errors.As(err, &parsingError) {
+				t.Errorf("ParseIfMatch(%q) error = %v, want a *ParsingError", tt.header, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseIfMatch(%q) = %v, %v, want %v", tt.header, got, err, tt.want)
+		}
+	}
+}
+
+func TestIfMatchMatches(t *testing.T) {
+	tests := []struct {
+		ifMatch IfMatch
+		version int32
+		want    bool
+	}{
+		{ifMatch: nil, version: 4, want: true},
+		{ifMatch: IfMatch{4}, version: 4, want: true},
+		{ifMatch: IfMatch{2, 4}, version: 4, want: true},
+		{ifMatch: IfMatch{2, 3}, version: 4, want: false},
+	}
+	for _, tt := range tests {
+		if got := tt.ifMatch.Matches(tt.version); got != tt.want {
+			t.Errorf("%v.Matches(%d) = %v, want %v", tt.ifMatch, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		etag   string
+		want   bool
+	}{
+		{header: `"2"`, etag: `"2"`, want: true},
+		{header: `"1", "2"`, etag: `"2"`, want: true},
+		{header: `W/"2"`, etag: `"2"`, want: true},
+		{header: `"2"`, etag: `W/"2"`, want: true},
+		{header: `*`, etag: `"5"`, want: true},
+		{header: `"1"`, etag: `"2"`, want: false},
+		{header: `"2"`, etag: ``, want: false},
+		{header: ``, etag: `"2"`, want: false},
+	}
+	for _, tt := range tests {
+		if got := ETagMatches(tt.header, tt.etag); got != tt.want {
+			t.Errorf("ETagMatches(%q, %q) = %v, want %v", tt.header, tt.etag, got, tt.want)
+		}
+	}
+}
+
+func TestIsNotModified(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		headers     map[string][]string
+		want        bool
+	}{
+		{name: "current version", ifNoneMatch: `"4"`, headers: ETagHeaders(4), want: true},
+		{name: "older version", ifNoneMatch: `"3"`, headers: ETagHeaders(4), want: false},
+		{name: "no precondition", headers: ETagHeaders(4), want: false},
+		{name: "response without an entity tag", ifNoneMatch: `*`, headers: nil, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/equipment/1", nil)
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			if got := IsNotModified(r, tt.headers); got != tt.want {
+				t.Errorf("IsNotModified() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// etagTestManufacturer mirrors the manufacturers table, whose rows are versioned and soft deleted.
+type etagTestManufacturer struct {
+	ManufacturerId int32
+	Name           string
+	PrimaryService string
+	PointOfContact string
+	Location       string
+	DateAdded      time.Time
+	Version        int32
+	DeletedAt      *time.Time
+	DeletedBy      *string
+}
+
+func TestVersionPreconditions(t *testing.T) {
+	forEachTestDatabase(t, func(t *testing.T) {
+		manufacturer := etagTestManufacturer{Name: "Acme", DateAdded: time.Now().UTC()}
+		id, err := testConnection(t, "write").InsertRowReturningID("manufacturers", manufacturer)
+		if err != nil {
+			t.Fatalf("InsertRowReturningID() error = %v", err)
+		}
+
+		steps := []struct {
+			name    string
+			version int32
+			wantErr error
+		}{
+			{name: "matching version", version: 1},
+			{name: "stale version", version: 1, wantErr: ErrVersionMismatch},
+			{name: "version from the future", version: 9, wantErr: ErrVersionMismatch},
+			{name: "no precondition", version: 0},
+			{name: "new version", version: 3},
+		}
+		for _, step := range steps {
+			manufacturer.Name = step.name
+			manufacturer.Version = step.version
+			err := testConnection(t, "write").UpdateRow("manufacturers", "manufacturerId", int32(id), manufacturer)
+			if !errors.Is(err, step.wantErr) {
+				t.Errorf("%s: UpdateRow() error = %v, want %v", step.name, err, step.wantErr)
+			}
+		}
+
+		var dest etagTestManufacturer
+		row, err := testConnection(t, "read").GetByID("manufacturers", "manufacturerId", int32(id), &dest)
+		if err != nil {
+			t.Fatalf("GetByID() error = %v", err)
+		}
+		if got := row.(etagTestManufacturer); got.Version != 4 || got.Name != "new version" {
+			t.Errorf("GetByID() = version %d named %q, want version 4 named %q", got.Version, got.Name, "new version")
+		}
+
+		resolutions := []struct {
+			ifMatch IfMatch
+			want    int32
+			wantErr error
+		}{
+			{ifMatch: nil, want: 0},
+			{ifMatch: IfMatch{2}, want: 2},
+			{ifMatch: IfMatch{2, 4}, want: 4},
+			{ifMatch: IfMatch{2, 3}, wantErr: ErrVersionMismatch},
+		}
+		for _, resolution := range resolutions {
+			got, err := ResolveIfMatch(context.Background(), "manufacturers", "manufacturerId", int32(id), resolution.ifMatch)
+			if got != resolution.want || !errors.Is(err, resolution.wantErr) {
+				t.Errorf("ResolveIfMatch(%v) = %d, %v, want %d, %v", resolution.ifMatch, got, err, resolution.want, resolution.wantErr)
+			}
+		}
+		if got, err := ResolveIfMatch(context.Background(), "manufacturers", "manufacturerId", int32(id)+100, IfMatch{2, 3}); got != 2 || err != nil {
+			t.Errorf("ResolveIfMatch() of a missing row = %d, %v, want 2, <nil>", got, err)
+		}
+
+		if err := testConnection(t, "delete").SoftDeleteRow("manufacturers", "manufacturerId", int32(id), 3, "test"); !errors.Is(err, ErrVersionMismatch) {
+			t.Errorf("SoftDeleteRow() with a stale version error = %v, want %v", err, ErrVersionMismatch)
+		}
+		if err := testConnection(t, "delete").SoftDeleteRow("manufacturers", "manufacturerId", int32(id), 4, "test"); err != nil {
+			t.Errorf("SoftDeleteRow() error = %v", err)
+		}
+	})
+}
@@ -1,4 +1,18 @@
 package smidgen
 
+import "time"
+
 var ServerConfigPath = ""
 var DatabaseConfigPath = ""
+
+// SoftDeleteRetention is how long a soft-deleted row must be kept before an administrator may purge it.
+var SoftDeleteRetention = 30 * 24 * time.Hour
+
+// IdempotencyWindow is how long the response to a request with an Idempotency-Key is kept for replay.
+var IdempotencyWindow = 24 * time.Hour
+
+// Version is the release the binary was built as. It is set at build time.
+var Version = ""
+
+// StartTime is when the process started.
+var StartTime = time.Now()
@@ -0,0 +1,105 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"database/sql"
+	"runtime/debug"
+	"time"
+)
+
+// requiredTables are the tables the API cannot serve requests without.
+var requiredTables = []string{"business_units", "manufacturers", "equipment", "users", "equipment_assignment", "audit_log"}
+
+// DatabaseHealth describes the state of the database as seen through the shared read pool.
+type DatabaseHealth struct {
+	Latency       time.Duration
+	MissingTables []string
+	Stats         sql.DBStats
+}
+
+// CheckDatabase pings the database through the shared read pool and checks that the schema tables exist,
+// giving up when ctx ends. The pool statistics are returned even when the check fails.
+func CheckDatabase(ctx context.Context, configPath string) (health DatabaseHealth, err error) {
+	pool, err := databasePoolFor(configPath, "read", "")
+	if err != nil {
+		return health, err
+	}
+	defer func() {
+		health.Stats = pool.db.Stats()
+	}()
+
+	start := time.Now()
+	if err := pool.db.PingContext(ctx); err != nil {
+		return health, err
+	}
+	health.Latency = time.Since(start)
+
+	rows, err := pool.db.QueryContext(ctx, pool.dialect.tablesQuery())
+	if err != nil {
+		return health, err
+	}
+	defer rows.Close()
+	tables := make(map[string]bool)
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return health, err
+		}
+		tables[table] = true
+	}
+	if err := rows.Err(); err != nil {
+		return health, err
+	}
+	for _, table := range requiredTables {
+		if !tables[table] {
+			health.MissingTables = append(health.MissingTables, table)
+		}
+	}
+	return health, nil
+}
+
+// BuildVersion returns the version the binary was built as, set with
+// -ldflags "-X smidgen-backend/src/utils.Version=...", or else the VCS revision it was built from.
+func BuildVersion() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "dev"
+}
+
+// Uptime returns how long the process has been running.
+func Uptime() time.Duration {
+	return time.Since(StartTime)
+}
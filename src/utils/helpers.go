@@ -39,6 +39,15 @@ func Response(code int, body interface{}) ImplResponse {
 	}
 }
 
+// ResponseWithHeaders return a ImplResponse struct filled, including headers
+func ResponseWithHeaders(code int, headers map[string][]string, body interface{}) ImplResponse {
+	return ImplResponse{
+		Code:    code,
+		Headers: headers,
+		Body:    body,
+	}
+}
+
 // IsZeroValue checks if the val is zero-ed value.
 func IsZeroValue(val interface{}) bool {
 	return val == nil || reflect.DeepEqual(val, reflect.Zero(reflect.TypeOf(val)).Interface())
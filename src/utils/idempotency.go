@@ -0,0 +1,268 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type idempotencyRecord struct {
+	fingerprint string
+	statusCode  int
+	headers     http.Header
+	body        []byte
+}
+
+// idempotencyContextKey holds the *idempotencyState of a request whose response may be stored.
+type idempotencyContextKey struct{}
+
+type idempotencyState struct {
+	withheld bool
+}
+
+// withholdFromReplay keeps the response to the request of ctx out of the idempotency store, because it
+// carries credentials such as API keys or session tokens. Operations of a batch withhold the whole batch.
+func withholdFromReplay(ctx context.Context) {
+	if state, ok := ctx.Value(idempotencyContextKey{}).(*idempotencyState); ok {
+		state.withheld = true
+	}
+}
+
+// credentialRoute withholds the responses of the routes issuing credentials from replay, see withholdFromReplay.
+func credentialRoute(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		withholdFromReplay(r.Context())
+		inner.ServeHTTP(w, r)
+	})
+}
+
+// responseRecorder passes a response through to the client while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(statusCode int) {
+	rec.statusCode = statusCode
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *responseRecorder) Write(data []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+	rec.body.Write(data)
+	return rec.ResponseWriter.Write(data)
+}
+
+// Idempotency makes POST, PUT, PATCH and DELETE requests carrying an Idempotency-Key header safe to retry.
+// The first request with a key is executed and its response stored; retries with the same key and body
+// replay that response, while reusing the key for a different request is rejected with 422.
+// Server errors, panics and responses carrying credentials or cookies are not stored, so a retry after one
+// executes the request again.
+func Idempotency(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || !isMutatingMethod(r.Method) {
+			inner.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			DefaultErrorHandler(w, r, &ParsingError{Err: err}, nil)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		log := LoggerFromContext(r.Context())
+		fingerprint := requestFingerprint(r, body)
+		actor := ActorFromContext(r.Context())
+
+		dao, err := NewDatabaseConnectionContext(r.Context(), DatabaseConfigPath, "write")
+		if err != nil {
+			log.Errorf("idempotency keys are unavailable: %v", err)
+			inner.ServeHTTP(w, r)
+			return
+		}
+		defer dao.Close()
+
+		record, reserved, err := dao.reserveIdempotencyKey(key, actor, fingerprint)
+		if err != nil {
+			log.Errorf("failed to reserve idempotency key: %v", err)
+			DefaultErrorHandler(w, r, errors.New("an error has occurred while processing the idempotency key"), &ImplResponse{Code: http.StatusInternalServerError})
+			return
+		}
+		if !reserved {
+			switch {
+			case record.fingerprint != fingerprint:
+				DefaultErrorHandler(w, r, fmt.Errorf("the %s has already been used for a different request", IdempotencyKeyHeader), &ImplResponse{Code: http.StatusUnprocessableEntity})
+			case record.statusCode == 0:
+				DefaultErrorHandler(w, r, fmt.Errorf("a request with this %s is still being processed", IdempotencyKeyHeader), &ImplResponse{Code: http.StatusConflict})
+			default:
+				for name, values := range record.headers {
+					if !isHopSpecificHeader(name) {
+						w.Header()[name] = values
+					}
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(record.statusCode)
+				w.Write(record.body)
+			}
+			return
+		}
+
+		// A panic must not leave the key reserved for the whole window; Recovery still answers it
+		defer func() {
+			if p := recover(); p != nil {
+				if err := dao.releaseIdempotencyKey(key, actor); err != nil {
+					log.Errorf("failed to release idempotency key: %v", err)
+				}
+				panic(p)
+			}
+		}()
+
+		state := &idempotencyState{}
+		recorder := &responseRecorder{ResponseWriter: w}
+		inner.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), idempotencyContextKey{}, state)))
+
+		if recorder.statusCode == 0 || recorder.statusCode >= http.StatusInternalServerError || state.withheld || len(w.Header().Values("Set-Cookie")) > 0 {
+			err = dao.releaseIdempotencyKey(key, actor)
+		} else {
+			err = dao.completeIdempotencyKey(key, actor, recorder.statusCode, w.Header(), recorder.body.Bytes())
+		}
+		if err != nil {
+			log.Errorf("failed to store idempotent response: %v", err)
+		}
+	})
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// isHopSpecificHeader reports whether the response header name describes the exchange that carried the response
+// rather than the response itself. Replays keep the values set for the current request instead.
+func isHopSpecificHeader(name string) bool {
+	name = strings.ToLower(name)
+	return name == strings.ToLower(RequestIDHeader) || name == "retry-after" || strings.HasPrefix(name, "ratelimit-")
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s\n", r.Method, r.URL.RequestURI())
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// reserveIdempotencyKey claims key for a new request. When the key is already taken, the stored record
+// is returned instead. Records older than IdempotencyWindow are discarded and the key claimed again.
+func (dao *DatabaseConnection) reserveIdempotencyKey(key string, actor string, fingerprint string) (idempotencyRecord, bool, error) {
+	table := dao.dialect.table("idempotency_keys")
+	p := dao.dialect.placeholder
+
+	_, err := dao.executor().Exec(fmt.Sprintf("DELETE FROM %s WHERE idempotency_key=%s AND actor=%s AND created_at<%s", table, p(1), p(2), p(3)),
+		key, actor, time.Now().UTC().Add(-IdempotencyWindow))
+	if err != nil {
+		return idempotencyRecord{}, false, err
+	}
+
+	_, err = dao.executor().Exec(fmt.Sprintf("INSERT INTO %s (idempotency_key, actor, fingerprint, status_code, created_at) VALUES (%s, %s, %s, 0, %s)", table, p(1), p(2), p(3), p(4)),
+		key, actor, fingerprint, time.Now().UTC())
+	if err == nil {
+		return idempotencyRecord{}, true, nil
+	}
+	if !dao.dialect.isUniqueViolation(err) {
+		return idempotencyRecord{}, false, err
+	}
+
+	var record idempotencyRecord
+	var headers, body string
+	err = dao.executor().QueryRow(fmt.Sprintf("SELECT fingerprint, status_code, response_headers, response_body FROM %s WHERE idempotency_key=%s AND actor=%s", table, p(1), p(2)), key, actor).
+		Scan(&record.fingerprint, &record.statusCode, &headers, &body)
+	if err == sql.ErrNoRows {
+		// The request holding the key gave it up in the meantime
+		return dao.reserveIdempotencyKey(key, actor, fingerprint)
+	}
+	if err != nil {
+		return idempotencyRecord{}, false, err
+	}
+	if headers != "" {
+		if err := json.Unmarshal([]byte(headers), &record.headers); err != nil {
+			return idempotencyRecord{}, false, err
+		}
+	}
+	record.body = []byte(body)
+	return record, false, nil
+}
+
+func (dao *DatabaseConnection) completeIdempotencyKey(key string, actor string, statusCode int, headers http.Header, body []byte) error {
+	encodedHeaders, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+	p := dao.dialect.placeholder
+	_, err = dao.executor().Exec(fmt.Sprintf("UPDATE %s SET status_code=%s, response_headers=%s, response_body=%s WHERE idempotency_key=%s AND actor=%s",
+		dao.dialect.table("idempotency_keys"), p(1), p(2), p(3), p(4), p(5)), statusCode, string(encodedHeaders), string(body), key, actor)
+	return err
+}
+
+func (dao *DatabaseConnection) releaseIdempotencyKey(key string, actor string) error {
+	p := dao.dialect.placeholder
+	_, err := dao.executor().Exec(fmt.Sprintf("DELETE FROM %s WHERE idempotency_key=%s AND actor=%s", dao.dialect.table("idempotency_keys"), p(1), p(2)), key, actor)
+	return err
+}
+
+// ExpireIdempotencyKeys deletes every stored response older than IdempotencyWindow of the tenant of ctx.
+func ExpireIdempotencyKeys(ctx context.Context, configPath string) error {
+	dao, err := NewDatabaseConnectionContext(ctx, configPath, "write")
+	if err != nil {
+		return err
+	}
+	defer dao.Close()
+
+	_, err = dao.executor().Exec(fmt.Sprintf("DELETE FROM %s WHERE created_at<%s", dao.dialect.table("idempotency_keys"), dao.dialect.placeholder(1)),
+		time.Now().UTC().Add(-IdempotencyWindow))
+	return err
+}
@@ -0,0 +1,288 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// idempotencyTestServer counts the requests reaching its handler, which answers with the status given in the
+// path and echoes the request body. The paths /credentials and /cookie answer with credentials and a cookie,
+// and /panic panics.
+type idempotencyTestServer struct {
+	calls   int
+	handler http.Handler
+}
+
+func newIdempotencyTestServer() *idempotencyTestServer {
+	server := &idempotencyTestServer{}
+	inner := Idempotency(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.calls++
+		switch r.URL.Path {
+		case "/credentials":
+			withholdFromReplay(r.Context())
+		case "/cookie":
+			w.Header().Set("Set-Cookie", "smidgen_session=secret")
+		case "/panic":
+			panic("test panic")
+		}
+		body, _ := io.ReadAll(r.Body)
+		status := http.StatusCreated
+		fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/status/"), "%d", &status)
+		w.Header().Set("Location", fmt.Sprintf("/equipment/%d", server.calls))
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "%d:%s", server.calls, body)
+	}))
+	// Like Logger and Limits, the outer middlewares set headers of their own on every response
+	server.handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(RequestIDHeader, r.Header.Get(RequestIDHeader))
+		w.Header().Set("RateLimit-Remaining", r.Header.Get(RequestIDHeader))
+		inner.ServeHTTP(w, r)
+	})
+	return server
+}
+
+type idempotencyTestRequest struct {
+	method string
+	path   string
+	key    string
+	body   string
+	actor  string
+}
+
+func (s *idempotencyTestServer) send(req idempotencyTestRequest, keyPrefix string, requestID string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(req.method, req.path, strings.NewReader(req.body))
+	if req.key != "" {
+		r.Header.Set(IdempotencyKeyHeader, keyPrefix+req.key)
+	}
+	r.Header.Set(RequestIDHeader, requestID)
+	r = r.WithContext(WithPrincipal(r.Context(), Principal{Subject: req.actor}))
+	w := httptest.NewRecorder()
+	s.handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotency(t *testing.T) {
+	tests := []struct {
+		name       string
+		requests   []idempotencyTestRequest
+		wantStatus []int
+		wantBody   []string
+		wantCalls  int
+	}{
+		{
+			name: "retries replay the first response",
+			requests: []idempotencyTestRequest{
+				{"POST", "/equipment", "k1", "a", "user:1"},
+				{"POST", "/equipment", "k1", "a", "user:1"},
+				{"POST", "/equipment", "k1", "a", "user:1"},
+			},
+			wantStatus: []int{201, 201, 201},
+			wantBody:   []string{"1:a", "1:a", "1:a"},
+			wantCalls:  1,
+		},
+		{
+			name: "a key reused for a different body is rejected",
+			requests: []idempotencyTestRequest{
+				{"POST", "/equipment", "k1", "a", "user:1"},
+				{"POST", "/equipment", "k1", "b", "user:1"},
+			},
+			wantStatus: []int{201, 422},
+			wantCalls:  1,
+		},
+		{
+			name: "a key reused for a different path is rejected",
+			requests: []idempotencyTestRequest{
+				{"PUT", "/equipment/1", "k1", "a", "user:1"},
+				{"PUT", "/equipment/2", "k1", "a", "user:1"},
+			},
+			wantStatus: []int{201, 422},
+			wantCalls:  1,
+		},
+		{
+			name: "keys belong to their actor",
+			requests: []idempotencyTestRequest{
+				{"POST", "/equipment", "k1", "a", "user:1"},
+				{"POST", "/equipment", "k1", "a", "user:2"},
+			},
+			wantStatus: []int{201, 201},
+			wantBody:   []string{"1:a", "2:a"},
+			wantCalls:  2,
+		},
+		{
+			name: "client errors are replayed",
+			requests: []idempotencyTestRequest{
+				{"POST", "/status/400", "k1", "a", "user:1"},
+				{"POST", "/status/400", "k1", "a", "user:1"},
+			},
+			wantStatus: []int{400, 400},
+			wantBody:   []string{"1:a", "1:a"},
+			wantCalls:  1,
+		},
+		{
+			name: "server errors are executed again",
+			requests: []idempotencyTestRequest{
+				{"POST", "/status/503", "k1", "a", "user:1"},
+				{"POST", "/status/503", "k1", "a", "user:1"},
+			},
+			wantStatus: []int{503, 503},
+			wantBody:   []string{"1:a", "2:a"},
+			wantCalls:  2,
+		},
+		{
+			name: "responses carrying credentials are executed again",
+			requests: []idempotencyTestRequest{
+				{"POST", "/credentials", "k1", "a", "user:1"},
+				{"POST", "/credentials", "k1", "a", "user:1"},
+			},
+			wantStatus: []int{201, 201},
+			wantBody:   []string{"1:a", "2:a"},
+			wantCalls:  2,
+		},
+		{
+			name: "responses setting cookies are executed again",
+			requests: []idempotencyTestRequest{
+				{"POST", "/cookie", "k1", "a", "user:1"},
+				{"POST", "/cookie", "k1", "a", "user:1"},
+			},
+			wantStatus: []int{201, 201},
+			wantBody:   []string{"1:a", "2:a"},
+			wantCalls:  2,
+		},
+		{
+			name: "requests without a key are always executed",
+			requests: []idempotencyTestRequest{
+				{"POST", "/equipment", "", "a", "user:1"},
+				{"POST", "/equipment", "", "a", "user:1"},
+			},
+			wantStatus: []int{201, 201},
+			wantCalls:  2,
+		},
+		{
+			name: "safe methods ignore the key",
+			requests: []idempotencyTestRequest{
+				{"GET", "/equipment", "k1", "", "user:1"},
+				{"GET", "/equipment", "k1", "", "user:1"},
+			},
+			wantStatus: []int{201, 201},
+			wantCalls:  2,
+		},
+	}
+	forEachTestDatabase(t, func(t *testing.T) {
+		for i, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				// Keys of earlier runs may still be stored, so every case uses keys of its own
+				keyPrefix := fmt.Sprintf("%d-%d-", time.Now().UnixNano(), i)
+				server := newIdempotencyTestServer()
+				for j, req := range tt.requests {
+					w := server.send(req, keyPrefix, fmt.Sprintf("request-%d", j))
+					if w.Code != tt.wantStatus[j] {
+						t.Errorf("request %d: status = %d, want %d", j, w.Code, tt.wantStatus[j])
+					}
+					if tt.wantBody != nil && w.Body.String() != tt.wantBody[j] {
+						t.Errorf("request %d: body = %q, want %q", j, w.Body.String(), tt.wantBody[j])
+					}
+				}
+				if server.calls != tt.wantCalls {
+					t.Errorf("handler called %d times, want %d", server.calls, tt.wantCalls)
+				}
+			})
+		}
+	})
+}
+
+func TestIdempotencyReplayHeaders(t *testing.T) {
+	forEachTestDatabase(t, func(t *testing.T) {
+		server := newIdempotencyTestServer()
+		req := idempotencyTestRequest{"POST", "/equipment", "replay", "a", "user:1"}
+		keyPrefix := fmt.Sprint(time.Now().UnixNano())
+		first := server.send(req, keyPrefix, "first")
+		replay := server.send(req, keyPrefix, "second")
+
+		if got := replay.Header().Get("Idempotent-Replayed"); got != "true" {
+			t.Errorf("Idempotent-Replayed = %q, want %q", got, "true")
+		}
+		if got, want := replay.Header().Get("Location"), first.Header().Get("Location"); got != want {
+			t.Errorf("Location = %q, want the stored %q", got, want)
+		}
+		for _, name := range []string{RequestIDHeader, "RateLimit-Remaining"} {
+			if got := replay.Header().Get(name); got != "second" {
+				t.Errorf("%s = %q, want %q of the current request", name, got, "second")
+			}
+		}
+	})
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	forEachTestDatabase(t, func(t *testing.T) {
+		server := newIdempotencyTestServer()
+		req := idempotencyTestRequest{"POST", "/equipment", "in-progress", "a", "user:1"}
+		keyPrefix := fmt.Sprint(time.Now().UnixNano())
+		r := httptest.NewRequest(req.method, req.path, strings.NewReader(req.body))
+
+		// Another request holds the key without having completed
+		_, reserved, err := testConnection(t, "write").reserveIdempotencyKey(keyPrefix+req.key, req.actor, requestFingerprint(r, []byte(req.body)))
+		if err != nil || !reserved {
+			t.Fatalf("reserveIdempotencyKey() = %v, %v, want a reservation", reserved, err)
+		}
+		if w := server.send(req, keyPrefix, "retry"); w.Code != http.StatusConflict {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusConflict)
+		}
+		if server.calls != 0 {
+			t.Errorf("handler called %d times, want 0", server.calls)
+		}
+
+		// Once the window has passed, the key is claimed again
+		window := IdempotencyWindow
+		IdempotencyWindow = -time.Second
+		defer func() { IdempotencyWindow = window }()
+		if w := server.send(req, keyPrefix, "later"); w.Code != http.StatusCreated || server.calls != 1 {
+			t.Errorf("status = %d after %d calls, want %d after 1", w.Code, server.calls, http.StatusCreated)
+		}
+	})
+}
+
+func TestIdempotencyPanic(t *testing.T) {
+	forEachTestDatabase(t, func(t *testing.T) {
+		server := newIdempotencyTestServer()
+		server.handler = Recovery(server.handler)
+		req := idempotencyTestRequest{"POST", "/panic", "panic", "a", "user:1"}
+		keyPrefix := fmt.Sprint(time.Now().UnixNano())
+
+		// The key is released, so the retry runs the handler again instead of finding it in progress
+		for i := 1; i <= 2; i++ {
+			if w := server.send(req, keyPrefix, fmt.Sprintf("request-%d", i)); w.Code != http.StatusInternalServerError || server.calls != i {
+				t.Errorf("request %d: status = %d after %d calls, want %d after %d", i, w.Code, server.calls, http.StatusInternalServerError, i)
+			}
+		}
+	})
+}
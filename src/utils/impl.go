@@ -27,6 +27,7 @@ package smidgen
 
 // ImplResponse defines an implementation response with error code and the associated body
 type ImplResponse struct {
-	Code int
-	Body interface{}
+	Code    int
+	Headers map[string][]string
+	Body    interface{}
 }
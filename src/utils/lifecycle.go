@@ -0,0 +1,78 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ready atomic.Bool
+
+	backgroundContext, stopBackground = context.WithCancel(context.Background())
+	backgroundWorkers                 sync.WaitGroup
+)
+
+// SetReady marks whether the server should receive traffic. It is set once the server is listening,
+// and cleared as soon as shutdown begins.
+func SetReady(isReady bool) {
+	ready.Store(isReady)
+}
+
+// IsReady reports whether the server should receive traffic.
+func IsReady() bool {
+	return ready.Load()
+}
+
+// RunInBackground runs worker in its own goroutine until shutdown. The context given to worker is
+// cancelled by StopBackgroundWorkers, and the worker must return soon after.
+func RunInBackground(name string, worker func(ctx context.Context)) {
+	backgroundWorkers.Add(1)
+	go func() {
+		defer backgroundWorkers.Done()
+		worker(backgroundContext)
+		log.Debugf("Background worker %s stopped.", name)
+	}()
+}
+
+// StopBackgroundWorkers cancels the background workers and waits for them to return, or for ctx to end.
+func StopBackgroundWorkers(ctx context.Context) error {
+	stopBackground()
+
+	done := make(chan struct{})
+	go func() {
+		backgroundWorkers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
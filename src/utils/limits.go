@@ -0,0 +1,287 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/time/rate"
+)
+
+// idleLimiterTimeout is how long the bucket of a client is kept after its last request.
+const idleLimiterTimeout = 10 * time.Minute
+
+// LimitsConfig bounds how often clients may call the API and how large their requests may be.
+// RateLimit applies to every route not listed in one of the RouteGroups.
+type LimitsConfig struct {
+	RateLimit `yaml:",inline"`
+	// RouteGroups give the routes they list, by the names of the routes, limits of their own.
+	RouteGroups []RouteGroupLimits `yaml:"route_groups"`
+}
+
+// RateLimit is a token bucket refilled with RequestsPerMinute tokens a minute, holding up to Burst tokens.
+// A RequestsPerMinute of 0 disables rate limiting, and a MaxBodyBytes of 0 disables the body size limit.
+type RateLimit struct {
+	RequestsPerMinute int   `yaml:"requests_per_minute"`
+	Burst             int   `yaml:"burst"`
+	MaxBodyBytes      int64 `yaml:"max_body_bytes"`
+}
+
+// RouteGroupLimits are the limits of the routes named in Routes. Clients have a separate bucket for each group.
+type RouteGroupLimits struct {
+	Name      string   `yaml:"name"`
+	Routes    []string `yaml:"routes"`
+	RateLimit `yaml:",inline"`
+}
+
+// Validate checks that every limit is usable.
+func (c LimitsConfig) Validate() error {
+	if err := c.RateLimit.validate("limits"); err != nil {
+		return err
+	}
+	seen := make(map[string]string)
+	for _, group := range c.RouteGroups {
+		if group.Name == "" || len(group.Routes) == 0 {
+			return errors.New("every route group requires a name and routes")
+		}
+		if err := group.validate("limits.route_groups." + group.Name); err != nil {
+			return err
+		}
+		for _, route := range group.Routes {
+			if other, ok := seen[route]; ok {
+				return fmt.Errorf("route %s is in both route groups %s and %s", route, other, group.Name)
+			}
+			seen[route] = group.Name
+		}
+	}
+	return nil
+}
+
+func (l RateLimit) validate(name string) error {
+	if l.RequestsPerMinute < 0 || l.Burst < 0 || l.MaxBodyBytes < 0 {
+		return fmt.Errorf("%s cannot be negative", name)
+	}
+	if l.RequestsPerMinute > 0 && l.Burst == 0 {
+		return fmt.Errorf("%s.burst is required with requests_per_minute", name)
+	}
+	return nil
+}
+
+// RequestLimits holds the token buckets of the clients of every route group of a LimitsConfig.
+type RequestLimits struct {
+	defaultGroup *limitGroup
+	groups       map[string]*limitGroup
+}
+
+// NewRequestLimits creates the buckets for the limits of config, which are applied by the Limits and
+// FailedAuthentications middlewares.
+func NewRequestLimits(config LimitsConfig) *RequestLimits {
+	limits := &RequestLimits{defaultGroup: newLimitGroup("default", config.RateLimit), groups: make(map[string]*limitGroup)}
+	for _, group := range config.RouteGroups {
+		groupLimits := newLimitGroup(group.Name, group.RateLimit)
+		if groupLimits.MaxBodyBytes == 0 {
+			groupLimits.MaxBodyBytes = config.MaxBodyBytes
+		}
+		for _, route := range group.Routes {
+			limits.groups[route] = groupLimits
+		}
+	}
+	return limits
+}
+
+// Limits applies the rate and body size limits to every request, keyed by the principal of the request, or by
+// the client IP for anonymous requests. It must run after the authentication middleware.
+// Operations of a batch are left alone, as the batch itself has been limited already and holds at most
+// batch.max_operations of them.
+func (l *RequestLimits) Limits(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(batchContextKey{}).(*Batch); ok {
+			inner.ServeHTTP(w, r)
+			return
+		}
+		group := l.defaultGroup
+		if route := mux.CurrentRoute(r); route != nil {
+			if limits, ok := l.groups[route.GetName()]; ok {
+				group = limits
+			}
+		}
+
+		if !group.allow(w, clientKey(r), time.Now()) {
+			EncodeProblemResponse(w, r, http.StatusTooManyRequests, "the rate limit has been exceeded, retry later", nil)
+			return
+		}
+		if group.MaxBodyBytes > 0 {
+			if r.ContentLength > group.MaxBodyBytes {
+				EncodeProblemResponse(w, r, http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("the request body may not exceed %d bytes", group.MaxBodyBytes), nil)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, group.MaxBodyBytes)
+		}
+		inner.ServeHTTP(w, r)
+	})
+}
+
+// FailedAuthentications takes a token from the bucket of the client IP for every request answered with 401, and
+// rejects the requests of clients whose bucket is empty before their credentials are looked up. It must run
+// before the authentication middleware, so that guessing keys and tokens is throttled like anonymous requests.
+func (l *RequestLimits) FailedAuthentications(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(batchContextKey{}).(*Batch); ok {
+			inner.ServeHTTP(w, r)
+			return
+		}
+		client := ipKey(r)
+		if l.defaultGroup.exhausted(w, client, time.Now()) {
+			EncodeProblemResponse(w, r, http.StatusTooManyRequests, "the rate limit has been exceeded, retry later", nil)
+			return
+		}
+		recorder := &statusRecorder{ResponseWriter: w}
+		inner.ServeHTTP(recorder, r)
+		if recorder.statusCode == http.StatusUnauthorized {
+			l.defaultGroup.take(client, time.Now())
+		}
+	})
+}
+
+// clientKey identifies the client of r for rate limiting. Principals of different tenants share subjects
+// such as user:1, so their keys carry the tenant.
+func clientKey(r *http.Request) string {
+	if actor := ActorFromContext(r.Context()); actor != "" {
+		return "principal:" + TenantToken(r.Context(), actor)
+	}
+	return ipKey(r)
+}
+
+// ipKey identifies the client of r by its IP.
+func ipKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// limitGroup holds the token buckets of every client of a route group.
+type limitGroup struct {
+	RateLimit
+	name      string
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newLimitGroup(name string, limits RateLimit) *limitGroup {
+	return &limitGroup{RateLimit: limits, name: name, clients: make(map[string]*clientLimiter), lastSweep: time.Now()}
+}
+
+// allow takes a token from the bucket of client and reports the state of the bucket in the headers of w.
+func (g *limitGroup) allow(w http.ResponseWriter, client string, now time.Time) bool {
+	if g.RequestsPerMinute == 0 {
+		return true
+	}
+	limiter := g.limiter(client, now)
+	allowed := limiter.AllowN(now, 1)
+	g.writeHeaders(w, limiter.TokensAt(now), !allowed)
+	return allowed
+}
+
+// exhausted reports whether the bucket of client is empty, and if so the state of the bucket in the headers of w.
+func (g *limitGroup) exhausted(w http.ResponseWriter, client string, now time.Time) bool {
+	if g.RequestsPerMinute == 0 {
+		return false
+	}
+	tokens := g.limiter(client, now).TokensAt(now)
+	if tokens >= 1 {
+		return false
+	}
+	g.writeHeaders(w, tokens, true)
+	return true
+}
+
+// take takes a token from the bucket of client, if one is left.
+func (g *limitGroup) take(client string, now time.Time) {
+	if g.RequestsPerMinute == 0 {
+		return
+	}
+	g.limiter(client, now).AllowN(now, 1)
+}
+
+// limiter returns the bucket of client, creating it if needed, and forgets the buckets of idle clients.
+func (g *limitGroup) limiter(client string, now time.Time) *rate.Limiter {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if now.Sub(g.lastSweep) > idleLimiterTimeout {
+		for key, c := range g.clients {
+			if now.Sub(c.lastSeen) > idleLimiterTimeout {
+				delete(g.clients, key)
+			}
+		}
+		g.lastSweep = now
+	}
+	c, ok := g.clients[client]
+	if !ok {
+		c = &clientLimiter{limiter: rate.NewLimiter(g.perSecond(), g.Burst)}
+		// The bucket starts full at now, even when the clock of the caller differs from the wall clock
+		c.limiter.AllowN(now, 0)
+		g.clients[client] = c
+	}
+	c.lastSeen = now
+	return c.limiter
+}
+
+func (g *limitGroup) perSecond() rate.Limit {
+	return rate.Limit(float64(g.RequestsPerMinute) / 60)
+}
+
+// writeHeaders reports a bucket holding tokens in the headers of w, with the time to wait for the next token
+// when the request was limited.
+func (g *limitGroup) writeHeaders(w http.ResponseWriter, tokens float64, limited bool) {
+	perSecond := float64(g.perSecond())
+	wHeader := w.Header()
+	wHeader.Set("RateLimit-Policy", fmt.Sprintf("%d;w=60;burst=%d;name=%q", g.RequestsPerMinute, g.Burst, g.name))
+	wHeader.Set("RateLimit-Limit", strconv.Itoa(g.Burst))
+	wHeader.Set("RateLimit-Remaining", strconv.Itoa(int(math.Max(0, math.Floor(tokens)))))
+	// The bucket is full again once the missing tokens have been refilled
+	wHeader.Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil((float64(g.Burst)-tokens)/perSecond))))
+	if limited {
+		wHeader.Set("Retry-After", strconv.Itoa(int(math.Ceil((1-tokens)/perSecond))))
+	}
+}
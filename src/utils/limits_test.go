@@ -0,0 +1,171 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimitGroupRefill(t *testing.T) {
+	// One token a second, up to two
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	steps := []struct {
+		name       string
+		after      time.Duration
+		allowed    bool
+		remaining  string
+		reset      string
+		retryAfter string
+	}{
+		{name: "first request of the burst", after: 0, allowed: true, remaining: "1", reset: "1"},
+		{name: "second request of the burst", after: 0, allowed: true, remaining: "0", reset: "2"},
+		{name: "bucket empty", after: 0, allowed: false, remaining: "0", reset: "2", retryAfter: "1"},
+		{name: "half a token refilled", after: 500 * time.Millisecond, allowed: false, remaining: "0", reset: "2", retryAfter: "1"},
+		{name: "a token refilled", after: time.Second, allowed: true, remaining: "0", reset: "2"},
+		{name: "bucket full again", after: time.Minute, allowed: true, remaining: "1", reset: "1"},
+	}
+
+	group := newLimitGroup("test", RateLimit{RequestsPerMinute: 60, Burst: 2})
+	for _, step := range steps {
+		w := httptest.NewRecorder()
+		allowed := group.allow(w, "ip:192.0.2.1", start.Add(step.after))
+		if allowed != step.allowed {
+			t.Fatalf("%s: allowed = %v, want %v", step.name, allowed, step.allowed)
+		}
+		headers := map[string]string{
+			"RateLimit-Limit":     "2",
+			"RateLimit-Remaining": step.remaining,
+			"RateLimit-Reset":     step.reset,
+			"Retry-After":         step.retryAfter,
+		}
+		for name, want := range headers {
+			if got := w.Header().Get(name); got != want {
+				t.Errorf("%s: %s = %q, want %q", step.name, name, got, want)
+			}
+		}
+	}
+}
+
+func TestLimitGroupRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		limit      RateLimit
+		retryAfter string
+		reset      string
+	}{
+		{name: "one token a second", limit: RateLimit{RequestsPerMinute: 60, Burst: 1}, retryAfter: "1", reset: "1"},
+		{name: "one token a minute", limit: RateLimit{RequestsPerMinute: 1, Burst: 1}, retryAfter: "60", reset: "60"},
+		{name: "rounded up to whole seconds", limit: RateLimit{RequestsPerMinute: 40, Burst: 3}, retryAfter: "2", reset: "5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := newLimitGroup("test", tt.limit)
+			for i := 0; i < tt.limit.Burst; i++ {
+				if !group.allow(httptest.NewRecorder(), "client", now) {
+					t.Fatalf("request %d of the burst was limited", i+1)
+				}
+			}
+			w := httptest.NewRecorder()
+			if group.allow(w, "client", now) {
+				t.Fatal("request after the burst was allowed")
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+			if got := w.Header().Get("RateLimit-Reset"); got != tt.reset {
+				t.Errorf("RateLimit-Reset = %q, want %q", got, tt.reset)
+			}
+		})
+	}
+}
+
+func TestLimitGroupSeparateClients(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	group := newLimitGroup("test", RateLimit{RequestsPerMinute: 60, Burst: 1})
+	if !group.allow(httptest.NewRecorder(), "ip:192.0.2.1", now) {
+		t.Fatal("first client was limited")
+	}
+	if !group.allow(httptest.NewRecorder(), "ip:192.0.2.2", now) {
+		t.Fatal("second client was limited by the bucket of the first")
+	}
+	if group.allow(httptest.NewRecorder(), "ip:192.0.2.1", now) {
+		t.Fatal("first client was allowed past its burst")
+	}
+}
+
+func TestFailedAuthentications(t *testing.T) {
+	limits := NewRequestLimits(LimitsConfig{RateLimit: RateLimit{RequestsPerMinute: 1, Burst: 3}})
+	reached := 0
+	handler := limits.FailedAuthentications(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached++
+		if r.Header.Get("Authorization") != "ApiKey valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	send := func(remoteAddr, authorization string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/equipment", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("Authorization", authorization)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	// Successful authentications are left to the limits of the principal
+	for i := 0; i < 5; i++ {
+		if w := send("192.0.2.1:1000", "ApiKey valid"); w.Code != http.StatusOK {
+			t.Fatalf("authenticated request %d: status = %d, want %d", i+1, w.Code, http.StatusOK)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if w := send("192.0.2.1:1000", "ApiKey guessed"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("guess %d: status = %d, want %d", i+1, w.Code, http.StatusUnauthorized)
+		}
+	}
+
+	reached = 0
+	for _, authorization := range []string{"ApiKey guessed", "ApiKey valid"} {
+		w := send("192.0.2.1:2000", authorization)
+		if w.Code != http.StatusTooManyRequests {
+			t.Errorf("%s after the failed guesses: status = %d, want %d", authorization, w.Code, http.StatusTooManyRequests)
+		}
+		if w.Header().Get("Retry-After") == "" {
+			t.Errorf("%s after the failed guesses: missing Retry-After", authorization)
+		}
+	}
+	if reached != 0 {
+		t.Errorf("throttled requests reached the authentication %d times", reached)
+	}
+	if w := send("192.0.2.2:1000", "ApiKey valid"); w.Code != http.StatusOK {
+		t.Errorf("other client: status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
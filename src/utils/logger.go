@@ -26,26 +26,120 @@
 package smidgen
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"os"
+	"regexp"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
 	logger "github.com/charmbracelet/log"
 )
 
+const (
+	// RequestIDHeader carries the ID of a request. It is taken from the request when present, and always
+	// echoed in the response.
+	RequestIDHeader = "X-Request-ID"
+
+	LogFormatConsole = "console"
+	LogFormatJSON    = "json"
+)
+
+// validRequestID limits the request IDs accepted from clients to something safe to log.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// defaultLogger is shared by every package, so SetLogFormat and Log(debug) apply to all of them.
+var defaultLogger = newLogger()
+
+type requestIDContextKey struct{}
+
+// Logger gives each request an ID and a logger carrying the ID, the route, the tenant and the user, then logs the
+// request once it has been served.
 func Logger(inner http.Handler, name string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
-		inner.ServeHTTP(w, r)
+		requestID := RequestIDFromContext(r.Context())
+		if requestID == "" {
+			requestID = r.Header.Get(RequestIDHeader)
+			if !validRequestID.MatchString(requestID) {
+				requestID = newRequestID()
+			}
+		}
+		w.Header().Set(RequestIDHeader, requestID)
 
-		Log().Info(fmt.Sprintf("[%s %s] %s TTE: %s", r.Method, r.RequestURI, name, time.Since(start)))
+		requestLog := Log().With("request_id", requestID, "route", name)
+		if tenant := TenantFromContext(r.Context()).Slug; tenant != "" {
+			requestLog = requestLog.With("tenant", tenant)
+		}
+		if actor := ActorFromContext(r.Context()); actor != "" {
+			requestLog = requestLog.With("user", actor)
+		}
+		ctx := context.WithValue(r.Context(), requestIDContextKey{}, requestID)
+		ctx = logger.WithContext(ctx, requestLog)
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		inner.ServeHTTP(recorder, r.WithContext(ctx))
+
+		if recorder.statusCode == 0 {
+			recorder.statusCode = http.StatusOK
+		}
+		requestLog.Info(fmt.Sprintf("[%s %s] %s TTE: %s", r.Method, r.RequestURI, name, time.Since(start)),
+			"status", recorder.statusCode)
 	})
 }
 
+// RequestIDFromContext returns the ID of the request of ctx, or "" outside of a request.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+// LoggerFromContext returns the logger of the request of ctx, or the default logger outside of a request.
+func LoggerFromContext(ctx context.Context) *logger.Logger {
+	if requestLog, ok := ctx.Value(logger.ContextKey).(*logger.Logger); ok {
+		return requestLog
+	}
+	return Log()
+}
+
+func newRequestID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// SetLogFormat switches every logger to format, LogFormatConsole or LogFormatJSON.
+func SetLogFormat(format string) error {
+	switch format {
+	case "", LogFormatConsole:
+		defaultLogger.SetFormatter(logger.TextFormatter)
+		defaultLogger.SetTimeFormat(logger.DefaultTimeFormat)
+	case LogFormatJSON:
+		defaultLogger.SetFormatter(logger.JSONFormatter)
+		defaultLogger.SetTimeFormat(time.RFC3339Nano)
+	default:
+		return fmt.Errorf("invalid log_format %q, expected console or json", format)
+	}
+	return nil
+}
+
+// Log returns the logger shared by the server. Passing debug switches it to or from the debug level.
 func Log(debug ...bool) *logger.Logger {
+	if len(debug) > 0 {
+		if debug[0] {
+			defaultLogger.SetLevel(logger.DebugLevel)
+		} else {
+			defaultLogger.SetLevel(logger.InfoLevel)
+		}
+	}
+	return defaultLogger
+}
+
+func newLogger() *logger.Logger {
 	styles := logger.DefaultStyles()
 	styles.Levels[logger.ErrorLevel] = lipgloss.NewStyle().
 		SetString("ERROR").
@@ -77,10 +171,6 @@ func Log(debug ...bool) *logger.Logger {
 		ReportTimestamp: true,
 	})
 
-	if len(debug) > 0 && debug[0] {
-		logger.SetLevel(-4)
-	}
-
 	logger.SetStyles(styles)
 	return logger
 }
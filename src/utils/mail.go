@@ -0,0 +1,199 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+const (
+	MailSenderLog  = "log"
+	MailSenderSMTP = "smtp"
+)
+
+// MailConfig chooses how emails, such as invitations, are sent.
+type MailConfig struct {
+	// Sender is "log" to only write emails to the log, as in development, or "smtp" to send them through Host.
+	Sender string `yaml:"sender"`
+	Host   string `yaml:"host"`
+	Port   string `yaml:"port"`
+	// Username and Password authenticate to the server, unless left out as for a local SMTP catcher.
+	// Password may be a reference such as env:NAME or file:PATH.
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// TLS is "starttls" to upgrade the connection when the server offers it (the default), "tls" for servers
+	// expecting TLS from the start, such as on port 465, or "none".
+	TLS  string `yaml:"tls"`
+	From string `yaml:"from"`
+}
+
+// Mail is an email to a single recipient.
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// MailSender sends emails. Implementations must be safe for concurrent use.
+type MailSender interface {
+	Send(ctx context.Context, mail Mail) error
+}
+
+// ApplyMailDefaults fills in every setting the config leaves out.
+func ApplyMailDefaults(c *MailConfig) {
+	if c.Sender == "" {
+		c.Sender = MailSenderLog
+	}
+	if c.Port == "" {
+		c.Port = "25"
+	}
+	if c.TLS == "" {
+		c.TLS = "starttls"
+	}
+	if c.From == "" {
+		c.From = "smidgen@localhost"
+	}
+}
+
+// Validate checks the config for settings that cannot be applied.
+func (c MailConfig) Validate() error {
+	switch c.Sender {
+	case MailSenderLog:
+		return nil
+	case MailSenderSMTP:
+		if c.Host == "" {
+			return fmt.Errorf("mail.host is required with the %q sender", MailSenderSMTP)
+		}
+	default:
+		return fmt.Errorf("mail.sender must be %q or %q, got %q", MailSenderLog, MailSenderSMTP, c.Sender)
+	}
+	switch c.TLS {
+	case "starttls", "tls", "none":
+		return nil
+	}
+	return fmt.Errorf("mail.tls must be \"starttls\", \"tls\" or \"none\", got %q", c.TLS)
+}
+
+// NewMailSender returns the sender config chooses.
+func NewMailSender(config MailConfig) (MailSender, error) {
+	ApplyMailDefaults(&config)
+	if config.Sender == MailSenderLog {
+		return logMailSender{}, nil
+	}
+	password, err := (&secretResolver{}).resolve(config.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mail.password: %v", err)
+	}
+	config.Password = password
+	return smtpMailSender{config: config}, nil
+}
+
+// logMailSender writes emails to the log instead of sending them.
+type logMailSender struct{}
+
+func (logMailSender) Send(ctx context.Context, mail Mail) error {
+	LoggerFromContext(ctx).Infof("Email to %s: %s\n%s", mail.To, mail.Subject, mail.Body)
+	return nil
+}
+
+type smtpMailSender struct {
+	config MailConfig
+}
+
+func (s smtpMailSender) Send(ctx context.Context, mail Mail) error {
+	address := net.JoinHostPort(s.config.Host, s.config.Port)
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if s.config.TLS == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: s.config.Host})
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to the mail server: %v", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+
+	client, err := smtp.NewClient(conn, s.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if s.config.TLS == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: s.config.Host}); err != nil {
+				return err
+			}
+		}
+	}
+	if s.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.config.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(mail.To); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(formatMail(s.config.From, mail)); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func formatMail(from string, mail Mail) []byte {
+	// Header values come from the server and the users table, but must still not be able to add headers
+	sanitize := strings.NewReplacer("\r", "", "\n", "")
+	var message strings.Builder
+	fmt.Fprintf(&message, "From: %s\r\n", sanitize.Replace(from))
+	fmt.Fprintf(&message, "To: %s\r\n", sanitize.Replace(mail.To))
+	fmt.Fprintf(&message, "Subject: %s\r\n", sanitize.Replace(mail.Subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+	return []byte(message.String())
+}
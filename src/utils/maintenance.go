@@ -0,0 +1,156 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Statuses of work orders, which move from open to in progress to completed.
+const (
+	WorkOrderStatusOpen       = "open"
+	WorkOrderStatusInProgress = "in_progress"
+	WorkOrderStatusCompleted  = "completed"
+)
+
+var (
+	ErrWorkOrderNotFound   = errors.New("the work order does not exist")
+	ErrWorkOrderTransition = errors.New("the work order cannot make this transition")
+)
+
+// MaintenanceConfig links work orders to the statuses of equipment, which are otherwise left to each deployment.
+type MaintenanceConfig struct {
+	// InRepairStatusId is the status_id of equipment a work order is in progress on.
+	InRepairStatusId int32 `yaml:"in_repair_status_id"`
+	// AvailableStatusId is the status_id equipment goes back to once its last work order is completed.
+	AvailableStatusId int32 `yaml:"available_status_id"`
+	// GenerateInterval is how often work orders are generated for the maintenance plans coming due, e.g. "1h".
+	GenerateInterval string `yaml:"generate_interval"`
+}
+
+// ApplyMaintenanceDefaults fills in every setting the config leaves out.
+func ApplyMaintenanceDefaults(c *MaintenanceConfig) {
+	if c.AvailableStatusId == 0 {
+		c.AvailableStatusId = 1
+	}
+	if c.InRepairStatusId == 0 {
+		c.InRepairStatusId = 2
+	}
+	if c.GenerateInterval == "" {
+		c.GenerateInterval = "1h"
+	}
+}
+
+// Validate checks the config for settings that cannot be applied.
+func (c MaintenanceConfig) Validate() error {
+	if c.InRepairStatusId < 1 || c.AvailableStatusId < 1 || c.InRepairStatusId == c.AvailableStatusId {
+		return fmt.Errorf("maintenance.in_repair_status_id and maintenance.available_status_id must be distinct positive IDs, got %d and %d", c.InRepairStatusId, c.AvailableStatusId)
+	}
+	if duration, err := time.ParseDuration(c.GenerateInterval); err != nil || duration <= 0 {
+		return fmt.Errorf("maintenance.generate_interval must be a positive duration such as \"1h\", got %q", c.GenerateInterval)
+	}
+	return nil
+}
+
+// StartWorkOrder moves the open work order workOrderId in progress and puts its equipment in repair. A version
+// of 0 skips the check of the version of the work order.
+func StartWorkOrder(ctx context.Context, workOrderId int32, version int32, config MaintenanceConfig) error {
+	return transitionWorkOrder(ctx, workOrderId, version, WorkOrderStatusOpen, WorkOrderStatusInProgress, "started_at",
+		func(dao *DatabaseConnection, tx sqlExecutor, equipmentId int32) error {
+			p := dao.dialect.placeholder
+			_, err := tx.Exec(fmt.Sprintf("UPDATE %s SET status_id=%s, version=version+1 WHERE equipment_id=%s AND status_id<>%s",
+				dao.dialect.table("equipment"), p(1), p(2), p(3)), config.InRepairStatusId, equipmentId, config.InRepairStatusId)
+			return err
+		})
+}
+
+// CompleteWorkOrder completes the work order workOrderId in progress. Its equipment goes back from in repair to
+// available, unless another of its work orders is still in progress.
+func CompleteWorkOrder(ctx context.Context, workOrderId int32, version int32, config MaintenanceConfig) error {
+	return transitionWorkOrder(ctx, workOrderId, version, WorkOrderStatusInProgress, WorkOrderStatusCompleted, "completed_at",
+		func(dao *DatabaseConnection, tx sqlExecutor, equipmentId int32) error {
+			p := dao.dialect.placeholder
+			_, err := tx.Exec(fmt.Sprintf("UPDATE %s SET status_id=%s, version=version+1 WHERE equipment_id=%s AND status_id=%s AND NOT EXISTS (SELECT 1 FROM %s WHERE equipment_id=%s AND status=%s)",
+				dao.dialect.table("equipment"), p(1), p(2), p(3), dao.dialect.table("work_orders"), p(4), p(5)),
+				config.AvailableStatusId, equipmentId, config.InRepairStatusId, equipmentId, WorkOrderStatusInProgress)
+			return err
+		})
+}
+
+// transitionWorkOrder moves workOrderId from status from to status to, stamping timeColumn, and updates its
+// equipment with updateEquipment in the same transaction.
+func transitionWorkOrder(ctx context.Context, workOrderId int32, version int32, from string, to string, timeColumn string,
+	updateEquipment func(dao *DatabaseConnection, tx sqlExecutor, equipmentId int32) error) error {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return err
+	}
+	defer dao.Close()
+
+	tx, err := dao.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	table := dao.dialect.table("work_orders")
+	p := dao.dialect.placeholder
+	var equipmentId, currentVersion int32
+	var status string
+	err = tx.QueryRow(fmt.Sprintf("SELECT equipment_id, status, version FROM %s WHERE work_order_id=%s", table, p(1)), workOrderId).
+		Scan(&equipmentId, &status, &currentVersion)
+	if err == sql.ErrNoRows {
+		return ErrWorkOrderNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if version != 0 && version != currentVersion {
+		return ErrVersionMismatch
+	}
+	if status != from {
+		return fmt.Errorf("%w: it is %s, but must be %s", ErrWorkOrderTransition, status, from)
+	}
+
+	result, err := tx.Exec(fmt.Sprintf("UPDATE %s SET status=%s, %s=%s, version=version+1 WHERE work_order_id=%s AND version=%s", table, p(1), timeColumn, p(2), p(3), p(4)),
+		to, time.Now().UTC(), workOrderId, currentVersion)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return ErrVersionMismatch
+	}
+	if err := updateEquipment(dao, tx, equipmentId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
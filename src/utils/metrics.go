@@ -0,0 +1,222 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "smidgen"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by route name, method and status code.",
+	}, []string{"route", "method", "status"})
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to serve HTTP requests, by route name, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Time taken by the database facade, by method and table.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "table"})
+	auditInsertFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "audit_log_insert_failures_total",
+		Help:      "Audit log entries that could not be written.",
+	})
+)
+
+// metricsTimeout bounds the queries run to collect the business metrics of a scrape.
+const metricsTimeout = 5 * time.Second
+
+// MetricsConfig sets where the Prometheus metrics are served. They count the inventory of every tenant, so
+// they are kept off the API and served on an address of their own, reachable by the scraper only.
+type MetricsConfig struct {
+	// Address is the host:port metrics are served on at /metrics. Metrics are not served when it is empty.
+	Address string `yaml:"address"`
+}
+
+// Enabled reports whether metrics should be served.
+func (c MetricsConfig) Enabled() bool {
+	return c.Address != ""
+}
+
+func init() {
+	prometheus.MustRegister(&inventoryCollector{
+		equipmentByStatus: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "equipment_by_status"),
+			"Equipment that has not been deleted, by tenant and status.", []string{"tenant", "status_id"}, nil),
+		equipmentByBusinessUnit: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "equipment_by_business_unit"),
+			"Equipment that has not been deleted, by tenant and business unit.", []string{"tenant", "business_unit_id"}, nil),
+	})
+}
+
+// MetricsHandler serves the metrics in the Prometheus text format.
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+// NewMetricsServer returns the server of the metrics at config.Address, apart from the API.
+func NewMetricsServer(config MetricsConfig) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", MetricsHandler())
+	return &http.Server{
+		Addr:         config.Address,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		Handler:      mux,
+	}
+}
+
+// Metrics counts and times the requests served by inner as the route name.
+func Metrics(inner http.Handler, name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		inner.ServeHTTP(recorder, r)
+
+		if recorder.statusCode == 0 {
+			recorder.statusCode = http.StatusOK
+		}
+		labels := prometheus.Labels{"route": name, "method": r.Method, "status": strconv.Itoa(recorder.statusCode)}
+		httpRequests.With(labels).Inc()
+		httpRequestDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (rec *statusRecorder) WriteHeader(statusCode int) {
+	if rec.statusCode == 0 {
+		rec.statusCode = statusCode
+	}
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *statusRecorder) Write(data []byte) (int, error) {
+	if rec.statusCode == 0 {
+		rec.statusCode = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(data)
+}
+
+// observeQuery records the time since start against the facade method and table.
+func observeQuery(method string, tableName string, start time.Time) {
+	queryDuration.WithLabelValues(method, tableName).Observe(time.Since(start).Seconds())
+}
+
+// registerPoolMetrics exports the statistics of a database pool under the given name.
+func registerPoolMetrics(pool *databasePool, name string) {
+	pool.collector = collectors.NewDBStatsCollector(pool.db, name)
+	if err := prometheus.Register(pool.collector); err != nil {
+		log.Warnf("failed to register metrics of database pool %s: %v", name, err)
+		pool.collector = nil
+	}
+}
+
+func unregisterPoolMetrics(pool *databasePool) {
+	if pool.collector != nil {
+		prometheus.Unregister(pool.collector)
+	}
+}
+
+// inventoryCollector counts equipment when metrics are scraped, so the numbers are always current. Every tenant
+// is counted under its slug; the default tenant has an empty tenant label.
+type inventoryCollector struct {
+	equipmentByStatus       *prometheus.Desc
+	equipmentByBusinessUnit *prometheus.Desc
+}
+
+func (c *inventoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.equipmentByStatus
+	ch <- c.equipmentByBusinessUnit
+}
+
+func (c *inventoryCollector) Collect(ch chan<- prometheus.Metric) {
+	if DatabaseConfigPath == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), metricsTimeout)
+	defer cancel()
+
+	err := ForEachTenant(ctx, DatabaseConfigPath, func(ctx context.Context) error {
+		tenant := TenantFromContext(ctx).Slug
+		pool, err := databasePoolFor(DatabaseConfigPath, "read", tenant)
+		if err != nil {
+			return err
+		}
+		var errs []error
+		for column, desc := range map[string]*prometheus.Desc{"status_id": c.equipmentByStatus, "business_unit_id": c.equipmentByBusinessUnit} {
+			query := fmt.Sprintf("SELECT %s, COUNT(*) FROM %s WHERE %s IS NULL GROUP BY %s",
+				column, pool.dialect.table("equipment"), deletedAtColumn, column)
+			if err := collectCounts(ctx, pool, query, desc, tenant, ch); err != nil {
+				errs = append(errs, fmt.Errorf("equipment by %s: %w", column, err))
+			}
+		}
+		return errors.Join(errs...)
+	})
+	if err != nil {
+		log.Errorf("failed to collect inventory metrics: %v", err)
+	}
+}
+
+func collectCounts(ctx context.Context, pool *databasePool, query string, desc *prometheus.Desc, tenant string, ch chan<- prometheus.Metric) error {
+	rows, err := pool.db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var label int64
+		var count float64
+		if err := rows.Scan(&label, &count); err != nil {
+			return err
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, count, tenant, strconv.FormatInt(label, 10))
+	}
+	return rows.Err()
+}
@@ -0,0 +1,115 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsTestEquipment are the columns of equipment that tests fill in.
+type metricsTestEquipment struct {
+	EquipmentId     int32
+	BusinessUnitId  int32
+	ManufacturerId  int32
+	Model           string
+	Description     string
+	StatusId        int32
+	DateReceived    time.Time
+	LastInventoried time.Time
+}
+
+// createTestEquipment creates count pieces of equipment with status statusId in the tenant of ctx.
+func createTestEquipment(t *testing.T, ctx context.Context, statusId int32, count int) {
+	t.Helper()
+	connection := func() *DatabaseConnection {
+		dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+		if err != nil {
+			t.Fatalf("NewDatabaseConnectionContext() error = %v", err)
+		}
+		return dao
+	}
+	businessUnitId, err := connection().InsertRowReturningID("business_units", sessionTestBusinessUnit{Name: "Depot"})
+	if err != nil {
+		t.Fatalf("InsertRowReturningID() error = %v", err)
+	}
+	manufacturerId, err := connection().InsertRowReturningID("manufacturers", etagTestManufacturer{Name: "Acme", DateAdded: time.Now().UTC()})
+	if err != nil {
+		t.Fatalf("InsertRowReturningID() error = %v", err)
+	}
+	for i := 0; i < count; i++ {
+		equipment := metricsTestEquipment{BusinessUnitId: int32(businessUnitId), ManufacturerId: int32(manufacturerId), Model: "M1",
+			StatusId: statusId, DateReceived: time.Now().UTC(), LastInventoried: time.Now().UTC()}
+		if _, err := connection().InsertRowReturningID("equipment", equipment); err != nil {
+			t.Fatalf("InsertRowReturningID() error = %v", err)
+		}
+	}
+}
+
+func TestInventoryCollector(t *testing.T) {
+	forEachTestDatabase(t, func(t *testing.T) {
+		acme, globex := createTestTenants(t)
+		createTestEquipment(t, context.Background(), 1, 1)
+		createTestEquipment(t, acme, 1, 2)
+		createTestEquipment(t, globex, 2, 3)
+
+		registry := prometheus.NewPedanticRegistry()
+		registry.MustRegister(&inventoryCollector{
+			equipmentByStatus:       prometheus.NewDesc("equipment_by_status", "Equipment.", []string{"tenant", "status_id"}, nil),
+			equipmentByBusinessUnit: prometheus.NewDesc("equipment_by_business_unit", "Equipment.", []string{"tenant", "business_unit_id"}, nil),
+		})
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatalf("Gather() error = %v", err)
+		}
+
+		got := make(map[string]float64)
+		for _, family := range families {
+			if family.GetName() != "equipment_by_status" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				labels := make(map[string]string)
+				for _, label := range metric.GetLabel() {
+					labels[label.GetName()] = label.GetValue()
+				}
+				got[labels["tenant"]+"/"+labels["status_id"]] = metric.GetGauge().GetValue()
+			}
+		}
+		want := map[string]float64{"/1": 1, "acme/1": 2, "globex/2": 3}
+		if len(got) != len(want) {
+			t.Errorf("collected %v, want %v", got, want)
+		}
+		for key, count := range want {
+			if got[key] != count {
+				t.Errorf("equipment of %s = %v, want %v", key, got[key], count)
+			}
+		}
+	})
+}
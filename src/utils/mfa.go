@@ -0,0 +1,293 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	recoveryCodeCount = 10
+	// maxMFAFailures is how many wrong codes a session may send before it is revoked, so that guessing
+	// codes requires logging in again every few attempts.
+	maxMFAFailures = 5
+)
+
+var (
+	ErrMFAAlreadyEnrolled = errors.New("multi-factor authentication is already enrolled, it must be reset first")
+	ErrMFANotEnrolled     = errors.New("multi-factor authentication has not been enrolled")
+	ErrInvalidMFACode     = errors.New("the code is invalid or has already been used")
+)
+
+// MFAEnrolled reports whether userId has confirmed the enrollment of an authenticator.
+func MFAEnrolled(ctx context.Context, userId int32) (bool, error) {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "read")
+	if err != nil {
+		return false, err
+	}
+	defer dao.Close()
+
+	var confirmedAt sql.NullTime
+	err = dao.executor().QueryRow(fmt.Sprintf("SELECT confirmed_at FROM %s WHERE user_id=%s", dao.dialect.table("user_mfa"), dao.dialect.placeholder(1)), userId).
+		Scan(&confirmedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return confirmedAt.Valid, err
+}
+
+// BeginMFAEnrollment generates a new secret for userId to add to an authenticator app. The secret only takes
+// effect once ConfirmMFAEnrollment is given a code generated from it, and replaces any earlier unconfirmed one.
+func BeginMFAEnrollment(ctx context.Context, userId int32) (string, error) {
+	enrolled, err := MFAEnrolled(ctx, userId)
+	if err != nil {
+		return "", err
+	}
+	if enrolled {
+		return "", ErrMFAAlreadyEnrolled
+	}
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return "", err
+	}
+	defer dao.Close()
+
+	table := dao.dialect.table("user_mfa")
+	p := dao.dialect.placeholder
+	tx, err := dao.begin()
+	if err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE user_id=%s AND confirmed_at IS NULL", table, p(1)), userId); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("INSERT INTO %s (user_id, secret, created_at) VALUES (%s, %s, %s)", table, p(1), p(2), p(3)), userId, secret, time.Now().UTC()); err != nil {
+		tx.Rollback()
+		if dao.dialect.isUniqueViolation(err) {
+			return "", ErrMFAAlreadyEnrolled
+		}
+		return "", err
+	}
+	return secret, tx.Commit()
+}
+
+// ConfirmMFAEnrollment enrolls userId once code shows their authenticator app holds the new secret, and
+// returns the recovery codes of the user. The codes are only stored hashed, so they cannot be shown again.
+func ConfirmMFAEnrollment(ctx context.Context, userId int32, code string) ([]string, error) {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return nil, err
+	}
+	defer dao.Close()
+
+	table := dao.dialect.table("user_mfa")
+	p := dao.dialect.placeholder
+	var secret string
+	var confirmedAt sql.NullTime
+	err = dao.executor().QueryRow(fmt.Sprintf("SELECT secret, confirmed_at FROM %s WHERE user_id=%s", table, p(1)), userId).Scan(&secret, &confirmedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrMFANotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if confirmedAt.Valid {
+		return nil, ErrMFAAlreadyEnrolled
+	}
+	step, ok := validateTOTP(secret, normalizeMFACode(code), time.Now(), 0)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		if codes[i], err = newRecoveryCode(); err != nil {
+			return nil, err
+		}
+	}
+	tx, err := dao.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	result, err := tx.Exec(fmt.Sprintf("UPDATE %s SET confirmed_at=%s, last_used_step=%s WHERE user_id=%s AND confirmed_at IS NULL", table, p(1), p(2), p(3)),
+		time.Now().UTC(), step, userId)
+	if err != nil {
+		return nil, err
+	}
+	if confirmed, _ := result.RowsAffected(); confirmed != 1 {
+		err = ErrMFAAlreadyEnrolled
+		return nil, err
+	}
+	if _, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE user_id=%s", dao.dialect.table("mfa_recovery_codes"), p(1)), userId); err != nil {
+		return nil, err
+	}
+	for _, recoveryCode := range codes {
+		_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s (code_hash, user_id) VALUES (%s, %s)", dao.dialect.table("mfa_recovery_codes"), p(1), p(2)),
+			HashAPIKeySecret(normalizeMFACode(recoveryCode)), userId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifyMFACode checks code, either a code of the authenticator app of userId or one of their unused
+// recovery codes. Either kind of code is only accepted once.
+func VerifyMFACode(ctx context.Context, userId int32, code string) error {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return err
+	}
+	defer dao.Close()
+
+	code = normalizeMFACode(code)
+	p := dao.dialect.placeholder
+	if len(code) != totpDigits {
+		result, err := dao.executor().Exec(fmt.Sprintf("UPDATE %s SET used_at=%s WHERE code_hash=%s AND user_id=%s AND used_at IS NULL",
+			dao.dialect.table("mfa_recovery_codes"), p(1), p(2), p(3)), time.Now().UTC(), HashAPIKeySecret(code), userId)
+		if err != nil {
+			return err
+		}
+		if used, _ := result.RowsAffected(); used != 1 {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	table := dao.dialect.table("user_mfa")
+	var secret string
+	var lastUsedStep int64
+	err = dao.executor().QueryRow(fmt.Sprintf("SELECT secret, last_used_step FROM %s WHERE user_id=%s AND confirmed_at IS NOT NULL", table, p(1)), userId).
+		Scan(&secret, &lastUsedStep)
+	if err == sql.ErrNoRows {
+		return ErrMFANotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+	step, ok := validateTOTP(secret, code, time.Now(), lastUsedStep)
+	if !ok {
+		return ErrInvalidMFACode
+	}
+	// Two requests racing with the same code must not both succeed
+	result, err := dao.executor().Exec(fmt.Sprintf("UPDATE %s SET last_used_step=%s WHERE user_id=%s AND last_used_step<%s", table, p(1), p(2), p(3)), step, userId, step)
+	if err != nil {
+		return err
+	}
+	if used, _ := result.RowsAffected(); used != 1 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// ResetMFA removes the authenticator and recovery codes of userId, who must enroll again if their business
+// unit requires it.
+func ResetMFA(ctx context.Context, userId int32) error {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "delete")
+	if err != nil {
+		return err
+	}
+	defer dao.Close()
+
+	for _, table := range []string{"mfa_recovery_codes", "user_mfa"} {
+		_, err := dao.executor().Exec(fmt.Sprintf("DELETE FROM %s WHERE user_id=%s", dao.dialect.table(table), dao.dialect.placeholder(1)), userId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CompleteSessionMFA gives the session of token full access once its user presented their second factor.
+func CompleteSessionMFA(ctx context.Context, token string) error {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return err
+	}
+	defer dao.Close()
+
+	p := dao.dialect.placeholder
+	_, err = dao.executor().Exec(fmt.Sprintf("UPDATE %s SET mfa_pending=%s WHERE token_hash=%s", dao.dialect.table("sessions"), p(1), p(2)), false, HashAPIKeySecret(token))
+	return err
+}
+
+// RecordSessionMFAFailure counts a wrong code sent with the session of token, revoking the session once it
+// has sent too many. It reports whether the session was revoked.
+func RecordSessionMFAFailure(ctx context.Context, token string) (bool, error) {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return false, err
+	}
+	defer dao.Close()
+
+	table := dao.dialect.table("sessions")
+	p := dao.dialect.placeholder
+	hash := HashAPIKeySecret(token)
+	if _, err := dao.executor().Exec(fmt.Sprintf("UPDATE %s SET mfa_failures=mfa_failures+1 WHERE token_hash=%s", table, p(1)), hash); err != nil {
+		return false, err
+	}
+	result, err := dao.executor().Exec(fmt.Sprintf("UPDATE %s SET revoked_at=%s WHERE token_hash=%s AND mfa_failures>=%s AND revoked_at IS NULL", table, p(1), p(2), p(3)),
+		time.Now().UTC(), hash, maxMFAFailures)
+	if err != nil {
+		return false, err
+	}
+	revoked, _ := result.RowsAffected()
+	return revoked == 1, nil
+}
+
+func newRecoveryCode() (string, error) {
+	var random [10]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(totpEncoding.EncodeToString(random[:]))
+	return code[:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:], nil
+}
+
+// normalizeMFACode lets users type codes with spaces or without dashes, and recovery codes in any case.
+func normalizeMFACode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
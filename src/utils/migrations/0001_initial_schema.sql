@@ -0,0 +1,58 @@
+-- Core Smidgen schema. {{schema}} is replaced with the schema prefix of the
+-- active dialect ("smidgen." on PostgreSQL, nothing on SQLite).
+
+CREATE TABLE IF NOT EXISTS {{schema}}business_units (
+    business_unit_id INTEGER PRIMARY KEY,
+    name             TEXT    NOT NULL,
+    point_of_contact TEXT    NOT NULL,
+    address_line_one TEXT    NOT NULL,
+    address_line_two TEXT    NOT NULL DEFAULT '',
+    state            TEXT    NOT NULL,
+    city             TEXT    NOT NULL,
+    country          TEXT    NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS {{schema}}manufacturers (
+    manufacturer_id  INTEGER   PRIMARY KEY,
+    name             TEXT      NOT NULL,
+    primary_service  TEXT      NOT NULL,
+    point_of_contact TEXT      NOT NULL,
+    location         TEXT      NOT NULL,
+    date_added       TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS {{schema}}equipment (
+    equipment_id     INTEGER   PRIMARY KEY,
+    business_unit_id INTEGER   NOT NULL REFERENCES {{schema}}business_units (business_unit_id),
+    manufacturer_id  INTEGER   NOT NULL REFERENCES {{schema}}manufacturers (manufacturer_id),
+    model            TEXT      NOT NULL,
+    description      TEXT      NOT NULL DEFAULT '',
+    status_id        INTEGER   NOT NULL,
+    date_received    TIMESTAMP NOT NULL,
+    last_inventoried TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS {{schema}}users (
+    user_id          INTEGER PRIMARY KEY,
+    business_unit_id INTEGER NOT NULL REFERENCES {{schema}}business_units (business_unit_id),
+    username         TEXT    NOT NULL UNIQUE,
+    password_hash    TEXT    NOT NULL,
+    password_salt    TEXT    NOT NULL,
+    first_name       TEXT    NOT NULL,
+    last_name        TEXT    NOT NULL,
+    primary_email    TEXT    NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS {{schema}}equipment_assignment (
+    assignment_id      INTEGER   PRIMARY KEY,
+    user_id            INTEGER   NOT NULL REFERENCES {{schema}}users (user_id),
+    equipment_id       INTEGER   NOT NULL REFERENCES {{schema}}equipment (equipment_id),
+    date_of_assignment TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS {{schema}}audit_log (
+    log_id           INTEGER   PRIMARY KEY,
+    action_timestamp TIMESTAMP NOT NULL,
+    action_status    TEXT      NOT NULL,
+    action           TEXT      NOT NULL
+);
@@ -0,0 +1,8 @@
+-- Row versions for optimistic concurrency. Every successful update increments
+-- the version, which is exposed to clients as the ETag of the row.
+
+ALTER TABLE {{schema}}business_units ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE {{schema}}manufacturers ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE {{schema}}equipment ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE {{schema}}users ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE {{schema}}equipment_assignment ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,13 @@
+-- Soft delete. Deleting a row only stamps deleted_at/deleted_by; the row is
+-- hidden from default listings and can be restored until it is purged.
+
+ALTER TABLE {{schema}}business_units ADD COLUMN deleted_at TIMESTAMP NULL;
+ALTER TABLE {{schema}}business_units ADD COLUMN deleted_by TEXT NULL;
+ALTER TABLE {{schema}}manufacturers ADD COLUMN deleted_at TIMESTAMP NULL;
+ALTER TABLE {{schema}}manufacturers ADD COLUMN deleted_by TEXT NULL;
+ALTER TABLE {{schema}}equipment ADD COLUMN deleted_at TIMESTAMP NULL;
+ALTER TABLE {{schema}}equipment ADD COLUMN deleted_by TEXT NULL;
+ALTER TABLE {{schema}}users ADD COLUMN deleted_at TIMESTAMP NULL;
+ALTER TABLE {{schema}}users ADD COLUMN deleted_by TEXT NULL;
+ALTER TABLE {{schema}}equipment_assignment ADD COLUMN deleted_at TIMESTAMP NULL;
+ALTER TABLE {{schema}}equipment_assignment ADD COLUMN deleted_by TEXT NULL;
@@ -0,0 +1,13 @@
+-- Responses to mutating requests sent with an Idempotency-Key header, kept so
+-- that retries replay the original outcome instead of repeating the change.
+
+CREATE TABLE IF NOT EXISTS {{schema}}idempotency_keys (
+    idempotency_key  TEXT      NOT NULL,
+    actor            TEXT      NOT NULL DEFAULT '',
+    fingerprint      TEXT      NOT NULL,
+    status_code      INTEGER   NOT NULL,
+    response_headers TEXT      NOT NULL DEFAULT '',
+    response_body    TEXT      NOT NULL DEFAULT '',
+    created_at       TIMESTAMP NOT NULL,
+    PRIMARY KEY (idempotency_key, actor)
+);
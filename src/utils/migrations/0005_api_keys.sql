@@ -0,0 +1,19 @@
+-- API keys let service accounts call the API without a user. Only a SHA-256
+-- hash of the secret part of each key is stored; the key_id part is public.
+
+CREATE TABLE IF NOT EXISTS {{schema}}api_keys (
+    key_id          INTEGER   PRIMARY KEY,
+    name            TEXT      NOT NULL,
+    service_account TEXT      NOT NULL,
+    scopes          TEXT      NOT NULL,
+    key_hash        TEXT      NOT NULL,
+    created_at      TIMESTAMP NOT NULL,
+    created_by      TEXT      NOT NULL DEFAULT '',
+    expires_at      TIMESTAMP NULL,
+    last_used_at    TIMESTAMP NULL,
+    revoked_at      TIMESTAMP NULL,
+    version         INTEGER   NOT NULL DEFAULT 1
+);
+
+-- Audit log entries record who performed the action: a user, service or API key.
+ALTER TABLE {{schema}}audit_log ADD COLUMN actor TEXT NOT NULL DEFAULT '';
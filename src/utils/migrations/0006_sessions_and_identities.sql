@@ -0,0 +1,30 @@
+-- Roles of users, such as admin. Users without a role have the default permissions.
+ALTER TABLE {{schema}}users ADD COLUMN role TEXT NOT NULL DEFAULT '';
+
+-- Accounts of users at external identity providers, keyed by the issuer and the
+-- subject the provider assigned to the user.
+CREATE TABLE IF NOT EXISTS {{schema}}user_identities (
+    issuer     TEXT      NOT NULL,
+    subject    TEXT      NOT NULL,
+    user_id    INTEGER   NOT NULL REFERENCES {{schema}}users (user_id),
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (issuer, subject)
+);
+
+-- Sessions of logged in users. Only a SHA-256 hash of each session token is stored.
+CREATE TABLE IF NOT EXISTS {{schema}}sessions (
+    token_hash TEXT      PRIMARY KEY,
+    user_id    INTEGER   NOT NULL REFERENCES {{schema}}users (user_id),
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NULL
+);
+
+-- Logins in progress with an identity provider, from the redirect to the provider
+-- until the provider redirects back.
+CREATE TABLE IF NOT EXISTS {{schema}}login_states (
+    state         TEXT      PRIMARY KEY,
+    nonce         TEXT      NOT NULL,
+    code_verifier TEXT      NOT NULL,
+    created_at    TIMESTAMP NOT NULL
+);
@@ -0,0 +1,26 @@
+-- Multi-factor authentication with time-based one-time passwords (TOTP).
+-- A user is enrolled once confirmed_at is set. last_used_step is the time step
+-- of the last accepted code, so a code cannot be replayed.
+CREATE TABLE IF NOT EXISTS {{schema}}user_mfa (
+    user_id        INTEGER   PRIMARY KEY REFERENCES {{schema}}users (user_id),
+    secret         TEXT      NOT NULL,
+    created_at     TIMESTAMP NOT NULL,
+    confirmed_at   TIMESTAMP NULL,
+    last_used_step INTEGER   NOT NULL DEFAULT 0
+);
+
+-- Single-use recovery codes, for users who lost their authenticator. Only a
+-- SHA-256 hash of each code is stored.
+CREATE TABLE IF NOT EXISTS {{schema}}mfa_recovery_codes (
+    code_hash TEXT      PRIMARY KEY,
+    user_id   INTEGER   NOT NULL REFERENCES {{schema}}users (user_id),
+    used_at   TIMESTAMP NULL
+);
+
+-- Sessions of users who must still present their second factor only give
+-- access to the routes completing the login.
+ALTER TABLE {{schema}}sessions ADD COLUMN mfa_pending BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE {{schema}}sessions ADD COLUMN mfa_failures INTEGER NOT NULL DEFAULT 0;
+
+-- Business units whose users must log in with a second factor.
+ALTER TABLE {{schema}}business_units ADD COLUMN require_mfa BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,15 @@
+-- One-time tokens emailed to users: invitations to choose a first password and
+-- links to reset a forgotten one. Only a SHA-256 hash of each token is stored.
+CREATE TABLE IF NOT EXISTS {{schema}}user_tokens (
+    token_hash TEXT      PRIMARY KEY,
+    user_id    INTEGER   NOT NULL REFERENCES {{schema}}users (user_id),
+    purpose    TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at    TIMESTAMP NULL
+);
+
+-- Consecutive wrong passwords, and until when the account refuses logins once
+-- there were too many.
+ALTER TABLE {{schema}}users ADD COLUMN failed_logins INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE {{schema}}users ADD COLUMN locked_until TIMESTAMP NULL;
@@ -0,0 +1,7 @@
+-- Whether users may log in: "active", "locked" after too many wrong passwords,
+-- or "deactivated" by an administrator. Deactivated users keep their history.
+ALTER TABLE {{schema}}users ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
+
+-- When users last logged in, and last made a request with a session.
+ALTER TABLE {{schema}}users ADD COLUMN last_login_at TIMESTAMP NULL;
+ALTER TABLE {{schema}}users ADD COLUMN last_activity_at TIMESTAMP NULL;
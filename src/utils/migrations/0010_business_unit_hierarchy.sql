@@ -0,0 +1,3 @@
+-- Business units form a hierarchy, such as division, battalion and company.
+-- Top-level units have no parent.
+ALTER TABLE {{schema}}business_units ADD COLUMN parent_business_unit_id INTEGER NULL REFERENCES {{schema}}business_units (business_unit_id);
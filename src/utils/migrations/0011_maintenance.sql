@@ -0,0 +1,35 @@
+-- Preventive maintenance. Plans repeat every interval_days, either for one
+-- piece of equipment or for every piece of a manufacturer, optionally of one
+-- model. Work orders move from open to in_progress to completed, and are
+-- either generated for a plan coming due or opened by hand for repairs.
+
+CREATE TABLE IF NOT EXISTS {{schema}}maintenance_plans (
+    plan_id         INTEGER   PRIMARY KEY,
+    name            TEXT      NOT NULL,
+    description     TEXT      NOT NULL DEFAULT '',
+    equipment_id    INTEGER   NULL REFERENCES {{schema}}equipment (equipment_id),
+    manufacturer_id INTEGER   NULL REFERENCES {{schema}}manufacturers (manufacturer_id),
+    model           TEXT      NOT NULL DEFAULT '',
+    interval_days   INTEGER   NOT NULL,
+    lead_days       INTEGER   NOT NULL DEFAULT 0,
+    created_at      TIMESTAMP NOT NULL,
+    version         INTEGER   NOT NULL DEFAULT 1,
+    deleted_at      TIMESTAMP NULL,
+    deleted_by      TEXT      NULL
+);
+
+CREATE TABLE IF NOT EXISTS {{schema}}work_orders (
+    work_order_id INTEGER          PRIMARY KEY,
+    equipment_id  INTEGER          NOT NULL REFERENCES {{schema}}equipment (equipment_id),
+    plan_id       INTEGER          NULL REFERENCES {{schema}}maintenance_plans (plan_id),
+    status        TEXT             NOT NULL DEFAULT 'open',
+    due_date      TIMESTAMP        NOT NULL,
+    technician    TEXT             NOT NULL DEFAULT '',
+    parts         TEXT             NOT NULL DEFAULT '',
+    labor_hours   DOUBLE PRECISION NOT NULL DEFAULT 0,
+    notes         TEXT             NOT NULL DEFAULT '',
+    opened_at     TIMESTAMP        NOT NULL,
+    started_at    TIMESTAMP        NULL,
+    completed_at  TIMESTAMP        NULL,
+    version       INTEGER          NOT NULL DEFAULT 1
+);
@@ -0,0 +1,4 @@
+-- Audit log entries can carry details about the action, e.g. how many
+-- assignments a user held when they were deactivated, keeping action a
+-- plain code that can be filtered on.
+ALTER TABLE {{schema}}audit_log ADD COLUMN detail TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,13 @@
+-- Registry of the tenants sharing the server. It lives next to the tables of
+-- the default tenant, while the tables of every other tenant live in a schema
+-- of their own on PostgreSQL or a database file of their own on SQLite.
+
+CREATE TABLE IF NOT EXISTS {{schema}}tenants (
+    tenant_id  INTEGER   PRIMARY KEY,
+    slug       TEXT      NOT NULL UNIQUE,
+    name       TEXT      NOT NULL,
+    hosts      TEXT      NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    created_by TEXT      NOT NULL DEFAULT '',
+    deleted_at TIMESTAMP NULL
+);
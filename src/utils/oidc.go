@@ -0,0 +1,366 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// loginStateTTL is how long users have to log in at the identity provider before the login is abandoned.
+const loginStateTTL = 10 * time.Minute
+
+var (
+	ErrInvalidLoginState = errors.New("the login is unknown or has expired, start it again")
+)
+
+// OIDCConfig lets users log in with an OpenID Connect identity provider. Users logging in for the first time
+// are created from the claims of their ID token, and the groups they belong to at the provider decide their
+// business unit and role on every login.
+type OIDCConfig struct {
+	// Issuer is the URL of the identity provider, from which its endpoints are discovered.
+	Issuer   string `yaml:"issuer"`
+	ClientID string `yaml:"client_id"`
+	// ClientSecret may be left out for public clients, since the authorization code is protected with PKCE.
+	// It may be a reference such as env:NAME or file:PATH.
+	ClientSecret string `yaml:"client_secret"`
+	// RedirectURL is the URL of the callback route, e.g. "https://smidgen.example.com/api/v1/auth/oidc/callback".
+	RedirectURL string `yaml:"redirect_url"`
+	// Scopes are requested in addition to "openid". Defaults to "profile" and "email".
+	Scopes []string `yaml:"scopes"`
+	// GroupsClaim is the claim of the ID token listing the groups of the user. Defaults to "groups".
+	GroupsClaim string `yaml:"groups_claim"`
+	// GroupMappings give the members of groups a business unit, a role, or both. The first mapping matching
+	// one of the groups of a user decides each.
+	GroupMappings []OIDCGroupMapping `yaml:"group_mappings"`
+	// DefaultBusinessUnitId is given to new users no mapping assigns a business unit to. Without it, such
+	// users cannot log in.
+	DefaultBusinessUnitId int32 `yaml:"default_business_unit_id"`
+	// SessionTTL is how long users stay logged in, e.g. "8h".
+	SessionTTL string `yaml:"session_ttl"`
+	// PostLoginRedirect is where browsers are sent once logged in. Without it, the callback returns the
+	// session token in its body instead.
+	PostLoginRedirect string `yaml:"post_login_redirect"`
+}
+
+// OIDCGroupMapping assigns the members of Group to a business unit and a role.
+type OIDCGroupMapping struct {
+	Group          string `yaml:"group"`
+	BusinessUnitId int32  `yaml:"business_unit_id"`
+	Role           string `yaml:"role"`
+}
+
+// Enabled reports whether users may log in with an identity provider.
+func (c OIDCConfig) Enabled() bool {
+	return c.Issuer != ""
+}
+
+// ApplyOIDCDefaults fills in every optional setting the config leaves out.
+func ApplyOIDCDefaults(c *OIDCConfig) {
+	if c.Scopes == nil {
+		c.Scopes = []string{"profile", "email"}
+	}
+	if c.GroupsClaim == "" {
+		c.GroupsClaim = "groups"
+	}
+	if c.SessionTTL == "" {
+		c.SessionTTL = "8h"
+	}
+}
+
+// Validate checks that an enabled config has the settings the login flow needs.
+func (c OIDCConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if c.ClientID == "" {
+		return errors.New("oidc.client_id is required when oidc.issuer is set")
+	}
+	for name, value := range map[string]string{"oidc.issuer": c.Issuer, "oidc.redirect_url": c.RedirectURL} {
+		if parsed, err := url.Parse(value); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return fmt.Errorf("%s must be an absolute URL, got %q", name, value)
+		}
+	}
+	if ttl, err := time.ParseDuration(c.SessionTTL); err != nil || ttl <= 0 {
+		return fmt.Errorf("oidc.session_ttl must be a positive duration such as \"8h\", got %q", c.SessionTTL)
+	}
+	for _, mapping := range c.GroupMappings {
+		if mapping.Group == "" {
+			return errors.New("every oidc.group_mappings entry needs a group")
+		}
+		if mapping.BusinessUnitId == 0 && mapping.Role == "" {
+			return fmt.Errorf("oidc.group_mappings entry for %q maps to neither a business unit nor a role", mapping.Group)
+		}
+	}
+	return nil
+}
+
+// MapGroups returns the business unit and role the first matching group mappings give to members of groups.
+// The business unit is 0 and the role "" when no mapping decides them.
+func (c OIDCConfig) MapGroups(groups []string) (businessUnitId int32, role string) {
+	member := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		member[group] = true
+	}
+	for _, mapping := range c.GroupMappings {
+		if !member[mapping.Group] {
+			continue
+		}
+		if businessUnitId == 0 {
+			businessUnitId = mapping.BusinessUnitId
+		}
+		if role == "" {
+			role = mapping.Role
+		}
+	}
+	return businessUnitId, role
+}
+
+// OIDCClaims are the claims of a verified ID token that users are provisioned from.
+type OIDCClaims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Email             string   `json:"email"`
+	PreferredUsername string   `json:"preferred_username"`
+	GivenName         string   `json:"given_name"`
+	FamilyName        string   `json:"family_name"`
+	Groups            []string `json:"-"`
+}
+
+// OIDCProvider runs the authorization code flow with PKCE against the identity provider of a config.
+// The provider is discovered on first use, so the server starts even when the identity provider is down.
+type OIDCProvider struct {
+	config OIDCConfig
+
+	mu       sync.Mutex
+	oauth2   *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewOIDCProvider returns the provider of config, or nil when config is not enabled.
+func NewOIDCProvider(config OIDCConfig) *OIDCProvider {
+	if !config.Enabled() {
+		return nil
+	}
+	ApplyOIDCDefaults(&config)
+	return &OIDCProvider{config: config}
+}
+
+// Config returns the settings of the provider.
+func (p *OIDCProvider) Config() OIDCConfig {
+	return p.config
+}
+
+func (p *OIDCProvider) discover(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.oauth2 != nil {
+		return p.oauth2, p.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, p.config.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to discover the identity provider: %v", err)
+	}
+	clientSecret, err := (&secretResolver{}).resolve(p.config.ClientSecret)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read oidc.client_secret: %v", err)
+	}
+	p.oauth2 = &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  p.config.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       append([]string{oidc.ScopeOpenID}, p.config.Scopes...),
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.config.ClientID})
+	return p.oauth2, p.verifier, nil
+}
+
+// AuthCodeURL starts a login, returning the URL of the identity provider to send the user to.
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context) (string, error) {
+	config, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	state, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	codeVerifier := oauth2.GenerateVerifier()
+
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return "", err
+	}
+	defer dao.Close()
+
+	p1 := dao.dialect.placeholder
+	_, err = dao.executor().Exec(fmt.Sprintf("INSERT INTO %s (state, nonce, code_verifier, created_at) VALUES (%s, %s, %s, %s)",
+		dao.dialect.table("login_states"), p1(1), p1(2), p1(3), p1(4)), state, nonce, codeVerifier, time.Now().UTC())
+	if err != nil {
+		return "", err
+	}
+	return config.AuthCodeURL(state, oauth2.S256ChallengeOption(codeVerifier), oidc.Nonce(nonce)), nil
+}
+
+// Exchange completes the login of state, redeeming code for an ID token and returning its verified claims.
+// Each state can only be used once.
+func (p *OIDCProvider) Exchange(ctx context.Context, state string, code string) (OIDCClaims, error) {
+	config, verifier, err := p.discover(ctx)
+	if err != nil {
+		return OIDCClaims{}, err
+	}
+	nonce, codeVerifier, err := consumeLoginState(ctx, state)
+	if err != nil {
+		return OIDCClaims{}, err
+	}
+
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return OIDCClaims{}, fmt.Errorf("failed to redeem the authorization code: %v", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return OIDCClaims{}, errors.New("the identity provider did not return an ID token")
+	}
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return OIDCClaims{}, fmt.Errorf("failed to verify the ID token: %v", err)
+	}
+	if idToken.Nonce != nonce {
+		return OIDCClaims{}, errors.New("the nonce of the ID token does not match the login")
+	}
+
+	var claims OIDCClaims
+	var allClaims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return OIDCClaims{}, err
+	}
+	if err := idToken.Claims(&allClaims); err != nil {
+		return OIDCClaims{}, err
+	}
+	switch groups := allClaims[p.config.GroupsClaim].(type) {
+	case string:
+		claims.Groups = []string{groups}
+	case []interface{}:
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				claims.Groups = append(claims.Groups, name)
+			}
+		}
+	}
+	return claims, nil
+}
+
+func consumeLoginState(ctx context.Context, state string) (nonce string, codeVerifier string, err error) {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return "", "", err
+	}
+	defer dao.Close()
+
+	table := dao.dialect.table("login_states")
+	p := dao.dialect.placeholder
+	var createdAt time.Time
+	err = dao.executor().QueryRow(fmt.Sprintf("SELECT nonce, code_verifier, created_at FROM %s WHERE state=%s", table, p(1)), state).
+		Scan(&nonce, &codeVerifier, &createdAt)
+	if err == sql.ErrNoRows {
+		return "", "", ErrInvalidLoginState
+	}
+	if err != nil {
+		return "", "", err
+	}
+	result, err := dao.executor().Exec(fmt.Sprintf("DELETE FROM %s WHERE state=%s", table, p(1)), state)
+	if err != nil {
+		return "", "", err
+	}
+	// Two callbacks racing for the same state must not both succeed
+	if deleted, err := result.RowsAffected(); err != nil || deleted != 1 {
+		return "", "", ErrInvalidLoginState
+	}
+	if time.Since(createdAt) > loginStateTTL {
+		return "", "", ErrInvalidLoginState
+	}
+	return nonce, codeVerifier, nil
+}
+
+// FindUserIdentity returns the user linked to the account subject of the identity provider issuer.
+func FindUserIdentity(ctx context.Context, issuer string, subject string) (int32, bool, error) {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "read")
+	if err != nil {
+		return 0, false, err
+	}
+	defer dao.Close()
+
+	var userId int32
+	p := dao.dialect.placeholder
+	err = dao.executor().QueryRow(fmt.Sprintf("SELECT user_id FROM %s WHERE issuer=%s AND subject=%s", dao.dialect.table("user_identities"), p(1), p(2)), issuer, subject).
+		Scan(&userId)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return userId, true, nil
+}
+
+// AddUserIdentity links the account subject of the identity provider issuer to the user userId.
+func AddUserIdentity(ctx context.Context, issuer string, subject string, userId int32) error {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return err
+	}
+	defer dao.Close()
+
+	p := dao.dialect.placeholder
+	_, err = dao.executor().Exec(fmt.Sprintf("INSERT INTO %s (issuer, subject, user_id, created_at) VALUES (%s, %s, %s, %s)",
+		dao.dialect.table("user_identities"), p(1), p(2), p(3), p(4)), issuer, subject, userId, time.Now().UTC())
+	return err
+}
+
+func randomToken() (string, error) {
+	var random [32]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(random[:]), nil
+}
@@ -0,0 +1,367 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testIssuer is an OpenID Connect identity provider serving discovery, its JWKS and a token endpoint that
+// enforces PKCE. Codes are issued by authorize instead of a login page.
+type testIssuer struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+
+	mu    sync.Mutex
+	codes map[string]testAuthorization
+	// signingKey signs the ID tokens, which the JWKS does not publish when it differs from key
+	signingKey *rsa.PrivateKey
+	// idToken changes the claims of the ID tokens before they are signed, if set
+	idToken func(claims map[string]interface{})
+	// omitIDToken leaves the ID token out of the token response
+	omitIDToken bool
+}
+
+// testAuthorization is what the issuer remembers of the authorization request a code was issued for.
+type testAuthorization struct {
+	challenge string
+	nonce     string
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &testIssuer{key: key, signingKey: key, clientID: "smidgen", codes: make(map[string]testAuthorization)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", issuer.discovery)
+	mux.HandleFunc("/jwks", issuer.jwks)
+	mux.HandleFunc("/token", issuer.token)
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+// config returns the settings of a client of the issuer.
+func (i *testIssuer) config() OIDCConfig {
+	return OIDCConfig{
+		Issuer:      i.server.URL,
+		ClientID:    i.clientID,
+		RedirectURL: "https://smidgen.example.com/api/v1/auth/oidc/callback",
+		GroupsClaim: "roles",
+	}
+}
+
+func (i *testIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"issuer":                                i.server.URL,
+		"authorization_endpoint":                i.server.URL + "/authorize",
+		"token_endpoint":                        i.server.URL + "/token",
+		"jwks_uri":                              i.server.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (i *testIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(i.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(i.key.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize plays the login page of the issuer for the authorization URL location, returning the code it
+// redirects back with.
+func (i *testIssuer) authorize(t *testing.T, location string) (state string, code string) {
+	t.Helper()
+	parsed, err := url.Parse(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if parsed.Path != "/authorize" || query.Get("response_type") != "code" || query.Get("client_id") != i.clientID {
+		t.Fatalf("unexpected authorization request %s", location)
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization request %s is not protected with PKCE", location)
+	}
+	if query.Get("state") == "" || query.Get("nonce") == "" {
+		t.Fatalf("authorization request %s has no state or nonce", location)
+	}
+	code, err = randomToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.mu.Lock()
+	i.codes[code] = testAuthorization{challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+	i.mu.Unlock()
+	return query.Get("state"), code
+}
+
+func (i *testIssuer) token(w http.ResponseWriter, r *http.Request) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	authorization, ok := i.codes[r.PostFormValue("code")]
+	delete(i.codes, r.PostFormValue("code"))
+	verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || r.PostFormValue("grant_type") != "authorization_code" ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != authorization.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":                i.server.URL,
+		"sub":                "subject-1",
+		"aud":                i.clientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Hour).Unix(),
+		"nonce":              authorization.nonce,
+		"email":              "jdoe@example.com",
+		"preferred_username": "jdoe",
+		"given_name":         "Jane",
+		"family_name":        "Doe",
+		"roles":              []string{"logistics", "admins"},
+	}
+	if i.idToken != nil {
+		i.idToken(claims)
+	}
+	response := map[string]interface{}{"access_token": "access", "token_type": "Bearer", "expires_in": 3600}
+	if !i.omitIDToken {
+		response["id_token"] = signTestJWT(i.signingKey, claims)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// signTestJWT signs claims with key as an RS256 JWT.
+func signTestJWT(key *rsa.PrivateKey, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestOIDCProviderLogin(t *testing.T) {
+	forEachTestDatabase(t, func(t *testing.T) {
+		issuer := newTestIssuer(t)
+		provider := NewOIDCProvider(issuer.config())
+		ctx := context.Background()
+
+		location, err := provider.AuthCodeURL(ctx)
+		if err != nil {
+			t.Fatalf("AuthCodeURL() error = %v", err)
+		}
+		state, code := issuer.authorize(t, location)
+		claims, err := provider.Exchange(ctx, state, code)
+		if err != nil {
+			t.Fatalf("Exchange() error = %v", err)
+		}
+		want := OIDCClaims{Issuer: issuer.server.URL, Subject: "subject-1", Email: "jdoe@example.com", PreferredUsername: "jdoe",
+			GivenName: "Jane", FamilyName: "Doe", Groups: []string{"logistics", "admins"}}
+		if claims.Issuer != want.Issuer || claims.Subject != want.Subject || claims.Email != want.Email ||
+			claims.PreferredUsername != want.PreferredUsername || claims.GivenName != want.GivenName ||
+			claims.FamilyName != want.FamilyName || strings.Join(claims.Groups, ",") != strings.Join(want.Groups, ",") {
+			t.Errorf("Exchange() = %+v, want %+v", claims, want)
+		}
+
+		// The state of a login is only good for one callback
+		if _, err := provider.Exchange(ctx, state, code); !errors.Is(err, ErrInvalidLoginState) {
+			t.Errorf("Exchange() of a used state error = %v, want %v", err, ErrInvalidLoginState)
+		}
+	})
+}
+
+func TestOIDCProviderGroupsClaim(t *testing.T) {
+	tests := []struct {
+		name   string
+		groups interface{}
+		want   []string
+	}{
+		{name: "list", groups: []string{"logistics", "admins"}, want: []string{"logistics", "admins"}},
+		{name: "single group", groups: "logistics", want: []string{"logistics"}},
+		{name: "entries other than names are ignored", groups: []interface{}{"logistics", 7}, want: []string{"logistics"}},
+		{name: "missing", groups: nil, want: nil},
+	}
+	forEachTestDatabase(t, func(t *testing.T) {
+		issuer := newTestIssuer(t)
+		provider := NewOIDCProvider(issuer.config())
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				issuer.idToken = func(claims map[string]interface{}) {
+					if tt.groups == nil {
+						delete(claims, "roles")
+						return
+					}
+					claims["roles"] = tt.groups
+				}
+				location, err := provider.AuthCodeURL(context.Background())
+				if err != nil {
+					t.Fatalf("AuthCodeURL() error = %v", err)
+				}
+				state, code := issuer.authorize(t, location)
+				claims, err := provider.Exchange(context.Background(), state, code)
+				if err != nil {
+					t.Fatalf("Exchange() error = %v", err)
+				}
+				if strings.Join(claims.Groups, ",") != strings.Join(tt.want, ",") {
+					t.Errorf("Groups = %v, want %v", claims.Groups, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestOIDCProviderRejectedLogins(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		// issuer sets up the issuer to misbehave
+		issuer func(i *testIssuer)
+		// callback returns the state and code the callback is made with, given those of the login and of a
+		// second login
+		callback  func(state, code, otherState, otherCode string) (string, string)
+		wantError string
+	}{
+		{name: "code of another login", callback: func(state, code, otherState, otherCode string) (string, string) {
+			return otherState, code
+		}, wantError: "failed to redeem the authorization code"},
+		{name: "unknown state", callback: func(state, code, otherState, otherCode string) (string, string) {
+			return "unknown", code
+		}, wantError: ErrInvalidLoginState.Error()},
+		{name: "no ID token", issuer: func(i *testIssuer) {
+			i.omitIDToken = true
+		}, wantError: "did not return an ID token"},
+		{name: "signed by an unknown key", issuer: func(i *testIssuer) {
+			i.signingKey = otherKey
+		}, wantError: "failed to verify the ID token"},
+		{name: "other issuer", issuer: func(i *testIssuer) {
+			i.idToken = func(claims map[string]interface{}) { claims["iss"] = "https://idp.example.com" }
+		}, wantError: "failed to verify the ID token"},
+		{name: "other audience", issuer: func(i *testIssuer) {
+			i.idToken = func(claims map[string]interface{}) { claims["aud"] = "another-client" }
+		}, wantError: "failed to verify the ID token"},
+		{name: "expired", issuer: func(i *testIssuer) {
+			i.idToken = func(claims map[string]interface{}) {
+				claims["iat"] = time.Now().Add(-2 * time.Hour).Unix()
+				claims["exp"] = time.Now().Add(-time.Hour).Unix()
+			}
+		}, wantError: "failed to verify the ID token"},
+		{name: "nonce of another login", issuer: func(i *testIssuer) {
+			i.idToken = func(claims map[string]interface{}) { claims["nonce"] = "replayed" }
+		}, wantError: "the nonce of the ID token does not match"},
+	}
+	forEachTestDatabase(t, func(t *testing.T) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				issuer := newTestIssuer(t)
+				if tt.issuer != nil {
+					tt.issuer(issuer)
+				}
+				provider := NewOIDCProvider(issuer.config())
+				ctx := context.Background()
+				var logins [2][2]string
+				for n := range logins {
+					location, err := provider.AuthCodeURL(ctx)
+					if err != nil {
+						t.Fatalf("AuthCodeURL() error = %v", err)
+					}
+					logins[n][0], logins[n][1] = issuer.authorize(t, location)
+				}
+				state, code := logins[0][0], logins[0][1]
+				if tt.callback != nil {
+					state, code = tt.callback(state, code, logins[1][0], logins[1][1])
+				}
+				_, err := provider.Exchange(ctx, state, code)
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Errorf("Exchange() error = %v, want %q", err, tt.wantError)
+				}
+			})
+		}
+	})
+}
+
+func TestOIDCConfigMapGroups(t *testing.T) {
+	config := OIDCConfig{GroupMappings: []OIDCGroupMapping{
+		{Group: "admins", Role: RoleAdmin},
+		{Group: "logistics", BusinessUnitId: 2},
+		{Group: "battalion", BusinessUnitId: 1, Role: "user"},
+	}}
+	tests := []struct {
+		name             string
+		groups           []string
+		wantBusinessUnit int32
+		wantRole         string
+	}{
+		{name: "no groups", groups: nil, wantBusinessUnit: 0, wantRole: ""},
+		{name: "unmapped groups", groups: []string{"visitors"}, wantBusinessUnit: 0, wantRole: ""},
+		{name: "business unit only", groups: []string{"logistics"}, wantBusinessUnit: 2, wantRole: ""},
+		{name: "both from one mapping", groups: []string{"battalion"}, wantBusinessUnit: 1, wantRole: "user"},
+		{name: "first mapping decides each", groups: []string{"battalion", "logistics", "admins"}, wantBusinessUnit: 2, wantRole: RoleAdmin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			businessUnitId, role := config.MapGroups(tt.groups)
+			if businessUnitId != tt.wantBusinessUnit || role != tt.wantRole {
+				t.Errorf("MapGroups(%v) = %d, %q, want %d, %q", tt.groups, businessUnitId, role, tt.wantBusinessUnit, tt.wantRole)
+			}
+		})
+	}
+}
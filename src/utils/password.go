@@ -0,0 +1,205 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Passwords are hashed with Argon2id. The parameters are recorded in the hash, so they can be raised later
+// without invalidating the passwords hashed before.
+const (
+	passwordHashPrefix = "argon2id"
+	argon2Time         = 3
+	argon2Memory       = 64 * 1024
+	argon2Threads      = 2
+	argon2KeyLength    = 32
+)
+
+var (
+	ErrWrongPassword = errors.New("the username or password is wrong")
+)
+
+// AccountsConfig sets how users log in with a password, and how they are invited to choose one.
+type AccountsConfig struct {
+	PasswordPolicy PasswordPolicy `yaml:"password_policy"`
+	// MaxFailedLogins is how many wrong passwords in a row lock an account for LockoutDuration.
+	MaxFailedLogins int `yaml:"max_failed_logins"`
+	// LockoutDuration is how long a locked account refuses logins, e.g. "15m".
+	LockoutDuration string `yaml:"lockout_duration"`
+	// SessionTTL is how long users logged in with a password stay logged in, e.g. "8h".
+	SessionTTL string `yaml:"session_ttl"`
+	// InvitationTTL is how long new users have to choose their password, e.g. "72h".
+	InvitationTTL string `yaml:"invitation_ttl"`
+	// PasswordResetTTL is how long links to reset a forgotten password, and tokens to enroll a second factor,
+	// work, e.g. "1h".
+	PasswordResetTTL string `yaml:"password_reset_ttl"`
+	// PasswordLinkURL is the page of the frontend where users choose their password. Invitation and password
+	// reset emails link to it with the token in the "token" query parameter.
+	PasswordLinkURL string `yaml:"password_link_url"`
+}
+
+// PasswordPolicy is what passwords users choose must satisfy.
+type PasswordPolicy struct {
+	MinLength        int  `yaml:"min_length"`
+	MaxLength        int  `yaml:"max_length"`
+	RequireUppercase bool `yaml:"require_uppercase"`
+	RequireLowercase bool `yaml:"require_lowercase"`
+	RequireDigit     bool `yaml:"require_digit"`
+	RequireSymbol    bool `yaml:"require_symbol"`
+}
+
+// ApplyAccountsDefaults fills in every setting the config leaves out.
+func ApplyAccountsDefaults(c *AccountsConfig) {
+	if c.PasswordPolicy.MinLength == 0 {
+		c.PasswordPolicy.MinLength = 12
+	}
+	if c.PasswordPolicy.MaxLength == 0 {
+		c.PasswordPolicy.MaxLength = 128
+	}
+	if c.MaxFailedLogins == 0 {
+		c.MaxFailedLogins = 5
+	}
+	defaults := map[*string]string{
+		&c.LockoutDuration:  "15m",
+		&c.SessionTTL:       "8h",
+		&c.InvitationTTL:    "72h",
+		&c.PasswordResetTTL: "1h",
+	}
+	for setting, value := range defaults {
+		if *setting == "" {
+			*setting = value
+		}
+	}
+}
+
+// Validate checks the config for settings that cannot be applied.
+func (c AccountsConfig) Validate() error {
+	if c.PasswordPolicy.MinLength < 1 || c.PasswordPolicy.MaxLength < c.PasswordPolicy.MinLength {
+		return fmt.Errorf("accounts.password_policy needs 0 < min_length <= max_length, got %d and %d", c.PasswordPolicy.MinLength, c.PasswordPolicy.MaxLength)
+	}
+	if c.MaxFailedLogins < 1 {
+		return fmt.Errorf("accounts.max_failed_logins must be positive, got %d", c.MaxFailedLogins)
+	}
+	durations := map[string]string{
+		"accounts.lockout_duration":   c.LockoutDuration,
+		"accounts.session_ttl":        c.SessionTTL,
+		"accounts.invitation_ttl":     c.InvitationTTL,
+		"accounts.password_reset_ttl": c.PasswordResetTTL,
+	}
+	for name, value := range durations {
+		if duration, err := time.ParseDuration(value); err != nil || duration <= 0 {
+			return fmt.Errorf("%s must be a positive duration such as \"1h\", got %q", name, value)
+		}
+	}
+	return nil
+}
+
+// Check returns why password does not satisfy the policy, or nil when it does.
+func (p PasswordPolicy) Check(password string, username string) error {
+	length := len([]rune(password))
+	if length < p.MinLength {
+		return fmt.Errorf("the password must be at least %d characters long", p.MinLength)
+	}
+	if length > p.MaxLength {
+		return fmt.Errorf("the password must be at most %d characters long", p.MaxLength)
+	}
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return errors.New("the password must not contain the username")
+	}
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	missing := []struct {
+		required, present bool
+		what              string
+	}{
+		{p.RequireUppercase, upper, "an uppercase letter"},
+		{p.RequireLowercase, lower, "a lowercase letter"},
+		{p.RequireDigit, digit, "a digit"},
+		{p.RequireSymbol, symbol, "a symbol"},
+	}
+	for _, requirement := range missing {
+		if requirement.required && !requirement.present {
+			return fmt.Errorf("the password must contain %s", requirement.what)
+		}
+	}
+	return nil
+}
+
+// HashPassword returns the hash and salt stored for password.
+func HashPassword(password string) (hash string, salt string, err error) {
+	var random [16]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		return "", "", err
+	}
+	key := argon2.IDKey([]byte(password), random[:], argon2Time, argon2Memory, argon2Threads, argon2KeyLength)
+	hash = fmt.Sprintf("%s$m=%d,t=%d,p=%d$%s", passwordHashPrefix, argon2Memory, argon2Time, argon2Threads, base64.RawStdEncoding.EncodeToString(key))
+	return hash, base64.RawStdEncoding.EncodeToString(random[:]), nil
+}
+
+// VerifyPassword reports whether password matches hash and salt. Passwords stored before they were hashed
+// by the server never match, so their users must reset them.
+func VerifyPassword(password string, hash string, salt string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 3 || parts[0] != passwordHashPrefix {
+		return false
+	}
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[1], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	saltBytes, err := base64.RawStdEncoding.DecodeString(salt)
+	if err != nil {
+		return false
+	}
+	key := argon2.IDKey([]byte(password), saltBytes, iterations, memory, threads, uint32(len(expected)))
+	return subtle.ConstantTimeCompare(key, expected) == 1
+}
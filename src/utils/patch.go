@@ -0,0 +1,348 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+var (
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+)
+
+// PatchDocument is a partial update sent with a PATCH request, either as a JSON Merge Patch
+// (RFC 7386) or as a JSON Patch (RFC 6902).
+type PatchDocument struct {
+	contentType string
+	merge       map[string]interface{}
+	operations  []jsonPatchOperation
+}
+
+type jsonPatchOperation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+// ParsePatchDocument reads a patch document of the given Content-Type from body.
+func ParsePatchDocument(contentType string, body io.Reader) (PatchDocument, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return PatchDocument{}, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, contentType)
+	}
+
+	patch := PatchDocument{contentType: mediaType}
+	d := json.NewDecoder(body)
+	switch mediaType {
+	case MergePatchContentType:
+		if err := d.Decode(&patch.merge); err != nil {
+			return PatchDocument{}, &ParsingError{Err: err}
+		}
+		if patch.merge == nil {
+			return PatchDocument{}, &ParsingError{Err: errors.New("merge patch must be a JSON object")}
+		}
+	case JSONPatchContentType:
+		d.DisallowUnknownFields()
+		if err := d.Decode(&patch.operations); err != nil {
+			return PatchDocument{}, &ParsingError{Err: err}
+		}
+	default:
+		return PatchDocument{}, fmt.Errorf("%w: %s, expected %s or %s", ErrUnsupportedMediaType, mediaType, MergePatchContentType, JSONPatchContentType)
+	}
+	return patch, nil
+}
+
+// Apply patches original and decodes the result into dest, which must be a pointer to the
+// same type as original. It returns the JSON names of the top-level fields the patch touched.
+func (p PatchDocument) Apply(original interface{}, dest interface{}) ([]string, error) {
+	encoded, err := json.Marshal(original)
+	if err != nil {
+		return nil, err
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(encoded, &document); err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	if p.contentType == MergePatchContentType {
+		document = mergePatch(document, p.merge).(map[string]interface{})
+		for name := range p.merge {
+			changed = append(changed, name)
+		}
+	} else {
+		var patched interface{} = document
+		for i, operation := range p.operations {
+			patched, err = operation.apply(patched)
+			if err != nil {
+				return nil, &ParsingError{Err: fmt.Errorf("operation %d: %v", i, err)}
+			}
+			changed = append(changed, topLevelMember(operation.Path))
+			if operation.Op == "move" {
+				changed = append(changed, topLevelMember(operation.From))
+			}
+		}
+		var ok bool
+		if document, ok = patched.(map[string]interface{}); !ok {
+			return nil, &ParsingError{Err: errors.New("patched document must be a JSON object")}
+		}
+	}
+
+	encoded, err = json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(encoded))
+	d.DisallowUnknownFields()
+	if err := d.Decode(dest); err != nil {
+		return nil, &ParsingError{Err: err}
+	}
+	return uniqueColumns(changed), nil
+}
+
+// mergePatch implements the MergePatch algorithm of RFC 7386.
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergePatch(targetObject[name], value)
+	}
+	return targetObject
+}
+
+func (o jsonPatchOperation) apply(document interface{}) (interface{}, error) {
+	var value interface{}
+	if o.Value != nil {
+		if err := json.Unmarshal(*o.Value, &value); err != nil {
+			return nil, err
+		}
+	} else if o.Op == "add" || o.Op == "replace" || o.Op == "test" {
+		return nil, fmt.Errorf("%s requires a value", o.Op)
+	}
+
+	switch o.Op {
+	case "add":
+		return jsonPointerSet(document, o.Path, value, true)
+	case "replace":
+		if _, err := jsonPointerGet(document, o.Path); err != nil {
+			return nil, err
+		}
+		return jsonPointerSet(document, o.Path, value, false)
+	case "remove":
+		return jsonPointerRemove(document, o.Path)
+	case "move", "copy":
+		moved, err := jsonPointerGet(document, o.From)
+		if err != nil {
+			return nil, err
+		}
+		if o.Op == "move" {
+			if document, err = jsonPointerRemove(document, o.From); err != nil {
+				return nil, err
+			}
+		}
+		return jsonPointerSet(document, o.Path, moved, true)
+	case "test":
+		actual, err := jsonPointerGet(document, o.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(actual, value) {
+			return nil, fmt.Errorf("test failed for %s", o.Path)
+		}
+		return document, nil
+	default:
+		return nil, fmt.Errorf("unknown operation %q", o.Op)
+	}
+}
+
+func splitJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func topLevelMember(pointer string) string {
+	tokens, _ := splitJSONPointer(pointer)
+	if len(tokens) == 0 {
+		return ""
+	}
+	return tokens[0]
+}
+
+func jsonPointerGet(document interface{}, pointer string) (interface{}, error) {
+	tokens, err := splitJSONPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	current := document
+	for _, token := range tokens {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path %s does not exist", pointer)
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("path %s does not exist", pointer)
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("path %s does not exist", pointer)
+		}
+	}
+	return current, nil
+}
+
+func jsonPointerSet(document interface{}, pointer string, value interface{}, insert bool) (interface{}, error) {
+	tokens, err := splitJSONPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	parentPointer := "/" + strings.Join(escapeJSONPointer(tokens[:len(tokens)-1]), "/")
+	parent := document
+	if len(tokens) > 1 {
+		if parent, err = jsonPointerGet(document, parentPointer); err != nil {
+			return nil, err
+		}
+	}
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = value
+	case []interface{}:
+		index := len(node)
+		if last != "-" {
+			if index, err = strconv.Atoi(last); err != nil || index < 0 || index > len(node) {
+				return nil, fmt.Errorf("invalid array index in %s", pointer)
+			}
+		}
+		if !insert {
+			if index == len(node) {
+				return nil, fmt.Errorf("invalid array index in %s", pointer)
+			}
+			node[index] = value
+			break
+		}
+		node = append(node[:index], append([]interface{}{value}, node[index:]...)...)
+		if len(tokens) == 1 {
+			return node, nil
+		}
+		return jsonPointerSet(document, parentPointer, node, false)
+	default:
+		return nil, fmt.Errorf("path %s does not exist", pointer)
+	}
+	return document, nil
+}
+
+func jsonPointerRemove(document interface{}, pointer string) (interface{}, error) {
+	tokens, err := splitJSONPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("cannot remove the whole document")
+	}
+	if _, err := jsonPointerGet(document, pointer); err != nil {
+		return nil, err
+	}
+	parentPointer := "/" + strings.Join(escapeJSONPointer(tokens[:len(tokens)-1]), "/")
+	parent := document
+	if len(tokens) > 1 {
+		parent, _ = jsonPointerGet(document, parentPointer)
+	}
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		delete(node, last)
+	case []interface{}:
+		index, _ := strconv.Atoi(last)
+		node = append(node[:index], node[index+1:]...)
+		if len(tokens) == 1 {
+			return node, nil
+		}
+		return jsonPointerSet(document, parentPointer, node, false)
+	}
+	return document, nil
+}
+
+func escapeJSONPointer(tokens []string) []string {
+	escaped := make([]string, len(tokens))
+	for i, token := range tokens {
+		escaped[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+	}
+	return escaped
+}
+
+func uniqueColumns(names []string) []string {
+	seen := make(map[string]bool)
+	var columns []string
+	for _, name := range names {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		columns = append(columns, name)
+	}
+	return columns
+}
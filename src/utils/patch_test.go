@@ -0,0 +1,177 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type patchTestItem struct {
+	Name    string            `json:"name"`
+	Count   int               `json:"count"`
+	Tags    []string          `json:"tags"`
+	Details map[string]string `json:"details"`
+}
+
+func TestPatchDocumentApply(t *testing.T) {
+	original := patchTestItem{Name: "radio", Count: 2, Tags: []string{"a", "b"}, Details: map[string]string{"colour": "green", "size": "s"}}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        patchTestItem
+		changed     []string
+	}{
+		{
+			name:        "merge patch replaces members",
+			contentType: MergePatchContentType,
+			body:        `{"name":"rifle","count":3}`,
+			want:        patchTestItem{Name: "rifle", Count: 3, Tags: []string{"a", "b"}, Details: map[string]string{"colour": "green", "size": "s"}},
+			changed:     []string{"count", "name"},
+		},
+		{
+			name:        "merge patch merges nested objects and removes nulls",
+			contentType: MergePatchContentType + "; charset=utf-8",
+			body:        `{"details":{"size":null,"weight":"2kg"}}`,
+			want:        patchTestItem{Name: "radio", Count: 2, Tags: []string{"a", "b"}, Details: map[string]string{"colour": "green", "weight": "2kg"}},
+			changed:     []string{"details"},
+		},
+		{
+			name:        "merge patch replaces arrays whole",
+			contentType: MergePatchContentType,
+			body:        `{"tags":["c"]}`,
+			want:        patchTestItem{Name: "radio", Count: 2, Tags: []string{"c"}, Details: map[string]string{"colour": "green", "size": "s"}},
+			changed:     []string{"tags"},
+		},
+		{
+			name:        "json patch replace and add",
+			contentType: JSONPatchContentType,
+			body:        `[{"op":"replace","path":"/name","value":"rifle"},{"op":"add","path":"/tags/1","value":"x"},{"op":"add","path":"/tags/-","value":"z"}]`,
+			want:        patchTestItem{Name: "rifle", Count: 2, Tags: []string{"a", "x", "b", "z"}, Details: map[string]string{"colour": "green", "size": "s"}},
+			changed:     []string{"name", "tags"},
+		},
+		{
+			name:        "json patch remove, copy and move",
+			contentType: JSONPatchContentType,
+			body:        `[{"op":"remove","path":"/tags/0"},{"op":"copy","from":"/details/colour","path":"/details/shade"},{"op":"move","from":"/details/size","path":"/details/fit"}]`,
+			want:        patchTestItem{Name: "radio", Count: 2, Tags: []string{"b"}, Details: map[string]string{"colour": "green", "shade": "green", "fit": "s"}},
+			changed:     []string{"tags", "details"},
+		},
+		{
+			name:        "json patch test and escaped pointers",
+			contentType: JSONPatchContentType,
+			body:        `[{"op":"test","path":"/count","value":2},{"op":"add","path":"/details/a~1b~0c","value":"v"}]`,
+			want:        patchTestItem{Name: "radio", Count: 2, Tags: []string{"a", "b"}, Details: map[string]string{"colour": "green", "size": "s", "a/b~c": "v"}},
+			changed:     []string{"count", "details"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := ParsePatchDocument(tt.contentType, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("ParsePatchDocument() error = %v", err)
+			}
+			var got patchTestItem
+			changed, err := patch.Apply(original, &got)
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %+v, want %+v", got, tt.want)
+			}
+			if !sameMembers(changed, tt.changed) {
+				t.Errorf("Apply() changed = %v, want %v", changed, tt.changed)
+			}
+		})
+	}
+	if original.Name != "radio" || len(original.Tags) != 2 {
+		t.Errorf("Apply() modified the original: %+v", original)
+	}
+}
+
+func TestPatchDocumentErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		parseError  bool
+	}{
+		{name: "unsupported media type", contentType: "application/json", body: `{}`, parseError: true},
+		{name: "merge patch must be an object", contentType: MergePatchContentType, body: `null`, parseError: true},
+		{name: "malformed json patch", contentType: JSONPatchContentType, body: `{"op":"add"}`, parseError: true},
+		{name: "unknown member in json patch", contentType: JSONPatchContentType, body: `[{"op":"add","path":"/name","value":"x","extra":1}]`, parseError: true},
+		{name: "unknown operation", contentType: JSONPatchContentType, body: `[{"op":"merge","path":"/name","value":"x"}]`},
+		{name: "failed test", contentType: JSONPatchContentType, body: `[{"op":"test","path":"/name","value":"rifle"}]`},
+		{name: "replace of a missing member", contentType: JSONPatchContentType, body: `[{"op":"replace","path":"/details/missing","value":"x"}]`},
+		{name: "add without value", contentType: JSONPatchContentType, body: `[{"op":"add","path":"/name"}]`},
+		{name: "array index out of range", contentType: JSONPatchContentType, body: `[{"op":"add","path":"/tags/5","value":"x"}]`},
+		{name: "invalid pointer", contentType: JSONPatchContentType, body: `[{"op":"remove","path":"name"}]`},
+		{name: "unknown field in result", contentType: MergePatchContentType, body: `{"colour":"red"}`},
+		{name: "wrong type in result", contentType: JSONPatchContentType, body: `[{"op":"replace","path":"/count","value":"many"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := ParsePatchDocument(tt.contentType, strings.NewReader(tt.body))
+			if tt.parseError {
+				if err == nil {
+					t.Fatal("ParsePatchDocument() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePatchDocument() error = %v", err)
+			}
+			var got patchTestItem
+			_, err = patch.Apply(patchTestItem{Name: "radio", Tags: []string{"a"}, Details: map[string]string{}}, &got)
+			var parsingError *ParsingError
+			if !errors.As(err, &parsingError) {
+				t.Errorf("Apply() error = %v, want a *ParsingError", err)
+			}
+		})
+	}
+}
+
+func sameMembers(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[string]int)
+	for _, s := range a {
+		counts[s]++
+	}
+	for _, s := range b {
+		counts[s]--
+	}
+	for _, n := range counts {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+const (
+	RoleAdmin   = "admin"
+	RoleService = "service"
+)
+
+// Scopes of API keys. ScopeAdmin grants every other scope.
+const (
+	ScopeRead   = "read"
+	ScopeWrite  = "write"
+	ScopeDelete = "delete"
+	ScopeAdmin  = "admin"
+)
+
+// Scopes lists every scope an API key may be granted.
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeDelete, ScopeAdmin}
+
+// ScopeForMethod returns the scope required to make requests with method.
+func ScopeForMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ScopeRead
+	case http.MethodDelete:
+		return ScopeDelete
+	default:
+		return ScopeWrite
+	}
+}
+
+var (
+	ErrForbidden              = errors.New("you are not allowed to perform this action")
+	ErrAuthenticationRequired = errors.New("authentication is required, present an API key or a session")
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// Subject uniquely identifies the caller, and is recorded as the actor of its changes.
+	Subject string
+	// UserId is the ID of the user behind the caller, or 0 when it is not a user.
+	UserId int32
+	Role   string
+	// Scopes restrict what the caller may do, see ScopeForMethod. A nil Scopes places no restriction.
+	Scopes []string
+	// MFAPending is set for users who logged in but have yet to present their second factor.
+	MFAPending bool
+}
+
+// Allows reports whether the scopes of the principal permit requests with method.
+func (p Principal) Allows(method string) bool {
+	if p.Scopes == nil {
+		return true
+	}
+	required := ScopeForMethod(method)
+	for _, scope := range p.Scopes {
+		if scope == required || scope == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+type principalContextKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying principal.
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal authenticated for the request of ctx, if any.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(Principal)
+	return principal, ok
+}
+
+// ActorFromContext returns the subject of the principal of ctx, or "" for anonymous requests.
+func ActorFromContext(ctx context.Context) string {
+	principal, _ := PrincipalFromContext(ctx)
+	return principal.Subject
+}
+
+// IsAdmin reports whether the request of ctx was made by an administrator.
+func IsAdmin(ctx context.Context) bool {
+	principal, ok := PrincipalFromContext(ctx)
+	return ok && principal.Role == RoleAdmin
+}
+
+// RequireAuthentication rejects anonymous requests with 401. It must run after the authentication middlewares.
+func RequireAuthentication(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := PrincipalFromContext(r.Context()); !ok {
+			DefaultErrorHandler(w, r, ErrAuthenticationRequired, &ImplResponse{Code: http.StatusUnauthorized})
+			return
+		}
+		inner.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,74 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireAuthentication(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	routes := testRouter{
+		"Public":  Route{Method: http.MethodGet, Pattern: "public", HandlerFunc: ok, Public: true},
+		"Private": Route{Method: http.MethodGet, Pattern: "private", HandlerFunc: ok},
+	}
+	router := NewRouter("/api", routes)
+	// Stands in for the authentication middlewares
+	router.Use(func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "ApiKey valid" {
+				r = r.WithContext(WithPrincipal(r.Context(), Principal{Subject: "service:test", Role: RoleService}))
+			}
+			inner.ServeHTTP(w, r)
+		})
+	})
+
+	tests := []struct {
+		path          string
+		authenticated bool
+		want          int
+	}{
+		{path: "/api/public", authenticated: false, want: http.StatusNoContent},
+		{path: "/api/public", authenticated: true, want: http.StatusNoContent},
+		{path: "/api/private", authenticated: false, want: http.StatusUnauthorized},
+		{path: "/api/private", authenticated: true, want: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.authenticated {
+			r.Header.Set("Authorization", "ApiKey valid")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("GET %s (authenticated %v): status = %d, want %d", tt.path, tt.authenticated, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testRouter serves routes in tests.
+type testRouter Routes
+
+func (t testRouter) Routes() Routes {
+	return Routes(t)
+}
+
+func TestRecoveryIsOutermost(t *testing.T) {
+	routes := testRouter{
+		"Panic": Route{Method: http.MethodGet, Pattern: "panic", HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+			panic("handler")
+		}, Public: true},
+		"Fine": Route{Method: http.MethodGet, Pattern: "fine", HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}, Public: true},
+	}
+	router := NewRouter("/api", routes)
+	router.Use(func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("middleware") == "panic" {
+				panic("middleware")
+			}
+			inner.ServeHTTP(w, r)
+		})
+	})
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{path: "/api/panic", want: http.StatusInternalServerError},
+		{path: "/api/fine?middleware=panic", want: http.StatusInternalServerError},
+		{path: "/api/fine", want: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.want {
+			t.Errorf("GET %s: status = %d, want %d", tt.path, w.Code, tt.want)
+			continue
+		}
+		if tt.want != http.StatusInternalServerError {
+			continue
+		}
+		var problem Problem
+		if w.Header().Get("Content-Type") != ProblemContentType || json.Unmarshal(w.Body.Bytes(), &problem) != nil || problem.Status != tt.want {
+			t.Errorf("GET %s: response is not a problem: %s %q", tt.path, w.Header().Get("Content-Type"), w.Body.String())
+		}
+	}
+}
@@ -33,6 +33,8 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -41,6 +43,10 @@ type Route struct {
 	Method      string
 	Pattern     string
 	HandlerFunc http.HandlerFunc
+	// Public routes may be called anonymously, every other route requires an authenticated principal.
+	Public bool
+	// Credentials routes answer with secrets such as API keys or session tokens, which Idempotency never stores.
+	Credentials bool
 }
 
 type Routes map[string]Route
@@ -59,18 +65,29 @@ const errMsgRequiredMissing = "required parameter is missing"
 
 func NewRouter(basePath string, routers ...Router) *mux.Router {
 	router := mux.NewRouter().StrictSlash(true)
+	// Recovery is the outermost middleware, so it also catches panics of the middlewares used after it
+	router.Use(Recovery)
+	// Preflight requests are answered by the CORS middleware; this route only makes them match
 	router.Methods("OPTIONS").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		//TODO: Modify for deployments
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 		w.WriteHeader(http.StatusNoContent)
 	})
 	for _, api := range routers {
 		for name, route := range api.Routes() {
 			var handler http.Handler
 			handler = route.HandlerFunc
+			if route.Credentials {
+				handler = credentialRoute(handler)
+			}
+			handler = Idempotency(handler)
+			if !route.Public {
+				handler = RequireAuthentication(handler)
+			}
 			handler = Logger(handler, name)
+			handler = Metrics(handler, name)
+			handler = Tracing(handler, name)
+			if route.Public {
+				handler = publicRoute{handler}
+			}
 			router.Methods(route.Method).
 				Path(
 					fmt.Sprintf("%s/%s", basePath, route.Pattern)).
@@ -82,12 +99,28 @@ func NewRouter(basePath string, routers ...Router) *mux.Router {
 	return router
 }
 
-func EncodeJSONResponse(i interface{}, status *int, w http.ResponseWriter) error {
+// publicRoute marks the handlers of public routes, see isPublicRoute.
+type publicRoute struct {
+	http.Handler
+}
+
+// isPublicRoute reports whether the route of r may be called anonymously.
+func isPublicRoute(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return false
+	}
+	_, ok := route.GetHandler().(publicRoute)
+	return ok
+}
+
+func EncodeJSONResponse(i interface{}, status *int, headers map[string][]string, w http.ResponseWriter) error {
 	wHeader := w.Header()
-	//TODO: Modify for deployments
-	wHeader.Set("Access-Control-Allow-Origin", "*")
-	wHeader.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT, DELETE")
-	wHeader.Set("Access-Control-Allow-Headers", "Content-Type")
+	for key, values := range headers {
+		for _, value := range values {
+			wHeader.Add(key, value)
+		}
+	}
 
 	f, ok := i.(*os.File)
 	if ok {
@@ -130,6 +163,44 @@ func ParseInt32(param string) (int32, error) {
 	return int32(val), err
 }
 
+// ParseDuration parses a string parameter to a duration, given in days such as "30d" or as a Go duration such as "12h".
+func ParseDuration(param string) (time.Duration, error) {
+	if param == "" {
+		return 0, nil
+	}
+
+	if days, ok := strings.CutSuffix(param, "d"); ok {
+		val, err := strconv.ParseInt(days, 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q, expected days such as \"30d\"", param)
+		}
+		return time.Duration(val) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(param)
+}
+
+// ParseBool parses a string parameter to a bool.
+func ParseBool(param string) (bool, error) {
+	if param == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(param)
+}
+
+// WithParse parses the parameter if it is present.
+func WithParse[T Number | string | bool](parse ParseString[T]) Operation[T] {
+	var empty T
+	return func(actual string) (T, bool, error) {
+		if actual == "" {
+			return empty, true, nil
+		}
+
+		v, err := parse(actual)
+		return v, false, err
+	}
+}
+
 // WithRequire validates required fields are in body.
 func WithRequire[T Number | string | bool](parse ParseString[T]) Operation[T] {
 	var empty T
@@ -160,3 +231,9 @@ func ParseNumericParameter[T Number](param string, fn Operation[T], checks ...Co
 
 	return v, nil
 }
+
+// ParseBoolParameter parses a string parameter to a bool.
+func ParseBoolParameter(param string, fn Operation[bool]) (bool, error) {
+	v, _, err := fn(param)
+	return v, err
+}
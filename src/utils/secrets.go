@@ -0,0 +1,213 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	envSecretPrefix       = "env:"
+	fileSecretPrefix      = "file:"
+	encryptedSecretPrefix = "encrypted:"
+	masterKeySize         = 32
+)
+
+// MasterKeyVariable is the environment variable the master key of the encrypted secrets file is read from
+// unless the database config names another source.
+const MasterKeyVariable = EnvironmentPrefix + "_MASTER_KEY"
+
+type secretsConfig struct {
+	// File is the encrypted secrets file that encrypted: references are looked up in.
+	File string `yaml:"file"`
+	// MasterKey unlocks File. It is itself a reference, and defaults to env:SMIDGEN_MASTER_KEY.
+	MasterKey string `yaml:"master_key"`
+}
+
+// secretResolver turns credential settings into their values. A setting is used as is, unless it is one of
+//
+//	env:NAME        the value of the environment variable NAME
+//	file:PATH       the contents of the file at PATH, without trailing newlines
+//	encrypted:NAME  the secret NAME of the encrypted secrets file
+type secretResolver struct {
+	config  secretsConfig
+	secrets map[string]string
+}
+
+func (r *secretResolver) resolve(setting string) (string, error) {
+	switch {
+	case strings.HasPrefix(setting, envSecretPrefix):
+		name := strings.TrimPrefix(setting, envSecretPrefix)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(setting, fileSecretPrefix):
+		path := strings.TrimPrefix(setting, fileSecretPrefix)
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %v", err)
+		}
+		return strings.TrimRight(string(contents), "\r\n"), nil
+	case strings.HasPrefix(setting, encryptedSecretPrefix):
+		name := strings.TrimPrefix(setting, encryptedSecretPrefix)
+		if r.secrets == nil {
+			if err := r.unlock(); err != nil {
+				return "", err
+			}
+		}
+		value, ok := r.secrets[name]
+		if !ok {
+			return "", fmt.Errorf("secret %s is not in %s", name, r.config.File)
+		}
+		return value, nil
+	default:
+		return setting, nil
+	}
+}
+
+func (r *secretResolver) unlock() error {
+	if r.config.File == "" {
+		return errors.New("encrypted secrets are referenced but no secrets file was configured")
+	}
+	masterKeySetting := r.config.MasterKey
+	if masterKeySetting == "" {
+		masterKeySetting = envSecretPrefix + MasterKeyVariable
+	}
+	if strings.HasPrefix(masterKeySetting, encryptedSecretPrefix) {
+		return errors.New("the master key cannot be stored in the encrypted secrets file")
+	}
+	masterKey, err := r.resolve(masterKeySetting)
+	if err != nil {
+		return fmt.Errorf("failed to read the master key: %v", err)
+	}
+	secrets, err := ReadEncryptedSecrets(r.config.File, masterKey)
+	if err != nil {
+		return err
+	}
+	r.secrets = secrets
+	return nil
+}
+
+// isSecretReference reports whether setting refers to a secret rather than holding it.
+func isSecretReference(setting string) bool {
+	for _, prefix := range []string{envSecretPrefix, fileSecretPrefix, encryptedSecretPrefix} {
+		if strings.HasPrefix(setting, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateMasterKey returns a new random master key for an encrypted secrets file.
+func GenerateMasterKey() (string, error) {
+	key := make([]byte, masterKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ReadEncryptedSecrets decrypts the secrets file at path with masterKey. A missing file holds no secrets.
+func ReadEncryptedSecrets(path string, masterKey string) (map[string]string, error) {
+	aead, err := secretsCipher(masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %v", err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("secrets file %s is corrupt", path)
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets file %s, check the master key", path)
+	}
+
+	secrets := make(map[string]string)
+	if err := yaml.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("secrets file %s is corrupt: %v", path, err)
+	}
+	return secrets, nil
+}
+
+// WriteEncryptedSecrets encrypts secrets with masterKey into the file at path, replacing its contents.
+func WriteEncryptedSecrets(path string, masterKey string, secrets map[string]string) error {
+	aead, err := secretsCipher(masterKey)
+	if err != nil {
+		return err
+	}
+	plaintext, err := yaml.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, nil)
+	return os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(sealed)+"\n"), 0600)
+}
+
+// SecretNames returns the names of secrets in order.
+func SecretNames(secrets map[string]string) []string {
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func secretsCipher(masterKey string) (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(masterKey))
+	if err != nil || len(key) != masterKeySize {
+		return nil, fmt.Errorf("the master key must be %d base64 encoded bytes", masterKeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
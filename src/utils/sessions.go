@@ -0,0 +1,260 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// SessionCookieName is the cookie browsers send the session token in after logging in.
+	SessionCookieName = "smidgen_session"
+	// SessionScheme is the scheme of Authorization headers carrying a session token, as in "Authorization: Bearer ...".
+	SessionScheme = "Bearer"
+)
+
+var (
+	ErrInvalidSession = errors.New("the session is invalid, expired or revoked")
+	ErrMFARequired    = errors.New("the second factor of the login must be presented first")
+)
+
+// mfaPendingRoutes are the routes users may call before presenting their second factor. Users without one
+// may only enroll with the token they were emailed, so a password alone does not bind a new factor.
+var mfaPendingRoutes = map[string]bool{
+	"EnrollMfa":      true,
+	"ConfirmMfa":     true,
+	"VerifyMfa":      true,
+	"Logout":         true,
+	"GetCurrentUser": true,
+}
+
+// UserSubject returns the subject of principals authenticated as the user userId, which is the actor
+// recorded in the audit log for their actions.
+func UserSubject(userId int32) string {
+	return fmt.Sprintf("user:%d", userId)
+}
+
+// CreateSession starts a session of userId lasting ttl, and returns the token authenticating it. Sessions with
+// mfaPending set only give access to the routes completing the login until CompleteSessionMFA is called.
+// Tokens are random, so only a SHA-256 hash of them is stored, as for API keys. The token is returned as
+// handed to clients, see TenantToken.
+func CreateSession(ctx context.Context, userId int32, ttl time.Duration, mfaPending bool) (string, time.Time, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer dao.Close()
+
+	now := time.Now().UTC()
+	expiresAt := now.Add(ttl)
+	p := dao.dialect.placeholder
+	_, err = dao.executor().Exec(fmt.Sprintf("INSERT INTO %s (token_hash, user_id, created_at, expires_at, mfa_pending) VALUES (%s, %s, %s, %s, %s)",
+		dao.dialect.table("sessions"), p(1), p(2), p(3), p(4), p(5)), HashAPIKeySecret(token), userId, now, expiresAt, mfaPending)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return TenantToken(ctx, token), expiresAt, nil
+}
+
+// RevokeSession ends the session of token. Revoking an unknown or already revoked session is not an error.
+func RevokeSession(ctx context.Context, token string) error {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return err
+	}
+	defer dao.Close()
+
+	p := dao.dialect.placeholder
+	_, err = dao.executor().Exec(fmt.Sprintf("UPDATE %s SET revoked_at=%s WHERE token_hash=%s AND revoked_at IS NULL",
+		dao.dialect.table("sessions"), p(1), p(2)), time.Now().UTC(), HashAPIKeySecret(token))
+	return err
+}
+
+// RevokeUserSessions ends every session of userId, such as after their password changed.
+func RevokeUserSessions(ctx context.Context, userId int32) error {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		return err
+	}
+	defer dao.Close()
+
+	p := dao.dialect.placeholder
+	_, err = dao.executor().Exec(fmt.Sprintf("UPDATE %s SET revoked_at=%s WHERE user_id=%s AND revoked_at IS NULL",
+		dao.dialect.table("sessions"), p(1), p(2)), time.Now().UTC(), userId)
+	return err
+}
+
+// ExpireSessions deletes every session that has expired, along with logins that were never completed and
+// expired tokens of emails, and unlocks the users whose lockout is over, in the tables of the tenant of ctx.
+func ExpireSessions(ctx context.Context, configPath string) error {
+	dao, err := NewDatabaseConnectionContext(ctx, configPath, "write")
+	if err != nil {
+		return err
+	}
+	defer dao.Close()
+
+	now := time.Now().UTC()
+	p := dao.dialect.placeholder
+	if _, err := dao.executor().Exec(fmt.Sprintf("DELETE FROM %s WHERE expires_at<%s", dao.dialect.table("sessions"), p(1)), now); err != nil {
+		return err
+	}
+	if _, err := dao.executor().Exec(fmt.Sprintf("DELETE FROM %s WHERE created_at<%s", dao.dialect.table("login_states"), p(1)), now.Add(-loginStateTTL)); err != nil {
+		return err
+	}
+	if _, err := dao.executor().Exec(fmt.Sprintf("DELETE FROM %s WHERE expires_at<%s", dao.dialect.table("user_tokens"), p(1)), now); err != nil {
+		return err
+	}
+	return unlockExpiredLockouts(dao)
+}
+
+// DeleteUserLogins deletes the sessions, second factors and emailed tokens of userId and unlinks the user from
+// their identity provider accounts, so the user can be purged.
+func DeleteUserLogins(ctx context.Context, userId int32) error {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "delete")
+	if err != nil {
+		return err
+	}
+	defer dao.Close()
+
+	for _, table := range []string{"sessions", "user_identities", "mfa_recovery_codes", "user_mfa", "user_tokens"} {
+		_, err := dao.executor().Exec(fmt.Sprintf("DELETE FROM %s WHERE user_id=%s", dao.dialect.table(table), dao.dialect.placeholder(1)), userId)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SessionTokenFromRequest returns the session token of r, taken from an "Authorization: Bearer" header or
+// else the session cookie, and whether it came from the header. The tenant prefix of the token is left out,
+// since Tenancy already checked it.
+func SessionTokenFromRequest(r *http.Request) (string, bool) {
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, SessionScheme) {
+		_, token = splitTenantToken(strings.TrimSpace(token))
+		return token, true
+	}
+	if cookie, err := r.Cookie(SessionCookieName); err == nil {
+		_, token := splitTenantToken(cookie.Value)
+		return token, false
+	}
+	return "", false
+}
+
+// SessionAuthentication authenticates requests carrying a session token as the user who logged in.
+// Invalid tokens, including those of deleted and deactivated users, are rejected with 401, and an invalid
+// session cookie is cleared. Public routes ignore an invalid cookie, so that the browser can still log in again.
+// Until the user presents their second factor, only the routes completing the login are allowed. Requests
+// already authenticated otherwise are passed on unchanged.
+func SessionAuthentication() func(http.Handler) http.Handler {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if _, ok := PrincipalFromContext(ctx); ok {
+				inner.ServeHTTP(w, r)
+				return
+			}
+			token, bearer := SessionTokenFromRequest(r)
+			if token == "" {
+				inner.ServeHTTP(w, r)
+				return
+			}
+			principal, err := authenticateSession(ctx, token)
+			if err != nil {
+				LoggerFromContext(ctx).Warnf("Rejected session: %v", err)
+				if !bearer && isPublicRoute(r) {
+					inner.ServeHTTP(w, r)
+					return
+				}
+				if !bearer {
+					http.SetCookie(w, &http.Cookie{Name: SessionCookieName, Path: "/", MaxAge: -1, HttpOnly: true})
+				}
+				w.Header().Set("WWW-Authenticate", SessionScheme)
+				DefaultErrorHandler(w, r, ErrInvalidSession, &ImplResponse{Code: http.StatusUnauthorized})
+				return
+			}
+			if principal.MFAPending {
+				if route := mux.CurrentRoute(r); route == nil || !mfaPendingRoutes[route.GetName()] {
+					DefaultErrorHandler(w, r, ErrMFARequired, &ImplResponse{Code: http.StatusUnauthorized})
+					return
+				}
+			}
+			inner.ServeHTTP(w, r.WithContext(WithPrincipal(ctx, principal)))
+		})
+	}
+}
+
+func authenticateSession(ctx context.Context, token string) (Principal, error) {
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "read")
+	if err != nil {
+		return Principal{}, err
+	}
+	defer dao.Close()
+
+	var userId int32
+	var role string
+	var expiresAt time.Time
+	var mfaPending bool
+	var status string
+	var revokedAt, deletedAt, lastActivity sql.NullTime
+	err = dao.executor().QueryRow(fmt.Sprintf("SELECT s.user_id, u.role, s.expires_at, s.revoked_at, u.deleted_at, s.mfa_pending, u.status, u.last_activity_at FROM %s s JOIN %s u ON u.user_id=s.user_id WHERE s.token_hash=%s",
+		dao.dialect.table("sessions"), dao.dialect.table("users"), dao.dialect.placeholder(1)), HashAPIKeySecret(token)).
+		Scan(&userId, &role, &expiresAt, &revokedAt, &deletedAt, &mfaPending, &status, &lastActivity)
+	if err == sql.ErrNoRows {
+		return Principal{}, errors.New("the session does not exist")
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+	if revokedAt.Valid {
+		return Principal{}, fmt.Errorf("the session of user %d has been revoked", userId)
+	}
+	if time.Now().After(expiresAt) {
+		return Principal{}, fmt.Errorf("the session of user %d has expired", userId)
+	}
+	if deletedAt.Valid {
+		return Principal{}, fmt.Errorf("user %d has been deleted", userId)
+	}
+	if status == UserStatusDeactivated {
+		return Principal{}, fmt.Errorf("user %d has been deactivated", userId)
+	}
+	if err := recordActivity(ctx, userId, lastActivity); err != nil {
+		LoggerFromContext(ctx).Warnf("Failed to record the activity of user %d: %v", userId, err)
+	}
+	return Principal{Subject: UserSubject(userId), UserId: userId, Role: role, MFAPending: mfaPending}, nil
+}
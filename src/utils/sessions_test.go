@@ -0,0 +1,208 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// sessionTestBusinessUnit and sessionTestUser are the columns of business units and users that tests fill in.
+type sessionTestBusinessUnit struct {
+	BusinessUnitId int32
+	Name           string
+	PointOfContact string
+	AddressLineOne string
+	State          string
+	City           string
+	Country        string
+}
+
+type sessionTestUser struct {
+	UserId         int32
+	BusinessUnitId int32
+	Username       string
+	PasswordHash   string
+	PasswordSalt   string
+	FirstName      string
+	LastName       string
+	PrimaryEmail   string
+}
+
+// createTestUser creates the user username, in a business unit of their own, in the tenant of ctx.
+func createTestUser(t *testing.T, ctx context.Context, username string) int32 {
+	t.Helper()
+	connection := func() *DatabaseConnection {
+		dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+		if err != nil {
+			t.Fatalf("NewDatabaseConnectionContext() error = %v", err)
+		}
+		return dao
+	}
+	businessUnitId, err := connection().InsertRowReturningID("business_units", sessionTestBusinessUnit{Name: username})
+	if err != nil {
+		t.Fatalf("InsertRowReturningID() error = %v", err)
+	}
+	userId, err := connection().InsertRowReturningID("users", sessionTestUser{BusinessUnitId: int32(businessUnitId), Username: username})
+	if err != nil {
+		t.Fatalf("InsertRowReturningID() error = %v", err)
+	}
+	return int32(userId)
+}
+
+// sessionTestRouter serves the public route logging in, a route completing the login and a normal route behind
+// SessionAuthentication.
+func sessionTestRouter() http.Handler {
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	router := NewRouter("/api", testRouter{
+		"Login":         Route{Method: http.MethodPost, Pattern: "auth/login", HandlerFunc: ok, Public: true},
+		"VerifyMfa":     Route{Method: http.MethodPost, Pattern: "auth/mfa/verify", HandlerFunc: ok},
+		"ListEquipment": Route{Method: http.MethodGet, Pattern: "equipment", HandlerFunc: ok},
+	})
+	router.Use(SessionAuthentication())
+	return router
+}
+
+func TestMFAPendingSession(t *testing.T) {
+	forEachTestDatabase(t, func(t *testing.T) {
+		ctx := context.Background()
+		userId := createTestUser(t, ctx, "jdoe")
+		pending, _, err := CreateSession(ctx, userId, time.Hour, true)
+		if err != nil {
+			t.Fatalf("CreateSession() error = %v", err)
+		}
+		complete, _, err := CreateSession(ctx, userId, time.Hour, false)
+		if err != nil {
+			t.Fatalf("CreateSession() error = %v", err)
+		}
+
+		tests := []struct {
+			name   string
+			method string
+			path   string
+			bearer string
+			cookie string
+			want   int
+		}{
+			{name: "pending bearer on a normal route", method: http.MethodGet, path: "/api/equipment", bearer: pending, want: http.StatusUnauthorized},
+			{name: "pending cookie on a normal route", method: http.MethodGet, path: "/api/equipment", cookie: pending, want: http.StatusUnauthorized},
+			{name: "token dropped on a normal route", method: http.MethodGet, path: "/api/equipment", want: http.StatusUnauthorized},
+			{name: "pending bearer completing the login", method: http.MethodPost, path: "/api/auth/mfa/verify", bearer: pending, want: http.StatusNoContent},
+			{name: "pending cookie completing the login", method: http.MethodPost, path: "/api/auth/mfa/verify", cookie: pending, want: http.StatusNoContent},
+			{name: "token dropped completing the login", method: http.MethodPost, path: "/api/auth/mfa/verify", want: http.StatusUnauthorized},
+			{name: "complete session on a normal route", method: http.MethodGet, path: "/api/equipment", bearer: complete, want: http.StatusNoContent},
+		}
+		router := sessionTestRouter()
+		for _, tt := range tests {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.bearer != "" {
+				r.Header.Set("Authorization", SessionScheme+" "+tt.bearer)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: SessionCookieName, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+			}
+		}
+	})
+}
+
+func TestRejectedSessions(t *testing.T) {
+	forEachTestDatabase(t, func(t *testing.T) {
+		ctx := context.Background()
+		session := func(userId int32) string {
+			token, _, err := CreateSession(ctx, userId, time.Hour, false)
+			if err != nil {
+				t.Fatalf("CreateSession() error = %v", err)
+			}
+			return token
+		}
+		active := session(createTestUser(t, ctx, "jdoe"))
+		revoked := session(createTestUser(t, ctx, "rroe"))
+		if err := RevokeSession(ctx, revoked); err != nil {
+			t.Fatalf("RevokeSession() error = %v", err)
+		}
+		deactivatedId := createTestUser(t, ctx, "mmoe")
+		deactivated := session(deactivatedId)
+		dao := testConnection(t, "write")
+		if _, err := dao.executor().Exec(fmt.Sprintf("UPDATE %s SET status=%s WHERE user_id=%s", dao.dialect.table("users"),
+			dao.dialect.placeholder(1), dao.dialect.placeholder(2)), UserStatusDeactivated, deactivatedId); err != nil {
+			t.Fatal(err)
+		}
+		dao.Close()
+
+		tests := []struct {
+			name   string
+			method string
+			path   string
+			bearer string
+			cookie string
+			want   int
+		}{
+			{name: "active cookie", method: http.MethodGet, path: "/api/equipment", cookie: active, want: http.StatusNoContent},
+			{name: "unknown cookie", method: http.MethodGet, path: "/api/equipment", cookie: "unknown", want: http.StatusUnauthorized},
+			{name: "revoked cookie", method: http.MethodGet, path: "/api/equipment", cookie: revoked, want: http.StatusUnauthorized},
+			{name: "deactivated cookie", method: http.MethodGet, path: "/api/equipment", cookie: deactivated, want: http.StatusUnauthorized},
+			{name: "deactivated bearer", method: http.MethodGet, path: "/api/equipment", bearer: deactivated, want: http.StatusUnauthorized},
+			{name: "deactivated cookie completing the login", method: http.MethodPost, path: "/api/auth/mfa/verify", cookie: deactivated, want: http.StatusUnauthorized},
+			{name: "revoked cookie logging in again", method: http.MethodPost, path: "/api/auth/login", cookie: revoked, want: http.StatusNoContent},
+			{name: "revoked bearer logging in again", method: http.MethodPost, path: "/api/auth/login", bearer: revoked, want: http.StatusUnauthorized},
+		}
+		router := sessionTestRouter()
+		for _, tt := range tests {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.bearer != "" {
+				r.Header.Set("Authorization", SessionScheme+" "+tt.bearer)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: SessionCookieName, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+				continue
+			}
+			// Rejected cookies are cleared, so the browser stops sending them
+			cleared := false
+			for _, cookie := range w.Result().Cookies() {
+				cleared = cleared || (cookie.Name == SessionCookieName && cookie.MaxAge < 0)
+			}
+			if wantCleared := tt.cookie != "" && tt.want == http.StatusUnauthorized; cleared != wantCleared {
+				t.Errorf("%s: cookie cleared = %v, want %v", tt.name, cleared, wantCleared)
+			}
+		}
+	})
+}
@@ -0,0 +1,487 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// TenancyConfig decides which tenant each request belongs to. Every tenant has tables of its own, so the
+// users, equipment and audit log of a tenant are never visible to another.
+type TenancyConfig struct {
+	// Enabled turns on tenant resolution. When disabled, every request belongs to the default tenant.
+	Enabled bool `yaml:"enabled"`
+	// BaseDomain is the domain tenants are subdomains of: "acme.example.com" resolves to tenant acme when
+	// the base domain is "example.com", and "example.com" itself to the default tenant.
+	BaseDomain string `yaml:"base_domain"`
+	// DefaultHosts are the hosts of the default tenant. When empty, every host that resolves to no tenant
+	// belongs to the default tenant; otherwise requests to other hosts are rejected with 404.
+	DefaultHosts []string `yaml:"default_hosts"`
+}
+
+// Validate checks the tenancy settings for hosts that could never match a request.
+func (c TenancyConfig) Validate() error {
+	for _, host := range append([]string{c.BaseDomain}, c.DefaultHosts...) {
+		if strings.ContainsAny(host, "/:* ") {
+			return fmt.Errorf("invalid tenancy host %q, expected a bare host name such as example.com", host)
+		}
+	}
+	return nil
+}
+
+// Tenant is an organisation whose data is isolated from every other organisation sharing the server.
+// The default tenant, which owns the tables that predate tenancy, is the zero Tenant.
+type Tenant struct {
+	TenantId int32 `json:"tenant_id"`
+	// Slug names the tenant in its subdomain, its tokens and its database schema.
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+	// Hosts are the custom domains the tenant is served on, besides its subdomain of the base domain.
+	Hosts     []string   `json:"hosts"`
+	CreatedAt time.Time  `json:"created_at"`
+	CreatedBy string     `json:"created_by"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+var (
+	ErrUnknownTenant  = errors.New("no tenant is served on this host")
+	ErrTenantMismatch = errors.New("the credentials belong to another tenant")
+	ErrTenantNotFound = errors.New("the tenant does not exist")
+	ErrTenantConflict = errors.New("the slug or a host of the tenant is already taken")
+)
+
+// tenantlessRoutes are the probes of the server itself, which are served on every host, such as the address of
+// the instance, without resolving a tenant.
+var tenantlessRoutes = map[string]bool{
+	"LivezGet":            true,
+	"ReadyzGet":           true,
+	"CheckHealthcheckGet": true,
+}
+
+// tenantCacheTTL bounds how long other instances of the server keep resolving a tenant after it was deleted.
+const tenantCacheTTL = 30 * time.Second
+
+// validTenantSlug keeps slugs usable as a DNS label, a schema name suffix, a file name suffix and a token prefix.
+var validTenantSlug = regexp.MustCompile(`^[a-z][a-z0-9]{1,29}$`)
+
+// IsValidTenantSlug reports whether slug may name a tenant.
+func IsValidTenantSlug(slug string) bool {
+	return validTenantSlug.MatchString(slug)
+}
+
+type tenantContextKey struct{}
+
+// WithTenant returns a copy of ctx whose database connections are scoped to tenant.
+func WithTenant(ctx context.Context, tenant Tenant) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant of the request of ctx, which is the default tenant outside of a request.
+func TenantFromContext(ctx context.Context) Tenant {
+	tenant, _ := ctx.Value(tenantContextKey{}).(Tenant)
+	return tenant
+}
+
+// TenantToken returns token as handed to the clients of the tenant of ctx. Tokens of tenants other than the
+// default one are prefixed with the slug of their tenant, as in "acme.smk_1_...", so that requests made to a
+// shared host still reach the right tenant.
+func TenantToken(ctx context.Context, token string) string {
+	if slug := TenantFromContext(ctx).Slug; slug != "" {
+		return slug + "." + token
+	}
+	return token
+}
+
+// splitTenantToken returns the slug of the tenant that issued token, and token without it.
+func splitTenantToken(token string) (string, string) {
+	if slug, rest, ok := strings.Cut(token, "."); ok {
+		return slug, rest
+	}
+	return "", token
+}
+
+// tokenTenant returns the slug of the tenant that issued the credentials of r, and whether r carries any.
+func tokenTenant(r *http.Request) (string, bool) {
+	token := ""
+	if scheme, value, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && (strings.EqualFold(scheme, APIKeyScheme) || strings.EqualFold(scheme, SessionScheme)) {
+		token = strings.TrimSpace(value)
+	} else if cookie, err := r.Cookie(SessionCookieName); err == nil {
+		token = cookie.Value
+	}
+	if token == "" {
+		return "", false
+	}
+	slug, _ := splitTenantToken(token)
+	return slug, true
+}
+
+// Tenancy scopes every request to the tenant of its host. Credentials issued by another tenant are rejected
+// with 401, except on the hosts of the default tenant, where the tenant named by the credentials is used.
+// Requests whose context already has a tenant, such as the operations of a batch, and requests to the probes
+// and metrics are passed on unchanged.
+func Tenancy(config TenancyConfig) func(http.Handler) http.Handler {
+	return func(inner http.Handler) http.Handler {
+		if !config.Enabled {
+			return inner
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if _, ok := ctx.Value(tenantContextKey{}).(Tenant); ok {
+				inner.ServeHTTP(w, r)
+				return
+			}
+			if route := mux.CurrentRoute(r); route != nil && tenantlessRoutes[route.GetName()] {
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			tenant, err := resolveTenant(config, requestHost(r))
+			if errors.Is(err, ErrUnknownTenant) {
+				DefaultErrorHandler(w, r, err, &ImplResponse{Code: http.StatusNotFound})
+				return
+			}
+			if err != nil {
+				LoggerFromContext(ctx).Errorf("Failed to resolve the tenant of %s: %v", r.Host, err)
+				DefaultErrorHandler(w, r, err, &ImplResponse{Code: http.StatusInternalServerError})
+				return
+			}
+			if slug, ok := tokenTenant(r); ok && slug != tenant.Slug {
+				claimed, found := Tenant{}, false
+				if tenant.Slug == "" {
+					claimed, found, err = findTenant(slug)
+					if err != nil {
+						DefaultErrorHandler(w, r, err, &ImplResponse{Code: http.StatusInternalServerError})
+						return
+					}
+				}
+				if !found {
+					LoggerFromContext(ctx).Warnf("Rejected credentials of tenant %q on %s", slug, r.Host)
+					DefaultErrorHandler(w, r, ErrTenantMismatch, &ImplResponse{Code: http.StatusUnauthorized})
+					return
+				}
+				tenant = claimed
+			}
+			inner.ServeHTTP(w, r.WithContext(WithTenant(ctx, tenant)))
+		})
+	}
+}
+
+// requestHost returns the host name r was sent to, without its port.
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// resolveTenant returns the tenant served on host, matching the custom hosts of tenants first, then the
+// subdomains of the base domain and finally the hosts of the default tenant.
+func resolveTenant(config TenancyConfig, host string) (Tenant, error) {
+	tenants, err := activeTenants()
+	if err != nil {
+		return Tenant{}, err
+	}
+	for _, tenant := range tenants {
+		for _, tenantHost := range tenant.Hosts {
+			if strings.EqualFold(tenantHost, host) {
+				return tenant, nil
+			}
+		}
+	}
+
+	baseDomain := strings.ToLower(config.BaseDomain)
+	if baseDomain != "" {
+		if host == baseDomain {
+			return Tenant{}, nil
+		}
+		if subdomain, ok := strings.CutSuffix(host, "."+baseDomain); ok {
+			for _, tenant := range tenants {
+				if tenant.Slug == subdomain {
+					return tenant, nil
+				}
+			}
+			return Tenant{}, ErrUnknownTenant
+		}
+	}
+
+	if len(config.DefaultHosts) == 0 {
+		return Tenant{}, nil
+	}
+	for _, defaultHost := range config.DefaultHosts {
+		if strings.EqualFold(defaultHost, host) {
+			return Tenant{}, nil
+		}
+	}
+	return Tenant{}, ErrUnknownTenant
+}
+
+// findTenant returns the active tenant named slug, if any.
+func findTenant(slug string) (Tenant, bool, error) {
+	tenants, err := activeTenants()
+	if err != nil {
+		return Tenant{}, false, err
+	}
+	for _, tenant := range tenants {
+		if tenant.Slug == slug {
+			return tenant, true, nil
+		}
+	}
+	return Tenant{}, false, nil
+}
+
+// tenantCache keeps the active tenants in memory, as every request needs them to be resolved.
+var tenantCache struct {
+	sync.Mutex
+	tenants  []Tenant
+	loadedAt time.Time
+}
+
+func activeTenants() ([]Tenant, error) {
+	tenantCache.Lock()
+	defer tenantCache.Unlock()
+
+	if tenantCache.tenants != nil && time.Since(tenantCache.loadedAt) < tenantCacheTTL {
+		return tenantCache.tenants, nil
+	}
+	tenants, err := ListTenants(context.Background(), false)
+	if err != nil {
+		return nil, err
+	}
+	if tenants == nil {
+		tenants = []Tenant{}
+	}
+	tenantCache.tenants = tenants
+	tenantCache.loadedAt = time.Now()
+	return tenants, nil
+}
+
+func invalidateTenantCache() {
+	tenantCache.Lock()
+	defer tenantCache.Unlock()
+	tenantCache.tenants = nil
+}
+
+// ListTenants returns the tenants of the registry, with the deleted ones when includeDeleted is set.
+// The default tenant is not part of the registry.
+func ListTenants(ctx context.Context, includeDeleted bool) ([]Tenant, error) {
+	dao, err := newTenantDatabaseConnection(DatabaseConfigPath, "read", "")
+	if err != nil {
+		return nil, err
+	}
+	defer dao.Close()
+	dao.ctx = context.WithoutCancel(ctx)
+
+	return dao.listTenants(includeDeleted)
+}
+
+func (dao *DatabaseConnection) listTenants(includeDeleted bool) ([]Tenant, error) {
+	query := fmt.Sprintf("SELECT tenant_id, slug, name, hosts, created_at, created_by, deleted_at FROM %s", dao.dialect.table("tenants"))
+	if !includeDeleted {
+		query += " WHERE deleted_at IS NULL"
+	}
+	rows, err := dao.executor().Query(query + " ORDER BY tenant_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []Tenant
+	for rows.Next() {
+		tenant, err := scanTenant(rows)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, tenant)
+	}
+	return tenants, rows.Err()
+}
+
+// GetTenant returns the tenant tenantId of the registry, or ErrTenantNotFound.
+func GetTenant(ctx context.Context, tenantId int32) (Tenant, error) {
+	dao, err := newTenantDatabaseConnection(DatabaseConfigPath, "read", "")
+	if err != nil {
+		return Tenant{}, err
+	}
+	defer dao.Close()
+	dao.ctx = context.WithoutCancel(ctx)
+
+	row := dao.executor().QueryRow(fmt.Sprintf("SELECT tenant_id, slug, name, hosts, created_at, created_by, deleted_at FROM %s WHERE tenant_id=%s",
+		dao.dialect.table("tenants"), dao.dialect.placeholder(1)), tenantId)
+	tenant, err := scanTenant(row)
+	if err == sql.ErrNoRows {
+		return Tenant{}, ErrTenantNotFound
+	}
+	return tenant, err
+}
+
+func scanTenant(row interface{ Scan(...interface{}) error }) (Tenant, error) {
+	var tenant Tenant
+	var hosts string
+	var deletedAt sql.NullTime
+	if err := row.Scan(&tenant.TenantId, &tenant.Slug, &tenant.Name, &hosts, &tenant.CreatedAt, &tenant.CreatedBy, &deletedAt); err != nil {
+		return Tenant{}, err
+	}
+	tenant.Hosts = strings.Fields(strings.ReplaceAll(hosts, ",", " "))
+	if tenant.Hosts == nil {
+		tenant.Hosts = []string{}
+	}
+	if deletedAt.Valid {
+		tenant.DeletedAt = &deletedAt.Time
+	}
+	return tenant, nil
+}
+
+// CreateTenant registers tenant and creates its tables. Slugs are never reused, even once their tenant has been
+// deleted, since the tables of a deleted tenant are kept. ErrTenantConflict is returned when the slug or a host
+// is already taken.
+func CreateTenant(ctx context.Context, tenant Tenant) (Tenant, error) {
+	tenants, err := ListTenants(ctx, true)
+	if err != nil {
+		return Tenant{}, err
+	}
+	for _, existing := range tenants {
+		if existing.Slug == tenant.Slug {
+			return Tenant{}, ErrTenantConflict
+		}
+		for _, host := range existing.Hosts {
+			for _, requested := range tenant.Hosts {
+				if existing.DeletedAt == nil && strings.EqualFold(host, requested) {
+					return Tenant{}, ErrTenantConflict
+				}
+			}
+		}
+	}
+
+	dao, err := newTenantDatabaseConnection(DatabaseConfigPath, "admin", "")
+	if err != nil {
+		return Tenant{}, err
+	}
+	defer dao.Close()
+	dao.ctx = context.WithoutCancel(ctx)
+
+	tx, err := dao.begin()
+	if err != nil {
+		return Tenant{}, err
+	}
+	defer tx.Rollback()
+
+	table := dao.dialect.table("tenants")
+	if err := tx.QueryRow("SELECT COALESCE(MAX(tenant_id), 0) + 1 FROM " + table).Scan(&tenant.TenantId); err != nil {
+		return Tenant{}, err
+	}
+	tenant.CreatedAt = time.Now().UTC()
+	tenant.CreatedBy = ActorFromContext(ctx)
+	tenant.DeletedAt = nil
+	if tenant.Hosts == nil {
+		tenant.Hosts = []string{}
+	}
+	for i, host := range tenant.Hosts {
+		tenant.Hosts[i] = strings.ToLower(host)
+	}
+	p := dao.dialect.placeholder
+	_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s (tenant_id, slug, name, hosts, created_at, created_by) VALUES (%s, %s, %s, %s, %s, %s)",
+		table, p(1), p(2), p(3), p(4), p(5), p(6)), tenant.TenantId, tenant.Slug, tenant.Name, strings.Join(tenant.Hosts, ","), tenant.CreatedAt, tenant.CreatedBy)
+	if err != nil {
+		return Tenant{}, err
+	}
+	// The tenant is only registered once its tables exist, so it never resolves to a half-created schema
+	if err := MigrateTenant(DatabaseConfigPath, tenant.Slug); err != nil {
+		return Tenant{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Tenant{}, err
+	}
+	invalidateTenantCache()
+	return tenant, nil
+}
+
+// DeleteTenant stops serving tenantId. Its tables are kept, so that its data can still be exported or restored
+// by an operator, but its hosts and tokens no longer resolve.
+func DeleteTenant(ctx context.Context, tenantId int32) (Tenant, error) {
+	tenant, err := GetTenant(ctx, tenantId)
+	if err != nil {
+		return Tenant{}, err
+	}
+	if tenant.DeletedAt != nil {
+		return Tenant{}, ErrTenantNotFound
+	}
+
+	dao, err := newTenantDatabaseConnection(DatabaseConfigPath, "admin", "")
+	if err != nil {
+		return Tenant{}, err
+	}
+	defer dao.Close()
+	dao.ctx = context.WithoutCancel(ctx)
+
+	now := time.Now().UTC()
+	p := dao.dialect.placeholder
+	result, err := dao.executor().Exec(fmt.Sprintf("UPDATE %s SET deleted_at=%s WHERE tenant_id=%s AND deleted_at IS NULL", dao.dialect.table("tenants"), p(1), p(2)), now, tenantId)
+	if err != nil {
+		return Tenant{}, err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return Tenant{}, err
+	} else if affected == 0 {
+		return Tenant{}, ErrTenantNotFound
+	}
+	invalidateTenantCache()
+	tenant.DeletedAt = &now
+	return tenant, nil
+}
+
+// ForEachTenant calls fn with a context of the default tenant, then of every active tenant, such as to run
+// the maintenance of the tables of every tenant. Every tenant is visited even when fn fails for one of them.
+func ForEachTenant(ctx context.Context, configPath string, fn func(ctx context.Context) error) error {
+	errs := []error{fn(WithTenant(ctx, Tenant{}))}
+
+	dao, err := newTenantDatabaseConnection(configPath, "read", "")
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	tenants, err := dao.listTenants(false)
+	dao.Close()
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	for _, tenant := range tenants {
+		if err := fn(WithTenant(ctx, tenant)); err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", tenant.Slug, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,261 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tenancyTestConfig serves tenants on subdomains of example.com, and the default tenant on example.com and
+// api.internal only.
+var tenancyTestConfig = TenancyConfig{Enabled: true, BaseDomain: "example.com", DefaultHosts: []string{"api.internal"}}
+
+// createTestTenants creates the tenants acme, served on acme.example.com, and globex, also served on
+// inventory.globex.test, and returns the contexts of their requests.
+func createTestTenants(t *testing.T) (acme context.Context, globex context.Context) {
+	t.Helper()
+	contexts := make([]context.Context, 2)
+	for i, tenant := range []Tenant{{Slug: "acme", Name: "Acme"}, {Slug: "globex", Name: "Globex", Hosts: []string{"inventory.globex.test"}}} {
+		created, err := CreateTenant(context.Background(), tenant)
+		if err != nil {
+			t.Fatalf("CreateTenant() error = %v", err)
+		}
+		contexts[i] = WithTenant(context.Background(), created)
+	}
+	return contexts[0], contexts[1]
+}
+
+// createTestAPIKey creates an API key with every scope in the tenant of ctx, and returns it as handed to clients.
+func createTestAPIKey(t *testing.T, ctx context.Context) string {
+	t.Helper()
+	secret, hash, err := NewAPIKeySecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dao, err := NewDatabaseConnectionContext(ctx, DatabaseConfigPath, "write")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyId, err := dao.InsertRowReturningID("api_keys", struct {
+		KeyId          int32
+		Name           string
+		ServiceAccount string
+		Scopes         string
+		KeyHash        string
+		CreatedAt      time.Time
+	}{Name: "test", ServiceAccount: "test", Scopes: strings.Join(Scopes, " "), KeyHash: hash, CreatedAt: time.Now().UTC()})
+	if err != nil {
+		t.Fatalf("InsertRowReturningID() error = %v", err)
+	}
+	return TenantToken(ctx, FormatAPIKey(int32(keyId), secret))
+}
+
+// retargetToken returns token with the tenant prefix of slug instead of its own.
+func retargetToken(token string, slug string) string {
+	_, rest := splitTenantToken(token)
+	return slug + "." + rest
+}
+
+func TestResolveTenant(t *testing.T) {
+	forEachTestDatabase(t, func(t *testing.T) {
+		createTestTenants(t)
+		tests := []struct {
+			host    string
+			want    string
+			wantErr error
+		}{
+			{host: "acme.example.com", want: "acme"},
+			{host: "globex.example.com", want: "globex"},
+			{host: "inventory.globex.test", want: "globex"},
+			{host: "example.com", want: ""},
+			{host: "api.internal", want: ""},
+			{host: "initech.example.com", wantErr: ErrUnknownTenant},
+			{host: "10.0.0.7", wantErr: ErrUnknownTenant},
+		}
+		for _, tt := range tests {
+			tenant, err := resolveTenant(tenancyTestConfig, tt.host)
+			if !errors.Is(err, tt.wantErr) || (err == nil && tenant.Slug != tt.want) {
+				t.Errorf("resolveTenant(%q) = %q, %v, want %q, %v", tt.host, tenant.Slug, err, tt.want, tt.wantErr)
+			}
+		}
+	})
+}
+
+func TestTenantIsolation(t *testing.T) {
+	forEachTestDatabase(t, func(t *testing.T) {
+		acme, globex := createTestTenants(t)
+		defaultTenant := context.Background()
+		tenants := []struct {
+			name string
+			ctx  context.Context
+		}{{name: "acme", ctx: acme}, {name: "globex", ctx: globex}, {name: "default", ctx: defaultTenant}}
+
+		userId := createTestUser(t, acme, "jdoe")
+		session, _, err := CreateSession(acme, userId, time.Hour, false)
+		if err != nil {
+			t.Fatalf("CreateSession() error = %v", err)
+		}
+		apiKey := createTestAPIKey(t, acme)
+		// The other tenants have a key with the same ID, but another secret
+		createTestAPIKey(t, globex)
+		createTestAPIKey(t, defaultTenant)
+		dao, err := NewDatabaseConnectionContext(acme, DatabaseConfigPath, "write")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, reserved, err := dao.reserveIdempotencyKey("key", UserSubject(userId), "fingerprint"); err != nil || !reserved {
+			t.Fatalf("reserveIdempotencyKey() = %v, %v", reserved, err)
+		}
+		if err := dao.completeIdempotencyKey("key", UserSubject(userId), http.StatusCreated, http.Header{}, []byte("created")); err != nil {
+			t.Fatalf("completeIdempotencyKey() error = %v", err)
+		}
+		dao.Close()
+
+		for _, tenant := range tenants {
+			visible := tenant.ctx == acme
+			t.Run(tenant.name, func(t *testing.T) {
+				dao, err := NewDatabaseConnectionContext(tenant.ctx, DatabaseConfigPath, "write")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer dao.Close()
+
+				var users int
+				if err := dao.executor().QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE username=%s", dao.dialect.table("users"), dao.dialect.placeholder(1)), "jdoe").
+					Scan(&users); err != nil {
+					t.Fatal(err)
+				}
+				if (users == 1) != visible {
+					t.Errorf("users named jdoe = %d, want visible %v", users, visible)
+				}
+
+				_, token := splitTenantToken(session)
+				if _, err := authenticateSession(tenant.ctx, token); (err == nil) != visible {
+					t.Errorf("authenticateSession() error = %v, want visible %v", err, visible)
+				}
+
+				_, key := splitTenantToken(apiKey)
+				r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(tenant.ctx)
+				if _, err := authenticateAPIKey(r, key); (err == nil) != visible {
+					t.Errorf("authenticateAPIKey() error = %v, want visible %v", err, visible)
+				}
+
+				// The key is only replayed in its tenant; elsewhere it is free to reserve
+				_, reserved, err := dao.reserveIdempotencyKey("key", UserSubject(userId), "fingerprint")
+				if err != nil {
+					t.Fatalf("reserveIdempotencyKey() error = %v", err)
+				}
+				if reserved == visible {
+					t.Errorf("reserveIdempotencyKey() reserved = %v, want visible %v", reserved, visible)
+				}
+			})
+		}
+	})
+}
+
+func TestTenancyRouting(t *testing.T) {
+	forEachTestDatabase(t, func(t *testing.T) {
+		acme, globex := createTestTenants(t)
+		acmeSession, _, err := CreateSession(acme, createTestUser(t, acme, "jdoe"), time.Hour, false)
+		if err != nil {
+			t.Fatalf("CreateSession() error = %v", err)
+		}
+		acmeKey := createTestAPIKey(t, acme)
+		globexKey := createTestAPIKey(t, globex)
+		_, bareAcmeKey := splitTenantToken(acmeKey)
+
+		ok := func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}
+		// The equipment route answers with the tenant and the principal of the request
+		router := NewRouter("/api", testRouter{
+			"LivezGet":  Route{Method: http.MethodGet, Pattern: "livez", HandlerFunc: ok, Public: true},
+			"ReadyzGet": Route{Method: http.MethodGet, Pattern: "readyz", HandlerFunc: ok, Public: true},
+			"ListEquipment": Route{Method: http.MethodGet, Pattern: "equipment", HandlerFunc: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, "%s %s", TenantFromContext(r.Context()).Slug, ActorFromContext(r.Context()))
+			}},
+		})
+		router.Use(Tenancy(tenancyTestConfig))
+		router.Use(APIKeyAuthentication())
+		router.Use(SessionAuthentication())
+
+		tests := []struct {
+			name          string
+			host          string
+			path          string
+			authorization string
+			want          int
+			wantTenant    string
+		}{
+			{name: "session on the host of its tenant", host: "acme.example.com", path: "/api/equipment",
+				authorization: "Bearer " + acmeSession, want: http.StatusOK, wantTenant: "acme"},
+			{name: "API key on a custom host of its tenant", host: "inventory.globex.test", path: "/api/equipment",
+				authorization: "ApiKey " + globexKey, want: http.StatusOK, wantTenant: "globex"},
+			{name: "session on a default host", host: "example.com", path: "/api/equipment",
+				authorization: "Bearer " + acmeSession, want: http.StatusOK, wantTenant: "acme"},
+			{name: "API key on a default host", host: "api.internal", path: "/api/equipment",
+				authorization: "ApiKey " + acmeKey, want: http.StatusOK, wantTenant: "acme"},
+			{name: "session on the host of another tenant", host: "globex.example.com", path: "/api/equipment",
+				authorization: "Bearer " + acmeSession, want: http.StatusUnauthorized},
+			{name: "API key on the host of another tenant", host: "acme.example.com", path: "/api/equipment",
+				authorization: "ApiKey " + globexKey, want: http.StatusUnauthorized},
+			{name: "session claiming another tenant", host: "globex.example.com", path: "/api/equipment",
+				authorization: "Bearer " + retargetToken(acmeSession, "globex"), want: http.StatusUnauthorized},
+			{name: "API key claiming another tenant on a default host", host: "example.com", path: "/api/equipment",
+				authorization: "ApiKey " + retargetToken(acmeKey, "globex"), want: http.StatusUnauthorized},
+			{name: "API key without its tenant", host: "example.com", path: "/api/equipment",
+				authorization: "ApiKey " + bareAcmeKey, want: http.StatusUnauthorized},
+			{name: "unknown tenant", host: "initech.example.com", path: "/api/equipment", want: http.StatusNotFound},
+			{name: "unknown host", host: "10.0.0.7", path: "/api/equipment", want: http.StatusNotFound},
+			{name: "liveness probe on any host", host: "10.0.0.7", path: "/api/livez", want: http.StatusOK},
+			{name: "readiness probe on any host", host: "10.0.0.7", path: "/api/readyz", want: http.StatusOK},
+			{name: "metrics are not served by the API", host: "example.com", path: "/metrics", want: http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r.Host = tt.host
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.want)
+				continue
+			}
+			if tt.wantTenant != "" && !strings.HasPrefix(w.Body.String(), tt.wantTenant+" ") {
+				t.Errorf("%s: served as %q, want tenant %s", tt.name, w.Body.String(), tt.wantTenant)
+			}
+		}
+	})
+}
@@ -0,0 +1,265 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// certificateCheckInterval is how often the certificate files are checked for rotation.
+const certificateCheckInterval = 5 * time.Second
+
+// TLSConfig holds the settings for serving over TLS. TLS is enabled when CertFile is set, and
+// client certificates are required when ClientCAFile is set.
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// MinVersion is "1.2" or "1.3". Defaults to "1.2".
+	MinVersion   string `yaml:"min_version"`
+	ClientCAFile string `yaml:"client_ca_file"`
+	// ClientAuth is "require" (the default) to reject clients without a certificate signed by the
+	// client CA, or "optional" to only verify the certificates clients choose to present.
+	ClientAuth string `yaml:"client_auth"`
+	// ServiceIdentities maps the subjects of client certificates to the principals they authenticate.
+	ServiceIdentities []ServiceIdentity `yaml:"service_identities"`
+}
+
+// ServiceIdentity authenticates clients presenting a certificate for Subject as the service Name.
+type ServiceIdentity struct {
+	// Subject is the distinguished name of the certificate, such as "CN=inventory-sync,O=Smidgen".
+	Subject string `yaml:"subject"`
+	Name    string `yaml:"name"`
+	Role    string `yaml:"role"`
+}
+
+// Enabled reports whether the server should be served over TLS.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// Validate checks the combination of settings without loading any files.
+func (c TLSConfig) Validate() error {
+	if !c.Enabled() {
+		if c.KeyFile != "" || c.ClientCAFile != "" {
+			return errors.New("tls.cert_file is required to serve over TLS")
+		}
+		return nil
+	}
+	if c.KeyFile == "" {
+		return errors.New("tls.key_file is required with tls.cert_file")
+	}
+	if _, err := tlsVersion(c.MinVersion); err != nil {
+		return err
+	}
+	switch c.ClientAuth {
+	case "", "require", "optional":
+	default:
+		return fmt.Errorf("invalid tls.client_auth %q, expected require or optional", c.ClientAuth)
+	}
+	if len(c.ServiceIdentities) > 0 && c.ClientCAFile == "" {
+		return errors.New("tls.client_ca_file is required to authenticate service identities")
+	}
+	for _, identity := range c.ServiceIdentities {
+		if identity.Subject == "" || identity.Name == "" {
+			return errors.New("every service identity requires a subject and a name")
+		}
+	}
+	return nil
+}
+
+func tlsVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("invalid tls.min_version %q, expected 1.2 or 1.3", version)
+	}
+}
+
+// NewServerTLSConfig loads the certificates of config into a tls.Config for the server. Rotated certificate,
+// key and client CA files are picked up by new connections without a restart.
+func NewServerTLSConfig(config TLSConfig) (*tls.Config, error) {
+	minVersion, err := tlsVersion(config.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	reloader := &certificateReloader{certFile: config.CertFile, keyFile: config.KeyFile, clientCAFile: config.ClientCAFile}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+
+	base := &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: reloader.getCertificate,
+	}
+	if config.ClientCAFile != "" {
+		base.ClientAuth = tls.RequireAndVerifyClientCert
+		if config.ClientAuth == "optional" {
+			base.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+		base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			perConnection := base.Clone()
+			perConnection.GetConfigForClient = nil
+			perConnection.ClientCAs = reloader.getClientCAs()
+			return perConnection, nil
+		}
+	}
+	return base, nil
+}
+
+// certificateReloader keeps the server certificate and client CAs in memory, reloading them when their
+// files change. A rotation that fails to load keeps the previous certificates in use.
+type certificateReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+	modTime     time.Time
+	checked     time.Time
+}
+
+func (r *certificateReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.checkRotation()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.certificate, nil
+}
+
+func (r *certificateReloader) getClientCAs() *x509.CertPool {
+	r.checkRotation()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.clientCAs
+}
+
+func (r *certificateReloader) checkRotation() {
+	r.mu.RLock()
+	due := time.Since(r.checked) >= certificateCheckInterval
+	r.mu.RUnlock()
+	if !due {
+		return
+	}
+
+	r.mu.Lock()
+	r.checked = time.Now()
+	modTime := r.modTime
+	r.mu.Unlock()
+
+	if latest, err := r.latestModTime(); err == nil && latest.After(modTime) {
+		if err := r.reload(); err != nil {
+			log.Errorf("Failed to reload rotated TLS certificates: %v", err)
+			return
+		}
+		log.Info("Reloaded rotated TLS certificates.")
+	}
+}
+
+func (r *certificateReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile, r.clientCAFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *certificateReloader) reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %v", err)
+	}
+	var clientCAs *x509.CertPool
+	if r.clientCAFile != "" {
+		pem, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA: %v", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in client CA %s", r.clientCAFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.certificate = &certificate
+	r.clientCAs = clientCAs
+	r.modTime = modTime
+	r.checked = time.Now()
+	return nil
+}
+
+// ClientCertificateAuthentication authenticates requests made with a verified client certificate as the
+// service identity mapped to its subject. Certificates that are not mapped are rejected with 403.
+// Requests without a client certificate are passed on unchanged.
+func ClientCertificateAuthentication(identities []ServiceIdentity) func(http.Handler) http.Handler {
+	bySubject := make(map[string]ServiceIdentity, len(identities))
+	for _, identity := range identities {
+		bySubject[identity.Subject] = identity
+	}
+
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+				inner.ServeHTTP(w, r)
+				return
+			}
+			subject := r.TLS.VerifiedChains[0][0].Subject.String()
+			identity, ok := bySubject[subject]
+			if !ok {
+				log.Warnf("Rejected client certificate for unknown subject %s", subject)
+				DefaultErrorHandler(w, r, ErrForbidden, &ImplResponse{Code: http.StatusForbidden})
+				return
+			}
+			principal := Principal{Subject: identity.Name, Role: identity.Role}
+			inner.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
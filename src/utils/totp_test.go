@@ -0,0 +1,97 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"testing"
+	"time"
+)
+
+// totpTestSecret is the secret of the SHA-1 test vectors of RFC 6238, "12345678901234567890" in base32.
+const totpTestSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// totpTestVectors are the SHA-1 test vectors of RFC 6238, appendix B, cut to the 6 digits authenticator apps show.
+var totpTestVectors = []struct {
+	unix int64
+	code string
+}{
+	{unix: 59, code: "287082"},
+	{unix: 1111111109, code: "081804"},
+	{unix: 1111111111, code: "050471"},
+	{unix: 1234567890, code: "005924"},
+	{unix: 2000000000, code: "279037"},
+	{unix: 20000000000, code: "353130"},
+}
+
+func TestTOTPCode(t *testing.T) {
+	for _, tt := range totpTestVectors {
+		if got := totpCode([]byte("12345678901234567890"), tt.unix/totpPeriod); got != tt.code {
+			t.Errorf("totpCode() at %d = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	for _, tt := range totpTestVectors {
+		now := time.Unix(tt.unix, 0)
+		step, ok := validateTOTP(totpTestSecret, tt.code, now, 0)
+		if !ok || step != tt.unix/totpPeriod {
+			t.Errorf("validateTOTP() at %d = %d, %v, want %d, true", tt.unix, step, ok, tt.unix/totpPeriod)
+		}
+	}
+
+	// The code of 1111111109 is for step 37037036
+	const code = "081804"
+	const step = 37037036
+	tests := []struct {
+		name         string
+		secret       string
+		code         string
+		now          int64
+		lastUsedStep int64
+		want         bool
+	}{
+		{name: "current step", secret: totpTestSecret, code: code, now: step * totpPeriod, want: true},
+		{name: "lowercase secret", secret: "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", code: code, now: step * totpPeriod, want: true},
+		{name: "one step late", secret: totpTestSecret, code: code, now: (step + 1) * totpPeriod, want: true},
+		{name: "one step early", secret: totpTestSecret, code: code, now: (step - 1) * totpPeriod, want: true},
+		{name: "two steps late", secret: totpTestSecret, code: code, now: (step + 2) * totpPeriod, want: false},
+		{name: "two steps early", secret: totpTestSecret, code: code, now: (step - 2) * totpPeriod, want: false},
+		{name: "already used", secret: totpTestSecret, code: code, now: step * totpPeriod, lastUsedStep: step, want: false},
+		{name: "later step used", secret: totpTestSecret, code: code, now: step * totpPeriod, lastUsedStep: step + 1, want: false},
+		{name: "wrong code", secret: totpTestSecret, code: "081805", now: step * totpPeriod, want: false},
+		{name: "8 digits", secret: totpTestSecret, code: "07081804", now: step * totpPeriod, want: false},
+		{name: "invalid secret", secret: "not base32!", code: code, now: step * totpPeriod, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := validateTOTP(tt.secret, tt.code, time.Unix(tt.now, 0), tt.lastUsedStep)
+			if ok != tt.want || (ok && got != step) {
+				t.Errorf("validateTOTP() = %d, %v, want %v", got, ok, tt.want)
+			}
+		})
+	}
+}
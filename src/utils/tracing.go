@@ -0,0 +1,236 @@
+/*
+ * Smidgen
+ *
+ * API for interacting with Smidgen.
+ *
+ *   Smidgen aims to simplify and automate common tasks that logisticians
+ *   conduct on a daily basis so they can focus on the effective distribution
+ *   of materiel, as well as maintain an accurate record keeping book of
+ *   receiving, issuance, audits, surpluses, amongst other logistical tasks.
+ *   Copyright (C) 2024  Jose Hernandez
+ *
+ *   This program is free software: you can redistribute it and/or modify
+ *   it under the terms of the GNU General Public License as published by
+ *   the Free Software Foundation, either version 3 of the License, or
+ *   (at your option) any later version.
+ *
+ *   This program is distributed in the hope that it will be useful,
+ *   but WITHOUT ANY WARRANTY; without even the implied warranty of
+ *   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ *   GNU General Public License for more details.
+ *
+ *   You should have received a copy of the GNU General Public License
+ *   along with this program.  If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package smidgen
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates every span of the server. It follows the provider installed by InitTracing.
+var tracer = otel.Tracer("smidgen-backend")
+
+// TracingConfig selects where the spans of the server are exported. Tracing is disabled when Exporter
+// is empty or "none".
+type TracingConfig struct {
+	// Exporter is "otlp" to send spans to a collector over OTLP/HTTP, "stdout" to print them, or "file"
+	// to append them to File, which works without any collector.
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the host:port of the OTLP collector. Defaults to OTEL_EXPORTER_OTLP_ENDPOINT, or localhost:4318.
+	Endpoint string `yaml:"endpoint"`
+	// Insecure sends spans to the collector over plain HTTP.
+	Insecure    bool   `yaml:"insecure"`
+	File        string `yaml:"file"`
+	ServiceName string `yaml:"service_name"`
+}
+
+// Enabled reports whether spans should be exported.
+func (c TracingConfig) Enabled() bool {
+	return c.Exporter != "" && c.Exporter != "none"
+}
+
+// Validate checks the combination of settings without connecting to any collector.
+func (c TracingConfig) Validate() error {
+	switch c.Exporter {
+	case "", "none", "otlp", "stdout":
+	case "file":
+		if c.File == "" {
+			return errors.New("tracing.file is required with the file exporter")
+		}
+	default:
+		return fmt.Errorf("invalid tracing.exporter %q, expected none, otlp, stdout or file", c.Exporter)
+	}
+	return nil
+}
+
+// InitTracing installs the exporter selected by config and the W3C trace context propagator.
+// The returned function flushes the remaining spans and must be called before the server exits.
+func InitTracing(config TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !config.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+	var err error
+	switch config.Exporter {
+	case "otlp":
+		var options []otlptracehttp.Option
+		if config.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(config.Endpoint))
+		}
+		if config.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), options...)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "file":
+		file, err = os.OpenFile(config.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0640)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open trace file: %v", err)
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	default:
+		return nil, fmt.Errorf("invalid tracing.exporter %q", config.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %v", config.Exporter, err)
+	}
+
+	serviceName := config.ServiceName
+	if serviceName == "" {
+		serviceName = "smidgen-backend"
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(BuildVersion()),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			err = errors.Join(err, file.Close())
+		}
+		return err
+	}, nil
+}
+
+// StartSpan starts a span named name as a child of the span in ctx. The caller must end the span.
+func StartSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// Tracing continues the trace of the incoming request, if any, in a server span named after the route.
+func Tracing(inner http.Handler, name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			))
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		inner.ServeHTTP(recorder, r.WithContext(ctx))
+
+		if recorder.statusCode == 0 {
+			recorder.statusCode = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(recorder.statusCode))
+		if recorder.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.statusCode))
+		}
+	})
+}
+
+// tracedExecutor runs the statements of a connection in a span each, as children of the span in ctx.
+type tracedExecutor struct {
+	ctx     context.Context
+	exec    tracedTarget
+	dialect databaseDialect
+}
+
+// tracedTarget is the part of *sql.DB and *sql.Tx the tracedExecutor wraps.
+type tracedTarget interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (e tracedExecutor) Exec(query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := e.start(query)
+	defer span.End()
+	result, err := e.exec.ExecContext(ctx, query, args...)
+	recordSpanError(span, err)
+	return result, err
+}
+
+func (e tracedExecutor) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := e.start(query)
+	defer span.End()
+	rows, err := e.exec.QueryContext(ctx, query, args...)
+	recordSpanError(span, err)
+	return rows, err
+}
+
+func (e tracedExecutor) QueryRow(query string, args ...interface{}) *sql.Row {
+	ctx, span := e.start(query)
+	defer span.End()
+	row := e.exec.QueryRowContext(ctx, query, args...)
+	if err := row.Err(); err != sql.ErrNoRows {
+		recordSpanError(span, err)
+	}
+	return row
+}
+
+func (e tracedExecutor) start(query string) (context.Context, trace.Span) {
+	operation, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	return tracer.Start(e.ctx, strings.ToUpper(operation),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			e.dialect.system(),
+			semconv.DBQueryText(query),
+		))
+}
+
+// system identifies the database in spans, using the names of the semantic conventions.
+func (d databaseDialect) system() attribute.KeyValue {
+	if d.driver == DriverSQLite {
+		return semconv.DBSystemSqlite
+	}
+	return semconv.DBSystemPostgreSQL
+}
+
+func recordSpanError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}